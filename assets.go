@@ -0,0 +1,38 @@
+// Package bamos embeds the templates and static assets of the web application, so
+// a deployed binary runs without the source tree.
+package bamos
+
+import (
+	"embed"
+	"io/fs"
+)
+
+var (
+	//go:embed templates/*.tmpl
+	templates embed.FS
+
+	//go:embed static
+	static embed.FS
+
+	templatesDir = sub(templates, "templates")
+	staticDir    = sub(static, "static")
+)
+
+// Templates returns the embedded templates directory.
+func Templates() fs.FS {
+	return templatesDir
+}
+
+// Static returns the embedded static assets directory, served under /static/.
+func Static() fs.FS {
+	return staticDir
+}
+
+// sub returns the embedded directory dir, which always exists.
+func sub(fsys fs.FS, dir string) fs.FS {
+	s, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
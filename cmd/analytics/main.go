@@ -0,0 +1,117 @@
+// Command analytics computes the headway and on-time performance metrics of
+// every line from the recorded realtime feeds, writing the report served by
+// the /analytics/lines/{route_id} pages.
+//
+// It reads the history store configured for the web application, accepting the
+// same configuration file, environment variables and flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/mayloo89/bamos/internal/analytics"
+	"github.com/mayloo89/bamos/internal/config"
+	"github.com/mayloo89/bamos/internal/history"
+	"github.com/mayloo89/bamos/internal/logging"
+	"github.com/mayloo89/bamos/internal/secrets"
+)
+
+func init() {
+	// Load .env file if present (for local development)
+	_ = godotenv.Load()
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("analytics", flag.ContinueOnError)
+	from := flags.String("from", "", "start of the analyzed range, RFC3339 (default: 7 days before -to)")
+	to := flags.String("to", "", "end of the analyzed range, RFC3339 (default: now)")
+	out := flags.String("out", "", "path of the JSON report read by the web application (default: data.analytics_file)")
+	csvOut := flags.String("csv", "", "also write the metrics of every line as CSV to this path")
+
+	cfg, err := config.Load(flags, args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if err := cfg.LoadSecrets(secrets.Chain{secrets.Env(os.Getenv), secrets.Dir(cfg.SecretsDir)}); err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = cfg.Data.AnalyticsFile
+	}
+
+	logger, _, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	end := time.Now()
+	if *to != "" {
+		t, err := time.Parse(time.RFC3339, *to)
+		if err != nil {
+			return fmt.Errorf("invalid -to value %q, must be RFC3339", *to)
+		}
+		end = t
+	}
+	start := end.AddDate(0, 0, -7)
+	if *from != "" {
+		t, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			return fmt.Errorf("invalid -from value %q, must be RFC3339", *from)
+		}
+		start = t
+	}
+
+	store, closeStore, err := history.OpenStore(cfg.History.Store, cfg.History.Dir, cfg.History.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+	if store == nil {
+		return fmt.Errorf("history.store must be set to read the recorded feeds")
+	}
+	report, err := analytics.Compute(ctx, store, start, end)
+	if err != nil {
+		return err
+	}
+
+	if err := analytics.WriteReport(*out, report); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	logger.Info("analytics written", "metrics", len(report.Metrics), "path", *out)
+
+	if *csvOut != "" {
+		f, err := os.Create(*csvOut)
+		if err != nil {
+			return err
+		}
+		err = analytics.WriteCSV(f, report.Metrics)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("error writing csv: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/analytics"
+	"github.com/mayloo89/bamos/internal/history"
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+func Test_run(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	dir := t.TempDir()
+	t.Setenv("HISTORY_STORE", "file")
+	t.Setenv("HISTORY_DIR", filepath.Join(dir, "history"))
+
+	store, err := history.NewFileStore(filepath.Join(dir, "history"))
+	required.NoError(err)
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, analytics.Location)
+	required.NoError(store.Save(context.Background(), history.Record{
+		Feed:      services.FeedTripUpdates,
+		FetchedAt: at,
+		Arrivals:  []realtime.Arrival{{TripID: "trip-1", RouteID: "1426", StopID: "stop-1", Time: at}},
+	}))
+
+	out := filepath.Join(dir, "analytics.json")
+	csvOut := filepath.Join(dir, "analytics.csv")
+	err = run(context.Background(), []string{"-from", "2026-10-19T00:00:00-03:00", "-to", "2026-10-20T00:00:00-03:00", "-out", out, "-csv", csvOut})
+	required.NoError(err)
+
+	report, err := analytics.ReadReport(out)
+	required.NoError(err)
+	required.Len(report.Line("1426"), 1)
+	assert.Equal(1, report.Line("1426")[0].Arrivals)
+
+	csv, err := os.ReadFile(csvOut)
+	required.NoError(err)
+	assert.True(strings.HasPrefix(string(csv), "route_id,bucket"))
+}
+
+func Test_run_Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("HISTORY_STORE", "")
+	assert.Error(run(context.Background(), nil), "a history store is required")
+	assert.Error(run(context.Background(), []string{"-from", "yesterday"}))
+}
@@ -1,86 +1,550 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/joho/godotenv"
 
+	"github.com/mayloo89/bamos/internal/accounts"
+	"github.com/mayloo89/bamos/internal/analytics"
 	"github.com/mayloo89/bamos/internal/config"
+	"github.com/mayloo89/bamos/internal/driver"
+	"github.com/mayloo89/bamos/internal/geo"
 	"github.com/mayloo89/bamos/internal/handler"
+	"github.com/mayloo89/bamos/internal/health"
 	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/history"
+	"github.com/mayloo89/bamos/internal/hub"
+	"github.com/mayloo89/bamos/internal/logging"
+	"github.com/mayloo89/bamos/internal/maps"
+	"github.com/mayloo89/bamos/internal/notify"
+	"github.com/mayloo89/bamos/internal/parking"
+	"github.com/mayloo89/bamos/internal/ratelimit"
+	"github.com/mayloo89/bamos/internal/realtime"
 	"github.com/mayloo89/bamos/internal/render"
+	"github.com/mayloo89/bamos/internal/secrets"
 	"github.com/mayloo89/bamos/internal/services"
+	"github.com/mayloo89/bamos/internal/sessionstore"
+	"github.com/mayloo89/bamos/internal/tracing"
+	"github.com/mayloo89/bamos/internal/webhooks"
 	"github.com/mayloo89/bamos/utils"
 )
 
-const portNumber = ":8080"
-
 var app config.AppConfig
 var session *scs.SessionManager
 
+// exportTimeout bounds every export of spans to the collector.
+const exportTimeout = 10 * time.Second
+
 func init() {
 	// Load .env file if present (for local development)
 	_ = godotenv.Load()
 }
 
 func main() {
-	err := run()
+	cfg, err := config.Load(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	if err := cfg.LoadSecrets(secrets.Chain{secrets.Env(os.Getenv), secrets.Dir(cfg.SecretsDir)}); err != nil {
+		log.Fatalf("invalid secrets:\n%v", err)
+	}
+	if err := checkCredentials(cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	err = run(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	fmt.Printf("starting application at port %s \n", portNumber)
-	repo := handler.NewRepo(&app, services.NewAPIClient())
+	// stop on SIGINT or SIGTERM, letting in-flight requests complete
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := start(ctx, cfg); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("application stopped")
+}
+
+// start runs the application until ctx is done, then shuts it down gracefully.
+func start(ctx context.Context, cfg *config.Settings) error {
+	stopTracing, err := startTracing(cfg)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	// keep the sessions across restarts and instances unless they are in memory
+	sessionStore, closeSessions, err := sessionstore.Open(cfg.Session.Store, cfg.Session.Dir, cfg.SessionDatabaseURL(), app.Logger)
+	if err != nil {
+		return err
+	}
+	defer closeSessions()
+	if sessionStore != nil {
+		session.Store = sessionStore
+	}
+
+	accountsDB, closeAccountsDB, err := openAccountsDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeAccountsDB()
+
+	accountStore, err := accounts.Open(cfg.Accounts.Store, accountsDB)
+	if err != nil {
+		return err
+	}
+
+	spots, err := parking.Open(cfg.Accounts.Store, accountsDB)
+	if err != nil {
+		return err
+	}
+
+	subscriptions, err := webhooks.Open(cfg.Accounts.Store, accountsDB)
+	if err != nil {
+		return err
+	}
+
+	apiClient := services.NewAPIClient(cfg.API.ClientID, cfg.API.ClientSecret, cfg.API.Timeout)
+	apiClient.BaseURL = cfg.API.BaseURL
+
+	store, closeStore, err := history.OpenStore(cfg.History.Store, cfg.History.Dir, cfg.History.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	replayer := historyReplayer(cfg, store)
+
+	var fetcher realtime.FeedFetcher = apiClient
+	if store != nil && replayer == nil {
+		fetcher = history.NewRecorder(apiClient, store, app.Logger)
+	}
+
+	// poll the realtime feeds in the background so page views never hit the upstream API
+	poller := realtime.NewPoller(fetcher, app.Logger, realtimeFeeds(cfg)...)
+	if replayer != nil {
+		// in replay mode the recorded feeds go through the poller instead of the upstream API
+		replayer.Target = poller
+		replayCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			if err := replayer.Run(replayCtx); err != nil && !errors.Is(err, context.Canceled) {
+				app.Logger.Error("error replaying history", "error", err)
+			}
+			app.Logger.Info("history replay finished")
+		}()
+	} else {
+		poller.Start(context.Background())
+	}
+	defer poller.Stop()
+
+	// the parking rules of a location are kept for api.parking_cache_ttl
+	repo := handler.NewRepo(&app, services.NewCachedClient(apiClient, cfg.API.ParkingCacheTTL))
+	repo.ParkingLimiter = ratelimit.New(cfg.API.ParkingRate)
+	// the rate limits tell the clients apart behind the load balancers
+	repo.TrustedProxies, err = cfg.TrustedProxies()
+	if err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	repo.Realtime = poller
+
+	// fan out realtime updates to WebSocket clients until the poller stops
+	realtimeHub := hub.New(poller)
+	go realtimeHub.Run()
+	repo.Hub = realtimeHub
+
+	// analytics are computed by the analytics command, the report is reloaded when it changes
+	repo.Analytics = &analytics.FileReports{Path: cfg.Data.AnalyticsFile}
+
+	repo.Health = readinessChecks(poller, map[string]any{
+		"database": store,
+		"accounts": accountStore,
+		"parking":  spots,
+		"webhooks": subscriptions,
+	})
+	repo.Accounts = accounts.New(accountStore)
+	repo.Parking = spots
+	repo.Webhooks = subscriptions
+
+	// addresses of the parking searches without one, from the streets of the city
+	repo.Streets, err = streetsGeocoder(cfg.Data.StreetsFile, app.Logger)
+	if err != nil {
+		return err
+	}
+	repo.Geocoder, err = mapGeocoder(cfg)
+	if err != nil {
+		return err
+	}
+	repo.GeocodeLimiter = ratelimit.New(cfg.Map.GeocodeRate)
+
+	// warn the users before parking becomes forbidden where they left the car
+	reminders := parking.NewReminders(spots, accountStore, reminderNotifier(cfg, app.Logger), app.Logger)
+	reminders.Lead = cfg.Reminders.Lead
+	reminders.Locale = app.Locale
+	workers, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go reminders.Run(workers, cfg.Reminders.Interval)
+
+	// post the new alerts and large delays of the realtime feeds to the subscribed webhooks
+	sender := webhooks.NewSender(subscriptions, cfg.Webhooks.Timeout)
+	sender.Attempts = cfg.Webhooks.Attempts
+	sender.Backoff = cfg.Webhooks.Backoff
+	dispatcher := webhooks.NewDispatcher(subscriptions, sender, repo.RouteIDs, app.Logger)
+	dispatcher.DelayThreshold = cfg.Webhooks.DelayThreshold
+	go dispatcher.Run(workers, poller)
+
 	srv := &http.Server{
-		Addr:    portNumber,
-		Handler: routes(&app, repo),
+		Addr:              cfg.Addr(),
+		Handler:           routes(&app, repo),
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
 	}
+	// stopping the poller ends the event streams and, through the hub, the WebSocket
+	// connections, which would otherwise hold the shutdown until its deadline
+	srv.RegisterOnShutdown(poller.Stop)
 
-	err = srv.ListenAndServe()
+	ln, err := net.Listen("tcp", srv.Addr)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	listeners := []listener{{name: "application", srv: srv, ln: ln}}
+
+	// the admin endpoints get their own listener, bound to the loopback interface
+	// by default and never exposed through the load balancer
+	if cfg.AdminAddr != "" {
+		adminSrv := &http.Server{
+			Addr:              cfg.AdminAddr,
+			Handler:           adminRoutes(&app),
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+			ReadTimeout:       cfg.Server.ReadTimeout,
+			WriteTimeout:      cfg.Server.WriteTimeout,
+			IdleTimeout:       cfg.Server.IdleTimeout,
+		}
+		adminLn, err := net.Listen("tcp", adminSrv.Addr)
+		if err != nil {
+			_ = ln.Close()
+			return err
+		}
+		listeners = append(listeners, listener{name: "admin", srv: adminSrv, ln: adminLn})
+		app.Logger.Info("starting admin server", "addr", adminLn.Addr().String())
 	}
+
+	app.Logger.Info("starting application", "port", cfg.Port, "env", cfg.Env)
+	return serveAll(ctx, cfg.Server.ShutdownTimeout, listeners...)
+}
+
+// listener is a server and the listener it accepts connections on.
+type listener struct {
+	name string
+	srv  *http.Server
+	ln   net.Listener
 }
 
-func run() error {
-	// change this when in production
-	app.InProduction = false
+// serveAll serves every listener until ctx is done or one of them fails, then
+// shuts them all down as serve does and waits for them, returning their errors.
+func serveAll(ctx context.Context, timeout time.Duration, listeners ...listener) error {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func() {
+			err := serve(ctx, l.srv, l.ln, timeout)
+			if err != nil {
+				err = fmt.Errorf("%s server: %w", l.name, err)
+			}
+			// a failing server stops the others
+			stop()
+			errs <- err
+		}()
+	}
+
+	var all []error
+	for range listeners {
+		all = append(all, <-errs)
+	}
+	return errors.Join(all...)
+}
 
-	// set up the loggers
-	app.InfoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	app.ErrorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+// serve accepts connections on ln until ctx is done, then shuts the server down,
+// giving in-flight requests up to timeout to complete before closing them.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, waiting for in-flight requests", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		_ = srv.Close()
+		return fmt.Errorf("error shutting down the server: %w", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func run(cfg *config.Settings) error {
+	app.InProduction = cfg.InProduction()
+	app.Locale = cfg.DefaultLocale()
+
+	// set up the logger, its level can be changed while running
+	logger, level, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		return err
+	}
+	app.Logger, app.LogLevel = logger, level
+	// the standard log package and the packages without a logger write through it too
+	slog.SetDefault(app.Logger)
+
+	// the map of the pages, Google Maps needs a key
+	app.Map, err = maps.NewProvider(cfg.MapProvider(), cfg.API.GoogleMapsAPIKey, cfg.Map.TileURL, cfg.Map.Attribution)
+	if err != nil {
+		return fmt.Errorf("invalid map: %w", err)
+	}
 
 	session = scs.New()
-	session.Lifetime = 24 * time.Hour
+	session.Lifetime = cfg.Session.Lifetime
 	session.Cookie.Persist = true
 	session.Cookie.SameSite = http.SameSiteLaxMode
 	session.Cookie.Secure = app.InProduction
 	app.Session = session
 
+	// templates and static assets are embedded, directories on disk override them in development
+	app.Templates, app.Static = nil, nil
+	if cfg.Templates.Dir != "" {
+		app.Templates = os.DirFS(cfg.Templates.Dir)
+	}
+	if cfg.Static.Dir != "" {
+		if _, err := os.Stat(cfg.Static.Dir); err != nil {
+			return fmt.Errorf("invalid static assets directory: %w", err)
+		}
+		app.Static = os.DirFS(cfg.Static.Dir)
+	}
+	render.NewTemplates(&app)
+
 	tc, err := render.CreateTemplateCache()
-	if err != nil {
-		log.Fatal("can not create template cache: " + err.Error())
+	switch {
+	case err != nil && cfg.Templates.Cache:
+		return fmt.Errorf("can not create template cache: %w", err)
+	case err != nil:
+		// without the cache pages are parsed on every change, the broken ones answer with an error page
+		app.Logger.Warn("templates can not be parsed", "error", err)
 	}
 
 	app.TemplateCache = tc
-	app.UseCache = false
-	routes, err := utils.GetRoutes()
+	app.UseCache = cfg.Templates.Cache
+	routes, err := utils.LoadRoutes(cfg.Data.RoutesFile)
 	if err != nil {
 		return fmt.Errorf("failed to load routes: %w", err)
 	}
 	app.DataCache.Routes = routes
 	if len(app.DataCache.Routes) <= 0 {
-		fmt.Printf("no routes were loaded in the cache\n")
+		app.Logger.Warn("no routes were loaded in the cache")
+	}
+	app.Logger.Info("routes cache loaded", "routes", len(app.DataCache.Routes))
+
+	// stops and shapes of the GeoJSON export, the files are downloaded with the GTFS of the city
+	if cfg.Data.GTFSDir != "" {
+		feed, err := utils.LoadFeed(cfg.Data.GTFSDir)
+		if err != nil {
+			return fmt.Errorf("failed to load the GTFS feed: %w", err)
+		}
+		app.DataCache.Feed = *feed
+		if len(feed.Stops) == 0 && len(feed.Shapes) == 0 {
+			app.Logger.Warn("no GTFS stops or shapes were loaded, the GeoJSON export only has the vehicles and parking rules", "dir", cfg.Data.GTFSDir)
+		}
+		app.Logger.Info("gtfs cache loaded", "stops", len(feed.Stops), "shapes", len(feed.Shapes))
 	}
-	fmt.Printf("routes cache loaded with %d routes.\n", len(app.DataCache.Routes))
 
-	render.NewTemplates(&app)
 	helpers.NewHelpers(&app)
 
 	return nil
 }
+
+// startTracing exports the traces to the configured OpenTelemetry collector, doing
+// nothing when there is none. The returned function exports the last spans.
+func startTracing(cfg *config.Settings) (func(), error) {
+	if cfg.Tracing.Endpoint == "" {
+		return func() {}, nil
+	}
+
+	headers, err := tracing.ParseHeaders(cfg.Tracing.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracing headers: %w", err)
+	}
+
+	provider, err := tracing.Setup(context.Background(), cfg.Tracing.ServiceName, cfg.Tracing.Endpoint, headers, exportTimeout)
+	if err != nil {
+		return nil, err
+	}
+	app.Logger.Info("exporting traces", "endpoint", cfg.Tracing.Endpoint, "service", cfg.Tracing.ServiceName)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			app.Logger.Warn("error exporting the last spans", "error", err)
+		}
+	}, nil
+}
+
+// openAccountsDB connects to the database of the postgres accounts store, which
+// also keeps the parking spots and webhooks of the users. It returns a nil pool
+// for the other stores.
+func openAccountsDB(cfg *config.Settings) (*sql.DB, func(), error) {
+	if cfg.Accounts.Store != "postgres" {
+		return nil, func() {}, nil
+	}
+	db, err := driver.ConnectSQL(cfg.AccountsDatabaseURL())
+	if err != nil {
+		return nil, nil, fmt.Errorf("can not connect to the accounts database: %w", err)
+	}
+	return db.SQL, func() { _ = db.SQL.Close() }, nil
+}
+
+// reminderNotifier returns the notifier of the parking reminders.
+func reminderNotifier(cfg *config.Settings, logger *slog.Logger) notify.Notifier {
+	switch cfg.Reminders.Notifier {
+	case "smtp":
+		return notify.NewSMTPNotifier(cfg.Reminders.SMTPAddr, cfg.Reminders.SMTPFrom, cfg.Reminders.SMTPUsername, cfg.Reminders.SMTPPassword)
+	case "webhook":
+		return notify.NewWebhookNotifier(cfg.Reminders.WebhookURL, notify.DefaultTimeout)
+	default:
+		return &notify.LogNotifier{Logger: logger}
+	}
+}
+
+// streetsGeocoder returns the reverse geocoder of the streets file, nil when there
+// is no file.
+func streetsGeocoder(path string, logger *slog.Logger) (*geo.Geocoder, error) {
+	if path == "" {
+		return nil, nil
+	}
+	geocoder, err := geo.LoadGeocoder(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.Warn("streets file not found, the addresses of the parking searches are not geocoded", "path", path)
+		return nil, nil
+	}
+	return geocoder, err
+}
+
+// mapGeocoder returns the geocoder of the address searches of the map, keeping
+// their places for map.geocode_cache_ttl. Google Geocoding needs a key.
+func mapGeocoder(cfg *config.Settings) (maps.Geocoder, error) {
+	var geocoder maps.Geocoder
+	if cfg.MapGeocoder() == maps.GoogleGeocoding {
+		if cfg.API.GoogleMapsAPIKey == "" {
+			return nil, errors.New("invalid map geocoder: the google geocoder requires a Google Maps API key")
+		}
+		geocoder = maps.NewGoogleGeocoder(cfg.API.GoogleMapsAPIKey, cfg.API.Timeout)
+	} else {
+		geocoder = maps.NewNominatimGeocoder(cfg.Map.NominatimURL, cfg.API.Timeout)
+	}
+	return maps.NewCachedGeocoder(geocoder, cfg.Map.GeocodeCacheTTL), nil
+}
+
+// realtimeFeeds returns the realtime feeds to poll with their configured intervals.
+func realtimeFeeds(cfg *config.Settings) []realtime.FeedConfig {
+	return []realtime.FeedConfig{
+		{Feed: services.FeedVehiclePositions, Interval: cfg.Realtime.VehiclePositionsInterval},
+		{Feed: services.FeedTripUpdates, Interval: cfg.Realtime.TripUpdatesInterval},
+		{Feed: services.FeedServiceAlerts, Interval: cfg.Realtime.ServiceAlertsInterval},
+	}
+}
+
+// historyReplayer returns the replayer of the recorded feeds, nil when replay is disabled.
+func historyReplayer(cfg *config.Settings, store history.Store) *history.Replayer {
+	if cfg.Replay.Speed <= 0 || store == nil {
+		return nil
+	}
+	return &history.Replayer{
+		Store: store,
+		Speed: cfg.Replay.Speed,
+		From:  cfg.Replay.From,
+		To:    cfg.Replay.To,
+	}
+}
+
+// readinessChecks returns the checks of the readiness probe: the routes and
+// templates are loaded, the upstream API is available and the stores kept in a
+// database, by check name, can reach it.
+func readinessChecks(source realtime.Source, stores map[string]any) *health.Checker {
+	checker := &health.Checker{}
+	checker.Add("routes", func(context.Context) error {
+		if len(app.DataCache.Routes) == 0 {
+			return errors.New("routes cache is empty")
+		}
+		return nil
+	})
+	checker.Add("templates", func(context.Context) error {
+		if len(app.TemplateCache) == 0 {
+			return errors.New("templates are not parsed")
+		}
+		return nil
+	})
+	checker.Add("upstream", health.Upstream(source, realtime.DefaultMaxAge))
+	names := make([]string, 0, len(stores))
+	for name := range stores {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if db, ok := stores[name].(health.Pinger); ok {
+			checker.Add(name, db.Ping)
+		}
+	}
+	return checker
+}
+
+// checkCredentials reports the missing upstream API credentials by name, failing
+// in production. Credential values are never logged.
+func checkCredentials(cfg *config.Settings) error {
+	missing := cfg.MissingCredentials()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	message := "missing credentials " + strings.Join(missing, ", ") + ", set them in the environment, a *_FILE variable or the secrets directory"
+	if cfg.InProduction() {
+		return errors.New(message)
+	}
+	slog.Warn(message + ", upstream API requests will fail")
+	return nil
+}
@@ -1,18 +1,416 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+
+	"github.com/mayloo89/bamos/internal/accounts"
+	"github.com/mayloo89/bamos/internal/config"
+	"github.com/mayloo89/bamos/internal/health"
+	"github.com/mayloo89/bamos/internal/history"
+	"github.com/mayloo89/bamos/internal/maps"
+	"github.com/mayloo89/bamos/internal/notify"
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/tracing"
 )
 
 func Test_run_Success(t *testing.T) {
 	assert := assert.New(t)
 
-	// Set the ROUTES_FILE env var so utils.GetRoutes works regardless of test working dir
+	// Set the ROUTES_FILE env var so the routes are found regardless of test working dir
 	t.Setenv("ROUTES_FILE", "../../static/routesinfo/routes.txt")
 
-	err := run()
+	err := run(testSettings(t))
 
 	assert.Nil(err, "run() should not return an error when ROUTES_FILE is set and file exists")
 }
+
+func Test_run_Production(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("ROUTES_FILE", "../../static/routesinfo/routes.txt")
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("TEMPLATE_CACHE", "true")
+	t.Setenv("NOMINATIM_URL", "https://nominatim.example.com")
+
+	err := run(testSettings(t))
+
+	assert.NoError(err)
+	assert.True(app.InProduction)
+	assert.True(app.UseCache)
+	assert.True(session.Cookie.Secure)
+}
+
+func Test_run_TemplateDir(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	dir := t.TempDir()
+	required.NoError(os.WriteFile(filepath.Join(dir, "home.page.tmpl"), []byte(`{{define "content"}}{{end`), 0o644))
+	t.Setenv("ROUTES_FILE", "../../static/routesinfo/routes.txt")
+	t.Setenv("TEMPLATE_DIR", dir)
+	defer func() { app.Templates = nil }()
+
+	// in development broken templates are reported by the pages using them
+	assert.NoError(run(testSettings(t)))
+	assert.NotNil(app.Templates)
+
+	t.Setenv("TEMPLATE_CACHE", "true")
+	assert.ErrorContains(run(testSettings(t)), "can not create template cache")
+
+	t.Setenv("TEMPLATE_CACHE", "false")
+	t.Setenv("STATIC_DIR", filepath.Join(dir, "missing"))
+	assert.ErrorContains(run(testSettings(t)), "invalid static assets directory")
+}
+
+func Test_realtimeFeeds_Intervals(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("VEHICLE_POSITIONS_INTERVAL", "10s")
+
+	feeds := realtimeFeeds(testSettings(t))
+
+	assert.Len(feeds, 3)
+	assert.Equal(10*time.Second, feeds[0].Interval)
+	assert.Equal(realtime.DefaultTripUpdatesInterval, feeds[1].Interval)
+	assert.Equal(realtime.DefaultServiceAlertsInterval, feeds[2].Interval)
+}
+
+func Test_reminderNotifier(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.IsType(&notify.LogNotifier{}, reminderNotifier(testSettings(t), nil))
+
+	t.Setenv("REMINDER_NOTIFIER", "smtp")
+	assert.IsType(&notify.SMTPNotifier{}, reminderNotifier(testSettings(t), nil))
+
+	t.Setenv("REMINDER_NOTIFIER", "webhook")
+	t.Setenv("REMINDER_WEBHOOK_URL", "http://localhost:9000/hooks")
+	n := reminderNotifier(testSettings(t), nil)
+	if assert.IsType(&notify.WebhookNotifier{}, n) {
+		assert.Equal("http://localhost:9000/hooks", n.(*notify.WebhookNotifier).URL)
+	}
+}
+
+func Test_streetsGeocoder(t *testing.T) {
+	assert := assert.New(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+
+	g, err := streetsGeocoder("", logger)
+	assert.NoError(err)
+	assert.Nil(g)
+
+	// a missing file disables the geocoding
+	g, err = streetsGeocoder(filepath.Join(dir, "callejero.csv"), logger)
+	assert.NoError(err)
+	assert.Nil(g)
+
+	path := filepath.Join(dir, "streets.csv")
+	require.NoError(t, os.WriteFile(path, []byte("wkt,nomoficial,alt_izqini,alt_izqfin,alt_derini,alt_derfin\n\"LINESTRING (-58.383 -34.6035, -58.385 -34.6036)\",CORRIENTES AV.,1001,1099,1000,1098\n"), 0o644))
+	g, err = streetsGeocoder(path, logger)
+	assert.NoError(err)
+	assert.NotNil(g)
+
+	require.NoError(t, os.WriteFile(path, []byte("name\n"), 0o644))
+	_, err = streetsGeocoder(path, logger)
+	assert.ErrorContains(err, "error reading streets file")
+}
+
+func Test_mapGeocoder(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	cfg := config.Default()
+	g, err := mapGeocoder(cfg)
+	assert.NoError(err)
+	required.IsType(&maps.CachedGeocoder{}, g, "the searches are cached")
+	assert.IsType(&maps.NominatimGeocoder{}, g.(*maps.CachedGeocoder).Geocoder)
+
+	cfg.API.GoogleMapsAPIKey = "key"
+	g, err = mapGeocoder(cfg)
+	assert.NoError(err)
+	required.IsType(&maps.CachedGeocoder{}, g)
+	assert.IsType(&maps.GoogleGeocoder{}, g.(*maps.CachedGeocoder).Geocoder)
+
+	cfg.API.GoogleMapsAPIKey = ""
+	cfg.Map.Geocoder = maps.GoogleGeocoding
+	_, err = mapGeocoder(cfg)
+	assert.ErrorContains(err, "requires a Google Maps API key")
+}
+
+func Test_historyReplayer(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	store, err := history.NewFileStore(t.TempDir())
+	required.NoError(err)
+
+	assert.Nil(historyReplayer(testSettings(t), store), "replay is disabled without REPLAY_SPEED")
+
+	t.Setenv("HISTORY_STORE", "file")
+	t.Setenv("REPLAY_SPEED", "10")
+	t.Setenv("REPLAY_FROM", "2026-10-19T08:00:00-03:00")
+
+	replayer := historyReplayer(testSettings(t), store)
+	required.NotNil(replayer)
+	assert.Equal(10.0, replayer.Speed)
+	assert.True(replayer.From.Equal(time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC)))
+	assert.True(replayer.To.IsZero())
+}
+
+func Test_readinessChecks(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("ROUTES_FILE", "../../static/routesinfo/routes.txt")
+	require.NoError(t, run(testSettings(t)))
+
+	poller := realtime.NewPoller(nil, app.Logger)
+	report := readinessChecks(poller, map[string]any{
+		"database": nil,
+		"accounts": accounts.NewMemoryStore(),
+		"pinger":   pingerFunc(func(context.Context) error { return errors.New("unreachable") }),
+	}).Run(context.Background())
+
+	assert.False(report.Ready(), "the upstream check fails until the vehicle positions are received")
+	assert.Equal(health.StatusOK, report.Checks["routes"].Status)
+	assert.Equal(health.StatusOK, report.Checks["templates"].Status)
+	assert.Equal(health.StatusUnavailable, report.Checks["upstream"].Status)
+	assert.NotContains(report.Checks, "database")
+	assert.NotContains(report.Checks, "accounts", "the stores in memory have nothing to check")
+	assert.Equal(health.StatusUnavailable, report.Checks["pinger"].Status)
+}
+
+// pingerFunc is a store reachable when the function returns no error.
+type pingerFunc func(context.Context) error
+
+func (f pingerFunc) Ping(ctx context.Context) error { return f(ctx) }
+
+func Test_startTracing(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	t.Setenv("ROUTES_FILE", "../../static/routesinfo/routes.txt")
+	required.NoError(run(testSettings(t)))
+
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	stop, err := startTracing(testSettings(t))
+	required.NoError(err)
+	stop()
+	_, span := tracing.Start(context.Background(), "disabled")
+	assert.False(span.IsRecording(), "tracing is disabled without an endpoint")
+
+	exported := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		exported <- r.URL.Path + " " + string(body)
+	}))
+	defer collector.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+	t.Setenv("OTEL_SERVICE_NAME", "bamos-test")
+	stop, err = startTracing(testSettings(t))
+	required.NoError(err)
+
+	_, span = tracing.Start(context.Background(), "enabled")
+	required.True(span.IsRecording())
+	span.End()
+
+	// the pending spans are exported when tracing stops
+	stop()
+	select {
+	case body := <-exported:
+		assert.Contains(body, "/v1/traces ")
+		assert.Contains(body, "enabled")
+		assert.Contains(body, "bamos-test")
+	default:
+		t.Fatal("spans were not exported on stop")
+	}
+	_, span = tracing.Start(context.Background(), "stopped")
+	assert.False(span.IsRecording())
+}
+
+func Test_checkCredentials(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("CABA_CLIENT_ID", "")
+	t.Setenv("CABA_CLIENT_SECRET", "")
+	assert.NoError(checkCredentials(testSettings(t)), "development only warns")
+
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("NOMINATIM_URL", "https://nominatim.example.com")
+	t.Setenv("CABA_CLIENT_ID", "s3cr3t-id")
+	err := checkCredentials(testSettings(t))
+	assert.ErrorContains(err, "missing credentials CABA_CLIENT_SECRET")
+	assert.NotContains(err.Error(), "s3cr3t-id")
+
+	t.Setenv("CABA_CLIENT_SECRET", "s3cr3t")
+	assert.NoError(checkCredentials(testSettings(t)))
+}
+
+func Test_serve_CompletesInFlightRequests(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})}
+	stopped := make(chan struct{})
+	srv.RegisterOnShutdown(func() { close(stopped) })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	required.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, srv, ln, 5*time.Second)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+
+	// shut down while the request is in flight
+	<-started
+	cancel()
+
+	res := <-responses
+	required.NoError(res.err)
+	assert.Equal("done", res.body)
+	assert.NoError(<-served)
+
+	// background workers registered on shutdown are stopped
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("shutdown hooks were not called")
+	}
+
+	// new connections are refused
+	_, err = http.Get("http://" + ln.Addr().String())
+	assert.Error(err)
+}
+
+func Test_serve_DrainDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, srv, ln, 50*time.Millisecond)
+	}()
+
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+
+	<-started
+	cancel()
+
+	// a request outliving the deadline does not block the shutdown forever
+	err = <-served
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_serveAll(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	started := make(chan struct{})
+	completed := make(chan struct{})
+	appSrv := &http.Server{Handler: http.NotFoundHandler()}
+	adminSrv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		close(completed)
+	})}
+	appLn, err := net.Listen("tcp", "127.0.0.1:0")
+	required.NoError(err)
+	adminLn, err := net.Listen("tcp", "127.0.0.1:0")
+	required.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serveAll(ctx, 5*time.Second, listener{name: "application", srv: appSrv, ln: appLn}, listener{name: "admin", srv: adminSrv, ln: adminLn})
+	}()
+	go func() {
+		if resp, err := http.Get("http://" + adminLn.Addr().String()); err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+
+	// the in-flight requests of the admin server complete before returning
+	<-started
+	cancel()
+	assert.NoError(<-served)
+	select {
+	case <-completed:
+	default:
+		t.Fatal("serveAll returned before the admin server shut down")
+	}
+
+	// a failing server shuts the others down
+	appLn, err = net.Listen("tcp", "127.0.0.1:0")
+	required.NoError(err)
+	adminLn, err = net.Listen("tcp", "127.0.0.1:0")
+	required.NoError(err)
+	required.NoError(adminLn.Close())
+	err = serveAll(context.Background(), 5*time.Second,
+		listener{name: "application", srv: &http.Server{Handler: http.NotFoundHandler()}, ln: appLn},
+		listener{name: "admin", srv: &http.Server{Handler: http.NotFoundHandler()}, ln: adminLn})
+	assert.ErrorContains(err, "admin server: ")
+	assert.ErrorIs(err, net.ErrClosed)
+}
+
+// testSettings loads the settings from the test environment.
+func testSettings(t *testing.T) *config.Settings {
+	cfg, err := config.Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, os.Getenv)
+	require.NoError(t, err)
+	return cfg
+}
@@ -4,6 +4,9 @@ import (
 	"net/http"
 
 	"github.com/justinas/nosurf"
+
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/i18n"
 )
 
 // NoSurf adds CSRF protection to all POST request
@@ -24,3 +27,42 @@ func NoSurf(next http.Handler) http.Handler {
 func SessionLoad(next http.Handler) http.Handler {
 	return session.LoadAndSave(next)
 }
+
+// Auth redirects the requests of anonymous users to the login page
+func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !helpers.IsAuthenticated(r) {
+			session.Put(r.Context(), "error", i18n.FromContext(r.Context()).T("nav.login_first"))
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Locale sets the locale of the request, from the first supported one of the lang
+// query parameter, which is kept as the preference of the session, that preference,
+// the Accept-Language header and the default locale of the app.
+func Locale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale, ok := i18n.Parse(r.URL.Query().Get("lang"))
+		if ok {
+			session.Put(r.Context(), "locale", string(locale))
+		} else {
+			locale, ok = i18n.Parse(session.GetString(r.Context(), "locale"))
+		}
+		if !ok {
+			locale, ok = i18n.Negotiate(r.Header.Get("Accept-Language"))
+		}
+		if !ok {
+			locale = app.Locale
+		}
+		if locale == "" {
+			locale = i18n.English
+		}
+
+		w.Header().Add("Vary", "Accept-Language")
+		w.Header().Set("Content-Language", string(locale))
+		next.ServeHTTP(w, r.WithContext(i18n.WithLocale(r.Context(), locale)))
+	})
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/alexedwards/scs/v2"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/mayloo89/bamos/internal/i18n"
 )
 
 func Test_NoSurf_Success(t *testing.T) {
@@ -42,3 +46,46 @@ func Test_SessionLoad_Success(t *testing.T) {
 	assert.NotNil(handler)
 	assert.True(expectedType)
 }
+
+func Test_Locale(t *testing.T) {
+	assert := assert.New(t)
+
+	defaultSession, defaultLocale := session, app.Locale
+	t.Cleanup(func() { session, app.Locale = defaultSession, defaultLocale })
+	session = scs.New()
+	app.Locale = i18n.Spanish
+
+	var locale i18n.Locale
+	handler := session.LoadAndSave(Locale(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale = i18n.FromContext(r.Context())
+	})))
+	serve := func(target, acceptLanguage string, cookies ...*http.Cookie) *http.Response {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	// the configured locale without a preference
+	res := serve("/", "")
+	assert.Equal(i18n.Spanish, locale)
+	assert.Equal("es-AR", res.Header.Get("Content-Language"))
+	assert.Contains(res.Header.Values("Vary"), "Accept-Language")
+
+	res = serve("/", "en-US,en;q=0.9")
+	assert.Equal(i18n.English, locale)
+	assert.Equal("en", res.Header.Get("Content-Language"))
+
+	// the lang parameter wins and is kept in the session
+	res = serve("/?lang=es", "en-US,en;q=0.9")
+	assert.Equal(i18n.Spanish, locale)
+	serve("/", "en-US,en;q=0.9", res.Cookies()...)
+	assert.Equal(i18n.Spanish, locale)
+
+	serve("/?lang=pt", "en")
+	assert.Equal(i18n.English, locale)
+}
@@ -1,35 +1,165 @@
 package main
 
 import (
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
+	"github.com/mayloo89/bamos"
+	"github.com/mayloo89/bamos/internal/apperror"
 	"github.com/mayloo89/bamos/internal/config"
 	"github.com/mayloo89/bamos/internal/handler"
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/logging"
+	"github.com/mayloo89/bamos/internal/metrics"
+	"github.com/mayloo89/bamos/internal/tracing"
 )
 
+// adminRoutes returns the handler of the admin listener, the endpoints that
+// change the running application and must not be reachable through the load
+// balancer.
+func adminRoutes(app *config.AppConfig) http.Handler {
+	mux := chi.NewRouter()
+
+	logger := app.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	mux.Use(logging.RequestIDMiddleware)
+	mux.Use(logging.AccessLog(logger))
+	mux.Use(middleware.Recoverer)
+
+	if app.LogLevel != nil {
+		mux.Handle("/debug/log-level", logging.LevelHandler(app.LogLevel, logger))
+	}
+
+	return mux
+}
+
 func routes(app *config.AppConfig, repo *handler.Repository) http.Handler {
 	mux := chi.NewRouter()
 
+	logger := app.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	mux.Use(logging.RequestIDMiddleware)
+	mux.Use(tracing.Middleware)
+	// metrics and logs go before the recoverer to record the 500 it writes
+	mux.Use(metrics.Middleware)
+	mux.Use(logging.AccessLog(logger))
 	mux.Use(middleware.Recoverer)
-	mux.Use(NoSurf)
-	mux.Use(SessionLoad)
 
-	fileServer := http.FileServer(http.Dir("./static/"))
-	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
+	// error pages are rendered like any other page, popping the session messages
+	mux.NotFound(sessionLoad(app, func(w http.ResponseWriter, r *http.Request) {
+		helpers.Error(w, r, apperror.NotFound())
+	}))
+	mux.MethodNotAllowed(sessionLoad(app, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowedMethods(mux, r.URL.Path), ", "))
+		helpers.Error(w, r, apperror.MethodNotAllowed())
+	}))
+
+	// Probes, JSON without session or CSRF token
+	mux.Get("/healthz", repo.Healthz)
+	mux.Get("/readyz", repo.Readyz)
+	mux.Get("/version", repo.Version)
+	mux.Method("GET", "/metrics", metrics.Handler())
+
+	// JSON APIs, without session or CSRF token, their errors are always problem+json
+	mux.Group(func(mux chi.Router) {
+		mux.Use(helpers.JSONErrors)
 
-	mux.Get("/", repo.Home)
-	mux.Get("/colectivos/vehiclePositionsSimple", repo.VehiclePositionsSimple)
-	// mux.Get("/colectivos/feed-gtfs-frequency", repo.FeedGtfsFrequency)
+		// Realtime API, WebSocket connections can not go through the session middleware
+		mux.Method("GET", "/api/v1/realtime", helpers.HandlerFunc(repo.RealtimeWebSocket))
+		// Address search of the maps
+		mux.Method("GET", "/api/v1/geocode", helpers.HandlerFunc(repo.Geocode))
+		// GeoJSON export for GIS tools
+		mux.Method("GET", "/api/v1/geojson/routes", helpers.HandlerFunc(repo.RoutesGeoJSON))
+		mux.Method("GET", "/api/v1/geojson/stops", helpers.HandlerFunc(repo.StopsGeoJSON))
+		mux.Method("GET", "/api/v1/geojson/vehicles", helpers.HandlerFunc(repo.VehiclesGeoJSON))
+		mux.Method("GET", "/api/v1/geojson/parking", helpers.HandlerFunc(repo.ParkingGeoJSON))
+	})
 
-	mux.Get("/colectivos/search", repo.SearchLine)
-	mux.Post("/colectivos/search", repo.PostSearchLine)
+	mux.Group(func(mux chi.Router) {
+		mux.Use(NoSurf)
+		mux.Use(SessionLoad)
+		mux.Use(Locale)
 
-	// Allowed Parking
-	mux.Get("/transit/allowed-parking", repo.AllowedParking)
-	mux.Post("/transit/allowed-parking", repo.PostAllowedParking)
+		fileServer := http.FileServer(http.FS(staticFS(app)))
+		mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
+
+		mux.Method("GET", "/", helpers.HandlerFunc(repo.Home))
+		mux.Method("GET", "/colectivos/vehiclePositionsSimple", helpers.HandlerFunc(repo.VehiclePositionsSimple))
+		mux.Method("GET", "/colectivos/live", helpers.HandlerFunc(repo.LiveVehicles))
+		mux.Method("GET", "/colectivos/stream", helpers.HandlerFunc(repo.VehicleStream))
+		// mux.Get("/colectivos/feed-gtfs-frequency", repo.FeedGtfsFrequency)
+
+		mux.Method("GET", "/colectivos/search", helpers.HandlerFunc(repo.SearchLine))
+		mux.Method("POST", "/colectivos/search", helpers.HandlerFunc(repo.PostSearchLine))
+
+		// Analytics
+		mux.Method("GET", "/analytics/lines/{route_id}", helpers.HandlerFunc(repo.AnalyticsLine))
+		mux.Method("GET", "/analytics/lines/{route_id}/export.csv", helpers.HandlerFunc(repo.AnalyticsLineCSV))
+
+		// Allowed Parking
+		mux.Method("GET", "/transit/allowed-parking", helpers.HandlerFunc(repo.AllowedParking))
+		mux.Method("POST", "/transit/allowed-parking", helpers.HandlerFunc(repo.PostAllowedParking))
+
+		// User accounts
+		mux.Method("GET", "/user/register", helpers.HandlerFunc(repo.Register))
+		mux.Method("POST", "/user/register", helpers.HandlerFunc(repo.PostRegister))
+		mux.Method("GET", "/user/login", helpers.HandlerFunc(repo.Login))
+		mux.Method("POST", "/user/login", helpers.HandlerFunc(repo.PostLogin))
+		mux.Method("POST", "/user/logout", helpers.HandlerFunc(repo.PostLogout))
+
+		mux.Route("/my", func(mux chi.Router) {
+			mux.Use(Auth)
+			mux.Method("GET", "/", helpers.HandlerFunc(repo.MyBamos))
+			mux.Method("POST", "/favorites/{kind}", helpers.HandlerFunc(repo.PostFavorite))
+			mux.Method("POST", "/favorites/{kind}/{id}/delete", helpers.HandlerFunc(repo.PostRemoveFavorite))
+			mux.Method("POST", "/parking", helpers.HandlerFunc(repo.PostParkHere))
+			mux.Method("POST", "/parking/delete", helpers.HandlerFunc(repo.PostLeaveSpot))
+			mux.Method("POST", "/webhooks", helpers.HandlerFunc(repo.PostWebhook))
+			mux.Method("GET", "/webhooks/{id}", helpers.HandlerFunc(repo.Webhook))
+			mux.Method("POST", "/webhooks/{id}/delete", helpers.HandlerFunc(repo.PostDeleteWebhook))
+		})
+	})
 
 	return mux
 }
+
+// staticFS returns the static assets directory, the embedded assets unless app
+// overrides them.
+func staticFS(app *config.AppConfig) fs.FS {
+	if app.Static != nil {
+		return app.Static
+	}
+	return bamos.Static()
+}
+
+// sessionLoad loads the session of the handler, and the locale it keeps, when the
+// app has one.
+func sessionLoad(app *config.AppConfig, h http.HandlerFunc) http.HandlerFunc {
+	if app.Session == nil {
+		return h
+	}
+	return app.Session.LoadAndSave(Locale(h)).ServeHTTP
+}
+
+// allowedMethods returns the methods routed for path, answered in the Allow
+// header of the 405 responses.
+func allowedMethods(mux chi.Routes, path string) []string {
+	var methods []string
+	for _, method := range []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"} {
+		if mux.Match(chi.NewRouteContext(), method, path) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"io"
+	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/mayloo89/bamos/internal/config"
 	"github.com/mayloo89/bamos/internal/handler"
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/logging"
 )
 
 func Test_routes_Success(t *testing.T) {
@@ -28,3 +36,175 @@ func Test_routes_Success(t *testing.T) {
 	assert.NotNil(handler)
 	assert.True(expectedType)
 }
+
+func Test_routes_Probes(t *testing.T) {
+	assert := assert.New(t)
+	ac := &config.AppConfig{}
+	repo := handler.NewRepo(ac, nil)
+	mux := routes(ac, repo)
+
+	for _, path := range []string{"/healthz", "/readyz", "/version"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+		assert.Equal(http.StatusOK, rr.Code, path)
+		assert.Equal("application/json", rr.Header().Get("Content-Type"), path)
+		// probes go through neither the session nor the CSRF middleware
+		assert.Empty(rr.Result().Cookies(), path)
+		assert.NotEmpty(rr.Header().Get(logging.RequestIDHeader), path)
+	}
+}
+
+func Test_routes_Geocode(t *testing.T) {
+	assert := assert.New(t)
+	ac := &config.AppConfig{}
+	mux := routes(ac, handler.NewRepo(ac, nil))
+
+	req := httptest.NewRequest("GET", "/api/v1/geocode?q=Corrientes+1234", nil)
+	req.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	// without a geocoder the search is unavailable
+	assert.Equal(http.StatusServiceUnavailable, rr.Code)
+	assert.Empty(rr.Result().Cookies())
+}
+
+func Test_routes_GeoJSON(t *testing.T) {
+	assert := assert.New(t)
+	ac := &config.AppConfig{}
+	mux := routes(ac, handler.NewRepo(ac, nil))
+
+	for _, path := range []string{"/api/v1/geojson/routes", "/api/v1/geojson/stops"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+		assert.Equal(http.StatusOK, rr.Code, path)
+		assert.Equal("application/geo+json", rr.Header().Get("Content-Type"), path)
+		assert.JSONEq(`{"type": "FeatureCollection", "features": []}`, rr.Body.String(), path)
+		assert.Empty(rr.Result().Cookies(), path)
+	}
+}
+
+func Test_routes_Static(t *testing.T) {
+	assert := assert.New(t)
+	ac := &config.AppConfig{}
+	mux := routes(ac, handler.NewRepo(ac, nil))
+
+	// the assets are embedded, they are served from any working directory
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/static/images/logo-ba.png", nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Equal("image/png", rr.Header().Get("Content-Type"))
+
+	ac.Static = fstest.MapFS{"app.css": {Data: []byte("body {}")}}
+	mux = routes(ac, handler.NewRepo(ac, nil))
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/static/app.css", nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Equal("body {}", rr.Body.String())
+}
+
+func Test_routes_Metrics(t *testing.T) {
+	assert := assert.New(t)
+	ac := &config.AppConfig{}
+	mux := routes(ac, handler.NewRepo(ac, nil))
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Contains(rr.Body.String(), `bamos_http_requests_total{method="GET",route="/healthz",status="200"}`)
+	assert.Contains(rr.Body.String(), "# TYPE bamos_http_request_duration_seconds histogram")
+	assert.Contains(rr.Body.String(), "# TYPE go_goroutines gauge")
+	assert.Empty(rr.Result().Cookies())
+}
+
+func Test_adminRoutes(t *testing.T) {
+	assert := assert.New(t)
+	logger, level, err := logging.New(io.Discard, logging.FormatText, "info")
+	require.NoError(t, err)
+	ac := &config.AppConfig{Logger: logger, LogLevel: level}
+
+	rr := httptest.NewRecorder()
+	adminRoutes(ac).ServeHTTP(rr, httptest.NewRequest("PUT", "/debug/log-level", strings.NewReader("debug")))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Equal(slog.LevelDebug, level.Level())
+
+	// the public router does not serve the admin endpoints
+	req := httptest.NewRequest("PUT", "/debug/log-level", strings.NewReader("error"))
+	req.Header.Set("Accept", "application/json")
+	rr = httptest.NewRecorder()
+	routes(ac, handler.NewRepo(ac, nil)).ServeHTTP(rr, req)
+	assert.Equal(http.StatusNotFound, rr.Code)
+	assert.Equal(slog.LevelDebug, level.Level())
+}
+
+func Test_routes_Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	// error pages are rendered with the templates and session of the application
+	t.Setenv("ROUTES_FILE", "../../static/routesinfo/routes.txt")
+	require.NoError(t, run(testSettings(t)))
+	mux := routes(&app, handler.NewRepo(&app, nil))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/no-such-page", nil))
+	assert.Equal(http.StatusNotFound, rr.Code)
+	assert.Contains(rr.Body.String(), "The page you are looking for does not exist.")
+
+	req := httptest.NewRequest("GET", "/no-such-page", nil)
+	req.Header.Set("Accept", "application/json")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	assert.Equal(http.StatusNotFound, rr.Code)
+	assert.Equal(helpers.ProblemContentType, rr.Header().Get("Content-Type"))
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("DELETE", "/transit/allowed-parking", nil))
+	assert.Equal(http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal("GET, POST", rr.Header().Get("Allow"))
+}
+
+func Test_routes_APIErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	// the APIs answer problem+json without an Accept header, even with the session of
+	// the application set up
+	t.Setenv("ROUTES_FILE", "../../static/routesinfo/routes.txt")
+	require.NoError(t, run(testSettings(t)))
+	mux := routes(&app, handler.NewRepo(&app, nil))
+
+	for path, status := range map[string]int{
+		"/api/v1/geocode?q=Corrientes+1234":  http.StatusServiceUnavailable,
+		"/api/v1/geojson/parking":            http.StatusBadRequest,
+		"/api/v1/geojson/stops?bbox=1,2":     http.StatusBadRequest,
+		"/api/v1/geojson/vehicles?route=abc": http.StatusServiceUnavailable,
+	} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+		assert.Equal(status, rr.Code, path)
+		assert.Equal(helpers.ProblemContentType, rr.Header().Get("Content-Type"), path)
+		assert.Empty(rr.Result().Cookies(), path)
+	}
+}
+
+func Test_routes_MyRequiresLogin(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("ROUTES_FILE", "../../static/routesinfo/routes.txt")
+	require.NoError(t, run(testSettings(t)))
+	mux := routes(&app, handler.NewRepo(&app, nil))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/my", nil))
+	assert.Equal(http.StatusSeeOther, rr.Code)
+	assert.Equal("/user/login", rr.Header().Get("Location"))
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/user/login", nil))
+	assert.Equal(http.StatusOK, rr.Code)
+}
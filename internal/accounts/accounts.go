@@ -0,0 +1,202 @@
+// Package accounts registers and authenticates the users of Bamos and keeps their
+// favorite lines, stops and parking addresses.
+package accounts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mayloo89/bamos/internal/i18n"
+)
+
+type (
+	// User is a registered user.
+	User struct {
+		ID           int64
+		Email        string
+		Name         string
+		PasswordHash []byte
+		Locale       i18n.Locale // Language of the notifications, chosen when registering
+		CreatedAt    time.Time
+	}
+
+	// Kind is a kind of favorite, named as in the URLs.
+	Kind string
+
+	// FavoriteLine is a saved bus line.
+	FavoriteLine struct {
+		ID   int64
+		Line string
+	}
+
+	// FavoriteStop is a saved stop, with the name the user gave it.
+	FavoriteStop struct {
+		ID     int64
+		StopID string
+		Name   string
+	}
+
+	// FavoriteAddress is a saved parking address.
+	FavoriteAddress struct {
+		ID        int64
+		Address   string
+		Latitude  float64
+		Longitude float64
+	}
+
+	// Favorites are the saved lines, stops and addresses of a user, oldest first.
+	Favorites struct {
+		Lines     []FavoriteLine
+		Stops     []FavoriteStop
+		Addresses []FavoriteAddress
+	}
+
+	// Store persists the users and their favorites.
+	Store interface {
+		// CreateUser saves a new user, setting its ID and creation time. It returns
+		// ErrEmailTaken when another user has the same email.
+		CreateUser(ctx context.Context, user *User) error
+		// UserByEmail returns the user with the given email, or ErrNotFound.
+		UserByEmail(ctx context.Context, email string) (*User, error)
+		// UserByID returns the user with the given ID, or ErrNotFound.
+		UserByID(ctx context.Context, id int64) (*User, error)
+		// Favorites returns the favorites of the user.
+		Favorites(ctx context.Context, userID int64) (*Favorites, error)
+		// AddFavoriteLine saves a line, doing nothing when it is already saved.
+		AddFavoriteLine(ctx context.Context, userID int64, line string) error
+		// AddFavoriteStop saves a stop, doing nothing when it is already saved.
+		AddFavoriteStop(ctx context.Context, userID int64, stop FavoriteStop) error
+		// AddFavoriteAddress saves a parking address.
+		AddFavoriteAddress(ctx context.Context, userID int64, address FavoriteAddress) error
+		// RemoveFavorite deletes a favorite of the user, or returns ErrNotFound.
+		RemoveFavorite(ctx context.Context, userID int64, kind Kind, id int64) error
+	}
+
+	// Accounts registers and authenticates users on top of a Store.
+	Accounts struct {
+		Store
+		Cost int // bcrypt cost of the password hashes
+
+		dummyOnce sync.Once
+		dummyHash []byte
+	}
+)
+
+// Kinds of favorites.
+const (
+	KindLine    Kind = "lines"
+	KindStop    Kind = "stops"
+	KindAddress Kind = "addresses"
+)
+
+const (
+	// MinPasswordLength is the minimum length of a password.
+	MinPasswordLength = 8
+	// MaxPasswordLength is the maximum length of a password, bcrypt ignores longer ones.
+	MaxPasswordLength = 72
+)
+
+var (
+	// ErrNotFound is returned when a user or favorite does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrEmailTaken is returned when registering an email that already has an account.
+	ErrEmailTaken = errors.New("email already registered")
+	// ErrInvalidCredentials is returned when the email or password are wrong.
+	ErrInvalidCredentials = errors.New("invalid email or password")
+)
+
+// New returns Accounts storing the users in store, hashing passwords with the
+// default bcrypt cost.
+func New(store Store) *Accounts {
+	return &Accounts{Store: store, Cost: bcrypt.DefaultCost}
+}
+
+// Register creates a user with the given email, name and password, notified in locale.
+func (a *Accounts) Register(ctx context.Context, email, name, password string, locale i18n.Locale) (*User, error) {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return nil, fmt.Errorf("password must be between %d and %d characters long", MinPasswordLength, MaxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), a.Cost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &User{
+		Email:        NormalizeEmail(email),
+		Name:         strings.TrimSpace(name),
+		PasswordHash: hash,
+		Locale:       locale,
+	}
+	if err := a.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Authenticate returns the user with the given email and password, or
+// ErrInvalidCredentials. Unknown emails take as long as wrong passwords, so
+// they can not be told apart.
+func (a *Accounts) Authenticate(ctx context.Context, email, password string) (*User, error) {
+	user, err := a.UserByEmail(ctx, NormalizeEmail(email))
+	if errors.Is(err, ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(a.dummy(), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+// dummy returns a hash compared against when the user does not exist.
+func (a *Accounts) dummy() []byte {
+	a.dummyOnce.Do(func() {
+		a.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("bamos-dummy-password"), a.Cost)
+	})
+	return a.dummyHash
+}
+
+// NormalizeEmail returns the email in lower case, without surrounding spaces.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ParseKind returns the kind of favorite named s.
+func ParseKind(s string) (Kind, error) {
+	switch kind := Kind(s); kind {
+	case KindLine, KindStop, KindAddress:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("invalid favorite kind %q", s)
+	}
+}
+
+// Open opens the store of the given kind, "memory" or "postgres" on db, the
+// accounts database whose pool the caller releases.
+func Open(kind string, db *sql.DB) (Store, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "postgres":
+		if db == nil {
+			return nil, errors.New("the postgres accounts store requires a database")
+		}
+		return NewPostgresStore(db), nil
+	default:
+		return nil, fmt.Errorf("invalid accounts store %q, must be memory or postgres", kind)
+	}
+}
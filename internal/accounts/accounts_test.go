@@ -0,0 +1,112 @@
+package accounts
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mayloo89/bamos/internal/i18n"
+)
+
+func newTestAccounts() *Accounts {
+	a := New(NewMemoryStore())
+	a.Cost = bcrypt.MinCost
+	return a
+}
+
+func Test_Register_Authenticate(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+	a := newTestAccounts()
+
+	user, err := a.Register(ctx, " Ana@Example.com ", " Ana ", "colectivo60", i18n.Spanish)
+	required.NoError(err)
+	assert.NotZero(user.ID)
+	assert.Equal("ana@example.com", user.Email)
+	assert.Equal("Ana", user.Name)
+	assert.Equal(i18n.Spanish, user.Locale)
+	assert.NotEqual([]byte("colectivo60"), user.PasswordHash, "the password is hashed")
+
+	_, err = a.Register(ctx, "ANA@example.com", "Other", "colectivo152", i18n.English)
+	assert.ErrorIs(err, ErrEmailTaken)
+
+	_, err = a.Register(ctx, "short@example.com", "Short", "60", i18n.English)
+	assert.ErrorContains(err, "password must be between 8 and 72 characters long")
+
+	found, err := a.Authenticate(ctx, "ana@EXAMPLE.com", "colectivo60")
+	required.NoError(err)
+	assert.Equal(user.ID, found.ID)
+
+	_, err = a.Authenticate(ctx, "ana@example.com", "wrong-password")
+	assert.ErrorIs(err, ErrInvalidCredentials)
+
+	_, err = a.Authenticate(ctx, "nobody@example.com", "colectivo60")
+	assert.ErrorIs(err, ErrInvalidCredentials, "unknown emails look like wrong passwords")
+}
+
+func Test_MemoryStore_Favorites(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	required.NoError(store.AddFavoriteLine(ctx, 1, "60"))
+	required.NoError(store.AddFavoriteLine(ctx, 1, "60"))
+	required.NoError(store.AddFavoriteLine(ctx, 1, "152"))
+	required.NoError(store.AddFavoriteStop(ctx, 1, FavoriteStop{StopID: "201001", Name: "Home"}))
+	required.NoError(store.AddFavoriteAddress(ctx, 1, FavoriteAddress{Address: "Av. Corrientes 1234", Latitude: -34.6037, Longitude: -58.3816}))
+	required.NoError(store.AddFavoriteLine(ctx, 2, "39"))
+
+	f, err := store.Favorites(ctx, 1)
+	required.NoError(err)
+	required.Len(f.Lines, 2, "a line is saved once")
+	assert.Equal("60", f.Lines[0].Line)
+	assert.Equal("152", f.Lines[1].Line)
+	required.Len(f.Stops, 1)
+	assert.Equal("Home", f.Stops[0].Name)
+	required.Len(f.Addresses, 1)
+	assert.Equal(-34.6037, f.Addresses[0].Latitude)
+
+	required.NoError(store.RemoveFavorite(ctx, 1, KindLine, f.Lines[0].ID))
+	assert.ErrorIs(store.RemoveFavorite(ctx, 1, KindLine, f.Lines[0].ID), ErrNotFound)
+
+	other, err := store.Favorites(ctx, 2)
+	required.NoError(err)
+	assert.ErrorIs(store.RemoveFavorite(ctx, 1, KindLine, other.Lines[0].ID), ErrNotFound,
+		"users can not remove the favorites of others")
+
+	f, err = store.Favorites(ctx, 1)
+	required.NoError(err)
+	required.Len(f.Lines, 1)
+	assert.Equal("152", f.Lines[0].Line)
+}
+
+func Test_ParseKind(t *testing.T) {
+	assert := assert.New(t)
+
+	kind, err := ParseKind("stops")
+	assert.NoError(err)
+	assert.Equal(KindStop, kind)
+
+	_, err = ParseKind("trips")
+	assert.ErrorContains(err, `invalid favorite kind "trips"`)
+}
+
+func Test_Open(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	store, err := Open("memory", nil)
+	required.NoError(err)
+	assert.IsType(&MemoryStore{}, store)
+
+	_, err = Open("postgres", nil)
+	assert.ErrorContains(err, "the postgres accounts store requires a database")
+
+	_, err = Open("redis", nil)
+	assert.ErrorContains(err, `invalid accounts store "redis"`)
+}
@@ -0,0 +1,168 @@
+package accounts
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the users and favorites in memory, they are lost on restart.
+// It is meant for development and tests.
+type MemoryStore struct {
+	mu        sync.Mutex
+	nextID    int64
+	users     map[int64]User
+	favorites map[int64]*Favorites
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:     map[int64]User{},
+		favorites: map[int64]*Favorites{},
+	}
+}
+
+// CreateUser saves a new user.
+func (s *MemoryStore) CreateUser(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == user.Email {
+			return ErrEmailTaken
+		}
+	}
+
+	user.ID = s.id()
+	user.CreatedAt = time.Now()
+	s.users[user.ID] = *user
+	return nil
+}
+
+// UserByEmail returns the user with the given email.
+func (s *MemoryStore) UserByEmail(ctx context.Context, email string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// UserByID returns the user with the given ID.
+func (s *MemoryStore) UserByID(ctx context.Context, id int64) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+// Favorites returns a copy of the favorites of the user.
+func (s *MemoryStore) Favorites(ctx context.Context, userID int64) (*Favorites, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := s.favoritesOf(userID)
+	return &Favorites{
+		Lines:     append([]FavoriteLine(nil), f.Lines...),
+		Stops:     append([]FavoriteStop(nil), f.Stops...),
+		Addresses: append([]FavoriteAddress(nil), f.Addresses...),
+	}, nil
+}
+
+// AddFavoriteLine saves a line.
+func (s *MemoryStore) AddFavoriteLine(ctx context.Context, userID int64, line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := s.favoritesOf(userID)
+	for _, l := range f.Lines {
+		if l.Line == line {
+			return nil
+		}
+	}
+	f.Lines = append(f.Lines, FavoriteLine{ID: s.id(), Line: line})
+	return nil
+}
+
+// AddFavoriteStop saves a stop.
+func (s *MemoryStore) AddFavoriteStop(ctx context.Context, userID int64, stop FavoriteStop) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := s.favoritesOf(userID)
+	for _, st := range f.Stops {
+		if st.StopID == stop.StopID {
+			return nil
+		}
+	}
+	stop.ID = s.id()
+	f.Stops = append(f.Stops, stop)
+	return nil
+}
+
+// AddFavoriteAddress saves a parking address.
+func (s *MemoryStore) AddFavoriteAddress(ctx context.Context, userID int64, address FavoriteAddress) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := s.favoritesOf(userID)
+	address.ID = s.id()
+	f.Addresses = append(f.Addresses, address)
+	return nil
+}
+
+// RemoveFavorite deletes a favorite of the user.
+func (s *MemoryStore) RemoveFavorite(ctx context.Context, userID int64, kind Kind, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := s.favoritesOf(userID)
+	removed := false
+	switch kind {
+	case KindLine:
+		f.Lines, removed = remove(f.Lines, func(l FavoriteLine) bool { return l.ID == id })
+	case KindStop:
+		f.Stops, removed = remove(f.Stops, func(st FavoriteStop) bool { return st.ID == id })
+	case KindAddress:
+		f.Addresses, removed = remove(f.Addresses, func(a FavoriteAddress) bool { return a.ID == id })
+	}
+	if !removed {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// favoritesOf returns the favorites of the user, creating them if needed.
+func (s *MemoryStore) favoritesOf(userID int64) *Favorites {
+	f, ok := s.favorites[userID]
+	if !ok {
+		f = &Favorites{}
+		s.favorites[userID] = f
+	}
+	return f
+}
+
+// id returns the next ID, shared by users and favorites.
+func (s *MemoryStore) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// remove returns the items without the first one matching, and whether one did.
+func remove[T any](items []T, match func(T) bool) ([]T, bool) {
+	for i, item := range items {
+		if match(item) {
+			return append(items[:i:i], items[i+1:]...), true
+		}
+	}
+	return items, false
+}
@@ -0,0 +1,182 @@
+package accounts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mayloo89/bamos/internal/i18n"
+)
+
+// uniqueViolation is the Postgres error code of a unique constraint violation.
+const uniqueViolation = "23505"
+
+// PostgresStore stores the users in the users table and their favorites in the
+// favorite_lines, favorite_stops and favorite_addresses tables.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a PostgresStore using the given connection pool.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Ping checks the database is reachable.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// CreateUser saves a new user.
+func (s *PostgresStore) CreateUser(ctx context.Context, user *User) error {
+	err := s.db.QueryRowContext(ctx, `insert into users (email, name, password_hash, locale, created_at, updated_at)
+		values ($1, $2, $3, $4, current_timestamp, current_timestamp) returning id, created_at`,
+		user.Email, user.Name, string(user.PasswordHash), string(user.Locale)).Scan(&user.ID, &user.CreatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// UserByEmail returns the user with the given email.
+func (s *PostgresStore) UserByEmail(ctx context.Context, email string) (*User, error) {
+	return s.user(ctx, `select id, email, name, password_hash, locale, created_at from users where email = $1`, email)
+}
+
+// UserByID returns the user with the given ID.
+func (s *PostgresStore) UserByID(ctx context.Context, id int64) (*User, error) {
+	return s.user(ctx, `select id, email, name, password_hash, locale, created_at from users where id = $1`, id)
+}
+
+func (s *PostgresStore) user(ctx context.Context, query string, arg any) (*User, error) {
+	var user User
+	var hash, locale string
+	err := s.db.QueryRowContext(ctx, query, arg).Scan(&user.ID, &user.Email, &user.Name, &hash, &locale, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.PasswordHash = []byte(hash)
+	user.Locale = i18n.Locale(locale)
+	return &user, nil
+}
+
+// Favorites returns the favorites of the user.
+func (s *PostgresStore) Favorites(ctx context.Context, userID int64) (*Favorites, error) {
+	var f Favorites
+
+	err := s.query(ctx, `select id, line from favorite_lines where user_id = $1 order by id`, userID,
+		func(rows *sql.Rows) error {
+			var l FavoriteLine
+			if err := rows.Scan(&l.ID, &l.Line); err != nil {
+				return err
+			}
+			f.Lines = append(f.Lines, l)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.query(ctx, `select id, stop_id, name from favorite_stops where user_id = $1 order by id`, userID,
+		func(rows *sql.Rows) error {
+			var st FavoriteStop
+			if err := rows.Scan(&st.ID, &st.StopID, &st.Name); err != nil {
+				return err
+			}
+			f.Stops = append(f.Stops, st)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.query(ctx, `select id, address, latitude, longitude from favorite_addresses where user_id = $1 order by id`, userID,
+		func(rows *sql.Rows) error {
+			var a FavoriteAddress
+			if err := rows.Scan(&a.ID, &a.Address, &a.Latitude, &a.Longitude); err != nil {
+				return err
+			}
+			f.Addresses = append(f.Addresses, a)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// AddFavoriteLine saves a line.
+func (s *PostgresStore) AddFavoriteLine(ctx context.Context, userID int64, line string) error {
+	_, err := s.db.ExecContext(ctx, `insert into favorite_lines (user_id, line, created_at, updated_at)
+		values ($1, $2, current_timestamp, current_timestamp) on conflict (user_id, line) do nothing`, userID, line)
+	return err
+}
+
+// AddFavoriteStop saves a stop.
+func (s *PostgresStore) AddFavoriteStop(ctx context.Context, userID int64, stop FavoriteStop) error {
+	_, err := s.db.ExecContext(ctx, `insert into favorite_stops (user_id, stop_id, name, created_at, updated_at)
+		values ($1, $2, $3, current_timestamp, current_timestamp) on conflict (user_id, stop_id) do nothing`,
+		userID, stop.StopID, stop.Name)
+	return err
+}
+
+// AddFavoriteAddress saves a parking address.
+func (s *PostgresStore) AddFavoriteAddress(ctx context.Context, userID int64, address FavoriteAddress) error {
+	_, err := s.db.ExecContext(ctx, `insert into favorite_addresses (user_id, address, latitude, longitude, created_at, updated_at)
+		values ($1, $2, $3, $4, current_timestamp, current_timestamp)`,
+		userID, address.Address, address.Latitude, address.Longitude)
+	return err
+}
+
+// RemoveFavorite deletes a favorite of the user.
+func (s *PostgresStore) RemoveFavorite(ctx context.Context, userID int64, kind Kind, id int64) error {
+	var table string
+	switch kind {
+	case KindLine:
+		table = "favorite_lines"
+	case KindStop:
+		table = "favorite_stops"
+	case KindAddress:
+		table = "favorite_addresses"
+	default:
+		return fmt.Errorf("invalid favorite kind %q", kind)
+	}
+
+	result, err := s.db.ExecContext(ctx, `delete from `+table+` where id = $1 and user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// query calls fn for every row of the query.
+func (s *PostgresStore) query(ctx context.Context, query string, arg any, fn func(*sql.Rows) error) error {
+	rows, err := s.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,64 @@
+package accounts
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mayloo89/bamos/internal/driver"
+	"github.com/mayloo89/bamos/internal/i18n"
+)
+
+// Test_PostgresStore needs a database migrated with the migrations directory.
+func Test_PostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	db, err := driver.ConnectSQL(dsn)
+	required.NoError(err)
+	t.Cleanup(func() { _ = db.SQL.Close() })
+
+	email := "test-" + time.Now().Format("20060102150405.000000000") + "@example.com"
+	t.Cleanup(func() {
+		_, _ = db.SQL.Exec(`delete from users where email = $1`, email)
+	})
+
+	a := New(NewPostgresStore(db.SQL))
+	a.Cost = bcrypt.MinCost
+	user, err := a.Register(ctx, email, "Test", "colectivo60", i18n.Spanish)
+	required.NoError(err)
+	_, err = a.Register(ctx, email, "Test", "colectivo60", i18n.English)
+	assert.ErrorIs(err, ErrEmailTaken)
+
+	found, err := a.Authenticate(ctx, email, "colectivo60")
+	required.NoError(err)
+	assert.Equal(user.ID, found.ID)
+	found, err = a.UserByID(ctx, user.ID)
+	required.NoError(err)
+	assert.Equal(i18n.Spanish, found.Locale)
+
+	required.NoError(a.AddFavoriteLine(ctx, user.ID, "60"))
+	required.NoError(a.AddFavoriteLine(ctx, user.ID, "60"))
+	required.NoError(a.AddFavoriteStop(ctx, user.ID, FavoriteStop{StopID: "201001", Name: "Home"}))
+	required.NoError(a.AddFavoriteAddress(ctx, user.ID, FavoriteAddress{Address: "Av. Corrientes 1234", Latitude: -34.6037, Longitude: -58.3816}))
+
+	f, err := a.Favorites(ctx, user.ID)
+	required.NoError(err)
+	required.Len(f.Lines, 1)
+	required.Len(f.Stops, 1)
+	required.Len(f.Addresses, 1)
+
+	required.NoError(a.RemoveFavorite(ctx, user.ID, KindStop, f.Stops[0].ID))
+	assert.ErrorIs(a.RemoveFavorite(ctx, user.ID, KindStop, f.Stops[0].ID), ErrNotFound)
+}
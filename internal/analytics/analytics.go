@@ -0,0 +1,301 @@
+// Package analytics computes headway and on-time performance metrics per line
+// from the recorded realtime feeds.
+//
+// Headways are the time between consecutive trips of a route arriving at the
+// same stop, taken from the last trip update prediction seen shortly before the
+// arrival. An arrival is on time when its delay is within EarlyTolerance and
+// LateTolerance.
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/history"
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+const (
+	// EarlyTolerance is how early an arrival can be and still be on time.
+	EarlyTolerance = time.Minute
+	// LateTolerance is how late an arrival can be and still be on time.
+	LateTolerance = 5 * time.Minute
+	// ObservationWindow is how close to the arrival the last prediction must be
+	// seen for the arrival to be counted.
+	ObservationWindow = 2 * time.Minute
+	// MaxHeadway is the longest headway counted, longer gaps are service breaks.
+	MaxHeadway = 2 * time.Hour
+	// tripGap separates two runs of the same trip ID, on different days.
+	tripGap = 6 * time.Hour
+)
+
+// Location is the time zone of the time of day buckets, Buenos Aires has no DST.
+var Location = time.FixedZone("ART", -3*60*60)
+
+// DefaultBuckets split the day around the morning and evening peaks.
+var DefaultBuckets = []Bucket{
+	{Name: "Night", From: 0, To: 6},
+	{Name: "Morning peak", From: 6, To: 10},
+	{Name: "Midday", From: 10, To: 16},
+	{Name: "Evening peak", From: 16, To: 20},
+	{Name: "Evening", From: 20, To: 24},
+}
+
+type (
+	// Bucket is a time of day range, from hour From to hour To excluded.
+	Bucket struct {
+		Name string `json:"name"`
+		From int    `json:"from"`
+		To   int    `json:"to"`
+	}
+
+	// Metrics are the performance metrics of a route within a time of day bucket.
+	Metrics struct {
+		RouteID         string  `json:"route_id"`
+		Bucket          string  `json:"bucket"`
+		Headways        int     `json:"headways"`         // Number of headways measured
+		AvgHeadway      float64 `json:"avg_headway"`      // Seconds
+		HeadwayVariance float64 `json:"headway_variance"` // Seconds squared
+		HeadwayCV       float64 `json:"headway_cv"`       // Coefficient of variation, higher means more bunching
+		Arrivals        int     `json:"arrivals"`
+		OnTime          int     `json:"on_time"`
+		OnTimePercent   float64 `json:"on_time_percent"`
+		Vehicles        int     `json:"vehicles"` // Distinct vehicles seen
+	}
+
+	// Analyzer accumulates history records and computes their metrics.
+	Analyzer struct {
+		buckets  []Bucket
+		current  map[arrivalKey]observation // Latest prediction of every trip stop
+		done     []observation              // Predictions of past runs of a trip
+		vehicles map[vehicleKey]struct{}
+	}
+
+	arrivalKey struct {
+		tripID string
+		stopID string
+	}
+
+	observation struct {
+		routeID   string
+		stopID    string
+		time      time.Time
+		delay     int32
+		fetchedAt time.Time
+	}
+
+	vehicleKey struct {
+		routeID   string
+		bucket    int
+		vehicleID string
+	}
+
+	// accumulator sums the values of a route bucket.
+	accumulator struct {
+		headways       int
+		sum, sumSquare float64
+		arrivals       int
+		onTime         int
+		vehicles       int
+	}
+
+	stopKey struct {
+		routeID string
+		stopID  string
+	}
+
+	bucketKey struct {
+		routeID string
+		bucket  int
+	}
+)
+
+// NewAnalyzer creates an Analyzer grouping metrics by the given buckets,
+// DefaultBuckets when none are given.
+func NewAnalyzer(buckets ...Bucket) *Analyzer {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return &Analyzer{
+		buckets:  buckets,
+		current:  map[arrivalKey]observation{},
+		vehicles: map[vehicleKey]struct{}{},
+	}
+}
+
+// Add accumulates a record. Records must be added in fetch order.
+func (a *Analyzer) Add(record history.Record) {
+	switch record.Feed {
+	case services.FeedVehiclePositions:
+		for _, v := range record.Vehicles {
+			a.addVehicle(v, record.FetchedAt)
+		}
+	case services.FeedTripUpdates:
+		for _, arrival := range record.Arrivals {
+			a.addArrival(arrival, record.FetchedAt)
+		}
+	}
+}
+
+func (a *Analyzer) addVehicle(v realtime.Vehicle, fetchedAt time.Time) {
+	seen := v.Timestamp
+	if seen.IsZero() {
+		seen = fetchedAt
+	}
+	if bucket := a.bucket(seen); bucket >= 0 {
+		a.vehicles[vehicleKey{routeID: v.RouteID, bucket: bucket, vehicleID: v.ID}] = struct{}{}
+	}
+}
+
+func (a *Analyzer) addArrival(arrival realtime.Arrival, fetchedAt time.Time) {
+	if arrival.Time.IsZero() {
+		return
+	}
+
+	key := arrivalKey{tripID: arrival.TripID, stopID: arrival.StopID}
+	if previous, ok := a.current[key]; ok && absDuration(arrival.Time.Sub(previous.time)) > tripGap {
+		a.done = append(a.done, previous)
+	}
+	a.current[key] = observation{
+		routeID:   arrival.RouteID,
+		stopID:    arrival.StopID,
+		time:      arrival.Time,
+		delay:     arrival.Delay,
+		fetchedAt: fetchedAt,
+	}
+}
+
+// Metrics returns the metrics of every route and bucket with data, ordered by
+// route and bucket.
+func (a *Analyzer) Metrics() []Metrics {
+	acc := map[bucketKey]*accumulator{}
+	get := func(routeID string, bucket int) *accumulator {
+		key := bucketKey{routeID: routeID, bucket: bucket}
+		if acc[key] == nil {
+			acc[key] = &accumulator{}
+		}
+		return acc[key]
+	}
+
+	// arrival times by route and stop, to measure headways
+	stops := map[stopKey][]time.Time{}
+	observations := append([]observation{}, a.done...)
+	for _, o := range a.current {
+		observations = append(observations, o)
+	}
+	for _, o := range observations {
+		if o.fetchedAt.Before(o.time.Add(-ObservationWindow)) {
+			// the trip was not seen close enough to the stop
+			continue
+		}
+		bucket := a.bucket(o.time)
+		if bucket < 0 {
+			continue
+		}
+
+		b := get(o.routeID, bucket)
+		b.arrivals++
+		if onTime(o.delay) {
+			b.onTime++
+		}
+
+		key := stopKey{routeID: o.routeID, stopID: o.stopID}
+		stops[key] = append(stops[key], o.time)
+	}
+
+	for key, times := range stops {
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+		for i := 1; i < len(times); i++ {
+			headway := times[i].Sub(times[i-1])
+			if headway <= 0 || headway > MaxHeadway {
+				continue
+			}
+			bucket := a.bucket(times[i])
+			if bucket < 0 {
+				continue
+			}
+			b := get(key.routeID, bucket)
+			b.headways++
+			b.sum += headway.Seconds()
+			b.sumSquare += headway.Seconds() * headway.Seconds()
+		}
+	}
+
+	for key := range a.vehicles {
+		get(key.routeID, key.bucket).vehicles++
+	}
+
+	metrics := make([]Metrics, 0, len(acc))
+	for key, b := range acc {
+		metrics = append(metrics, b.metrics(key.routeID, a.buckets[key.bucket].Name))
+	}
+	order := map[string]int{}
+	for i, bucket := range a.buckets {
+		order[bucket.Name] = i
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].RouteID != metrics[j].RouteID {
+			return metrics[i].RouteID < metrics[j].RouteID
+		}
+		return order[metrics[i].Bucket] < order[metrics[j].Bucket]
+	})
+
+	return metrics
+}
+
+// bucket returns the index of the bucket of t, -1 when no bucket contains it.
+func (a *Analyzer) bucket(t time.Time) int {
+	hour := t.In(Location).Hour()
+	for i, bucket := range a.buckets {
+		if hour >= bucket.From && hour < bucket.To {
+			return i
+		}
+	}
+	return -1
+}
+
+func (b *accumulator) metrics(routeID, bucket string) Metrics {
+	m := Metrics{
+		RouteID:  routeID,
+		Bucket:   bucket,
+		Headways: b.headways,
+		Arrivals: b.arrivals,
+		OnTime:   b.onTime,
+		Vehicles: b.vehicles,
+	}
+	if b.headways > 0 {
+		n := float64(b.headways)
+		m.AvgHeadway = b.sum / n
+		m.HeadwayVariance = math.Max(0, b.sumSquare/n-m.AvgHeadway*m.AvgHeadway)
+		m.HeadwayCV = math.Sqrt(m.HeadwayVariance) / m.AvgHeadway
+	}
+	if b.arrivals > 0 {
+		m.OnTimePercent = 100 * float64(b.onTime) / float64(b.arrivals)
+	}
+	return m
+}
+
+// AvgHeadwayMinutes returns the average headway in minutes.
+func (m Metrics) AvgHeadwayMinutes() float64 {
+	return m.AvgHeadway / 60
+}
+
+// HeadwayStdDevMinutes returns the standard deviation of the headways in minutes.
+func (m Metrics) HeadwayStdDevMinutes() float64 {
+	return math.Sqrt(m.HeadwayVariance) / 60
+}
+
+// onTime reports whether an arrival with the given delay in seconds is on time.
+func onTime(delay int32) bool {
+	d := time.Duration(delay) * time.Second
+	return d >= -EarlyTolerance && d <= LateTolerance
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,92 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/history"
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+func Test_Analyzer_Metrics(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	// 08:00 in Buenos Aires, within the morning peak
+	morning := time.Date(2026, 10, 19, 8, 0, 0, 0, Location)
+
+	analyzer := NewAnalyzer()
+	// arrivals at stop-1 at 08:00, 08:10 and 08:30, the last one 7 minutes late
+	for i, arrival := range []struct {
+		trip  string
+		at    time.Duration
+		delay int32
+	}{
+		{"trip-1", 0, 0},
+		{"trip-2", 10 * time.Minute, 60},
+		{"trip-3", 30 * time.Minute, 420},
+	} {
+		at := morning.Add(arrival.at)
+		// an early prediction, replaced by the one seen right before the arrival
+		analyzer.Add(tripUpdates(at.Add(-20*time.Minute), realtime.Arrival{
+			TripID: arrival.trip, RouteID: "1426", StopID: "stop-1", Time: at.Add(time.Minute), Delay: 999,
+		}))
+		analyzer.Add(tripUpdates(at.Add(-time.Minute), realtime.Arrival{
+			TripID: arrival.trip, RouteID: "1426", StopID: "stop-1", Time: at, Delay: arrival.delay,
+		}))
+		analyzer.Add(history.Record{Feed: services.FeedVehiclePositions, FetchedAt: at, Vehicles: []realtime.Vehicle{
+			{ID: "bus-" + string(rune('a'+i)), RouteID: "1426", Timestamp: at},
+		}})
+	}
+	// only predicted far ahead, never observed close to the stop
+	analyzer.Add(tripUpdates(morning, realtime.Arrival{
+		TripID: "trip-4", RouteID: "1426", StopID: "stop-1", Time: morning.Add(time.Hour),
+	}))
+
+	metrics := analyzer.Metrics()
+	required.Len(metrics, 1)
+
+	m := metrics[0]
+	assert.Equal("1426", m.RouteID)
+	assert.Equal("Morning peak", m.Bucket)
+	assert.Equal(3, m.Arrivals)
+	assert.Equal(2, m.OnTime)
+	assert.InDelta(66.67, m.OnTimePercent, 0.01)
+	assert.Equal(2, m.Headways)
+	// headways of 10 and 20 minutes
+	assert.InDelta(900, m.AvgHeadway, 0.001)
+	assert.InDelta(300*300, m.HeadwayVariance, 0.001)
+	assert.InDelta(1.0/3, m.HeadwayCV, 0.001)
+	assert.InDelta(15, m.AvgHeadwayMinutes(), 0.001)
+	assert.InDelta(5, m.HeadwayStdDevMinutes(), 0.001)
+	assert.Equal(3, m.Vehicles)
+}
+
+func Test_Analyzer_Buckets(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, Location)
+	analyzer := NewAnalyzer(Bucket{Name: "AM", From: 0, To: 12}, Bucket{Name: "PM", From: 12, To: 24})
+	for _, hour := range []int{9, 21} {
+		at := day.Add(time.Duration(hour) * time.Hour)
+		analyzer.Add(tripUpdates(at, realtime.Arrival{TripID: "trip", RouteID: "1426", StopID: "stop-1", Time: at}))
+	}
+
+	metrics := analyzer.Metrics()
+	required.Len(metrics, 2)
+	assert.Equal("AM", metrics[0].Bucket)
+	assert.Equal("PM", metrics[1].Bucket)
+	// the same trip ID twelve hours apart are two different runs, too far apart to be a headway
+	assert.Equal(1, metrics[0].Arrivals)
+	assert.Equal(1, metrics[1].Arrivals)
+	assert.Zero(metrics[1].Headways)
+}
+
+func tripUpdates(fetchedAt time.Time, arrivals ...realtime.Arrival) history.Record {
+	return history.Record{Feed: services.FeedTripUpdates, FetchedAt: fetchedAt, Arrivals: arrivals}
+}
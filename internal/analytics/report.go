@@ -0,0 +1,178 @@
+package analytics
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/history"
+	"github.com/mayloo89/bamos/internal/metrics"
+)
+
+// DefaultReportFile is where the analytics command writes the report by default.
+const DefaultReportFile = "data/analytics.json"
+
+// csvHeader is the header row of the CSV export.
+var csvHeader = []string{
+	"route_id", "bucket", "headways", "avg_headway_seconds", "headway_variance", "headway_cv",
+	"arrivals", "on_time", "on_time_percent", "vehicles",
+}
+
+type (
+	// Report is the result of a batch computation over a range of the history.
+	Report struct {
+		GeneratedAt time.Time `json:"generated_at"`
+		From        time.Time `json:"from"`
+		To          time.Time `json:"to"`
+		Buckets     []Bucket  `json:"buckets"`
+		Metrics     []Metrics `json:"metrics"`
+	}
+
+	// Reports provides the latest computed report.
+	Reports interface {
+		Report() (*Report, error)
+	}
+
+	// FileReports reads the report written by the analytics command, reloading it
+	// when the file changes.
+	FileReports struct {
+		Path string
+
+		mu      sync.Mutex
+		modTime time.Time
+		report  *Report
+	}
+)
+
+// Compute loads the records fetched within [from, to) from the store and computes
+// their metrics.
+func Compute(ctx context.Context, store history.Store, from, to time.Time, buckets ...Bucket) (*Report, error) {
+	analyzer := NewAnalyzer(buckets...)
+	err := store.Load(ctx, from, to, func(record history.Record) error {
+		analyzer.Add(record)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading history: %w", err)
+	}
+
+	return &Report{
+		GeneratedAt: time.Now(),
+		From:        from,
+		To:          to,
+		Buckets:     analyzer.buckets,
+		Metrics:     analyzer.Metrics(),
+	}, nil
+}
+
+// Line returns the metrics of a route.
+func (r *Report) Line(routeID string) []Metrics {
+	var metrics []Metrics
+	for _, m := range r.Metrics {
+		if m.RouteID == routeID {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+// WriteReport writes the report as JSON, replacing the file atomically so readers
+// never see a partial report.
+func WriteReport(path string, report *Report) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	err = json.NewEncoder(tmp).Encode(report)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadReport reads a report written by WriteReport.
+func ReadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("malformed analytics report %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+// Report returns the report in the file, os.ErrNotExist when it was not computed yet.
+func (f *FileReports) Report() (*Report, error) {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	hit := f.report != nil && info.ModTime().Equal(f.modTime)
+	metrics.CacheLookup("analytics_report", hit)
+	if !hit {
+		report, err := ReadReport(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		f.report, f.modTime = report, info.ModTime()
+	}
+
+	return f.report, nil
+}
+
+// WriteCSV writes the metrics as CSV, with a header row.
+func WriteCSV(w io.Writer, metrics []Metrics) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, m := range metrics {
+		err := cw.Write([]string{
+			m.RouteID,
+			m.Bucket,
+			strconv.Itoa(m.Headways),
+			formatFloat(m.AvgHeadway),
+			formatFloat(m.HeadwayVariance),
+			formatFloat(m.HeadwayCV),
+			strconv.Itoa(m.Arrivals),
+			strconv.Itoa(m.OnTime),
+			formatFloat(m.OnTimePercent),
+			strconv.Itoa(m.Vehicles),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/history"
+	"github.com/mayloo89/bamos/internal/metrics"
+	"github.com/mayloo89/bamos/internal/realtime"
+)
+
+func Test_Compute_WriteRead(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	store, err := history.NewFileStore(t.TempDir())
+	required.NoError(err)
+
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, Location)
+	for i, trip := range []string{"trip-1", "trip-2"} {
+		arrival := at.Add(time.Duration(i) * 12 * time.Minute)
+		required.NoError(store.Save(context.Background(), tripUpdates(arrival, realtime.Arrival{
+			TripID: trip, RouteID: "1426", StopID: "stop-1", Time: arrival,
+		})))
+	}
+
+	report, err := Compute(context.Background(), store, time.Time{}, time.Time{})
+	required.NoError(err)
+	required.Len(report.Line("1426"), 1)
+	assert.Empty(report.Line("1427"))
+	assert.InDelta(720, report.Line("1426")[0].AvgHeadway, 0.001)
+
+	path := filepath.Join(t.TempDir(), "reports", "analytics.json")
+	required.NoError(WriteReport(path, report))
+
+	reports := &FileReports{Path: path}
+	loaded, err := reports.Report()
+	required.NoError(err)
+	assert.Equal(report.Metrics, loaded.Metrics)
+	assert.Equal(DefaultBuckets, loaded.Buckets)
+
+	// the unchanged file is served from memory
+	hits := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("analytics_report", "hit"))
+	cached, err := reports.Report()
+	required.NoError(err)
+	assert.Same(loaded, cached)
+	assert.Equal(hits+1, testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("analytics_report", "hit")))
+}
+
+func Test_FileReports_NotComputed(t *testing.T) {
+	reports := &FileReports{Path: filepath.Join(t.TempDir(), "analytics.json")}
+
+	_, err := reports.Report()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_WriteCSV(t *testing.T) {
+	assert := assert.New(t)
+
+	var out strings.Builder
+	err := WriteCSV(&out, []Metrics{{
+		RouteID: "1426", Bucket: "Morning peak", Headways: 2, AvgHeadway: 900, HeadwayVariance: 90000,
+		HeadwayCV: 1.0 / 3, Arrivals: 3, OnTime: 2, OnTimePercent: 200.0 / 3, Vehicles: 3,
+	}})
+
+	assert.NoError(err)
+	assert.Equal(
+		"route_id,bucket,headways,avg_headway_seconds,headway_variance,headway_cv,arrivals,on_time,on_time_percent,vehicles\n"+
+			"1426,Morning peak,2,900.00,90000.00,0.33,3,2,66.67,3\n",
+		out.String())
+}
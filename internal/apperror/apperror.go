@@ -0,0 +1,84 @@
+// Package apperror defines the errors handlers return, carrying the HTTP status,
+// a machine readable code and the message shown to the user.
+package apperror
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error is an application error. Its Message is safe to show to users, while
+// the Cause is only logged.
+type Error struct {
+	Status  int    // HTTP status of the response
+	Code    string // machine readable code, e.g. "invalid_coordinates"
+	Message string // message shown to the user
+	Cause   error  // underlying error, optional
+}
+
+// New returns an Error with the given status, code and user message.
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Error returns the code and message, followed by the cause when there is one.
+func (e *Error) Error() string {
+	msg := e.Code + ": " + e.Message
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithCause returns a copy of the error caused by err.
+func (e *Error) WithCause(err error) *Error {
+	c := *e
+	c.Cause = err
+	return &c
+}
+
+// BadRequest returns a 400 error with the given code and user message.
+func BadRequest(code, message string) *Error {
+	return New(http.StatusBadRequest, code, message)
+}
+
+// NotFound returns the 404 error of a page that does not exist.
+func NotFound() *Error {
+	return New(http.StatusNotFound, "not_found", "The page you are looking for does not exist.")
+}
+
+// MethodNotAllowed returns the 405 error of a method a page does not support.
+func MethodNotAllowed() *Error {
+	return New(http.StatusMethodNotAllowed, "method_not_allowed", "This page does not support the request method.")
+}
+
+// Unavailable returns a 503 error with the given code and user message.
+func Unavailable(code, message string) *Error {
+	return New(http.StatusServiceUnavailable, code, message)
+}
+
+// Upstream returns the 502 error of a failed call to the upstream API.
+func Upstream(cause error) *Error {
+	return New(http.StatusBadGateway, "upstream_error",
+		"The transport service is not responding, please try again in a few minutes.").WithCause(cause)
+}
+
+// Internal returns the 500 error of an unexpected failure, hiding its cause from the user.
+func Internal(cause error) *Error {
+	return New(http.StatusInternalServerError, "internal_error",
+		"Something went wrong on our side, please try again later.").WithCause(cause)
+}
+
+// From returns the Error in the chain of err, or an internal error caused by err.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err)
+}
@@ -0,0 +1,46 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Error(t *testing.T) {
+	assert := assert.New(t)
+
+	cause := errors.New("strconv.ParseFloat: invalid syntax")
+	err := BadRequest("invalid_coordinates", "The location is not valid.").WithCause(cause)
+
+	assert.Equal(http.StatusBadRequest, err.Status)
+	assert.Equal("invalid_coordinates: The location is not valid.: strconv.ParseFloat: invalid syntax", err.Error())
+	assert.ErrorIs(err, cause)
+	assert.Equal("not_found: The page you are looking for does not exist.", NotFound().Error())
+}
+
+func Test_WithCause_Copies(t *testing.T) {
+	base := Unavailable("analytics_unavailable", "Analytics are not available.")
+	caused := base.WithCause(errors.New("boom"))
+
+	assert.Nil(t, base.Cause)
+	assert.NotNil(t, caused.Cause)
+}
+
+func Test_From(t *testing.T) {
+	assert := assert.New(t)
+
+	notFound := NotFound()
+	assert.Same(notFound, From(fmt.Errorf("rendering: %w", notFound)))
+
+	cause := errors.New("template not found")
+	internal := From(cause)
+	assert.Equal(http.StatusInternalServerError, internal.Status)
+	assert.Equal("internal_error", internal.Code)
+	assert.NotContains(internal.Message, "template", "causes are never shown to users")
+	assert.ErrorIs(internal, cause)
+
+	assert.Equal(http.StatusBadGateway, From(Upstream(cause)).Status)
+}
@@ -0,0 +1,83 @@
+// Package cache keeps the answers of the upstream lookups in memory for a while,
+// so repeated searches do not call the upstream services again.
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/metrics"
+)
+
+// Cache is a named in-memory cache of at most size values, each kept for ttl. Its
+// lookups are counted by name in the cache metrics. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	name string
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[K]entry[V]
+}
+
+// entry is a cached value with its expiration time.
+type entry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// New returns an empty Cache.
+func New[K comparable, V any](name string, ttl time.Duration, size int) *Cache[K, V] {
+	return &Cache[K, V]{name: name, ttl: ttl, size: size, now: time.Now, entries: make(map[K]entry[V])}
+}
+
+// Get returns the value of key, if it is cached and has not expired.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && !c.now().Before(e.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	metrics.CacheLookup(c.name, ok)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set caches the value of key for the ttl of the cache. A full cache drops its
+// expired values first, then the ones closest to expiring.
+func (c *Cache[K, V]) Set(key K, value V) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		c.evict(now)
+	}
+	c.entries[key] = entry[V]{value: value, expires: now.Add(c.ttl)}
+}
+
+// evict makes room for a value, dropping the expired values or, when there is
+// none, the one closest to expiring.
+func (c *Cache[K, V]) evict(now time.Time) {
+	var oldest K
+	var oldestExpires time.Time
+	for key, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestExpires.IsZero() || e.expires.Before(oldestExpires) {
+			oldest, oldestExpires = key, e.expires
+		}
+	}
+	if len(c.entries) >= c.size {
+		delete(c.entries, oldest)
+	}
+}
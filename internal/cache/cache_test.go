@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mayloo89/bamos/internal/metrics"
+)
+
+func Test_Cache(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	c := New[string, int]("test", time.Minute, 2)
+	c.now = func() time.Time { return now }
+	hits := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("test", "hit"))
+	misses := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("test", "miss"))
+
+	_, ok := c.Get("a")
+	assert.False(ok)
+	c.Set("a", 1)
+	v, ok := c.Get("a")
+	assert.True(ok)
+	assert.Equal(1, v)
+	assert.Equal(hits+1, testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("test", "hit")))
+	assert.Equal(misses+1, testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("test", "miss")))
+
+	// a full cache drops the value closest to expiring
+	now = now.Add(10 * time.Second)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	_, ok = c.Get("a")
+	assert.False(ok)
+	v, _ = c.Get("b")
+	assert.Equal(2, v)
+
+	// values expire after the ttl
+	now = now.Add(time.Minute)
+	_, ok = c.Get("b")
+	assert.False(ok)
+	_, ok = c.Get("c")
+	assert.False(ok)
+	assert.Empty(c.entries)
+}
@@ -2,21 +2,34 @@ package config
 
 import (
 	"html/template"
-	"log"
+	"io/fs"
+	"log/slog"
 
 	"github.com/alexedwards/scs/v2"
+
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/maps"
 	"github.com/mayloo89/bamos/utils"
 )
 
+// SessionUserIDKey is the session key of the ID of the logged in user, shared by
+// the helpers and the renderer.
+const SessionUserIDKey = "user_id"
+
 // AppConfig holds the application configurations
 type AppConfig struct {
 	UseCache      bool
 	TemplateCache map[string]*template.Template
+	Templates     fs.FS // Template directory overriding the embedded templates, optional
+	Static        fs.FS // Static assets directory overriding the embedded assets, optional
 	InProduction  bool
+	Map           maps.Provider // Map of the pages
+	Locale        i18n.Locale   // Locale of the requests not choosing a supported one
 	Session       *scs.SessionManager
-	InfoLog       *log.Logger
-	ErrorLog      *log.Logger
+	Logger        *slog.Logger
+	LogLevel      *slog.LevelVar // Level of Logger, changed at runtime
 	DataCache     struct {
 		Routes []utils.Route
+		Feed   utils.Feed // Stops and shapes of the static GTFS, empty when not loaded
 	}
 }
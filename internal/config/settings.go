@@ -0,0 +1,693 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/logging"
+	"github.com/mayloo89/bamos/internal/ratelimit"
+	"github.com/mayloo89/bamos/internal/secrets"
+	"github.com/mayloo89/bamos/internal/tracing"
+)
+
+const (
+	// EnvDevelopment is the environment of a local development server.
+	EnvDevelopment = "development"
+	// EnvProduction enables secure cookies and requires the API credentials, the
+	// template cache is enabled apart with templates.cache.
+	EnvProduction = "production"
+
+	// configEnv is the environment variable with the path of the configuration file.
+	configEnv = "BAMOS_CONFIG"
+)
+
+type (
+	// Settings are the validated settings of the application, see Load.
+	Settings struct {
+		Env       string           `yaml:"env"`
+		Port      int              `yaml:"port"`
+		AdminAddr string           `yaml:"admin_addr"` // Address of the admin endpoints, none when it is empty
+		Locale    string           `yaml:"locale"`     // Locale of the requests not choosing a supported one
+		Log       LogSettings      `yaml:"log"`
+		Server    ServerSettings   `yaml:"server"`
+		Session   SessionSettings  `yaml:"session"`
+		Accounts  AccountSettings  `yaml:"accounts"`
+		Reminders ReminderSettings `yaml:"reminders"`
+		Webhooks  WebhookSettings  `yaml:"webhooks"`
+		Templates TemplateSettings `yaml:"templates"`
+		Static    StaticSettings   `yaml:"static"`
+		API       APISettings      `yaml:"api"`
+		Map       MapSettings      `yaml:"map"`
+		Realtime  RealtimeSettings `yaml:"realtime"`
+		History   HistorySettings  `yaml:"history"`
+		Replay    ReplaySettings   `yaml:"replay"`
+		Data      DataSettings     `yaml:"data"`
+		Tracing   TracingSettings  `yaml:"tracing"`
+
+		// SecretsDir is a directory of secret files, such as /run/secrets, see LoadSecrets.
+		SecretsDir string `yaml:"secrets_dir"`
+	}
+
+	// LogSettings configure the application logs.
+	LogSettings struct {
+		Format string `yaml:"format"` // "text" or "json"
+		Level  string `yaml:"level"`  // "debug", "info", "warn" or "error"
+	}
+
+	// ServerSettings are the timeouts of the HTTP server and the proxies in front of it.
+	ServerSettings struct {
+		ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+		ReadTimeout       time.Duration `yaml:"read_timeout"`
+		WriteTimeout      time.Duration `yaml:"write_timeout"`
+		IdleTimeout       time.Duration `yaml:"idle_timeout"`
+		ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"` // Time given to in-flight requests on shutdown
+		TrustedProxies    string        `yaml:"trusted_proxies"`  // Comma separated addresses and CIDR prefixes of the load balancers
+	}
+
+	// SessionSettings configure the user sessions.
+	SessionSettings struct {
+		Lifetime    time.Duration `yaml:"lifetime"`
+		Store       string        `yaml:"store"` // "memory", "file" or "postgres"
+		Dir         string        `yaml:"dir"`
+		DatabaseURL string        `yaml:"database_url"` // history.database_url when empty
+	}
+
+	// AccountSettings configure the storage of the user accounts.
+	AccountSettings struct {
+		Store       string `yaml:"store"`        // "memory" or "postgres"
+		DatabaseURL string `yaml:"database_url"` // history.database_url when empty
+	}
+
+	// ReminderSettings configure the reminders sent before parking becomes forbidden
+	// where the users parked.
+	ReminderSettings struct {
+		Notifier     string        `yaml:"notifier"` // "log", "smtp" or "webhook"
+		Lead         time.Duration `yaml:"lead"`     // How long in advance the users are warned
+		Interval     time.Duration `yaml:"interval"`
+		SMTPAddr     string        `yaml:"smtp_addr"`
+		SMTPFrom     string        `yaml:"smtp_from"`
+		SMTPUsername string        `yaml:"smtp_username"`
+		SMTPPassword string        `yaml:"smtp_password"`
+		WebhookURL   string        `yaml:"webhook_url"`
+	}
+
+	// WebhookSettings configure the webhooks posting the service alerts and large
+	// delays of the lines and stops the users subscribed to. The subscriptions are
+	// stored in accounts.store.
+	WebhookSettings struct {
+		DelayThreshold time.Duration `yaml:"delay_threshold"` // Smallest delay posted
+		Attempts       int           `yaml:"attempts"`
+		Backoff        time.Duration `yaml:"backoff"` // Wait before the first retry, doubled at every retry
+		Timeout        time.Duration `yaml:"timeout"`
+	}
+
+	// TemplateSettings configure the template rendering.
+	TemplateSettings struct {
+		Cache bool   `yaml:"cache"` // Parse the templates once at startup, otherwise when they change
+		Dir   string `yaml:"dir"`   // Directory overriding the embedded templates, for development
+	}
+
+	// StaticSettings configure the static assets served under /static/.
+	StaticSettings struct {
+		Dir string `yaml:"dir"` // Directory overriding the embedded assets, for development
+	}
+
+	// APISettings configure the upstream APIs.
+	APISettings struct {
+		BaseURL          string        `yaml:"base_url"`
+		ClientID         string        `yaml:"client_id"`
+		ClientSecret     string        `yaml:"client_secret"`
+		Timeout          time.Duration `yaml:"timeout"`
+		GoogleMapsAPIKey string        `yaml:"google_maps_api_key"`
+
+		ParkingRate     int           `yaml:"parking_rate"`      // Parking rules lookups per minute of each client of the GeoJSON export
+		ParkingCacheTTL time.Duration `yaml:"parking_cache_ttl"` // How long the parking rules of a location are kept
+	}
+
+	// MapSettings configure the map of the pages and the geocoding of its searches.
+	MapSettings struct {
+		Provider     string `yaml:"provider"` // google, osm or tiles; google with a Google Maps key and osm otherwise when empty
+		TileURL      string `yaml:"tile_url"` // Tiles of the tiles provider, with {z}, {x} and {y}
+		Attribution  string `yaml:"attribution"`
+		Geocoder     string `yaml:"geocoder"`      // google or nominatim; google with a Google Maps key and nominatim otherwise when empty
+		NominatimURL string `yaml:"nominatim_url"` // the public server when empty, required in production
+
+		GeocodeRate     int           `yaml:"geocode_rate"`      // Address searches per minute of each client
+		GeocodeCacheTTL time.Duration `yaml:"geocode_cache_ttl"` // How long the places of a search are kept
+	}
+
+	// RealtimeSettings are the polling intervals of the realtime feeds.
+	RealtimeSettings struct {
+		VehiclePositionsInterval time.Duration `yaml:"vehicle_positions_interval"`
+		TripUpdatesInterval      time.Duration `yaml:"trip_updates_interval"`
+		ServiceAlertsInterval    time.Duration `yaml:"service_alerts_interval"`
+	}
+
+	// HistorySettings configure the recording of the realtime feeds.
+	HistorySettings struct {
+		Store       string `yaml:"store"` // "file", "postgres" or empty to disable recording
+		Dir         string `yaml:"dir"`
+		DatabaseURL string `yaml:"database_url"`
+	}
+
+	// ReplaySettings configure the replay of the recorded feeds, disabled when Speed is zero.
+	ReplaySettings struct {
+		Speed float64   `yaml:"speed"`
+		From  time.Time `yaml:"from"`
+		To    time.Time `yaml:"to"`
+	}
+
+	// DataSettings are the paths of the data files.
+	DataSettings struct {
+		RoutesFile    string `yaml:"routes_file"`
+		AnalyticsFile string `yaml:"analytics_file"`
+		StreetsFile   string `yaml:"streets_file"` // reverse geocoding is disabled when it is empty or missing
+		GTFSDir       string `yaml:"gtfs_dir"`     // stops and shapes of the GeoJSON export, none when it is empty or missing
+	}
+
+	// TracingSettings configure the export of traces to an OpenTelemetry collector.
+	TracingSettings struct {
+		Endpoint    string `yaml:"endpoint"` // OTLP/HTTP base URL, tracing is disabled when empty
+		ServiceName string `yaml:"service_name"`
+		Headers     string `yaml:"headers"` // key=value pairs sent to the collector, comma separated
+	}
+
+	// field binds a setting to its environment variable and command line flag.
+	field struct {
+		key    string // Key in the configuration file, dashed for the flag name
+		env    string
+		secret bool // Secrets have no flag, command lines are visible to other users
+		bool   bool
+		set    func(string) error
+	}
+)
+
+// Default returns the settings used when nothing else is configured.
+func Default() *Settings {
+	return &Settings{
+		Env:       EnvDevelopment,
+		Port:      8080,
+		AdminAddr: "localhost:9090",
+		Locale:    string(i18n.English),
+		Log:       LogSettings{Format: logging.FormatText, Level: "info"},
+		Server: ServerSettings{
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Session:  SessionSettings{Lifetime: 24 * time.Hour, Store: "memory", Dir: "data/sessions"},
+		Accounts: AccountSettings{Store: "memory"},
+		Reminders: ReminderSettings{
+			Notifier: "log",
+			Lead:     30 * time.Minute,
+			Interval: time.Minute,
+			SMTPAddr: "localhost:1025",
+			SMTPFrom: "bamos@localhost",
+		},
+		Webhooks: WebhookSettings{
+			DelayThreshold: 10 * time.Minute,
+			Attempts:       4,
+			Backoff:        time.Second,
+			Timeout:        10 * time.Second,
+		},
+		API: APISettings{
+			BaseURL:         "https://apitransporte.buenosaires.gob.ar",
+			Timeout:         3 * time.Second,
+			ParkingRate:     30,
+			ParkingCacheTTL: time.Hour,
+		},
+		Map: MapSettings{GeocodeRate: 30, GeocodeCacheTTL: 24 * time.Hour},
+		Realtime: RealtimeSettings{
+			VehiclePositionsInterval: 30 * time.Second,
+			TripUpdatesInterval:      30 * time.Second,
+			ServiceAlertsInterval:    5 * time.Minute,
+		},
+		History: HistorySettings{Dir: "data/history"},
+		Data: DataSettings{
+			RoutesFile:    "static/routesinfo/routes.txt",
+			AnalyticsFile: "data/analytics.json",
+			StreetsFile:   "data/callejero.csv",
+			GTFSDir:       "data/gtfs",
+		},
+		Tracing: TracingSettings{ServiceName: "bamos"},
+	}
+}
+
+// Load reads the settings from, in increasing order of precedence, the defaults,
+// the YAML file given by the -config flag or BAMOS_CONFIG, the environment and
+// the command line flags, which are registered on flags and parsed from args.
+// The result is validated, every invalid value being reported at once.
+func Load(flags *flag.FlagSet, args []string, getenv func(string) string) (*Settings, error) {
+	s := Default()
+	fields := s.fields()
+
+	configFile := flags.String("config", "", "path of the YAML configuration file (env "+configEnv+")")
+	flagValues := map[string]string{}
+	for _, f := range fields {
+		if f.secret {
+			continue
+		}
+		name := flagName(f.key)
+		usage := fmt.Sprintf("%s (env %s)", f.key, f.env)
+		if f.bool {
+			flags.BoolFunc(name, usage, func(value string) error {
+				flagValues[f.key] = value
+				return nil
+			})
+		} else {
+			flags.Func(name, usage, func(value string) error {
+				flagValues[f.key] = value
+				return nil
+			})
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	path := *configFile
+	if path == "" {
+		path = getenv(configEnv)
+	}
+	if path != "" {
+		if err := s.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, f := range fields {
+		if value := getenv(f.env); value != "" {
+			if err := f.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s environment variable %q: %w", f.env, value, err))
+			}
+		}
+		if value, ok := flagValues[f.key]; ok {
+			if err := f.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid -%s flag %q: %w", flagName(f.key), value, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// LoadSecrets sets the secret settings found by the provider, named after their
+// environment variables, over the values of the configuration file and environment.
+func (s *Settings) LoadSecrets(provider secrets.Provider) error {
+	var errs []error
+	for _, f := range s.fields() {
+		if !f.secret {
+			continue
+		}
+		value, err := provider.Secret(f.env)
+		switch {
+		case errors.Is(err, secrets.ErrNotFound):
+		case err != nil:
+			errs = append(errs, err)
+		default:
+			_ = f.set(value)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MissingCredentials returns the names of the required upstream API credentials
+// that are not set.
+func (s *Settings) MissingCredentials() []string {
+	var missing []string
+	if s.API.ClientID == "" {
+		missing = append(missing, "CABA_CLIENT_ID")
+	}
+	if s.API.ClientSecret == "" {
+		missing = append(missing, "CABA_CLIENT_SECRET")
+	}
+	return missing
+}
+
+// SessionDatabaseURL returns the database of the postgres session store, the history
+// database unless one is set for the sessions.
+func (s *Settings) SessionDatabaseURL() string {
+	if s.Session.DatabaseURL != "" {
+		return s.Session.DatabaseURL
+	}
+	return s.History.DatabaseURL
+}
+
+// AccountsDatabaseURL returns the database of the postgres accounts store, the
+// history database unless one is set for the accounts.
+func (s *Settings) AccountsDatabaseURL() string {
+	if s.Accounts.DatabaseURL != "" {
+		return s.Accounts.DatabaseURL
+	}
+	return s.History.DatabaseURL
+}
+
+// InProduction reports whether the application runs in production.
+func (s *Settings) InProduction() bool {
+	return s.Env == EnvProduction
+}
+
+// DefaultLocale returns the locale of the requests not choosing a supported one.
+func (s *Settings) DefaultLocale() i18n.Locale {
+	l, _ := i18n.Parse(s.Locale)
+	return l
+}
+
+// MapProvider returns the provider of the map, Google when there is a Google Maps
+// key and OpenStreetMap otherwise, unless one is set.
+func (s *Settings) MapProvider() string {
+	switch {
+	case s.Map.Provider != "":
+		return s.Map.Provider
+	case s.API.GoogleMapsAPIKey != "":
+		return "google"
+	default:
+		return "osm"
+	}
+}
+
+// MapGeocoder returns the geocoder of the map searches, Google when there is a
+// Google Maps key and Nominatim otherwise, unless one is set.
+func (s *Settings) MapGeocoder() string {
+	switch {
+	case s.Map.Geocoder != "":
+		return s.Map.Geocoder
+	case s.API.GoogleMapsAPIKey != "":
+		return "google"
+	default:
+		return "nominatim"
+	}
+}
+
+// TrustedProxies returns the proxies whose X-Forwarded-For header names the
+// clients, none when the clients connect directly.
+func (s *Settings) TrustedProxies() ([]netip.Prefix, error) {
+	return ratelimit.ParsePrefixes(s.Server.TrustedProxies)
+}
+
+// Addr returns the address the HTTP server listens on.
+func (s *Settings) Addr() string {
+	return ":" + strconv.Itoa(s.Port)
+}
+
+// Validate checks every setting, returning all the invalid ones.
+func (s *Settings) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	positive := func(key string, d time.Duration) {
+		check(d > 0, "%s must be a positive duration, got %s", key, d)
+	}
+
+	check(s.Env == EnvDevelopment || s.Env == EnvProduction,
+		"env must be %s or %s, got %q", EnvDevelopment, EnvProduction, s.Env)
+	check(s.Port > 0 && s.Port <= 65535, "port must be between 1 and 65535, got %d", s.Port)
+	if s.AdminAddr != "" {
+		_, _, err := net.SplitHostPort(s.AdminAddr)
+		check(err == nil, "admin_addr must be a host and port, e.g. localhost:9090, got %q", s.AdminAddr)
+	}
+	_, ok := i18n.Parse(s.Locale)
+	check(ok, "locale must be es-AR or en, got %q", s.Locale)
+	check(s.Log.Format == logging.FormatText || s.Log.Format == logging.FormatJSON,
+		"log.format must be %s or %s, got %q", logging.FormatText, logging.FormatJSON, s.Log.Format)
+	_, err := logging.ParseLevel(s.Log.Level)
+	check(err == nil, "log.level must be debug, info, warn or error, got %q", s.Log.Level)
+	positive("server.read_header_timeout", s.Server.ReadHeaderTimeout)
+	positive("server.read_timeout", s.Server.ReadTimeout)
+	positive("server.write_timeout", s.Server.WriteTimeout)
+	positive("server.idle_timeout", s.Server.IdleTimeout)
+	positive("server.shutdown_timeout", s.Server.ShutdownTimeout)
+	_, err = s.TrustedProxies()
+	check(err == nil, "server.trusted_proxies must be addresses or CIDR prefixes separated by commas, got %q", s.Server.TrustedProxies)
+	positive("session.lifetime", s.Session.Lifetime)
+	switch s.Session.Store {
+	case "memory":
+	case "file":
+		check(s.Session.Dir != "", "session.dir is required by the file session store")
+	case "postgres":
+		check(s.SessionDatabaseURL() != "", "session.database_url or history.database_url is required by the postgres session store")
+	default:
+		errs = append(errs, fmt.Errorf("session.store must be memory, file or postgres, got %q", s.Session.Store))
+	}
+	switch s.Accounts.Store {
+	case "memory":
+	case "postgres":
+		check(s.AccountsDatabaseURL() != "", "accounts.database_url or history.database_url is required by the postgres accounts store")
+	default:
+		errs = append(errs, fmt.Errorf("accounts.store must be memory or postgres, got %q", s.Accounts.Store))
+	}
+	switch s.Reminders.Notifier {
+	case "log":
+	case "smtp":
+		check(s.Reminders.SMTPAddr != "", "reminders.smtp_addr is required by the smtp notifier")
+		check(s.Reminders.SMTPFrom != "", "reminders.smtp_from is required by the smtp notifier")
+	case "webhook":
+		check(strings.HasPrefix(s.Reminders.WebhookURL, "https://") || strings.HasPrefix(s.Reminders.WebhookURL, "http://"),
+			"reminders.webhook_url must be an http or https URL, got %q", s.Reminders.WebhookURL)
+	default:
+		errs = append(errs, fmt.Errorf("reminders.notifier must be log, smtp or webhook, got %q", s.Reminders.Notifier))
+	}
+	positive("reminders.lead", s.Reminders.Lead)
+	positive("reminders.interval", s.Reminders.Interval)
+	positive("webhooks.delay_threshold", s.Webhooks.DelayThreshold)
+	check(s.Webhooks.Attempts > 0, "webhooks.attempts must be positive, got %d", s.Webhooks.Attempts)
+	positive("webhooks.backoff", s.Webhooks.Backoff)
+	positive("webhooks.timeout", s.Webhooks.Timeout)
+	check(strings.HasPrefix(s.API.BaseURL, "https://") || strings.HasPrefix(s.API.BaseURL, "http://"),
+		"api.base_url must be an http or https URL, got %q", s.API.BaseURL)
+	positive("api.timeout", s.API.Timeout)
+	check(s.API.ParkingRate > 0, "api.parking_rate must be positive, got %d", s.API.ParkingRate)
+	positive("api.parking_cache_ttl", s.API.ParkingCacheTTL)
+	switch s.Map.Provider {
+	case "", "google", "osm":
+	case "tiles":
+		check(strings.HasPrefix(s.Map.TileURL, "https://") || strings.HasPrefix(s.Map.TileURL, "http://") || strings.HasPrefix(s.Map.TileURL, "/"),
+			"map.tile_url must be an http, https or relative URL with the tiles provider, got %q", s.Map.TileURL)
+	default:
+		errs = append(errs, fmt.Errorf("map.provider must be google, osm, tiles or empty, got %q", s.Map.Provider))
+	}
+	check(s.Map.Geocoder == "" || s.Map.Geocoder == "google" || s.Map.Geocoder == "nominatim",
+		"map.geocoder must be google, nominatim or empty, got %q", s.Map.Geocoder)
+	if s.Map.NominatimURL != "" {
+		check(strings.HasPrefix(s.Map.NominatimURL, "https://") || strings.HasPrefix(s.Map.NominatimURL, "http://"),
+			"map.nominatim_url must be an http or https URL, got %q", s.Map.NominatimURL)
+	} else if s.InProduction() && s.MapGeocoder() == "nominatim" {
+		// the public server only allows an occasional search, see its usage policy
+		errs = append(errs, errors.New("map.nominatim_url is required in production by the nominatim geocoder"))
+	}
+	check(s.Map.GeocodeRate > 0, "map.geocode_rate must be positive, got %d", s.Map.GeocodeRate)
+	positive("map.geocode_cache_ttl", s.Map.GeocodeCacheTTL)
+	positive("realtime.vehicle_positions_interval", s.Realtime.VehiclePositionsInterval)
+	positive("realtime.trip_updates_interval", s.Realtime.TripUpdatesInterval)
+	positive("realtime.service_alerts_interval", s.Realtime.ServiceAlertsInterval)
+
+	switch s.History.Store {
+	case "":
+	case "file":
+		check(s.History.Dir != "", "history.dir is required by the file history store")
+	case "postgres":
+		check(s.History.DatabaseURL != "", "history.database_url is required by the postgres history store")
+	default:
+		errs = append(errs, fmt.Errorf("history.store must be file, postgres or empty, got %q", s.History.Store))
+	}
+
+	check(s.Replay.Speed >= 0, "replay.speed must not be negative, got %g", s.Replay.Speed)
+	if s.Replay.Speed > 0 {
+		check(s.History.Store != "", "replay.speed requires history.store to be set")
+	}
+	if !s.Replay.From.IsZero() && !s.Replay.To.IsZero() {
+		check(s.Replay.From.Before(s.Replay.To), "replay.from must be before replay.to")
+	}
+
+	check(s.Data.RoutesFile != "", "data.routes_file is required")
+	check(s.Data.AnalyticsFile != "", "data.analytics_file is required")
+
+	if s.Tracing.Endpoint != "" {
+		check(strings.HasPrefix(s.Tracing.Endpoint, "https://") || strings.HasPrefix(s.Tracing.Endpoint, "http://"),
+			"tracing.endpoint must be an http or https URL, got %q", s.Tracing.Endpoint)
+	}
+	if _, err := tracing.ParseHeaders(s.Tracing.Headers); err != nil {
+		errs = append(errs, fmt.Errorf("tracing.headers: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// readFile merges the YAML configuration file into the settings. Unknown keys
+// are rejected to catch typos.
+func (s *Settings) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("can not read configuration file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// fields returns the settings that can be set from the environment and flags.
+func (s *Settings) fields() []field {
+	return []field{
+		{key: "env", env: "APP_ENV", set: stringVar(&s.Env)},
+		{key: "port", env: "PORT", set: intVar(&s.Port)},
+		{key: "admin_addr", env: "ADMIN_ADDR", set: stringVar(&s.AdminAddr)},
+		{key: "locale", env: "DEFAULT_LOCALE", set: stringVar(&s.Locale)},
+		{key: "log.format", env: "LOG_FORMAT", set: stringVar(&s.Log.Format)},
+		{key: "log.level", env: "LOG_LEVEL", set: stringVar(&s.Log.Level)},
+		{key: "server.read_header_timeout", env: "HTTP_READ_HEADER_TIMEOUT", set: durationVar(&s.Server.ReadHeaderTimeout)},
+		{key: "server.read_timeout", env: "HTTP_READ_TIMEOUT", set: durationVar(&s.Server.ReadTimeout)},
+		{key: "server.write_timeout", env: "HTTP_WRITE_TIMEOUT", set: durationVar(&s.Server.WriteTimeout)},
+		{key: "server.idle_timeout", env: "HTTP_IDLE_TIMEOUT", set: durationVar(&s.Server.IdleTimeout)},
+		{key: "server.shutdown_timeout", env: "SHUTDOWN_TIMEOUT", set: durationVar(&s.Server.ShutdownTimeout)},
+		{key: "server.trusted_proxies", env: "TRUSTED_PROXIES", set: stringVar(&s.Server.TrustedProxies)},
+		{key: "session.lifetime", env: "SESSION_LIFETIME", set: durationVar(&s.Session.Lifetime)},
+		{key: "session.store", env: "SESSION_STORE", set: stringVar(&s.Session.Store)},
+		{key: "session.dir", env: "SESSION_DIR", set: stringVar(&s.Session.Dir)},
+		{key: "session.database_url", env: "SESSION_DATABASE_URL", secret: true, set: stringVar(&s.Session.DatabaseURL)},
+		{key: "accounts.store", env: "ACCOUNTS_STORE", set: stringVar(&s.Accounts.Store)},
+		{key: "accounts.database_url", env: "ACCOUNTS_DATABASE_URL", secret: true, set: stringVar(&s.Accounts.DatabaseURL)},
+		{key: "reminders.notifier", env: "REMINDER_NOTIFIER", set: stringVar(&s.Reminders.Notifier)},
+		{key: "reminders.lead", env: "REMINDER_LEAD", set: durationVar(&s.Reminders.Lead)},
+		{key: "reminders.interval", env: "REMINDER_INTERVAL", set: durationVar(&s.Reminders.Interval)},
+		{key: "reminders.smtp_addr", env: "SMTP_ADDR", set: stringVar(&s.Reminders.SMTPAddr)},
+		{key: "reminders.smtp_from", env: "SMTP_FROM", set: stringVar(&s.Reminders.SMTPFrom)},
+		{key: "reminders.smtp_username", env: "SMTP_USERNAME", secret: true, set: stringVar(&s.Reminders.SMTPUsername)},
+		{key: "reminders.smtp_password", env: "SMTP_PASSWORD", secret: true, set: stringVar(&s.Reminders.SMTPPassword)},
+		{key: "reminders.webhook_url", env: "REMINDER_WEBHOOK_URL", secret: true, set: stringVar(&s.Reminders.WebhookURL)},
+		{key: "webhooks.delay_threshold", env: "WEBHOOK_DELAY_THRESHOLD", set: durationVar(&s.Webhooks.DelayThreshold)},
+		{key: "webhooks.attempts", env: "WEBHOOK_ATTEMPTS", set: intVar(&s.Webhooks.Attempts)},
+		{key: "webhooks.backoff", env: "WEBHOOK_BACKOFF", set: durationVar(&s.Webhooks.Backoff)},
+		{key: "webhooks.timeout", env: "WEBHOOK_TIMEOUT", set: durationVar(&s.Webhooks.Timeout)},
+		{key: "templates.cache", env: "TEMPLATE_CACHE", bool: true, set: boolVar(&s.Templates.Cache)},
+		{key: "templates.dir", env: "TEMPLATE_DIR", set: stringVar(&s.Templates.Dir)},
+		{key: "static.dir", env: "STATIC_DIR", set: stringVar(&s.Static.Dir)},
+		{key: "api.base_url", env: "CABA_API_URL", set: stringVar(&s.API.BaseURL)},
+		{key: "api.client_id", env: "CABA_CLIENT_ID", secret: true, set: stringVar(&s.API.ClientID)},
+		{key: "api.client_secret", env: "CABA_CLIENT_SECRET", secret: true, set: stringVar(&s.API.ClientSecret)},
+		{key: "api.timeout", env: "API_TIMEOUT", set: durationVar(&s.API.Timeout)},
+		{key: "api.google_maps_api_key", env: "GOOGLE_MAPS_API_KEY", secret: true, set: stringVar(&s.API.GoogleMapsAPIKey)},
+		{key: "api.parking_rate", env: "PARKING_RATE", set: intVar(&s.API.ParkingRate)},
+		{key: "api.parking_cache_ttl", env: "PARKING_CACHE_TTL", set: durationVar(&s.API.ParkingCacheTTL)},
+		{key: "map.provider", env: "MAP_PROVIDER", set: stringVar(&s.Map.Provider)},
+		{key: "map.tile_url", env: "MAP_TILE_URL", set: stringVar(&s.Map.TileURL)},
+		{key: "map.attribution", env: "MAP_ATTRIBUTION", set: stringVar(&s.Map.Attribution)},
+		{key: "map.geocoder", env: "MAP_GEOCODER", set: stringVar(&s.Map.Geocoder)},
+		{key: "map.nominatim_url", env: "NOMINATIM_URL", set: stringVar(&s.Map.NominatimURL)},
+		{key: "map.geocode_rate", env: "GEOCODE_RATE", set: intVar(&s.Map.GeocodeRate)},
+		{key: "map.geocode_cache_ttl", env: "GEOCODE_CACHE_TTL", set: durationVar(&s.Map.GeocodeCacheTTL)},
+		{key: "realtime.vehicle_positions_interval", env: "VEHICLE_POSITIONS_INTERVAL", set: durationVar(&s.Realtime.VehiclePositionsInterval)},
+		{key: "realtime.trip_updates_interval", env: "TRIP_UPDATES_INTERVAL", set: durationVar(&s.Realtime.TripUpdatesInterval)},
+		{key: "realtime.service_alerts_interval", env: "SERVICE_ALERTS_INTERVAL", set: durationVar(&s.Realtime.ServiceAlertsInterval)},
+		{key: "history.store", env: "HISTORY_STORE", set: stringVar(&s.History.Store)},
+		{key: "history.dir", env: "HISTORY_DIR", set: stringVar(&s.History.Dir)},
+		{key: "history.database_url", env: "DATABASE_URL", secret: true, set: stringVar(&s.History.DatabaseURL)},
+		{key: "replay.speed", env: "REPLAY_SPEED", set: floatVar(&s.Replay.Speed)},
+		{key: "replay.from", env: "REPLAY_FROM", set: timeVar(&s.Replay.From)},
+		{key: "replay.to", env: "REPLAY_TO", set: timeVar(&s.Replay.To)},
+		{key: "data.routes_file", env: "ROUTES_FILE", set: stringVar(&s.Data.RoutesFile)},
+		{key: "data.analytics_file", env: "ANALYTICS_FILE", set: stringVar(&s.Data.AnalyticsFile)},
+		{key: "data.streets_file", env: "STREETS_FILE", set: stringVar(&s.Data.StreetsFile)},
+		{key: "data.gtfs_dir", env: "GTFS_DIR", set: stringVar(&s.Data.GTFSDir)},
+		{key: "tracing.endpoint", env: "OTEL_EXPORTER_OTLP_ENDPOINT", set: stringVar(&s.Tracing.Endpoint)},
+		{key: "tracing.service_name", env: "OTEL_SERVICE_NAME", set: stringVar(&s.Tracing.ServiceName)},
+		{key: "tracing.headers", env: "OTEL_EXPORTER_OTLP_HEADERS", secret: true, set: stringVar(&s.Tracing.Headers)},
+		{key: "secrets_dir", env: "SECRETS_DIR", set: stringVar(&s.SecretsDir)},
+	}
+}
+
+// flagName returns the command line flag name of a configuration key.
+func flagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+func stringVar(p *string) func(string) error {
+	return func(value string) error {
+		*p = value
+		return nil
+	}
+}
+
+func intVar(p *int) func(string) error {
+	return func(value string) error {
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		*p = v
+		return nil
+	}
+}
+
+func floatVar(p *float64) func(string) error {
+	return func(value string) error {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		*p = v
+		return nil
+	}
+}
+
+func boolVar(p *bool) func(string) error {
+	return func(value string) error {
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		*p = v
+		return nil
+	}
+}
+
+func durationVar(p *time.Duration) func(string) error {
+	return func(value string) error {
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("must be a duration such as 30s or 5m")
+		}
+		*p = v
+		return nil
+	}
+}
+
+func timeVar(p *time.Time) func(string) error {
+	return func(value string) error {
+		v, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.New("must be an RFC3339 time such as 2026-10-19T08:00:00-03:00")
+		}
+		*p = v
+		return nil
+	}
+}
@@ -0,0 +1,427 @@
+package config
+
+import (
+	"flag"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/analytics"
+	"github.com/mayloo89/bamos/internal/history"
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/logging"
+	"github.com/mayloo89/bamos/internal/maps"
+	"github.com/mayloo89/bamos/internal/parking"
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/secrets"
+	"github.com/mayloo89/bamos/internal/services"
+	"github.com/mayloo89/bamos/internal/sessionstore"
+	"github.com/mayloo89/bamos/internal/tracing"
+	"github.com/mayloo89/bamos/internal/webhooks"
+)
+
+func Test_Load_Defaults(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(nil))
+	required.NoError(err)
+
+	assert.Equal(Default(), s)
+	assert.False(s.InProduction())
+	assert.Equal(":8080", s.Addr())
+}
+
+func Test_Default_Packages(t *testing.T) {
+	assert := assert.New(t)
+
+	// the defaults are written in the config, they must not drift from the ones
+	// the packages apply to their zero values
+	s := Default()
+	assert.Equal(sessionstore.DefaultDir, s.Session.Dir)
+	assert.Equal(parking.DefaultLead, s.Reminders.Lead)
+	assert.Equal(parking.DefaultInterval, s.Reminders.Interval)
+	assert.Equal(webhooks.DefaultDelayThreshold, s.Webhooks.DelayThreshold)
+	assert.Equal(webhooks.DefaultAttempts, s.Webhooks.Attempts)
+	assert.Equal(webhooks.DefaultBackoff, s.Webhooks.Backoff)
+	assert.Equal(webhooks.DefaultTimeout, s.Webhooks.Timeout)
+	assert.Equal(services.BaseURL, s.API.BaseURL)
+	assert.Equal(services.DefaultTimeout, s.API.Timeout)
+	assert.Equal(maps.NominatimURL, maps.NewNominatimGeocoder(s.Map.NominatimURL, 0).URL)
+	assert.Equal(realtime.DefaultVehiclePositionsInterval, s.Realtime.VehiclePositionsInterval)
+	assert.Equal(realtime.DefaultTripUpdatesInterval, s.Realtime.TripUpdatesInterval)
+	assert.Equal(realtime.DefaultServiceAlertsInterval, s.Realtime.ServiceAlertsInterval)
+	assert.Equal(history.DefaultDir, s.History.Dir)
+	assert.Equal(analytics.DefaultReportFile, s.Data.AnalyticsFile)
+	assert.Equal(tracing.DefaultServiceName, s.Tracing.ServiceName)
+	assert.Equal(logging.FormatText, s.Log.Format)
+
+	s.API.GoogleMapsAPIKey = "key"
+	assert.Equal(maps.Google, s.MapProvider())
+	assert.Equal(maps.GoogleGeocoding, s.MapGeocoder())
+}
+
+func Test_Load_Precedence(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "bamos.yaml")
+	required.NoError(os.WriteFile(path, []byte(`
+env: production
+port: 9000
+session:
+  lifetime: 1h
+templates:
+  cache: true
+api:
+  client_id: file-id
+  timeout: 5s
+map:
+  nominatim_url: https://nominatim.example.com
+realtime:
+  vehicle_positions_interval: 15s
+replay:
+  from: 2026-10-19T08:00:00-03:00
+`), 0o644))
+
+	env := testEnv(map[string]string{
+		"BAMOS_CONFIG":   path,
+		"PORT":           "9001",
+		"CABA_CLIENT_ID": "env-id",
+		"API_TIMEOUT":    "4s",
+	})
+	args := []string{"-port", "9002", "-templates-cache=false"}
+
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), args, env)
+	required.NoError(err)
+
+	// file
+	assert.True(s.InProduction())
+	assert.Equal(time.Hour, s.Session.Lifetime)
+	assert.Equal(15*time.Second, s.Realtime.VehiclePositionsInterval)
+	assert.True(s.Replay.From.Equal(time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC)))
+	// env over file
+	assert.Equal("env-id", s.API.ClientID)
+	assert.Equal(4*time.Second, s.API.Timeout)
+	// flags over env
+	assert.Equal(9002, s.Port)
+	assert.False(s.Templates.Cache)
+	// untouched defaults
+	assert.Equal(30*time.Second, s.Realtime.TripUpdatesInterval)
+}
+
+func Test_Load_ConfigFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bamos.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("port: 9000\n"), 0o644))
+
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path}, testEnv(nil))
+	require.NoError(t, err)
+	assert.Equal(t, 9000, s.Port)
+}
+
+func Test_Load_InvalidValues(t *testing.T) {
+	assert := assert.New(t)
+
+	env := testEnv(map[string]string{
+		"PORT":         "http",
+		"API_TIMEOUT":  "3",
+		"REPLAY_FROM":  "yesterday",
+		"CABA_API_URL": "https://example.com",
+	})
+
+	_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-template-cache"}, env)
+	assert.Error(err, "unknown flag")
+
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	// every invalid value is reported at once
+	assert.ErrorContains(err, `invalid PORT environment variable "http": must be an integer`)
+	assert.ErrorContains(err, `invalid API_TIMEOUT environment variable "3": must be a duration`)
+	assert.ErrorContains(err, `invalid REPLAY_FROM environment variable "yesterday": must be an RFC3339 time`)
+}
+
+func Test_Load_Validation(t *testing.T) {
+	assert := assert.New(t)
+
+	env := testEnv(map[string]string{
+		"APP_ENV":                     "staging",
+		"PORT":                        "70000",
+		"ADMIN_ADDR":                  "9090",
+		"VEHICLE_POSITIONS_INTERVAL":  "-1s",
+		"HISTORY_STORE":               "postgres",
+		"REPLAY_SPEED":                "2",
+		"LOG_FORMAT":                  "xml",
+		"LOG_LEVEL":                   "verbose",
+		"OTEL_EXPORTER_OTLP_ENDPOINT": "collector:4318",
+	})
+
+	_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	assert.ErrorContains(err, `env must be development or production, got "staging"`)
+	assert.ErrorContains(err, "port must be between 1 and 65535, got 70000")
+	assert.ErrorContains(err, `admin_addr must be a host and port, e.g. localhost:9090, got "9090"`)
+	assert.ErrorContains(err, "realtime.vehicle_positions_interval must be a positive duration, got -1s")
+	assert.ErrorContains(err, "history.database_url is required by the postgres history store")
+	assert.ErrorContains(err, `log.format must be text or json, got "xml"`)
+	assert.ErrorContains(err, `log.level must be debug, info, warn or error, got "verbose"`)
+	assert.ErrorContains(err, `tracing.endpoint must be an http or https URL, got "collector:4318"`)
+}
+
+func Test_Load_SessionStore(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(nil))
+	required.NoError(err)
+	assert.Equal("memory", s.Session.Store)
+
+	env := testEnv(map[string]string{"SESSION_STORE": "postgres", "DATABASE_URL": "postgres://localhost/bamos"})
+	s, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	required.NoError(err)
+	assert.Equal("postgres://localhost/bamos", s.SessionDatabaseURL(), "the history database is shared")
+
+	env = testEnv(map[string]string{"SESSION_STORE": "postgres"})
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	assert.ErrorContains(err, "session.database_url or history.database_url is required by the postgres session store")
+
+	env = testEnv(map[string]string{"SESSION_STORE": "redis"})
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	assert.ErrorContains(err, `session.store must be memory, file or postgres, got "redis"`)
+}
+
+func Test_Load_AccountsStore(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(nil))
+	required.NoError(err)
+	assert.Equal("memory", s.Accounts.Store)
+
+	env := testEnv(map[string]string{"ACCOUNTS_STORE": "postgres", "DATABASE_URL": "postgres://localhost/bamos"})
+	s, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	required.NoError(err)
+	assert.Equal("postgres", s.Accounts.Store)
+
+	assert.Equal("postgres://localhost/bamos", s.AccountsDatabaseURL())
+
+	// the accounts can have a database of their own
+	env = testEnv(map[string]string{"ACCOUNTS_STORE": "postgres", "DATABASE_URL": "postgres://localhost/bamos", "ACCOUNTS_DATABASE_URL": "postgres://localhost/accounts"})
+	s, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	required.NoError(err)
+	assert.Equal("postgres://localhost/accounts", s.AccountsDatabaseURL())
+
+	env = testEnv(map[string]string{"ACCOUNTS_STORE": "postgres"})
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	assert.ErrorContains(err, "accounts.database_url or history.database_url is required by the postgres accounts store")
+
+	env = testEnv(map[string]string{"ACCOUNTS_STORE": "file"})
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	assert.ErrorContains(err, `accounts.store must be memory or postgres, got "file"`)
+}
+
+func Test_Load_Reminders(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(nil))
+	required.NoError(err)
+	assert.Equal("log", s.Reminders.Notifier)
+	assert.Equal(30*time.Minute, s.Reminders.Lead)
+
+	env := testEnv(map[string]string{"REMINDER_NOTIFIER": "smtp", "SMTP_ADDR": "mail:1025", "REMINDER_LEAD": "1h"})
+	s, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	required.NoError(err)
+	assert.Equal("mail:1025", s.Reminders.SMTPAddr)
+	assert.Equal(time.Hour, s.Reminders.Lead)
+
+	env = testEnv(map[string]string{"REMINDER_NOTIFIER": "webhook"})
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	assert.ErrorContains(err, `reminders.webhook_url must be an http or https URL, got ""`)
+
+	env = testEnv(map[string]string{"REMINDER_NOTIFIER": "sms", "REMINDER_INTERVAL": "0s"})
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	assert.ErrorContains(err, `reminders.notifier must be log, smtp or webhook, got "sms"`)
+	assert.ErrorContains(err, "reminders.interval must be a positive duration")
+}
+
+func Test_Load_Webhooks(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(nil))
+	required.NoError(err)
+	assert.Equal(10*time.Minute, s.Webhooks.DelayThreshold)
+	assert.Equal(4, s.Webhooks.Attempts)
+
+	env := testEnv(map[string]string{"WEBHOOK_DELAY_THRESHOLD": "5m", "WEBHOOK_ATTEMPTS": "2"})
+	s, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	required.NoError(err)
+	assert.Equal(5*time.Minute, s.Webhooks.DelayThreshold)
+	assert.Equal(2, s.Webhooks.Attempts)
+
+	env = testEnv(map[string]string{"WEBHOOK_ATTEMPTS": "0", "WEBHOOK_BACKOFF": "-1s"})
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	assert.ErrorContains(err, "webhooks.attempts must be positive, got 0")
+	assert.ErrorContains(err, "webhooks.backoff must be a positive duration")
+}
+
+func Test_Load_Locale(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(nil))
+	required.NoError(err)
+	assert.Equal(i18n.English, s.DefaultLocale())
+
+	env := testEnv(map[string]string{"DEFAULT_LOCALE": "es-AR"})
+	s, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	required.NoError(err)
+	assert.Equal(i18n.Spanish, s.DefaultLocale())
+
+	env = testEnv(map[string]string{"DEFAULT_LOCALE": "pt"})
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	assert.ErrorContains(err, `locale must be es-AR or en, got "pt"`)
+}
+
+func Test_Load_ParkingLookups(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(nil))
+	required.NoError(err)
+	assert.Equal(30, s.API.ParkingRate)
+	assert.Equal(time.Hour, s.API.ParkingCacheTTL)
+
+	s, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(map[string]string{"PARKING_RATE": "5", "PARKING_CACHE_TTL": "10m"}))
+	required.NoError(err)
+	assert.Equal(5, s.API.ParkingRate)
+	assert.Equal(10*time.Minute, s.API.ParkingCacheTTL)
+
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(map[string]string{"PARKING_RATE": "-1", "PARKING_CACHE_TTL": "0s"}))
+	assert.ErrorContains(err, "api.parking_rate must be positive, got -1")
+	assert.ErrorContains(err, "api.parking_cache_ttl must be a positive duration, got 0s")
+}
+
+func Test_Load_TrustedProxies(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(nil))
+	required.NoError(err)
+	proxies, err := s.TrustedProxies()
+	required.NoError(err)
+	assert.Empty(proxies)
+
+	s, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8, 192.168.1.1"}))
+	required.NoError(err)
+	proxies, err = s.TrustedProxies()
+	required.NoError(err)
+	assert.Equal([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.1/32")}, proxies)
+
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(map[string]string{"TRUSTED_PROXIES": "load-balancer"}))
+	assert.ErrorContains(err, `server.trusted_proxies must be addresses or CIDR prefixes separated by commas, got "load-balancer"`)
+}
+
+func Test_Load_Map(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(nil))
+	required.NoError(err)
+	assert.Equal(maps.OpenStreetMap, s.MapProvider())
+	assert.Equal(maps.Nominatim, s.MapGeocoder())
+	assert.Empty(s.Map.NominatimURL, "the public server in development")
+	assert.Equal(30, s.Map.GeocodeRate)
+	assert.Equal(24*time.Hour, s.Map.GeocodeCacheTTL)
+
+	// production needs a Nominatim server of its own
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(map[string]string{"APP_ENV": "production"}))
+	assert.ErrorContains(err, "map.nominatim_url is required in production by the nominatim geocoder")
+	env := testEnv(map[string]string{"APP_ENV": "production", "NOMINATIM_URL": "https://nominatim.example.com", "GEOCODE_RATE": "10", "GEOCODE_CACHE_TTL": "1h"})
+	s, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	required.NoError(err)
+	assert.Equal("https://nominatim.example.com", s.Map.NominatimURL)
+	assert.Equal(10, s.Map.GeocodeRate)
+	assert.Equal(time.Hour, s.Map.GeocodeCacheTTL)
+
+	// the Google Maps API key chooses Google unless other provider is set
+	env = testEnv(map[string]string{"GOOGLE_MAPS_API_KEY": "key"})
+	s, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	required.NoError(err)
+	assert.Equal(maps.Google, s.MapProvider())
+	assert.Equal(maps.GoogleGeocoding, s.MapGeocoder())
+
+	env = testEnv(map[string]string{"GOOGLE_MAPS_API_KEY": "key", "MAP_PROVIDER": "tiles", "MAP_TILE_URL": "/tiles/{z}/{x}/{y}.png", "MAP_GEOCODER": "nominatim"})
+	s, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	required.NoError(err)
+	assert.Equal(maps.Tiles, s.MapProvider())
+	assert.Equal(maps.Nominatim, s.MapGeocoder())
+
+	for env, msg := range map[string]string{
+		"MAP_PROVIDER=bing":       `map.provider must be google, osm, tiles or empty, got "bing"`,
+		"MAP_PROVIDER=tiles":      `map.tile_url must be an http, https or relative URL with the tiles provider, got ""`,
+		"MAP_GEOCODER=photon":     `map.geocoder must be google, nominatim or empty, got "photon"`,
+		"NOMINATIM_URL=nominatim": `map.nominatim_url must be an http or https URL, got "nominatim"`,
+		"GEOCODE_RATE=0":          `map.geocode_rate must be positive, got 0`,
+		"GEOCODE_CACHE_TTL=0s":    `map.geocode_cache_ttl must be a positive duration, got 0s`,
+	} {
+		key, value, _ := strings.Cut(env, "=")
+		_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, testEnv(map[string]string{key: value}))
+		assert.ErrorContains(err, msg, env)
+	}
+}
+
+func Test_Load_InvalidFile(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "bamos.yaml")
+	assert.NoError(os.WriteFile(path, []byte("prot: 9000\n"), 0o644))
+
+	_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path}, testEnv(nil))
+	assert.ErrorContains(err, "field prot not found")
+
+	_, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path + ".missing"}, testEnv(nil))
+	assert.ErrorContains(err, "can not read configuration file")
+}
+
+func Test_Load_SecretsHaveNoFlags(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := Load(flags, nil, testEnv(nil))
+	require.NoError(t, err)
+
+	assert.NotNil(t, flags.Lookup("api-timeout"))
+	assert.Nil(t, flags.Lookup("api-client-secret"))
+	assert.Nil(t, flags.Lookup("history-database-url"))
+}
+
+func Test_LoadSecrets(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	dir := t.TempDir()
+	required.NoError(os.WriteFile(filepath.Join(dir, "caba_client_secret"), []byte("file-secret\n"), 0o600))
+
+	env := testEnv(map[string]string{"CABA_CLIENT_ID": "env-id", "SECRETS_DIR": dir})
+	s, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil, env)
+	required.NoError(err)
+	assert.Equal([]string{"CABA_CLIENT_SECRET"}, s.MissingCredentials())
+
+	required.NoError(s.LoadSecrets(secrets.Chain{secrets.Env(env), secrets.Dir(s.SecretsDir)}))
+	assert.Equal("env-id", s.API.ClientID)
+	assert.Equal("file-secret", s.API.ClientSecret)
+	assert.Empty(s.MissingCredentials())
+
+	err = s.LoadSecrets(secrets.Env(testEnv(map[string]string{"DATABASE_URL_FILE": filepath.Join(dir, "missing")})))
+	assert.ErrorContains(err, "can not read secret DATABASE_URL")
+}
+
+// testEnv returns a getenv function reading only the given variables.
+func testEnv(vars map[string]string) func(string) string {
+	return func(key string) string {
+		return vars[key]
+	}
+}
@@ -0,0 +1,49 @@
+// Package driver opens the database connection pool used by Bamos.
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	// registers the pgx driver for database/sql
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// DB holds the database connection pool
+type DB struct {
+	SQL *sql.DB
+}
+
+const (
+	maxOpenDbConn = 10
+	maxIdleDbConn = 5
+	maxDbLifetime = 5 * time.Minute
+	pingTimeout   = 5 * time.Second
+)
+
+// ConnectSQL creates a Postgres connection pool for the given DSN and checks it is reachable.
+func ConnectSQL(dsn string) (*DB, error) {
+	d, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetMaxOpenConns(maxOpenDbConn)
+	d.SetMaxIdleConns(maxIdleDbConn)
+	d.SetConnMaxLifetime(maxDbLifetime)
+
+	if err := testDB(d); err != nil {
+		_ = d.Close()
+		return nil, err
+	}
+
+	return &DB{SQL: d}, nil
+}
+
+// testDB pings the database
+func testDB(d *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	return d.PingContext(ctx)
+}
@@ -1,16 +1,36 @@
 package forms
 
-type errors map[string][]string
+import "github.com/mayloo89/bamos/internal/i18n"
 
-// Add adds an error message for a given form field
-func (e errors) Add(field, msg string) {
-	e[field] = append(e[field], msg)
+// Message is a form error: the catalog key of its text and the arguments of the
+// key, translated to the locale of the request when the page is rendered.
+type Message struct {
+	Key  string
+	Args []interface{}
 }
 
-// Get returns the first error message from a field
-func (e errors) Get(field string) string {
-	if errorMsg, exists := e[field]; exists {
-		return errorMsg[0]
+// T returns the text of the message in locale l.
+func (m Message) T(l i18n.Locale) string {
+	return l.T(m.Key, m.Args...)
+}
+
+// String returns the English text of the message, for the logs.
+func (m Message) String() string {
+	return m.T(i18n.English)
+}
+
+type errors map[string][]Message
+
+// Add adds an error to a given form field, the catalog key of its message and the
+// arguments of the key
+func (e errors) Add(field, key string, args ...interface{}) {
+	e[field] = append(e[field], Message{Key: key, Args: args})
+}
+
+// Get returns the first error of a field, nil when it has none
+func (e errors) Get(field string) *Message {
+	if messages, exists := e[field]; exists {
+		return &messages[0]
 	}
-	return ""
+	return nil
 }
@@ -1,9 +1,13 @@
 package forms
 
 import (
-	"fmt"
+	"math"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/mayloo89/bamos/internal/geo"
 )
 
 type Form struct {
@@ -14,7 +18,7 @@ type Form struct {
 func New(data url.Values) *Form {
 	return &Form{
 		data,
-		errors(map[string][]string{}),
+		errors(map[string][]Message{}),
 	}
 }
 
@@ -36,7 +40,7 @@ func (f *Form) Required(fields ...string) {
 	for _, field := range fields {
 		value := f.Get(field)
 		if strings.TrimSpace(value) == "" {
-			f.Errors.Add(field, "This field cannot be blank")
+			f.Errors.Add(field, "form.required")
 		}
 	}
 }
@@ -45,8 +49,120 @@ func (f *Form) Required(fields ...string) {
 func (f *Form) MinLength(field string, length int) bool {
 	x := f.Get(field)
 	if len(x) < length {
-		f.Errors.Add(field, fmt.Sprintf("This field must be at least %d characters long", length))
+		f.Errors.Add(field, "form.min_length", length)
+		return false
+	}
+	return true
+}
+
+// MaxLength check for maximum length
+func (f *Form) MaxLength(field string, length int) bool {
+	x := f.Get(field)
+	if len(x) > length {
+		f.Errors.Add(field, "form.max_length", length)
 		return false
 	}
 	return true
 }
+
+// The validators and accessors below skip blank fields, which are reported by
+// Required.
+
+// Int returns the value of a field as a whole number, adding an error when it is
+// not one.
+func (f *Form) Int(field string) (int, bool) {
+	x := strings.TrimSpace(f.Get(field))
+	if x == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(x)
+	if err != nil {
+		f.Errors.Add(field, "form.int")
+		return 0, false
+	}
+	return n, true
+}
+
+// Float returns the value of a field as a number, adding an error when it is not
+// a finite one.
+func (f *Form) Float(field string) (float64, bool) {
+	x := strings.TrimSpace(f.Get(field))
+	if x == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(x, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		f.Errors.Add(field, "form.float")
+		return 0, false
+	}
+	return n, true
+}
+
+// IntRange checks that a field is a whole number between min and max, inclusive
+func (f *Form) IntRange(field string, min, max int) bool {
+	n, ok := f.Int(field)
+	if !ok {
+		return false
+	}
+	if n < min || n > max {
+		f.Errors.Add(field, "form.int_range", min, max)
+		return false
+	}
+	return true
+}
+
+// FloatRange checks that a field is a number between min and max, inclusive
+func (f *Form) FloatRange(field string, min, max float64) bool {
+	n, ok := f.Float(field)
+	if !ok {
+		return false
+	}
+	if n < min || n > max {
+		f.Errors.Add(field, "form.float_range", min, max)
+		return false
+	}
+	return true
+}
+
+// LatLon returns the latitude and longitude of the given fields, adding an error
+// when they are not numbers or the point is outside the AMBA.
+func (f *Form) LatLon(latField, lonField string) (float64, float64, bool) {
+	lat, latOK := f.Float(latField)
+	lon, lonOK := f.Float(lonField)
+	if !latOK || !lonOK {
+		return 0, 0, false
+	}
+	if !geo.AMBA.Contains(geo.Point{Lat: lat, Lon: lon}) {
+		f.Errors.Add(latField, "form.amba")
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// Matches checks that a field matches the pattern
+func (f *Form) Matches(field string, pattern *regexp.Regexp) bool {
+	x := f.Get(field)
+	if x == "" {
+		return false
+	}
+	if !pattern.MatchString(x) {
+		f.Errors.Add(field, "form.format")
+		return false
+	}
+	return true
+}
+
+// In checks that a field is one of the values
+func (f *Form) In(field string, values ...string) bool {
+	x := f.Get(field)
+	if x == "" {
+		return false
+	}
+	for _, v := range values {
+		if x == v {
+			return true
+		}
+	}
+	f.Errors.Add(field, "form.in", strings.Join(values, ", "))
+	return false
+}
@@ -2,9 +2,12 @@ package forms
 
 import (
 	"net/url"
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/mayloo89/bamos/internal/i18n"
 )
 
 func Test_New_Success(t *testing.T) {
@@ -67,7 +70,7 @@ func Test_MinLenght_Success(t *testing.T) {
 	errDetail := form.Errors.Get("test-value")
 
 	assert.True(form.Valid())
-	assert.Equal("", errDetail)
+	assert.Nil(errDetail)
 }
 
 func Test_MinLenght_Error(t *testing.T) {
@@ -81,5 +84,130 @@ func Test_MinLenght_Error(t *testing.T) {
 	errDetail := form.Errors.Get("test-value")
 
 	assert.False(form.Valid())
-	assert.Equal("This field must be at least 10 characters long", errDetail)
+	assert.Equal(&Message{Key: "form.min_length", Args: []interface{}{10}}, errDetail)
+	assert.Equal("This field must be at least 10 characters long", errDetail.String())
+	assert.Equal("Este campo debe tener al menos 10 caracteres", errDetail.T(i18n.Spanish))
+}
+
+func Test_MaxLength(t *testing.T) {
+	assert := assert.New(t)
+
+	form := New(url.Values{"test-value": {"test"}})
+
+	assert.True(form.MaxLength("test-value", 4))
+	assert.False(form.MaxLength("test-value", 3))
+	assert.Equal("This field must be at most 3 characters long", form.Errors.Get("test-value").String())
+}
+
+func Test_Int(t *testing.T) {
+	assert := assert.New(t)
+
+	form := New(url.Values{"n": {" 42 "}, "text": {"4.2"}})
+
+	n, ok := form.Int("n")
+	assert.True(ok)
+	assert.Equal(42, n)
+
+	_, ok = form.Int("missing")
+	assert.False(ok)
+	assert.True(form.Valid(), "blank fields are left to Required")
+
+	_, ok = form.Int("text")
+	assert.False(ok)
+	assert.Equal("This field must be a whole number", form.Errors.Get("text").String())
+}
+
+func Test_Float(t *testing.T) {
+	assert := assert.New(t)
+
+	form := New(url.Values{"n": {"-34.6"}, "text": {"ERROR"}, "nan": {"NaN"}})
+
+	n, ok := form.Float("n")
+	assert.True(ok)
+	assert.Equal(-34.6, n)
+
+	_, ok = form.Float("text")
+	assert.False(ok)
+	assert.Equal("This field must be a number", form.Errors.Get("text").String())
+
+	_, ok = form.Float("nan")
+	assert.False(ok)
+	assert.Equal("This field must be a number", form.Errors.Get("nan").String())
+}
+
+func Test_IntRange(t *testing.T) {
+	assert := assert.New(t)
+
+	form := New(url.Values{"line": {"60"}})
+	assert.True(form.IntRange("line", 1, 999))
+	assert.True(form.Valid())
+
+	for _, line := range []string{"0", "1000", "-5"} {
+		form = New(url.Values{"line": {line}})
+		assert.False(form.IntRange("line", 1, 999), line)
+		assert.Equal("This field must be between 1 and 999", form.Errors.Get("line").String(), line)
+	}
+
+	form = New(url.Values{"line": {"sixty"}})
+	assert.False(form.IntRange("line", 1, 999))
+	assert.Equal("This field must be a whole number", form.Errors.Get("line").String())
+}
+
+func Test_FloatRange(t *testing.T) {
+	assert := assert.New(t)
+
+	form := New(url.Values{"speed": {"12.5"}})
+	assert.True(form.FloatRange("speed", 0, 120))
+	assert.False(form.FloatRange("speed", 0, 10.5))
+	assert.Equal("This field must be between 0 and 10.5", form.Errors.Get("speed").String())
+	assert.Equal("Este campo debe estar entre 0 y 10.5", form.Errors.Get("speed").T(i18n.Spanish))
+}
+
+func Test_LatLon(t *testing.T) {
+	assert := assert.New(t)
+
+	form := New(url.Values{"lat": {"-34.6037"}, "lon": {"-58.3816"}})
+	lat, lon, ok := form.LatLon("lat", "lon")
+	assert.True(ok)
+	assert.Equal(-34.6037, lat)
+	assert.Equal(-58.3816, lon)
+	assert.True(form.Valid())
+
+	form = New(url.Values{"lat": {"40.7128"}, "lon": {"-74.0060"}})
+	_, _, ok = form.LatLon("lat", "lon")
+	assert.False(ok)
+	assert.Equal("The location must be within the Buenos Aires metropolitan area", form.Errors.Get("lat").String())
+	assert.Equal("La ubicación debe estar dentro del área metropolitana de Buenos Aires", form.Errors.Get("lat").T(i18n.Spanish))
+
+	// in the Río de la Plata
+	form = New(url.Values{"lat": {"-34.55"}, "lon": {"-58.30"}})
+	_, _, ok = form.LatLon("lat", "lon")
+	assert.False(ok)
+
+	form = New(url.Values{"lat": {"-34.6037"}, "lon": {"ERROR"}})
+	_, _, ok = form.LatLon("lat", "lon")
+	assert.False(ok)
+	assert.Empty(form.Errors.Get("lat"))
+	assert.Equal("This field must be a number", form.Errors.Get("lon").String())
+}
+
+func Test_Matches(t *testing.T) {
+	assert := assert.New(t)
+
+	stopID := regexp.MustCompile(`^\d+$`)
+	form := New(url.Values{"stop": {"1234"}, "text": {"12a"}})
+
+	assert.True(form.Matches("stop", stopID))
+	assert.False(form.Matches("text", stopID))
+	assert.Equal("This field has an invalid format", form.Errors.Get("text").String())
+}
+
+func Test_In(t *testing.T) {
+	assert := assert.New(t)
+
+	form := New(url.Values{"kind": {"lines"}, "other": {"cars"}})
+
+	assert.True(form.In("kind", "lines", "stops"))
+	assert.False(form.In("other", "lines", "stops"))
+	assert.Equal("This field must be one of lines, stops", form.Errors.Get("other").String())
 }
@@ -0,0 +1,168 @@
+// Package geo locates points in the areas served by bamos, the city of Buenos
+// Aires and its metropolitan area, finds the street address of a point and the
+// point of an address, and writes GeoJSON.
+package geo
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Point is a location, in degrees.
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// earthRadius is the mean radius of the Earth, in meters.
+const earthRadius = 6371000
+
+// Distance returns the great-circle distance between two points, in meters.
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLon := lat2-lat1, radians(b.Lon-a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Area is a region made of polygons, each one an outer ring followed by its holes.
+type Area struct {
+	Name     string
+	Polygons [][][]Point
+	min, max Point
+}
+
+// Contains reports whether p is within the area, its border included.
+func (a *Area) Contains(p Point) bool {
+	if p.Lat < a.min.Lat || p.Lat > a.max.Lat || p.Lon < a.min.Lon || p.Lon > a.max.Lon {
+		return false
+	}
+	for _, polygon := range a.Polygons {
+		if len(polygon) == 0 || !inRing(p, polygon[0]) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if inRing(p, hole) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// inRing reports whether p is within a closed ring, by the crossings of a ray
+// going east from p.
+func inRing(p Point, ring []Point) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			in = !in
+		}
+	}
+	return in
+}
+
+//go:embed areas.geojson
+var areasFile []byte
+
+var (
+	areas = mustParseAreas(areasFile)
+
+	// CABA is the Ciudad Autónoma de Buenos Aires, the area of the parking rules.
+	CABA = areas["CABA"]
+	// AMBA is the Área Metropolitana de Buenos Aires, the area of the transport API.
+	AMBA = areas["AMBA"]
+)
+
+// mustParseAreas parses the embedded areas, panicking when they are invalid or
+// one is missing.
+func mustParseAreas(b []byte) map[string]*Area {
+	a, err := ParseAreas(b)
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range []string{"CABA", "AMBA"} {
+		if a[name] == nil {
+			panic(fmt.Sprintf("geo: the %s area is missing", name))
+		}
+	}
+	return a
+}
+
+// ParseAreas parses the Polygon and MultiPolygon features of a GeoJSON feature
+// collection by their name property.
+func ParseAreas(b []byte) (map[string]*Area, error) {
+	var collection struct {
+		Features []struct {
+			Properties struct {
+				Name string `json:"name"`
+			} `json:"properties"`
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(b, &collection); err != nil {
+		return nil, fmt.Errorf("can not parse the areas: %w", err)
+	}
+
+	areas := make(map[string]*Area, len(collection.Features))
+	for _, f := range collection.Features {
+		var polygons [][][][2]float64
+		switch f.Geometry.Type {
+		case "Polygon":
+			var polygon [][][2]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &polygon); err != nil {
+				return nil, fmt.Errorf("can not parse the %s area: %w", f.Properties.Name, err)
+			}
+			polygons = append(polygons, polygon)
+		case "MultiPolygon":
+			if err := json.Unmarshal(f.Geometry.Coordinates, &polygons); err != nil {
+				return nil, fmt.Errorf("can not parse the %s area: %w", f.Properties.Name, err)
+			}
+		default:
+			return nil, fmt.Errorf("the %s area is a %s, expected a Polygon or a MultiPolygon", f.Properties.Name, f.Geometry.Type)
+		}
+		areas[f.Properties.Name] = newArea(f.Properties.Name, polygons)
+	}
+	return areas, nil
+}
+
+// newArea returns an area of GeoJSON polygons, whose positions are longitude and
+// latitude.
+func newArea(name string, polygons [][][][2]float64) *Area {
+	a := &Area{
+		Name: name,
+		min:  Point{Lat: math.Inf(1), Lon: math.Inf(1)},
+		max:  Point{Lat: math.Inf(-1), Lon: math.Inf(-1)},
+	}
+	for _, polygon := range polygons {
+		var rings [][]Point
+		for _, ring := range polygon {
+			points := make([]Point, len(ring))
+			for i, position := range ring {
+				p := Point{Lat: position[1], Lon: position[0]}
+				points[i] = p
+				a.min.Lat, a.min.Lon = math.Min(a.min.Lat, p.Lat), math.Min(a.min.Lon, p.Lon)
+				a.max.Lat, a.max.Lon = math.Max(a.max.Lat, p.Lat), math.Max(a.max.Lon, p.Lon)
+			}
+			rings = append(rings, points)
+		}
+		a.Polygons = append(a.Polygons, rings)
+	}
+	return a
+}
@@ -0,0 +1,110 @@
+package geo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Areas(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]struct {
+		point      Point
+		caba, amba bool
+	}{
+		"Obelisco":        {Point{Lat: -34.6037, Lon: -58.3816}, true, true},
+		"Palermo":         {Point{Lat: -34.5780, Lon: -58.4260}, true, true},
+		"Liniers":         {Point{Lat: -34.6420, Lon: -58.5200}, true, true},
+		"Avellaneda":      {Point{Lat: -34.6625, Lon: -58.3650}, false, true},
+		"San Isidro":      {Point{Lat: -34.4708, Lon: -58.5286}, false, true},
+		"La Plata":        {Point{Lat: -34.9205, Lon: -57.9536}, false, true},
+		"Río de la Plata": {Point{Lat: -34.5500, Lon: -58.3000}, false, false},
+		"Montevideo":      {Point{Lat: -34.9011, Lon: -56.1645}, false, false},
+		"New York":        {Point{Lat: 40.7128, Lon: -74.0060}, false, false},
+	}
+	for name, test := range tests {
+		assert.Equal(test.caba, CABA.Contains(test.point), name)
+		assert.Equal(test.amba, AMBA.Contains(test.point), name)
+	}
+}
+
+func Test_ParseAreas(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	// a square with a hole and a second square
+	areas, err := ParseAreas([]byte(`{"type": "FeatureCollection", "features": [{
+		"type": "Feature",
+		"properties": {"name": "squares"},
+		"geometry": {"type": "MultiPolygon", "coordinates": [
+			[[[0, 0], [4, 0], [4, 4], [0, 4], [0, 0]], [[1, 1], [2, 1], [2, 2], [1, 2], [1, 1]]],
+			[[[10, 10], [11, 10], [11, 11], [10, 11], [10, 10]]]
+		]}
+	}]}`))
+	required.NoError(err)
+	squares := areas["squares"]
+	required.NotNil(squares)
+
+	assert.True(squares.Contains(Point{Lat: 3, Lon: 3}))
+	assert.False(squares.Contains(Point{Lat: 1.5, Lon: 1.5}))
+	assert.True(squares.Contains(Point{Lat: 10.5, Lon: 10.5}))
+	assert.False(squares.Contains(Point{Lat: 7, Lon: 7}))
+
+	_, err = ParseAreas([]byte(`{"features": [{"properties": {"name": "line"}, "geometry": {"type": "LineString", "coordinates": []}}]}`))
+	assert.ErrorContains(err, "the line area is a LineString")
+
+	_, err = ParseAreas([]byte(`{"features": [{"properties": {"name": "bad"}, "geometry": {"type": "Polygon", "coordinates": [1]}}]}`))
+	assert.ErrorContains(err, "can not parse the bad area")
+}
+
+func Test_Distance(t *testing.T) {
+	obelisco := Point{Lat: -34.6037, Lon: -58.3816}
+	congreso := Point{Lat: -34.6097, Lon: -58.3926}
+
+	assert.InDelta(t, 1200, Distance(obelisco, congreso), 50)
+	assert.Zero(t, Distance(obelisco, obelisco))
+}
+
+func Test_ParseBBox(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	b, err := ParseBBox("-58.45,-34.65,-58.35,-34.55")
+	required.NoError(err)
+	assert.Equal(BBox{Min: Point{Lat: -34.65, Lon: -58.45}, Max: Point{Lat: -34.55, Lon: -58.35}}, b)
+	assert.Equal([]float64{-58.45, -34.65, -58.35, -34.55}, b.Array())
+	assert.True(b.Contains(Point{Lat: -34.6037, Lon: -58.3816}))
+	assert.False(b.Contains(Point{Lat: -34.6625, Lon: -58.3650}))
+	assert.True(b.Crosses([]Point{{Lat: -34.7, Lon: -58.4}, {Lat: -34.6, Lon: -58.4}}))
+	assert.False(b.Crosses([]Point{{Lat: -34.7, Lon: -58.4}, {Lat: -34.68, Lon: -58.4}}))
+
+	for _, s := range []string{"", "-58.45,-34.65,-58.35", "-58.45,-34.65,-58.35,north", "-58.35,-34.65,-58.45,-34.55", "-58.45,-95,-58.35,-34.55"} {
+		_, err = ParseBBox(s)
+		assert.Error(err, s)
+	}
+}
+
+func Test_GeoJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	path := []Point{{Lat: -34.6035, Lon: -58.3830}, {Lat: -34.6036, Lon: -58.3850}}
+	collection := NewFeatureCollection(
+		NewFeature("1", PointGeometry(path[0]), map[string]any{"name": "Obelisco"}),
+		NewFeature("2", MultiLineStringGeometry(path, path[:1]), nil),
+		NewFeature("3", nil, nil),
+	)
+	b, err := json.Marshal(collection)
+	require.NoError(t, err)
+	assert.JSONEq(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "id": "1", "geometry": {"type": "Point", "coordinates": [-58.383, -34.6035]}, "properties": {"name": "Obelisco"}},
+		{"type": "Feature", "id": "2", "geometry": {"type": "MultiLineString", "coordinates": [[[-58.383, -34.6035], [-58.385, -34.6036]]]}, "properties": {}},
+		{"type": "Feature", "id": "3", "geometry": null, "properties": {}}
+	]}`, string(b))
+
+	assert.Nil(MultiLineStringGeometry(path[:1]))
+	b, _ = json.Marshal(NewFeatureCollection())
+	assert.JSONEq(`{"type": "FeatureCollection", "features": []}`, string(b))
+}
@@ -0,0 +1,359 @@
+package geo
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxDistance is the farthest a point can be from a street for Reverse to
+// find its address, in meters.
+const DefaultMaxDistance = 100
+
+// metersPerDegree is the length of a degree of latitude, in meters.
+const metersPerDegree = earthRadius * math.Pi / 180
+
+// Street is a segment of a street between two corners, with the range of the
+// door numbers, the heights, of each side. The left side is the one on the left
+// going along Path, from the first height to the last one.
+type Street struct {
+	Name               string
+	Path               []Point
+	LeftFrom, LeftTo   int
+	RightFrom, RightTo int
+	minLat, maxLat     float64
+	minLon, maxLon     float64
+}
+
+// Address is the street address of a point.
+type Address struct {
+	Street string
+	Number int
+	// Distance is how far the point is from the street, in meters.
+	Distance float64
+}
+
+// String returns the street and number, or only the street when it has no number.
+func (a Address) String() string {
+	if a.Number == 0 {
+		return a.Street
+	}
+	return a.Street + " " + strconv.Itoa(a.Number)
+}
+
+// ParseAddress parses an address written as the street followed by the door
+// number, such as "CORRIENTES AV. 1038", false when it has no number.
+func ParseAddress(s string) (Address, bool) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndex(s, " ")
+	if i < 0 {
+		return Address{}, false
+	}
+	n, err := strconv.Atoi(s[i+1:])
+	street := strings.TrimSpace(s[:i])
+	if err != nil || n <= 0 || street == "" {
+		return Address{}, false
+	}
+	return Address{Street: street, Number: n}, true
+}
+
+// Geocoder finds the address of a point among the streets of the city, without
+// calling any service.
+type Geocoder struct {
+	streets     []Street
+	MaxDistance float64 // meters, DefaultMaxDistance when zero
+}
+
+// NewGeocoder returns a Geocoder of the streets.
+func NewGeocoder(streets []Street) *Geocoder {
+	for i := range streets {
+		s := &streets[i]
+		s.minLat, s.maxLat = math.Inf(1), math.Inf(-1)
+		s.minLon, s.maxLon = math.Inf(1), math.Inf(-1)
+		for _, p := range s.Path {
+			s.minLat, s.maxLat = math.Min(s.minLat, p.Lat), math.Max(s.maxLat, p.Lat)
+			s.minLon, s.maxLon = math.Min(s.minLon, p.Lon), math.Max(s.maxLon, p.Lon)
+		}
+	}
+	return &Geocoder{streets: streets}
+}
+
+// LoadGeocoder returns a Geocoder of the streets of a CSV file, see ReadStreets.
+func LoadGeocoder(path string) (*Geocoder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open streets file at %s: %w", path, err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil {
+			slog.Warn("error closing csv file", "path", path, "error", cerr)
+		}
+	}()
+
+	streets, err := ReadStreets(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading streets file at %s: %w", path, err)
+	}
+	return NewGeocoder(streets), nil
+}
+
+// ReadStreets reads the street segments of a CSV file with the columns of the
+// "callejero" of the city: the WKT LINESTRING or MULTILINESTRING geometry, the
+// nomoficial name and the alt_izqini, alt_izqfin, alt_derini and alt_derfin
+// heights of the left and right sides.
+func ReadStreets(r io.Reader) ([]Street, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("can not read the header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+	column := func(names ...string) int {
+		for _, name := range names {
+			if i := slices.Index(header, name); i >= 0 {
+				return i
+			}
+		}
+		return -1
+	}
+	geometry, name := column("wkt", "geometry", "geom"), column("nomoficial", "nombre", "name")
+	heights := []int{column("alt_izqini"), column("alt_izqfin"), column("alt_derini"), column("alt_derfin")}
+	if geometry < 0 || name < 0 || slices.Contains(heights, -1) {
+		return nil, errors.New("expected the wkt, nomoficial, alt_izqini, alt_izqfin, alt_derini and alt_derfin columns")
+	}
+
+	var streets []Street
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return streets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < len(header) {
+			return nil, fmt.Errorf("line %d: expected %d columns, got %d", line, len(header), len(record))
+		}
+
+		path, err := parseLineString(record[geometry])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		var h [4]int
+		for i, column := range heights {
+			if h[i], err = parseHeight(record[column]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		streets = append(streets, Street{
+			Name:     strings.TrimSpace(record[name]),
+			Path:     path,
+			LeftFrom: h[0], LeftTo: h[1],
+			RightFrom: h[2], RightTo: h[3],
+		})
+	}
+}
+
+// parseLineString returns the points of a WKT LINESTRING, or of every line of a
+// MULTILINESTRING in order.
+func parseLineString(wkt string) ([]Point, error) {
+	wkt = strings.TrimSpace(wkt)
+	kind, coordinates, ok := strings.Cut(wkt, "(")
+	kind = strings.ToUpper(strings.TrimSpace(kind))
+	if !ok || (kind != "LINESTRING" && kind != "MULTILINESTRING") {
+		return nil, fmt.Errorf("invalid geometry %.40q, expected a LINESTRING or a MULTILINESTRING", wkt)
+	}
+	coordinates = strings.NewReplacer("(", "", ")", "").Replace(coordinates)
+
+	var path []Point
+	for _, position := range strings.Split(coordinates, ",") {
+		fields := strings.Fields(position)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid position %q", position)
+		}
+		lon, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid longitude %q", fields[0])
+		}
+		lat, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid latitude %q", fields[1])
+		}
+		path = append(path, Point{Lat: lat, Lon: lon})
+	}
+	if len(path) < 2 {
+		return nil, fmt.Errorf("invalid geometry %.40q, expected at least 2 positions", wkt)
+	}
+	return path, nil
+}
+
+// parseHeight parses a door number, which may be written as a decimal number, 0
+// when it is empty.
+func parseHeight(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	h, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid height %q", s)
+	}
+	return int(h), nil
+}
+
+// Reverse returns the address of the street nearest to p, interpolating the door
+// number along the side of the street p is on, and false when no street is within
+// the maximum distance.
+func (g *Geocoder) Reverse(p Point) (Address, bool) {
+	maxDistance := g.MaxDistance
+	if maxDistance <= 0 {
+		maxDistance = DefaultMaxDistance
+	}
+	// a degree of longitude is shorter than one of latitude away from the equator
+	scale := math.Cos(radians(p.Lat))
+	margin := maxDistance / metersPerDegree
+
+	var (
+		best      *Street
+		bestMatch match
+		found     bool
+	)
+	for i := range g.streets {
+		s := &g.streets[i]
+		if p.Lat < s.minLat-margin || p.Lat > s.maxLat+margin ||
+			p.Lon < s.minLon-margin/scale || p.Lon > s.maxLon+margin/scale {
+			continue
+		}
+		m := s.nearest(p, scale)
+		if m.distance <= maxDistance && (!found || m.distance < bestMatch.distance) {
+			best, bestMatch, found = s, m, true
+		}
+	}
+	if !found {
+		return Address{}, false
+	}
+
+	from, to := best.LeftFrom, best.LeftTo
+	if !bestMatch.left {
+		from, to = best.RightFrom, best.RightTo
+	}
+	if from == 0 && to == 0 {
+		// the numbers of some segments are only on one side
+		from, to = best.LeftFrom+best.RightFrom, best.LeftTo+best.RightTo
+	}
+	return Address{
+		Street:   best.Name,
+		Number:   interpolate(from, to, bestMatch.fraction),
+		Distance: bestMatch.distance,
+	}, true
+}
+
+// Locate returns the point of the address, interpolating its door number along the
+// segment of the street with it, and false when no segment has it. The names of
+// the streets are compared without case.
+func (g *Geocoder) Locate(a Address) (Point, bool) {
+	for i := range g.streets {
+		s := &g.streets[i]
+		if !strings.EqualFold(s.Name, a.Street) {
+			continue
+		}
+		for _, side := range [][2]int{{s.LeftFrom, s.LeftTo}, {s.RightFrom, s.RightTo}} {
+			from, to := side[0], side[1]
+			if from == 0 && to == 0 || (a.Number-from)%2 != 0 ||
+				a.Number < min(from, to) || a.Number > max(from, to) {
+				continue
+			}
+			fraction := 0.0
+			if to != from {
+				fraction = float64(a.Number-from) / float64(to-from)
+			}
+			return s.pointAt(fraction), true
+		}
+	}
+	return Point{}, false
+}
+
+// pointAt returns the point at fraction of the length of the street from its start.
+func (s *Street) pointAt(fraction float64) Point {
+	var length float64
+	for i := 1; i < len(s.Path); i++ {
+		length += Distance(s.Path[i-1], s.Path[i])
+	}
+	along := fraction * length
+	for i := 1; i < len(s.Path); i++ {
+		a, b := s.Path[i-1], s.Path[i]
+		segment := Distance(a, b)
+		if along <= segment && segment > 0 {
+			t := along / segment
+			return Point{Lat: a.Lat + t*(b.Lat-a.Lat), Lon: a.Lon + t*(b.Lon-a.Lon)}
+		}
+		along -= segment
+	}
+	return s.Path[len(s.Path)-1]
+}
+
+// match is where a point is the nearest to a street.
+type match struct {
+	distance float64 // meters from the point
+	fraction float64 // of the length of the street from its start
+	left     bool    // whether the point is on the left side
+}
+
+// nearest returns where s is the nearest to p, measured on a plane around p where
+// a degree of longitude is scale times a degree of latitude.
+func (s *Street) nearest(p Point, scale float64) match {
+	xy := func(q Point) (float64, float64) {
+		return (q.Lon - p.Lon) * scale * metersPerDegree, (q.Lat - p.Lat) * metersPerDegree
+	}
+
+	best := match{distance: math.Inf(1)}
+	var length, along float64
+	for i := 1; i < len(s.Path); i++ {
+		ax, ay := xy(s.Path[i-1])
+		bx, by := xy(s.Path[i])
+		dx, dy := bx-ax, by-ay
+		segment := math.Hypot(dx, dy)
+
+		// the projection of p, the origin, on the segment
+		t := 0.0
+		if segment > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/(segment*segment)))
+		}
+		if d := math.Hypot(ax+t*dx, ay+t*dy); d < best.distance {
+			best.distance = d
+			along = length + t*segment
+			// p is on the left when it is counterclockwise from the direction of the segment
+			best.left = dx*(-ay)-dy*(-ax) > 0
+		}
+		length += segment
+	}
+	if length > 0 {
+		best.fraction = along / length
+	}
+	return best
+}
+
+// interpolate returns the door number at fraction of the way from the height from
+// to the height to, with the parity of from, as every side is either odd or even.
+func interpolate(from, to int, fraction float64) int {
+	n := int(math.Round(float64(from) + fraction*float64(to-from)))
+	if (n-from)%2 != 0 {
+		if to >= from {
+			n--
+		} else {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,128 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const streetsCSV = `WKT,codigo,nomoficial,alt_izqini,alt_izqfin,alt_derini,alt_derfin
+"LINESTRING (-58.3830 -34.6035, -58.3850 -34.6036)",1,CORRIENTES AV.,1001,1099,1000,1098
+"MULTILINESTRING ((-58.3840 -34.6020, -58.3841 -34.6030), (-58.3841 -34.6030, -58.3842 -34.6045))",2,PASAJE CARABELAS,0,0,200.0,298.0
+`
+
+func Test_ReadStreets(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	streets, err := ReadStreets(strings.NewReader(streetsCSV))
+	required.NoError(err)
+	required.Len(streets, 2)
+	assert.Equal("CORRIENTES AV.", streets[0].Name)
+	assert.Equal([]Point{{Lat: -34.6035, Lon: -58.3830}, {Lat: -34.6036, Lon: -58.3850}}, streets[0].Path)
+	assert.Equal(1001, streets[0].LeftFrom)
+	assert.Equal(1098, streets[0].RightTo)
+	assert.Len(streets[1].Path, 4)
+	assert.Equal(298, streets[1].RightTo)
+
+	_, err = ReadStreets(strings.NewReader("nomoficial,alt_izqini\n"))
+	assert.ErrorContains(err, "expected the wkt, nomoficial")
+
+	_, err = ReadStreets(strings.NewReader("wkt,nomoficial,alt_izqini,alt_izqfin,alt_derini,alt_derfin\nPOINT (1 2),A,1,2,3,4\n"))
+	assert.ErrorContains(err, "line 2: invalid geometry")
+
+	_, err = ReadStreets(strings.NewReader("wkt,nomoficial,alt_izqini,alt_izqfin,alt_derini,alt_derfin\n\"LINESTRING (1 2, 3 4)\",A,1,x,3,4\n"))
+	assert.ErrorContains(err, `line 2: invalid height "x"`)
+}
+
+func Test_Reverse(t *testing.T) {
+	assert := assert.New(t)
+
+	streets, err := ReadStreets(strings.NewReader(streetsCSV))
+	require.NoError(t, err)
+	g := NewGeocoder(streets)
+
+	// north of the middle of Corrientes, going west, is the right side
+	a, ok := g.Reverse(Point{Lat: -34.6034, Lon: -58.3838})
+	assert.True(ok)
+	assert.Equal("CORRIENTES AV. 1038", a.String())
+	assert.InDelta(15, a.Distance, 5)
+
+	a, ok = g.Reverse(Point{Lat: -34.6038, Lon: -58.3838})
+	assert.True(ok)
+	assert.Equal("CORRIENTES AV. 1041", a.String())
+
+	// the pasaje only has numbers on one side
+	a, ok = g.Reverse(Point{Lat: -34.6020, Lon: -58.3838})
+	assert.True(ok)
+	assert.Equal("PASAJE CARABELAS 200", a.String())
+
+	_, ok = g.Reverse(Point{Lat: -34.6200, Lon: -58.4000})
+	assert.False(ok)
+
+	g.MaxDistance = 10
+	_, ok = g.Reverse(Point{Lat: -34.6034, Lon: -58.3838})
+	assert.False(ok)
+}
+
+func Test_LoadGeocoder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "callejero.csv")
+	require.NoError(t, os.WriteFile(path, []byte(streetsCSV), 0o644))
+
+	g, err := LoadGeocoder(path)
+	require.NoError(t, err)
+	_, ok := g.Reverse(Point{Lat: -34.6034, Lon: -58.3838})
+	assert.True(t, ok)
+
+	_, err = LoadGeocoder(path + ".missing")
+	assert.ErrorContains(t, err, "could not open streets file")
+}
+
+func Test_Address_String(t *testing.T) {
+	assert.Equal(t, "PASAJE CARABELAS", Address{Street: "PASAJE CARABELAS"}.String())
+}
+
+func Test_Locate(t *testing.T) {
+	assert := assert.New(t)
+
+	streets, err := ReadStreets(strings.NewReader(streetsCSV))
+	require.NoError(t, err)
+	g := NewGeocoder(streets)
+
+	// the middle of Corrientes, on either side
+	for _, number := range []int{1049, 1050} {
+		p, ok := g.Locate(Address{Street: "Corrientes Av.", Number: number})
+		assert.True(ok, number)
+		assert.InDelta(-58.3840, p.Lon, 0.0001, number)
+		assert.InDelta(-34.60355, p.Lat, 0.0001, number)
+	}
+
+	// the start of the pasaje, on its only side with numbers
+	p, ok := g.Locate(Address{Street: "PASAJE CARABELAS", Number: 200})
+	assert.True(ok)
+	assert.Equal(Point{Lat: -34.6020, Lon: -58.3840}, p)
+
+	_, ok = g.Locate(Address{Street: "CORRIENTES AV.", Number: 1200})
+	assert.False(ok)
+	_, ok = g.Locate(Address{Street: "PASAJE CARABELAS", Number: 201})
+	assert.False(ok)
+	_, ok = g.Locate(Address{Street: "FLORIDA", Number: 100})
+	assert.False(ok)
+}
+
+func Test_ParseAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	a, ok := ParseAddress(" CORRIENTES AV. 1038 ")
+	assert.True(ok)
+	assert.Equal(Address{Street: "CORRIENTES AV.", Number: 1038}, a)
+
+	for _, s := range []string{"", "CORRIENTES AV.", "1038", "CORRIENTES AV. 0", "CORRIENTES AV. S/N"} {
+		_, ok = ParseAddress(s)
+		assert.False(ok, s)
+	}
+}
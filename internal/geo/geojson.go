@@ -0,0 +1,124 @@
+package geo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BBox is a bounding box, the area between its south west and north east corners.
+type BBox struct {
+	Min, Max Point
+}
+
+// ParseBBox parses a bounding box written as "min longitude,min latitude,max
+// longitude,max latitude", the order of GeoJSON.
+func ParseBBox(s string) (BBox, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 4 {
+		return BBox{}, fmt.Errorf("invalid bounding box %q, expected min longitude, min latitude, max longitude and max latitude", s)
+	}
+	var v [4]float64
+	for i, f := range fields {
+		n, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return BBox{}, fmt.Errorf("invalid bounding box %q: %q is not a number", s, f)
+		}
+		v[i] = n
+	}
+	b := BBox{Min: Point{Lat: v[1], Lon: v[0]}, Max: Point{Lat: v[3], Lon: v[2]}}
+	if b.Min.Lon > b.Max.Lon || b.Min.Lat > b.Max.Lat ||
+		b.Min.Lon < -180 || b.Max.Lon > 180 || b.Min.Lat < -90 || b.Max.Lat > 90 {
+		return BBox{}, fmt.Errorf("invalid bounding box %q, the corners are out of order or range", s)
+	}
+	return b, nil
+}
+
+// Contains reports whether p is within the bounding box, its border included.
+func (b BBox) Contains(p Point) bool {
+	return p.Lat >= b.Min.Lat && p.Lat <= b.Max.Lat && p.Lon >= b.Min.Lon && p.Lon <= b.Max.Lon
+}
+
+// Crosses reports whether a point of the path is within the bounding box. A
+// segment crossing the box between two points outside it does not count, the
+// points of the shapes are close enough for the maps.
+func (b BBox) Crosses(path []Point) bool {
+	for _, p := range path {
+		if b.Contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Array returns the bounding box as the bbox member of GeoJSON.
+func (b BBox) Array() []float64 {
+	return []float64{b.Min.Lon, b.Min.Lat, b.Max.Lon, b.Max.Lat}
+}
+
+// FeatureCollection is a GeoJSON feature collection, RFC 7946.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	BBox     []float64 `json:"bbox,omitempty"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature, its geometry is null when the location is unknown.
+type Feature struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id,omitempty"`
+	Geometry   *Geometry      `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry, its coordinates are longitude and latitude.
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// NewFeatureCollection returns a collection of the features, never null in JSON.
+func NewFeatureCollection(features ...Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// NewFeature returns a feature with the geometry and properties.
+func NewFeature(id string, geometry *Geometry, properties map[string]any) Feature {
+	if properties == nil {
+		properties = map[string]any{}
+	}
+	return Feature{Type: "Feature", ID: id, Geometry: geometry, Properties: properties}
+}
+
+// PointGeometry returns the Point geometry of p.
+func PointGeometry(p Point) *Geometry {
+	return &Geometry{Type: "Point", Coordinates: position(p)}
+}
+
+// MultiLineStringGeometry returns the MultiLineString geometry of the paths, the
+// ones with less than two points left out, or nil when none is left.
+func MultiLineStringGeometry(paths ...[]Point) *Geometry {
+	lines := make([][][2]float64, 0, len(paths))
+	for _, path := range paths {
+		if len(path) < 2 {
+			continue
+		}
+		line := make([][2]float64, len(path))
+		for i, p := range path {
+			line[i] = position(p)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return &Geometry{Type: "MultiLineString", Coordinates: lines}
+}
+
+// position returns p as a GeoJSON position.
+func position(p Point) [2]float64 {
+	return [2]float64{p.Lon, p.Lat}
+}
@@ -0,0 +1,350 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mayloo89/bamos/internal/accounts"
+	"github.com/mayloo89/bamos/internal/apperror"
+	"github.com/mayloo89/bamos/internal/forms"
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/model"
+	"github.com/mayloo89/bamos/internal/parking"
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/render"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+type (
+	// lineStatus is a favorite line with the number of its vehicles on the street.
+	lineStatus struct {
+		accounts.FavoriteLine
+		Vehicles int
+	}
+
+	// stopStatus is a favorite stop with its next predicted arrivals.
+	stopStatus struct {
+		accounts.FavoriteStop
+		Arrivals []realtime.Arrival
+	}
+
+	// addressStatus is a favorite address with its parking rules.
+	addressStatus struct {
+		accounts.FavoriteAddress
+		Rules map[string][]string
+		Error string
+	}
+)
+
+// maxStopArrivals is the number of arrivals shown for each favorite stop.
+const maxStopArrivals = 3
+
+var (
+	errAccountsUnavailable = apperror.Unavailable("accounts_unavailable", "User accounts are not available right now.")
+	errFavoriteNotFound    = apperror.New(http.StatusNotFound, "favorite_not_found", "The favorite does not exist.")
+)
+
+// Register renders the registration form.
+func (m *Repository) Register(w http.ResponseWriter, r *http.Request) error {
+	return render.RenderTemplate(w, r, "register.page.tmpl", &model.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// PostRegister creates an account and logs the user in.
+func (m *Repository) PostRegister(w http.ResponseWriter, r *http.Request) error {
+	if m.Accounts == nil {
+		return errAccountsUnavailable
+	}
+	if err := r.ParseForm(); err != nil {
+		return errInvalidForm.WithCause(err)
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name", "email", "password")
+	form.MinLength("password", accounts.MinPasswordLength)
+	form.MaxLength("password", accounts.MaxPasswordLength)
+
+	if form.Valid() {
+		user, err := m.Accounts.Register(r.Context(), form.Get("email"), form.Get("name"), form.Get("password"), locale(r))
+		switch {
+		case errors.Is(err, accounts.ErrEmailTaken):
+			form.Errors.Add("email", "register.email_taken")
+		case err != nil:
+			return err
+		default:
+			if err := m.logIn(r, user); err != nil {
+				return err
+			}
+			m.App.Session.Put(r.Context(), "flash", translate(r, "account.welcome", user.Name))
+			http.Redirect(w, r, "/my", http.StatusSeeOther)
+			return nil
+		}
+	}
+
+	return render.RenderTemplate(w, r, "register.page.tmpl", &model.TemplateData{
+		Form: form,
+	})
+}
+
+// Login renders the login form.
+func (m *Repository) Login(w http.ResponseWriter, r *http.Request) error {
+	return render.RenderTemplate(w, r, "login.page.tmpl", &model.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// PostLogin logs the user in and redirects to the My bamos page.
+func (m *Repository) PostLogin(w http.ResponseWriter, r *http.Request) error {
+	if m.Accounts == nil {
+		return errAccountsUnavailable
+	}
+	if err := r.ParseForm(); err != nil {
+		return errInvalidForm.WithCause(err)
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("email", "password")
+	if !form.Valid() {
+		return render.RenderTemplate(w, r, "login.page.tmpl", &model.TemplateData{
+			Form: form,
+		})
+	}
+
+	user, err := m.Accounts.Authenticate(r.Context(), form.Get("email"), form.Get("password"))
+	if errors.Is(err, accounts.ErrInvalidCredentials) {
+		return render.RenderTemplate(w, r, "login.page.tmpl", &model.TemplateData{
+			Form:  form,
+			Error: translate(r, "login.invalid"),
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := m.logIn(r, user); err != nil {
+		return err
+	}
+	m.App.Session.Put(r.Context(), "flash", translate(r, "login.welcome", user.Name))
+	http.Redirect(w, r, "/my", http.StatusSeeOther)
+	return nil
+}
+
+// PostLogout logs the user out and redirects to the home page.
+func (m *Repository) PostLogout(w http.ResponseWriter, r *http.Request) error {
+	// a new token, so the old session can not be reused
+	if err := m.App.Session.RenewToken(r.Context()); err != nil {
+		return err
+	}
+	m.App.Session.Remove(r.Context(), helpers.UserIDKey)
+	m.App.Session.Put(r.Context(), "flash", translate(r, "logout.done"))
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
+
+// logIn keeps the user in a session with a new token, preventing session fixation.
+func (m *Repository) logIn(r *http.Request, user *accounts.User) error {
+	if err := m.App.Session.RenewToken(r.Context()); err != nil {
+		return err
+	}
+	m.App.Session.Put(r.Context(), helpers.UserIDKey, user.ID)
+	return nil
+}
+
+// MyBamos renders the favorites of the logged in user with their live information:
+// the vehicles of each line, the next arrivals at each stop and the parking rules
+// of each address, along with the spot where the user parked and their webhooks.
+func (m *Repository) MyBamos(w http.ResponseWriter, r *http.Request) error {
+	if m.Accounts == nil {
+		return errAccountsUnavailable
+	}
+
+	user, err := m.Accounts.UserByID(r.Context(), helpers.UserID(r))
+	if err != nil {
+		return fmt.Errorf("can not find the logged in user: %w", err)
+	}
+	favorites, err := m.Accounts.Favorites(r.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	var snapshot *realtime.Snapshot
+	if m.Realtime != nil {
+		snapshot = m.Realtime.Snapshot()
+	}
+
+	lines := make([]lineStatus, 0, len(favorites.Lines))
+	for _, line := range favorites.Lines {
+		status := lineStatus{FavoriteLine: line}
+		if routeIDs := m.RouteIDs(line.Line); len(routeIDs) > 0 {
+			status.Vehicles = len(snapshot.Vehicles(routeIDs...))
+		}
+		lines = append(lines, status)
+	}
+
+	arrivals := snapshot.Arrivals()
+	now := time.Now()
+	stops := make([]stopStatus, 0, len(favorites.Stops))
+	for _, stop := range favorites.Stops {
+		stops = append(stops, stopStatus{FavoriteStop: stop, Arrivals: nextArrivals(arrivals, stop.StopID, now)})
+	}
+
+	addresses := make([]addressStatus, 0, len(favorites.Addresses))
+	for _, address := range favorites.Addresses {
+		status := addressStatus{FavoriteAddress: address}
+		rules, err := m.APIClient.ParkingRules(r.Context(), address.Latitude, address.Longitude)
+		if err != nil && !errors.Is(err, services.ErrNoParkingRules) {
+			m.logger().WarnContext(r.Context(), "parking rules of a favorite address failed", "error", err)
+			status.Error = translate(r, "my.rules_unavailable")
+		}
+		status.Rules = rules
+		addresses = append(addresses, status)
+	}
+
+	data := map[string]interface{}{
+		"lines":     lines,
+		"stops":     stops,
+		"addresses": addresses,
+		"realtime":  snapshot != nil,
+	}
+	if m.Parking != nil {
+		spot, err := m.Parking.Spot(r.Context(), user.ID)
+		switch {
+		case errors.Is(err, parking.ErrNotFound):
+		case err != nil:
+			return err
+		default:
+			data["spot"] = newSpotStatus(*spot, now)
+		}
+	}
+	if m.Webhooks != nil {
+		subs, err := m.Webhooks.Subscriptions(r.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		data["webhooks"] = subs
+	}
+
+	return render.RenderTemplate(w, r, "my.page.tmpl", &model.TemplateData{
+		StringMap: map[string]string{"name": user.Name},
+		Data:      data,
+	})
+}
+
+// nextArrivals returns the first upcoming arrivals at the stop.
+func nextArrivals(arrivals []realtime.Arrival, stopID string, now time.Time) []realtime.Arrival {
+	var next []realtime.Arrival
+	for _, arrival := range arrivals {
+		if arrival.StopID == stopID && !arrival.Time.Before(now) {
+			next = append(next, arrival)
+		}
+	}
+	slices.SortFunc(next, func(a, b realtime.Arrival) int { return a.Time.Compare(b.Time) })
+	if len(next) > maxStopArrivals {
+		next = next[:maxStopArrivals]
+	}
+	return next
+}
+
+// PostFavorite saves a line, stop or address, given by the kind URL parameter, as a
+// favorite of the logged in user.
+func (m *Repository) PostFavorite(w http.ResponseWriter, r *http.Request) error {
+	if m.Accounts == nil {
+		return errAccountsUnavailable
+	}
+	kind, err := accounts.ParseKind(chi.URLParam(r, "kind"))
+	if err != nil {
+		return apperror.NotFound()
+	}
+	if err := r.ParseForm(); err != nil {
+		return errInvalidForm.WithCause(err)
+	}
+
+	userID := helpers.UserID(r)
+	form := forms.New(r.PostForm)
+	var saved string
+	var invalid *forms.Message // the form error of an invalid field, shown to the user
+	switch kind {
+	case accounts.KindLine:
+		form.Required("line")
+		if form.Valid() {
+			err = m.Accounts.AddFavoriteLine(r.Context(), userID, form.Get("line"))
+			saved = translate(r, "favorite.line_saved", form.Get("line"))
+		}
+	case accounts.KindStop:
+		form.Required("stop_id")
+		if form.Valid() {
+			name := form.Get("name")
+			if name == "" {
+				name = form.Get("stop_id")
+			}
+			err = m.Accounts.AddFavoriteStop(r.Context(), userID, accounts.FavoriteStop{StopID: form.Get("stop_id"), Name: name})
+			saved = translate(r, "favorite.stop_saved", name)
+		}
+	case accounts.KindAddress:
+		form.Required("address", "latitude", "longitude")
+		if !form.Valid() {
+			break
+		}
+		// only the locations of the AMBA are kept, like the ones of the searches
+		lat, lon, ok := form.LatLon("latitude", "longitude")
+		if !ok {
+			if invalid = form.Errors.Get("latitude"); invalid == nil {
+				invalid = form.Errors.Get("longitude")
+			}
+			break
+		}
+		err = m.Accounts.AddFavoriteAddress(r.Context(), userID, accounts.FavoriteAddress{
+			Address: form.Get("address"), Latitude: lat, Longitude: lon,
+		})
+		saved = translate(r, "favorite.address_saved", form.Get("address"))
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case form.Valid():
+		m.App.Session.Put(r.Context(), "flash", saved)
+	case invalid != nil:
+		m.App.Session.Put(r.Context(), "error", invalid.T(locale(r)))
+	default:
+		m.App.Session.Put(r.Context(), "error", translate(r, "favorite.missing_field"))
+	}
+	http.Redirect(w, r, "/my", http.StatusSeeOther)
+	return nil
+}
+
+// PostRemoveFavorite deletes a favorite of the logged in user.
+func (m *Repository) PostRemoveFavorite(w http.ResponseWriter, r *http.Request) error {
+	if m.Accounts == nil {
+		return errAccountsUnavailable
+	}
+	kind, err := accounts.ParseKind(chi.URLParam(r, "kind"))
+	if err != nil {
+		return apperror.NotFound()
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return errFavoriteNotFound
+	}
+
+	err = m.Accounts.RemoveFavorite(r.Context(), helpers.UserID(r), kind, id)
+	if errors.Is(err, accounts.ErrNotFound) {
+		return errFavoriteNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	m.App.Session.Put(r.Context(), "flash", translate(r, "favorite.removed"))
+	http.Redirect(w, r, "/my", http.StatusSeeOther)
+	return nil
+}
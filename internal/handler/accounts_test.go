@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mayloo89/bamos/internal/accounts"
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/parking"
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+	"github.com/mayloo89/bamos/utils"
+)
+
+// setupTestAccounts adds accounts kept in memory, hashing with the cheapest cost.
+func setupTestAccounts(repo *Repository) *accounts.Accounts {
+	repo.Accounts = accounts.New(accounts.NewMemoryStore())
+	repo.Accounts.Cost = bcrypt.MinCost
+	return repo.Accounts
+}
+
+// postForm returns a form POST request, with the cookies of the previous response if any.
+func postForm(t *testing.T, path string, form url.Values, prev *httptest.ResponseRecorder) *http.Request {
+	req, err := http.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if prev != nil {
+		for _, cookie := range prev.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+	}
+	return req
+}
+
+// withURLParams sets the chi URL parameters of the request, as the router does.
+func withURLParams(req *http.Request, params map[string]string) *http.Request {
+	rctx := chi.NewRouteContext()
+	for key, value := range params {
+		rctx.URLParams.Add(key, value)
+	}
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func Test_PostRegister(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	repo, app := setupTestApp(new(services.MockAPIClient))
+	setupTestSession(app)
+	a := setupTestAccounts(repo)
+
+	form := url.Values{"name": {"Ana"}, "email": {"ana@example.com"}, "password": {"colectivo60"}}
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostRegister), postForm(t, "/user/register", form, nil))
+	required.Equal(http.StatusSeeOther, rr.Code)
+	assert.Equal("/my", rr.Header().Get("Location"))
+
+	_, err := a.Authenticate(context.Background(), "ana@example.com", "colectivo60")
+	assert.NoError(err)
+
+	// the same email can not be registered twice
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostRegister), postForm(t, "/user/register", form, nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Contains(rr.Body.String(), "This email is already registered")
+	req := postForm(t, "/user/register", form, nil)
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostRegister), req.WithContext(i18n.WithLocale(req.Context(), i18n.Spanish)))
+	assert.Contains(rr.Body.String(), "Este email ya está registrado", "form errors are translated to the locale of the request")
+
+	form.Set("email", "other@example.com")
+	form.Set("password", "short")
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostRegister), postForm(t, "/user/register", form, nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Contains(rr.Body.String(), "This field must be at least 8 characters long")
+}
+
+func Test_PostLogin_MyBamos(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("ParkingRules", mock.Anything, -34.6037, -58.3816).
+		Return(services.SimplifiedRules{"Av. Corrientes 1234": {"Prohibido estacionar"}}, nil)
+	repo, app := setupTestApp(mockAPIClient)
+	setupTestSession(app)
+	a := setupTestAccounts(repo)
+	app.DataCache.Routes = []utils.Route{{ID: "1426", ShortName: "60"}}
+
+	now := time.Now()
+	repo.Realtime = &testRealtimeSource{snapshot: &realtime.Snapshot{Feeds: map[services.Feed]*realtime.FeedSnapshot{
+		services.FeedVehiclePositions: {Message: &gtfs.FeedMessage{Entity: []*gtfs.FeedEntity{
+			{Id: proto.String("v1"), Vehicle: &gtfs.VehiclePosition{
+				Trip:     &gtfs.TripDescriptor{RouteId: proto.String("1426")},
+				Position: &gtfs.Position{Latitude: proto.Float32(-34.6), Longitude: proto.Float32(-58.4)},
+			}},
+		}}, FetchedAt: now},
+		services.FeedTripUpdates: {Message: &gtfs.FeedMessage{Entity: []*gtfs.FeedEntity{
+			{Id: proto.String("t1"), TripUpdate: &gtfs.TripUpdate{
+				Trip: &gtfs.TripDescriptor{TripId: proto.String("trip-1"), RouteId: proto.String("1426")},
+				StopTimeUpdate: []*gtfs.TripUpdate_StopTimeUpdate{{
+					StopId:  proto.String("201001"),
+					Arrival: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(now.Add(5 * time.Minute).Unix())},
+				}},
+			}},
+		}}, FetchedAt: now},
+	}}}
+
+	user, err := a.Register(context.Background(), "ana@example.com", "Ana", "colectivo60", i18n.English)
+	required.NoError(err)
+	required.NoError(a.AddFavoriteLine(context.Background(), user.ID, "60"))
+	required.NoError(a.AddFavoriteStop(context.Background(), user.ID, accounts.FavoriteStop{StopID: "201001", Name: "Home"}))
+	required.NoError(a.AddFavoriteAddress(context.Background(), user.ID, accounts.FavoriteAddress{
+		Address: "Av. Corrientes 1234", Latitude: -34.6037, Longitude: -58.3816,
+	}))
+
+	form := url.Values{"email": {"ana@example.com"}, "password": {"wrong-password"}}
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostLogin), postForm(t, "/user/login", form, nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Contains(rr.Body.String(), "Invalid email or password.")
+
+	form.Set("password", "colectivo60")
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostLogin), postForm(t, "/user/login", form, nil))
+	required.Equal(http.StatusSeeOther, rr.Code)
+	required.Equal("/my", rr.Header().Get("Location"))
+
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.MyBamos), redirectRequest(t, rr))
+	required.Equal(http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	assert.Contains(body, "Welcome back, Ana.")
+	assert.Contains(body, "Line 60")
+	assert.Contains(body, "1 vehicles")
+	assert.Contains(body, "Route 1426 at "+now.Add(5*time.Minute).Format("15:04"))
+	// the rules of the API are in Spanish
+	assert.Contains(body, "No parking.")
+	assert.Contains(body, "Log out")
+
+	req := redirectRequest(t, rr)
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.MyBamos), req.WithContext(i18n.WithLocale(req.Context(), i18n.Spanish)))
+	required.Equal(http.StatusOK, rr.Code)
+	body = rr.Body.String()
+	assert.Contains(body, "Recorrido 1426 a las "+now.Add(5*time.Minute).Format("15:04")+", en 5 min")
+	assert.Contains(body, "Prohibido estacionar")
+	assert.Contains(body, "Salir")
+}
+
+func Test_PostFavorite(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	repo, app := setupTestApp(new(services.MockAPIClient))
+	setupTestSession(app)
+	a := setupTestAccounts(repo)
+	user, err := a.Register(context.Background(), "ana@example.com", "Ana", "colectivo60", i18n.English)
+	required.NoError(err)
+
+	// log in through the session, the favorites are saved for the logged in user
+	login := func(w http.ResponseWriter, r *http.Request) error {
+		app.Session.Put(r.Context(), helpers.UserIDKey, user.ID)
+		return nil
+	}
+	session := serveWithSession(app.Session, helpers.HandlerFunc(login), httptest.NewRequest("GET", "/", nil))
+
+	req := withURLParams(postForm(t, "/my/favorites/lines", url.Values{"line": {"60"}}, session), map[string]string{"kind": "lines"})
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostFavorite), req)
+	required.Equal(http.StatusSeeOther, rr.Code)
+	assert.Equal("/my", rr.Header().Get("Location"))
+
+	// the addresses outside the AMBA are not kept, the form error is shown in the locale of the user
+	outside := url.Values{"address": {"San Martín 1"}, "latitude": {"-31.4135"}, "longitude": {"-64.1811"}}
+	req = withURLParams(postForm(t, "/my/favorites/addresses", outside, session), map[string]string{"kind": "addresses"})
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostFavorite), req.WithContext(i18n.WithLocale(req.Context(), i18n.Spanish)))
+	required.Equal(http.StatusSeeOther, rr.Code)
+	req = redirectRequest(t, rr)
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.MyBamos), req)
+	assert.Contains(rr.Body.String(), "La ubicación debe estar dentro del área metropolitana de Buenos Aires")
+
+	address := url.Values{"address": {"Av. Corrientes 1234"}, "latitude": {"-34.6037"}, "longitude": {"-58.3816"}}
+	req = withURLParams(postForm(t, "/my/favorites/addresses", address, session), map[string]string{"kind": "addresses"})
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostFavorite), req)
+	required.Equal(http.StatusSeeOther, rr.Code)
+
+	favorites, err := a.Favorites(context.Background(), user.ID)
+	required.NoError(err)
+	required.Len(favorites.Lines, 1)
+	assert.Equal("60", favorites.Lines[0].Line)
+	required.Len(favorites.Addresses, 1)
+	assert.Equal(-58.3816, favorites.Addresses[0].Longitude)
+
+	req = withURLParams(postForm(t, "/my/favorites/trips", url.Values{}, session), map[string]string{"kind": "trips"})
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostFavorite), req)
+	assert.Equal(http.StatusNotFound, rr.Code)
+
+	id := favorites.Lines[0].ID
+	params := map[string]string{"kind": "lines", "id": strconv.FormatInt(id, 10)}
+	req = withURLParams(postForm(t, "/my/favorites/lines/"+params["id"]+"/delete", url.Values{}, session), params)
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostRemoveFavorite), req)
+	required.Equal(http.StatusSeeOther, rr.Code)
+
+	// removing it again answers not found
+	req = withURLParams(postForm(t, "/my/favorites/lines/"+params["id"]+"/delete", url.Values{}, session), params)
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostRemoveFavorite), req)
+	assert.Equal(http.StatusNotFound, rr.Code)
+}
+
+func Test_PostLogin_AccountsUnavailable(t *testing.T) {
+	repo, app := setupTestApp(new(services.MockAPIClient))
+	setupTestSession(app)
+
+	form := url.Values{"email": {"ana@example.com"}, "password": {"colectivo60"}}
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostLogin), postForm(t, "/user/login", form, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
+
+func Test_PostParkHere(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	rules := []string{"Lado par: prohibido estacionar las 24 hs.", "Lado impar: permitido las 24 hs."}
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("ParkingRules", mock.Anything, -34.6037, -58.3816).
+		Return(services.SimplifiedRules{"Corrientes 1000": rules}, nil)
+	repo, app := setupTestApp(mockAPIClient)
+	setupTestSession(app)
+	a := setupTestAccounts(repo)
+	spots := parking.NewMemoryStore()
+	repo.Parking = spots
+	user, err := a.Register(context.Background(), "ana@example.com", "Ana", "colectivo60", i18n.English)
+	required.NoError(err)
+
+	login := func(w http.ResponseWriter, r *http.Request) error {
+		app.Session.Put(r.Context(), helpers.UserIDKey, user.ID)
+		return nil
+	}
+	session := serveWithSession(app.Session, helpers.HandlerFunc(login), httptest.NewRequest("GET", "/", nil))
+
+	form := url.Values{
+		"address":   {"Corrientes 1000"},
+		"latitude":  {"-34.6037"},
+		"longitude": {"-58.3816"},
+		"rule":      {"Lado impar: permitido las 24 hs."},
+	}
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostParkHere), postForm(t, "/my/parking", form, session))
+	required.Equal(http.StatusSeeOther, rr.Code)
+	assert.Equal("/my", rr.Header().Get("Location"))
+
+	spot, err := spots.Spot(context.Background(), user.ID)
+	required.NoError(err)
+	assert.Equal("Corrientes 1000", spot.Address)
+	assert.Equal(rules, spot.Rules, "the rules come from the API, not the form")
+
+	// the page shows the spot and the warning, parking is always forbidden on one side
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.MyBamos), redirectRequest(t, rr))
+	required.Equal(http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	assert.Contains(body, "Parking is forbidden at Corrientes 1000 right now")
+	assert.Contains(body, "Your car")
+
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostLeaveSpot), postForm(t, "/my/parking/delete", url.Values{}, session))
+	required.Equal(http.StatusSeeOther, rr.Code)
+	_, err = spots.Spot(context.Background(), user.ID)
+	assert.ErrorIs(err, parking.ErrNotFound)
+
+	// addresses without parking rules at the location
+	form.Set("address", "Corrientes 2000")
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostParkHere), postForm(t, "/my/parking", form, session))
+	assert.Equal(http.StatusBadRequest, rr.Code)
+	_, err = spots.Spot(context.Background(), user.ID)
+	assert.ErrorIs(err, parking.ErrNotFound)
+
+	// and locations that are not numbers or outside the AMBA
+	for _, lat := range []string{"", "NaN", "Inf", "-40.5"} {
+		form.Set("address", "Corrientes 1000")
+		form.Set("latitude", lat)
+		rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostParkHere), postForm(t, "/my/parking", form, session))
+		assert.Equal(http.StatusBadRequest, rr.Code, lat)
+	}
+	mockAPIClient.AssertNumberOfCalls(t, "ParkingRules", 2)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mayloo89/bamos/internal/analytics"
+	"github.com/mayloo89/bamos/internal/apperror"
+	"github.com/mayloo89/bamos/internal/model"
+	"github.com/mayloo89/bamos/internal/render"
+)
+
+// AnalyticsLine renders the headway and on-time performance of a route, as
+// computed by the analytics command.
+func (m *Repository) AnalyticsLine(w http.ResponseWriter, r *http.Request) error {
+	routeID := chi.URLParam(r, "route_id")
+
+	stringMap := map[string]string{"route_id": routeID}
+	data := make(map[string]interface{})
+
+	for _, route := range m.App.DataCache.Routes {
+		if route.ID == routeID {
+			data["route"] = route
+			break
+		}
+	}
+
+	report, unavailable := m.analyticsReport(r.Context())
+	switch {
+	case unavailable != "":
+		stringMap["error"] = translate(r, unavailable)
+	case len(report.Line(routeID)) == 0:
+		stringMap["error"] = translate(r, "analytics.not_found", routeID)
+	default:
+		data["metrics"] = report.Line(routeID)
+		stringMap["generated"] = report.GeneratedAt.In(analytics.Location).Format(time.DateTime)
+		if !report.From.IsZero() {
+			stringMap["from"] = report.From.In(analytics.Location).Format(time.DateTime)
+		}
+		if !report.To.IsZero() {
+			stringMap["to"] = report.To.In(analytics.Location).Format(time.DateTime)
+		}
+	}
+
+	return render.RenderTemplate(w, r, "analytics.page.tmpl", &model.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// AnalyticsLineCSV exports the metrics of a route as CSV.
+func (m *Repository) AnalyticsLineCSV(w http.ResponseWriter, r *http.Request) error {
+	routeID := chi.URLParam(r, "route_id")
+
+	report, unavailable := m.analyticsReport(r.Context())
+	if unavailable != "" {
+		return apperror.Unavailable("analytics_unavailable", translate(r, unavailable))
+	}
+
+	metrics := report.Line(routeID)
+	if len(metrics) == 0 {
+		return apperror.New(http.StatusNotFound, "route_not_found", translate(r, "analytics.not_found", routeID))
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "analytics-"+routeID+".csv"))
+	if err := analytics.WriteCSV(w, metrics); err != nil {
+		m.logger().WarnContext(r.Context(), "error writing analytics csv", "error", err)
+	}
+	return nil
+}
+
+// analyticsReport returns the latest report, or the catalog key of a message for
+// the user when there is none.
+func (m *Repository) analyticsReport(ctx context.Context) (*analytics.Report, string) {
+	if m.Analytics == nil {
+		return nil, "analytics.unavailable"
+	}
+
+	report, err := m.Analytics.Report()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "analytics.not_computed"
+	}
+	if err != nil {
+		m.logger().ErrorContext(ctx, "error reading analytics report", "error", err)
+		return nil, "analytics.not_loaded"
+	}
+
+	return report, ""
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/analytics"
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/services"
+	"github.com/mayloo89/bamos/utils"
+)
+
+func Test_AnalyticsLine(t *testing.T) {
+	repo, app := setupTestApp(new(services.MockAPIClient))
+	app.DataCache.Routes = []utils.Route{{ID: "1426", ShortName: "505R3"}}
+	repo.Analytics = testReports(t)
+
+	rr := httptest.NewRecorder()
+	helpers.HandlerFunc(repo.AnalyticsLine).ServeHTTP(rr, routeRequest(t, "/analytics/lines/1426", "1426"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "505R3")
+	assert.Contains(t, rr.Body.String(), "Morning peak")
+	assert.Contains(t, rr.Body.String(), "66.7%")
+}
+
+func Test_AnalyticsLine_NotComputed(t *testing.T) {
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+	repo.Analytics = &analytics.FileReports{Path: filepath.Join(t.TempDir(), "analytics.json")}
+
+	rr := httptest.NewRecorder()
+	helpers.HandlerFunc(repo.AnalyticsLine).ServeHTTP(rr, routeRequest(t, "/analytics/lines/1426", "1426"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Analytics have not been computed yet.")
+}
+
+func Test_AnalyticsLineCSV(t *testing.T) {
+	assert := assert.New(t)
+
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+	repo.Analytics = testReports(t)
+
+	rr := httptest.NewRecorder()
+	helpers.HandlerFunc(repo.AnalyticsLineCSV).ServeHTTP(rr, routeRequest(t, "/analytics/lines/1426/export.csv", "1426"))
+
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Equal("text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(rr.Header().Get("Content-Disposition"), "analytics-1426.csv")
+	assert.Contains(rr.Body.String(), "1426,Morning peak,2,900.00")
+
+	rr = httptest.NewRecorder()
+	helpers.HandlerFunc(repo.AnalyticsLineCSV).ServeHTTP(rr, routeRequest(t, "/analytics/lines/1427/export.csv", "1427"))
+	assert.Equal(http.StatusNotFound, rr.Code)
+
+	repo.Analytics = nil
+	rr = httptest.NewRecorder()
+	req := routeRequest(t, "/analytics/lines/1426/export.csv", "1426")
+	req.Header.Set("Accept", "application/json")
+	helpers.HandlerFunc(repo.AnalyticsLineCSV).ServeHTTP(rr, req)
+	assert.Equal(http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(helpers.ProblemContentType, rr.Header().Get("Content-Type"))
+	assert.Contains(rr.Body.String(), `"code":"analytics_unavailable"`)
+}
+
+// testReports returns reports with the morning peak metrics of route 1426.
+func testReports(t *testing.T) analytics.Reports {
+	path := filepath.Join(t.TempDir(), "analytics.json")
+	require.NoError(t, analytics.WriteReport(path, &analytics.Report{
+		GeneratedAt: time.Now(),
+		Buckets:     analytics.DefaultBuckets,
+		Metrics: []analytics.Metrics{{
+			RouteID: "1426", Bucket: "Morning peak", Headways: 2, AvgHeadway: 900, HeadwayVariance: 90000,
+			HeadwayCV: 1.0 / 3, Arrivals: 3, OnTime: 2, OnTimePercent: 200.0 / 3, Vehicles: 3,
+		}},
+	}))
+	return &analytics.FileReports{Path: path}
+}
+
+// routeRequest returns a GET request with the route_id URL parameter set, as chi does.
+func routeRequest(t *testing.T, target, routeID string) *http.Request {
+	req, err := http.NewRequest("GET", target, nil)
+	require.NoError(t, err)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("route_id", routeID)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
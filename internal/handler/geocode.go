@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mayloo89/bamos/internal/apperror"
+	"github.com/mayloo89/bamos/internal/forms"
+	"github.com/mayloo89/bamos/internal/geo"
+	"github.com/mayloo89/bamos/internal/maps"
+)
+
+// maxGeocodeQueryLength bounds the addresses searched with the geocode API.
+const maxGeocodeQueryLength = 200
+
+// Errors answered by the geocode API.
+var (
+	errInvalidGeocodeQuery = apperror.BadRequest("invalid_query", fmt.Sprintf("Type an address of at most %d characters in the q parameter.", maxGeocodeQueryLength))
+	errGeocoderUnavailable = apperror.Unavailable("geocoder_unavailable", "The address search is not available.")
+	errGeocoderFailed      = apperror.New(http.StatusBadGateway, "geocoder_error", "The address search is not responding, please try again in a few minutes.")
+)
+
+// geocodeResponse is the answer of the geocode API.
+type geocodeResponse struct {
+	Results []maps.Place `json:"results"`
+}
+
+// Geocode answers the places of the AMBA matching the address of the q query
+// parameter as JSON, the best match first. It is the address search of the
+// maps drawn without the Google Maps JavaScript API. The searches of each client
+// are limited by GeocodeLimiter.
+func (m *Repository) Geocode(w http.ResponseWriter, r *http.Request) error {
+	form := forms.New(r.URL.Query())
+	form.Required("q")
+	form.MaxLength("q", maxGeocodeQueryLength)
+	if !form.Valid() {
+		return errInvalidGeocodeQuery
+	}
+	if m.Geocoder == nil {
+		return errGeocoderUnavailable
+	}
+	if err := m.allow(w, r, m.GeocodeLimiter); err != nil {
+		return err
+	}
+
+	places, err := m.Geocoder.Geocode(r.Context(), form.Get("q"))
+	if err != nil {
+		return errGeocoderFailed.WithCause(fmt.Errorf("error geocoding the address: %w", err))
+	}
+
+	// the geocoders prefer the AMBA, without limiting their results to it
+	results := make([]maps.Place, 0, len(places))
+	for _, p := range places {
+		if geo.AMBA.Contains(geo.Point{Lat: p.Lat, Lon: p.Lon}) {
+			results = append(results, p)
+		}
+	}
+	writeJSON(w, http.StatusOK, geocodeResponse{Results: results})
+	return nil
+}
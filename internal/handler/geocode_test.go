@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/maps"
+	"github.com/mayloo89/bamos/internal/ratelimit"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+// testGeocoder answers the places, or the error, of every search.
+type testGeocoder struct {
+	places []maps.Place
+	err    error
+	query  string
+}
+
+func (g *testGeocoder) Geocode(_ context.Context, query string) ([]maps.Place, error) {
+	g.query = query
+	return g.places, g.err
+}
+
+func geocodeRequest(q string) *http.Request {
+	req := httptest.NewRequest("GET", "/api/v1/geocode?q="+url.QueryEscape(q), nil)
+	req.Header.Set("Accept", "application/json")
+	return req
+}
+
+func Test_Geocode(t *testing.T) {
+	assert := assert.New(t)
+
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+	geocoder := &testGeocoder{places: []maps.Place{
+		{Address: "Av. Corrientes 1234, CABA", Lat: -34.6037, Lon: -58.3816},
+		{Address: "Corrientes, Córdoba", Lat: -31.4201, Lon: -64.1888},
+	}}
+	repo.Geocoder = geocoder
+
+	rr := httptest.NewRecorder()
+	helpers.HandlerFunc(repo.Geocode).ServeHTTP(rr, geocodeRequest("Corrientes 1234"))
+
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Equal("Corrientes 1234", geocoder.query)
+	// the places outside the AMBA are left out
+	assert.JSONEq(`{"results":[{"address":"Av. Corrientes 1234, CABA","lat":-34.6037,"lon":-58.3816}]}`, rr.Body.String())
+
+	geocoder.places = nil
+	rr = httptest.NewRecorder()
+	helpers.HandlerFunc(repo.Geocode).ServeHTTP(rr, geocodeRequest("nowhere"))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.JSONEq(`{"results":[]}`, rr.Body.String())
+}
+
+func Test_Geocode_Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+
+	for _, q := range []string{"", strings.Repeat("a", maxGeocodeQueryLength+1)} {
+		rr := httptest.NewRecorder()
+		helpers.HandlerFunc(repo.Geocode).ServeHTTP(rr, geocodeRequest(q))
+		assert.Equal(http.StatusBadRequest, rr.Code)
+		assert.Contains(rr.Body.String(), "invalid_query")
+	}
+
+	// without a geocoder
+	rr := httptest.NewRecorder()
+	helpers.HandlerFunc(repo.Geocode).ServeHTTP(rr, geocodeRequest("Corrientes 1234"))
+	assert.Equal(http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(rr.Body.String(), "geocoder_unavailable")
+
+	repo.Geocoder = &testGeocoder{err: errors.New("geocoder answered with status 429")}
+	rr = httptest.NewRecorder()
+	helpers.HandlerFunc(repo.Geocode).ServeHTTP(rr, geocodeRequest("Corrientes 1234"))
+	assert.Equal(http.StatusBadGateway, rr.Code)
+	assert.Contains(rr.Body.String(), "geocoder_error")
+	assert.NotContains(rr.Body.String(), "429")
+}
+
+func Test_Geocode_RateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+	repo.Geocoder = &testGeocoder{}
+	repo.GeocodeLimiter = ratelimit.New(2)
+
+	search := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := geocodeRequest("Corrientes 1234")
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		helpers.HandlerFunc(repo.Geocode).ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(http.StatusOK, search("10.0.0.1:1234").Code)
+	assert.Equal(http.StatusOK, search("10.0.0.1:1235").Code)
+	rr := search("10.0.0.1:1236")
+	assert.Equal(http.StatusTooManyRequests, rr.Code)
+	assert.Contains(rr.Body.String(), "rate_limited")
+	assert.Equal("30", rr.Header().Get("Retry-After"))
+
+	assert.Equal(http.StatusOK, search("10.0.0.2:1234").Code, "every client has its own searches")
+}
+
+func Test_Geocode_RateLimit_Forwarded(t *testing.T) {
+	assert := assert.New(t)
+
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+	repo.Geocoder = &testGeocoder{}
+	repo.GeocodeLimiter = ratelimit.New(1)
+	repo.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	// every search comes from the load balancer, which appends the client to the header
+	search := func(forwardedFor string) int {
+		req := geocodeRequest("Corrientes 1234")
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rr := httptest.NewRecorder()
+		helpers.HandlerFunc(repo.Geocode).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(http.StatusOK, search("203.0.113.1"))
+	assert.Equal(http.StatusOK, search("203.0.113.2"), "every forwarded client has its own searches")
+	assert.Equal(http.StatusTooManyRequests, search("203.0.113.1"))
+	assert.Equal(http.StatusTooManyRequests, search("198.51.100.9, 203.0.113.1"), "the hops before the load balancer are made up")
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/apperror"
+	"github.com/mayloo89/bamos/internal/forms"
+	"github.com/mayloo89/bamos/internal/geo"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+// geoJSONContentType is the media type of the GeoJSON responses, RFC 7946.
+const geoJSONContentType = "application/geo+json"
+
+// Errors answered by the GeoJSON export.
+var (
+	errInvalidBBox  = apperror.BadRequest("invalid_bbox", "The bbox parameter must be the min longitude, min latitude, max longitude and max latitude, separated by commas.")
+	errInvalidPoint = apperror.BadRequest("invalid_point", "Give the lat and lon parameters of a location of the Buenos Aires metropolitan area.")
+)
+
+// bboxFilter returns the optional bbox query parameter of the request, see
+// geo.ParseBBox, and false when there is none.
+func bboxFilter(r *http.Request) (geo.BBox, bool, error) {
+	s := r.URL.Query().Get("bbox")
+	if s == "" {
+		return geo.BBox{}, false, nil
+	}
+	b, err := geo.ParseBBox(s)
+	if err != nil {
+		return geo.BBox{}, false, errInvalidBBox.WithCause(err)
+	}
+	return b, true, nil
+}
+
+// RoutesGeoJSON answers the shapes of the routes as a GeoJSON feature collection,
+// a MultiLineString of the paths of the trips of every route. The optional line
+// query parameter restricts them to the routes of that bus line and bbox to the
+// ones passing through the bounding box.
+func (m *Repository) RoutesGeoJSON(w http.ResponseWriter, r *http.Request) error {
+	bbox, filtered, err := bboxFilter(r)
+	if err != nil {
+		return err
+	}
+	line := r.URL.Query().Get("line")
+	routeIDs := m.RouteIDs(line)
+
+	feed := m.App.DataCache.Feed
+	features := []geo.Feature{}
+	for _, route := range m.App.DataCache.Routes {
+		if line != "" && !slices.Contains(routeIDs, route.ID) {
+			continue
+		}
+		var paths [][]geo.Point
+		for _, id := range feed.RouteShapes[route.ID] {
+			paths = append(paths, feed.Shapes[id])
+		}
+		if filtered && !slices.ContainsFunc(paths, bbox.Crosses) {
+			continue
+		}
+		geometry := geo.MultiLineStringGeometry(paths...)
+		if geometry == nil {
+			// the routes without shapes can not be drawn
+			continue
+		}
+		features = append(features, geo.NewFeature(route.ID, geometry, map[string]any{
+			"route_id":         route.ID,
+			"agency_id":        route.AgencyID,
+			"route_short_name": route.ShortName,
+			"route_long_name":  route.LongName,
+			"route_desc":       route.Desc,
+			"route_type":       route.Type,
+			"route_color":      route.Color,
+			"route_text_color": route.TextColor,
+		}))
+	}
+	writeGeoJSON(w, geo.NewFeatureCollection(features...))
+	return nil
+}
+
+// StopsGeoJSON answers the stops as a GeoJSON feature collection of points. The
+// optional bbox query parameter restricts them to the ones in the bounding box.
+func (m *Repository) StopsGeoJSON(w http.ResponseWriter, r *http.Request) error {
+	bbox, filtered, err := bboxFilter(r)
+	if err != nil {
+		return err
+	}
+
+	features := []geo.Feature{}
+	for _, stop := range m.App.DataCache.Feed.Stops {
+		point := geo.Point{Lat: stop.Lat, Lon: stop.Lon}
+		if filtered && !bbox.Contains(point) {
+			continue
+		}
+		features = append(features, geo.NewFeature(stop.ID, geo.PointGeometry(point), map[string]any{
+			"stop_id":   stop.ID,
+			"stop_code": stop.Code,
+			"stop_name": stop.Name,
+		}))
+	}
+	writeGeoJSON(w, geo.NewFeatureCollection(features...))
+	return nil
+}
+
+// VehiclesGeoJSON answers the vehicles of the latest realtime snapshot as a
+// GeoJSON feature collection of points. The optional line query parameter
+// restricts them to the routes of that bus line and bbox to the ones in the
+// bounding box.
+func (m *Repository) VehiclesGeoJSON(w http.ResponseWriter, r *http.Request) error {
+	bbox, filtered, err := bboxFilter(r)
+	if err != nil {
+		return err
+	}
+	if m.Realtime == nil {
+		return errRealtimeUnavailable
+	}
+
+	features := []geo.Feature{}
+	line := r.URL.Query().Get("line")
+	routeIDs := m.RouteIDs(line)
+	if line == "" || len(routeIDs) > 0 {
+		for _, v := range m.Realtime.Snapshot().Vehicles(routeIDs...) {
+			point := geo.Point{Lat: v.Latitude, Lon: v.Longitude}
+			if filtered && !bbox.Contains(point) {
+				continue
+			}
+			properties := map[string]any{
+				"vehicle_id": v.ID,
+				"label":      v.Label,
+				"route_id":   v.RouteID,
+				"trip_id":    v.TripID,
+				"bearing":    v.Bearing,
+				"speed":      v.Speed,
+			}
+			if !v.Timestamp.IsZero() {
+				properties["timestamp"] = v.Timestamp.UTC().Format(time.RFC3339)
+			}
+			features = append(features, geo.NewFeature(v.ID, geo.PointGeometry(point), properties))
+		}
+	}
+	writeGeoJSON(w, geo.NewFeatureCollection(features...))
+	return nil
+}
+
+// ParkingGeoJSON answers the parking rules around the location of the lat and
+// lon query parameters as a GeoJSON feature collection, a point at every
+// address with rules. The addresses are located among the streets of the city,
+// their geometry is null when they are not found. Only the city has parking
+// rules, the collection is empty for the rest of the AMBA. The lookups of each
+// client are limited by ParkingLimiter.
+func (m *Repository) ParkingGeoJSON(w http.ResponseWriter, r *http.Request) error {
+	form := forms.New(r.URL.Query())
+	form.Required("lat", "lon")
+	lat, lon, _ := form.LatLon("lat", "lon")
+	if !form.Valid() {
+		return errInvalidPoint.WithCause(fmt.Errorf("invalid location %q, %q: %v", form.Get("lat"), form.Get("lon"), form.Errors))
+	}
+	if err := m.allow(w, r, m.ParkingLimiter); err != nil {
+		return err
+	}
+
+	point := geo.Point{Lat: lat, Lon: lon}
+	features := []geo.Feature{}
+	if geo.CABA.Contains(point) {
+		rules, err := m.APIClient.ParkingRules(r.Context(), lat, lon)
+		if err != nil && !errors.Is(err, services.ErrNoParkingRules) {
+			return apperror.Upstream(fmt.Errorf("error calling ParkingRules service: %w", err))
+		}
+
+		addresses := make([]string, 0, len(rules))
+		for address := range rules {
+			addresses = append(addresses, address)
+		}
+		slices.Sort(addresses)
+		for _, address := range addresses {
+			var geometry *geo.Geometry
+			if a, ok := geo.ParseAddress(address); ok && m.Streets != nil {
+				if p, ok := m.Streets.Locate(a); ok {
+					geometry = geo.PointGeometry(p)
+				}
+			}
+			features = append(features, geo.NewFeature("", geometry, map[string]any{
+				"address": address,
+				"rules":   rules[address],
+			}))
+		}
+	}
+	writeGeoJSON(w, geo.NewFeatureCollection(features...))
+	return nil
+}
+
+// writeGeoJSON writes the feature collection as GeoJSON.
+func writeGeoJSON(w http.ResponseWriter, collection geo.FeatureCollection) {
+	writeJSONAs(w, http.StatusOK, geoJSONContentType, collection)
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/geo"
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/ratelimit"
+	"github.com/mayloo89/bamos/internal/services"
+	"github.com/mayloo89/bamos/utils"
+)
+
+// serveGeoJSON answers the request with h, decoding the feature collection of
+// the 200 responses.
+func serveGeoJSON(t *testing.T, h helpers.HandlerFunc, target string) (*httptest.ResponseRecorder, geo.FeatureCollection) {
+	req := httptest.NewRequest("GET", target, nil)
+	req.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	var collection geo.FeatureCollection
+	if rr.Code == http.StatusOK {
+		assert.Equal(t, "application/geo+json", rr.Header().Get("Content-Type"))
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &collection))
+		assert.Equal(t, "FeatureCollection", collection.Type)
+	}
+	return rr, collection
+}
+
+// featureIDs returns the IDs of the features of the collection.
+func featureIDs(collection geo.FeatureCollection) []string {
+	ids := []string{}
+	for _, f := range collection.Features {
+		ids = append(ids, f.ID)
+	}
+	return ids
+}
+
+func setupGeoJSONApp() *Repository {
+	repo, app := setupTestApp(new(services.MockAPIClient))
+	app.DataCache.Routes = []utils.Route{
+		{ID: "1426", ShortName: "505R3", LongName: "JMALBR505", Type: "3", Color: "FF0000"},
+		{ID: "1500", ShortName: "60A", Type: "3"},
+		{ID: "1600", ShortName: "152A", Type: "3"},
+	}
+	app.DataCache.Feed = utils.Feed{
+		Stops: []utils.Stop{
+			{ID: "201", Name: "AV. CORRIENTES 1000", Lat: -34.6035, Lon: -58.3830},
+			{ID: "301", Code: "9", Name: "EST. BURZACO", Lat: -34.8270, Lon: -58.3930},
+		},
+		Shapes: map[string][]geo.Point{
+			"s1": {{Lat: -34.6035, Lon: -58.3830}, {Lat: -34.6036, Lon: -58.3850}},
+			"s2": {{Lat: -34.8270, Lon: -58.3930}, {Lat: -34.8200, Lon: -58.3900}},
+		},
+		RouteShapes: map[string][]string{"1426": {"s2"}, "1500": {"s1", "s2"}},
+	}
+	return repo
+}
+
+func Test_RoutesGeoJSON(t *testing.T) {
+	assert := assert.New(t)
+	repo := setupGeoJSONApp()
+
+	// the routes without shapes are left out
+	rr, collection := serveGeoJSON(t, repo.RoutesGeoJSON, "/api/v1/geojson/routes")
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Equal([]string{"1426", "1500"}, featureIDs(collection))
+	route := collection.Features[0]
+	assert.Equal("MultiLineString", route.Geometry.Type)
+	assert.Equal("505R3", route.Properties["route_short_name"])
+	assert.Equal("FF0000", route.Properties["route_color"])
+	assert.JSONEq(`{"type": "MultiLineString", "coordinates": [[[-58.383, -34.6035], [-58.385, -34.6036]], [[-58.393, -34.827], [-58.39, -34.82]]]}`,
+		mustJSON(t, collection.Features[1].Geometry))
+
+	_, collection = serveGeoJSON(t, repo.RoutesGeoJSON, "/api/v1/geojson/routes?line=505")
+	assert.Equal([]string{"1426"}, featureIDs(collection))
+
+	// the routes passing through the center of the city
+	_, collection = serveGeoJSON(t, repo.RoutesGeoJSON, "/api/v1/geojson/routes?bbox=-58.40,-34.61,-58.37,-34.60")
+	assert.Equal([]string{"1500"}, featureIDs(collection))
+
+	_, collection = serveGeoJSON(t, repo.RoutesGeoJSON, "/api/v1/geojson/routes?line=999")
+	assert.Empty(collection.Features)
+}
+
+func Test_StopsGeoJSON(t *testing.T) {
+	assert := assert.New(t)
+	repo := setupGeoJSONApp()
+
+	_, collection := serveGeoJSON(t, repo.StopsGeoJSON, "/api/v1/geojson/stops")
+	assert.Equal([]string{"201", "301"}, featureIDs(collection))
+	stop := collection.Features[1]
+	assert.JSONEq(`{"type": "Point", "coordinates": [-58.393, -34.827]}`, mustJSON(t, stop.Geometry))
+	assert.Equal(map[string]any{"stop_id": "301", "stop_code": "9", "stop_name": "EST. BURZACO"}, stop.Properties)
+
+	_, collection = serveGeoJSON(t, repo.StopsGeoJSON, "/api/v1/geojson/stops?bbox=-58.40,-34.61,-58.37,-34.60")
+	assert.Equal([]string{"201"}, featureIDs(collection))
+
+	for _, bbox := range []string{"-58.40,-34.61", "-58.37,-34.61,-58.40,-34.60", "west,-34.61,-58.37,-34.60"} {
+		rr, _ := serveGeoJSON(t, repo.StopsGeoJSON, "/api/v1/geojson/stops?bbox="+bbox)
+		assert.Equal(http.StatusBadRequest, rr.Code, bbox)
+		assert.Contains(rr.Body.String(), "invalid_bbox", bbox)
+	}
+}
+
+func Test_VehiclesGeoJSON(t *testing.T) {
+	assert := assert.New(t)
+	repo := setupGeoJSONApp()
+
+	rr, _ := serveGeoJSON(t, repo.VehiclesGeoJSON, "/api/v1/geojson/vehicles")
+	assert.Equal(http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(rr.Body.String(), "realtime_unavailable")
+
+	repo.Realtime = &testRealtimeSource{snapshot: testStreamSnapshot(
+		vehicleEntity("bus-1", "1426", -34.60),
+		vehicleEntity("bus-2", "1500", -34.80),
+	)}
+
+	_, collection := serveGeoJSON(t, repo.VehiclesGeoJSON, "/api/v1/geojson/vehicles")
+	assert.Equal([]string{"bus-1", "bus-2"}, featureIDs(collection))
+	assert.Equal("1426", collection.Features[0].Properties["route_id"])
+	assert.Equal("Point", collection.Features[0].Geometry.Type)
+
+	_, collection = serveGeoJSON(t, repo.VehiclesGeoJSON, "/api/v1/geojson/vehicles?line=60")
+	assert.Equal([]string{"bus-2"}, featureIDs(collection))
+
+	_, collection = serveGeoJSON(t, repo.VehiclesGeoJSON, "/api/v1/geojson/vehicles?bbox=-58.45,-34.65,-58.35,-34.55")
+	assert.Equal([]string{"bus-1"}, featureIDs(collection))
+
+	// a line without routes has no vehicles, rather than all of them
+	_, collection = serveGeoJSON(t, repo.VehiclesGeoJSON, "/api/v1/geojson/vehicles?line=999")
+	assert.Empty(collection.Features)
+}
+
+func Test_ParkingGeoJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("ParkingRules", mock.Anything, -34.6037, -58.3816).Return(services.SimplifiedRules{
+		"CORRIENTES AV. 1050": {"Lado par: prohibido estacionar las 24 hs."},
+		"FLORIDA 100":         {"Lado impar: prohibido estacionar las 24 hs."},
+	}, nil)
+	mockAPIClient.On("ParkingRules", mock.Anything, -34.6040, -58.3816).Return(nil, errors.New("upstream error"))
+	repo, _ := setupTestApp(mockAPIClient)
+	streets, err := geo.ReadStreets(strings.NewReader("wkt,nomoficial,alt_izqini,alt_izqfin,alt_derini,alt_derfin\n" +
+		"\"LINESTRING (-58.3830 -34.6035, -58.3850 -34.6036)\",CORRIENTES AV.,1001,1099,1000,1098\n"))
+	require.NoError(t, err)
+	repo.Streets = geo.NewGeocoder(streets)
+
+	rr, collection := serveGeoJSON(t, repo.ParkingGeoJSON, "/api/v1/geojson/parking?lat=-34.6037&lon=-58.3816")
+	assert.Equal(http.StatusOK, rr.Code)
+	required := require.New(t)
+	required.Len(collection.Features, 2)
+	corrientes, florida := collection.Features[0], collection.Features[1]
+	assert.Equal("CORRIENTES AV. 1050", corrientes.Properties["address"])
+	assert.Equal([]any{"Lado par: prohibido estacionar las 24 hs."}, corrientes.Properties["rules"])
+	required.NotNil(corrientes.Geometry)
+	assert.Equal("Point", corrientes.Geometry.Type)
+	// the addresses not found among the streets have no geometry
+	assert.Equal("FLORIDA 100", florida.Properties["address"])
+	assert.Nil(florida.Geometry)
+	assert.Contains(rr.Body.String(), `"geometry":null`)
+
+	// outside the city there are no rules
+	_, collection = serveGeoJSON(t, repo.ParkingGeoJSON, "/api/v1/geojson/parking?lat=-34.6625&lon=-58.3650")
+	assert.Empty(collection.Features)
+
+	for _, query := range []string{"", "lat=-34.6037", "lat=40.7128&lon=-74.0060", "lat=south&lon=-58.3816"} {
+		rr, _ = serveGeoJSON(t, repo.ParkingGeoJSON, "/api/v1/geojson/parking?"+query)
+		assert.Equal(http.StatusBadRequest, rr.Code, query)
+		assert.Contains(rr.Body.String(), "invalid_point", query)
+	}
+
+	rr, _ = serveGeoJSON(t, repo.ParkingGeoJSON, "/api/v1/geojson/parking?lat=-34.6040&lon=-58.3816")
+	assert.Equal(http.StatusBadGateway, rr.Code)
+}
+
+func Test_ParkingGeoJSON_RateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("ParkingRules", mock.Anything, -34.6037, -58.3816).Return(services.SimplifiedRules{}, nil)
+	repo, _ := setupTestApp(mockAPIClient)
+	repo.ParkingLimiter = ratelimit.New(1)
+
+	rr, _ := serveGeoJSON(t, repo.ParkingGeoJSON, "/api/v1/geojson/parking?lat=-34.6037&lon=-58.3816")
+	assert.Equal(http.StatusOK, rr.Code)
+	rr, _ = serveGeoJSON(t, repo.ParkingGeoJSON, "/api/v1/geojson/parking?lat=-34.6037&lon=-58.3816")
+	assert.Equal(http.StatusTooManyRequests, rr.Code)
+	assert.Contains(rr.Body.String(), "rate_limited")
+	assert.Equal("60", rr.Header().Get("Retry-After"))
+	mockAPIClient.AssertNumberOfCalls(t, "ParkingRules", 1)
+}
+
+func mustJSON(t *testing.T, v any) string {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(b)
+}
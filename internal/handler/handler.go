@@ -4,38 +4,65 @@ package handler
 import (
 	"errors"
 	"fmt"
-	"html/template"
-	"io"
-	"log"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
-	"os"
+	"net/netip"
+	"net/url"
 	"strconv"
-	"strings"
-
-	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
-	"google.golang.org/protobuf/proto"
+	"time"
 
+	"github.com/mayloo89/bamos/internal/accounts"
+	"github.com/mayloo89/bamos/internal/analytics"
+	"github.com/mayloo89/bamos/internal/apperror"
 	"github.com/mayloo89/bamos/internal/config"
 	"github.com/mayloo89/bamos/internal/forms"
-	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/geo"
+	"github.com/mayloo89/bamos/internal/health"
+	"github.com/mayloo89/bamos/internal/hub"
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/maps"
 	"github.com/mayloo89/bamos/internal/model"
+	"github.com/mayloo89/bamos/internal/parking"
+	"github.com/mayloo89/bamos/internal/ratelimit"
+	"github.com/mayloo89/bamos/internal/realtime"
 	"github.com/mayloo89/bamos/internal/render"
 	"github.com/mayloo89/bamos/internal/services"
+	"github.com/mayloo89/bamos/internal/webhooks"
 	"github.com/mayloo89/bamos/utils"
 )
 
-var clientID = "REPLACED"
-var clientSecret = "REPLACED"
-var apiBaseURL = "https://apitransporte.buenosaires.gob.ar"
-
 type (
 	// Repository holds the application config and API client for handlers.
 	Repository struct {
 		App       *config.AppConfig  // Application configuration
 		APIClient services.APIClient // API client for external services
+		Realtime  realtime.Source    // Latest realtime feeds snapshot, optional
+		Hub       *hub.Hub           // Realtime updates for WebSocket clients, optional
+		Analytics analytics.Reports  // Computed history analytics, optional
+		Health    *health.Checker    // Readiness checks, optional
+		Accounts  *accounts.Accounts // User accounts and favorites, optional
+		Parking   parking.Store      // Spots where the users parked, optional
+		Webhooks  webhooks.Store     // Webhook subscriptions of the users, optional
+		Resolver  webhooks.Resolver  // Host lookups of the webhook URLs, net.DefaultResolver when nil
+		Streets   *geo.Geocoder      // Addresses of the CABA streets, optional
+		Geocoder  maps.Geocoder      // Address searches of the map, optional
+
+		GeocodeLimiter *ratelimit.Limiter // Address searches of each client, unlimited when nil
+		ParkingLimiter *ratelimit.Limiter // Parking rules lookups of the GeoJSON export of each client, unlimited when nil
+		TrustedProxies []netip.Prefix     // Proxies whose X-Forwarded-For header names the client, none when empty
 	}
 )
 
+// Errors answered by the form handlers.
+var (
+	errInvalidForm     = apperror.BadRequest("invalid_form", "The form could not be read, please submit it again.")
+	errMissingLocation = apperror.BadRequest("missing_location", "Choose a location on the map before searching.")
+	errInvalidLocation = apperror.BadRequest("invalid_location", "The location is not valid, please choose it again on the map.")
+	errRateLimited     = apperror.New(http.StatusTooManyRequests, "rate_limited", "Too many requests, please wait a moment before trying again.")
+)
+
 // NewRepo creates a new Repository with the given AppConfig and APIClient.
 func NewRepo(a *config.AppConfig, apiClient services.APIClient) *Repository {
 	return &Repository{
@@ -44,290 +71,267 @@ func NewRepo(a *config.AppConfig, apiClient services.APIClient) *Repository {
 	}
 }
 
-// Home renders the home page.
-func (m *Repository) Home(w http.ResponseWriter, r *http.Request) {
-
-	stringMap := make(map[string]string)
-	stringMap["test"] = "Hello again."
-
-	err := render.RenderTemplate(w, r, "home.page.tmpl", &model.TemplateData{
-		StringMap: stringMap,
-	})
-	if err != nil {
-		helpers.ServerError(w, err)
+// logger returns the application logger, the default one when it is not set up.
+func (m *Repository) logger() *slog.Logger {
+	if m.App == nil || m.App.Logger == nil {
+		return slog.Default()
 	}
+	return m.App.Logger
 }
 
-// HTTPClient is an interface for making HTTP requests, used for dependency injection.
-type HTTPClient interface {
-	Do(req *http.Request) (*http.Response, error)
+// resolver returns the resolver of the webhook URLs, the default one when it is not set up.
+func (m *Repository) resolver() webhooks.Resolver {
+	if m.Resolver == nil {
+		return net.DefaultResolver
+	}
+	return m.Resolver
 }
 
-var httpClient HTTPClient = &http.Client{} // Default to the real HTTP client
+// allow spends a request of the client of r, behind the trusted proxies, with
+// limiter, answering errRateLimited with a Retry-After header when it has none left.
+// A nil limiter allows them all.
+func (m *Repository) allow(w http.ResponseWriter, r *http.Request, limiter *ratelimit.Limiter) error {
+	if limiter == nil {
+		return nil
+	}
+	client := ratelimit.ClientIP(r, m.TrustedProxies)
+	if !limiter.Allow(client) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limiter.RetryAfter().Seconds()))))
+		return errRateLimited.WithCause(fmt.Errorf("client %s exceeded its requests", client))
+	}
+	return nil
+}
 
-// SetHTTPClient sets the global HTTP client for use in handlers.
-func SetHTTPClient(client HTTPClient) {
-	httpClient = client
+// locale returns the locale of the request.
+func locale(r *http.Request) i18n.Locale {
+	return i18n.FromContext(r.Context())
 }
 
-// VehiclePositionsSimple fetches and displays simple vehicle positions from the API.
-func (m *Repository) VehiclePositionsSimple(w http.ResponseWriter, r *http.Request) {
-	stringMap := make(map[string]string)
-	urlBase := apiBaseURL + "/colectivos/vehiclePositionsSimple"
+// translate returns the message of key in the locale of the request, see i18n.Locale.T.
+func translate(r *http.Request, key string, args ...interface{}) string {
+	return locale(r).T(key, args...)
+}
 
-	req, err := http.NewRequest("GET", urlBase, nil)
-	if err != nil {
-		stringMap["error"] = err.Error()
-	}
+// Home renders the home page.
+func (m *Repository) Home(w http.ResponseWriter, r *http.Request) error {
 
-	q := req.URL.Query()
-	q.Add("client_id", clientID)
-	q.Add("client_secret", clientSecret)
+	stringMap := make(map[string]string)
+	stringMap["test"] = "Hello again."
 
-	req.URL.RawQuery = q.Encode()
+	return render.RenderTemplate(w, r, "home.page.tmpl", &model.TemplateData{
+		StringMap: stringMap,
+	})
+}
 
-	resp, err := httpClient.Do(req) // Use the injected HTTP client
-	if err != nil {
-		stringMap["error"] = err.Error()
-	}
+// VehiclePositionsSimple displays the vehicle positions from the latest realtime snapshot.
+// The optional "line" query parameter restricts the result to the routes of that bus line.
+func (m *Repository) VehiclePositionsSimple(w http.ResponseWriter, r *http.Request) error {
+	stringMap := make(map[string]string)
+	data := make(map[string]interface{})
 
-	if resp.StatusCode != http.StatusOK {
-		stringMap["error"] = fmt.Sprintf("Error getting request, response code: %d", resp.StatusCode)
-	}
+	line := r.URL.Query().Get("line")
+	data["line"] = line
 
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			log.Println("error closing response body:", cerr)
+	if m.Realtime == nil {
+		stringMap["error"] = translate(r, "positions.unavailable")
+	} else {
+		snapshot := m.Realtime.Snapshot()
+		feed := snapshot.Feed(services.FeedVehiclePositions)
+
+		routeIDs := m.RouteIDs(line)
+
+		switch {
+		case feed == nil || feed.Message == nil:
+			stringMap["error"] = translate(r, "positions.not_received")
+		case line != "" && len(routeIDs) == 0:
+			stringMap["error"] = translate(r, "line.not_found", line)
+		default:
+			data["vehicles"] = snapshot.Vehicles(routeIDs...)
+			data["updated"] = feed.FetchedAt.Format(time.DateTime)
+			if feed.Stale(time.Now(), realtime.DefaultMaxAge) {
+				stringMap["warning"] = translate(r, "positions.stale")
+			}
 		}
-	}()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		stringMap["error"] = err.Error()
 	}
 
-	stringMap["response"] = string(body)
-
-	err = render.RenderTemplate(w, r, "positionsimple.page.tmpl", &model.TemplateData{
+	return render.RenderTemplate(w, r, "positionsimple.page.tmpl", &model.TemplateData{
 		StringMap: stringMap,
+		Data:      data,
 	})
-	if err != nil {
-		helpers.ServerError(w, err)
+}
+
+// RouteIDs returns the IDs of the routes of the given bus line, nil when line is empty.
+func (m *Repository) RouteIDs(line string) []string {
+	if line == "" {
+		return nil
 	}
+
+	var ids []string
+	for _, route := range utils.SearchLine(line, m.App.DataCache.Routes) {
+		ids = append(ids, route.ID)
+	}
+	return ids
 }
 
-// SearchLine renders the search page for bus lines.
-func (m *Repository) SearchLine(w http.ResponseWriter, r *http.Request) {
+// SearchLine renders the search page for bus lines, with the routes of the
+// optional "line" query parameter the search form redirects to.
+func (m *Repository) SearchLine(w http.ResponseWriter, r *http.Request) error {
 	data := make(map[string]interface{})
-	data["line"] = ""
 
-	err := render.RenderTemplate(w, r, "search.page.tmpl", &model.TemplateData{
+	line := r.URL.Query().Get("line")
+	data["line"] = line
+	if line != "" {
+		if result := utils.SearchLine(line, m.App.DataCache.Routes); len(result) > 0 {
+			data["result"] = result
+		}
+	}
+
+	return render.RenderTemplate(w, r, "search.page.tmpl", &model.TemplateData{
 		Form: forms.New(nil),
 		Data: data,
 	})
-	if err != nil {
-		helpers.ServerError(w, err)
-	}
 }
 
 // TODO: consider to use a json for the form values
 // by doing this we could expose the SearchLine as an API request
-// PostSearchLine handles POST requests for searching bus lines.
-func (m *Repository) PostSearchLine(w http.ResponseWriter, r *http.Request) {
+// PostSearchLine handles POST requests for searching bus lines, redirecting to
+// the results with a flash message.
+func (m *Repository) PostSearchLine(w http.ResponseWriter, r *http.Request) error {
 	data := make(map[string]interface{})
 
-	err := r.ParseForm()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := r.ParseForm(); err != nil {
+		return errInvalidForm.WithCause(err)
 	}
 
 	line := r.Form.Get("line")
 
 	form := forms.New(r.PostForm)
 	form.Required("line")
+	form.IntRange("line", 1, 999)
 
 	if !form.Valid() {
-		err := render.RenderTemplate(w, r, "search.page.tmpl", &model.TemplateData{
+		data["line"] = line
+		return render.RenderTemplate(w, r, "search.page.tmpl", &model.TemplateData{
 			Form: form,
 			Data: data,
 		})
-		if err != nil {
-			helpers.ServerError(w, err)
-		}
-
-		return
 	}
 
 	result := utils.SearchLine(line, m.App.DataCache.Routes)
-	resultString := strings.ReplaceAll(fmt.Sprintf("%+v", result), "} {", "} <br> {")
-
-	data["result"] = template.HTML(resultString)
-	data["line"] = line
-
-	err = render.RenderTemplate(w, r, "search.page.tmpl", &model.TemplateData{
-		Form: form,
-		Data: data,
-	})
-	if err != nil {
-		helpers.ServerError(w, err)
+	if len(result) == 0 {
+		m.App.Session.Put(r.Context(), "warning", translate(r, "line.not_found", line))
+	} else {
+		m.App.Session.Put(r.Context(), "flash", translate(r, "search.found", len(result), line))
 	}
+
+	http.Redirect(w, r, "/colectivos/search?line="+url.QueryEscape(line), http.StatusSeeOther)
+	return nil
 }
 
-// AllowedParking renders the allowed parking page.
-func (m *Repository) AllowedParking(w http.ResponseWriter, r *http.Request) {
-	googleMapsAPIKey := os.Getenv("GOOGLE_MAPS_API_KEY")
-	data := map[string]interface{}{
-		"google_maps_api_key": googleMapsAPIKey,
+// AllowedParking renders the allowed parking page, with the result of the search
+// the form redirected from, if any.
+func (m *Repository) AllowedParking(w http.ResponseWriter, r *http.Request) error {
+	data := make(map[string]interface{})
+
+	if m.App.Session != nil {
+		if search, ok := m.App.Session.Pop(r.Context(), "parking_search").(model.ParkingSearch); ok {
+			data["address"] = search.Address
+			data["latitude"] = search.Latitude
+			data["longitude"] = search.Longitude
+			if len(search.Rules) > 0 {
+				data["rules"] = search.Rules
+			}
+		}
 	}
-	err := render.RenderTemplate(w, r, "allowedparking.page.tmpl", &model.TemplateData{
+
+	return render.RenderTemplate(w, r, "allowedparking.page.tmpl", &model.TemplateData{
 		Data: data,
 	})
-	if err != nil {
-		helpers.ServerError(w, err)
-	}
 }
 
 // PostAllowedParking handles POST requests for allowed parking queries.
-// It validates input, calls the ParkingRules API, and renders the result.
-func (m *Repository) PostAllowedParking(w http.ResponseWriter, r *http.Request) {
+// It validates input, calls the ParkingRules API, and redirects to the result.
+func (m *Repository) PostAllowedParking(w http.ResponseWriter, r *http.Request) error {
 	// Check for nil repository or config
 	if m == nil || m.App == nil {
-		log.Println("Repository or AppConfig is nil in PostAllowedParking")
-		helpers.ServerError(w, fmt.Errorf("repository or AppConfig is nil"))
-		return
+		return errors.New("repository or AppConfig is nil in PostAllowedParking")
 	}
 
-	data := make(map[string]interface{})
-
 	// Parse the form data
 	if err := r.ParseForm(); err != nil {
-		log.Println("Error parsing form data:", err)
-		helpers.ServerError(w, err)
-		return
+		return errInvalidForm.WithCause(err)
 	}
 
 	// Validate latitude and longitude
-	latStr := r.Form.Get("latitude")
-	lonStr := r.Form.Get("longitude")
-	if latStr == "" || lonStr == "" {
-		log.Println("Missing latitude or longitude in form data")
-		helpers.ClientError(w, http.StatusBadRequest)
-		return
-	}
-
-	lat, err := strconv.ParseFloat(latStr, 64)
-	if err != nil {
-		log.Println("Invalid latitude value:", latStr)
-		helpers.ClientError(w, http.StatusBadRequest)
-		return
+	form := forms.New(r.PostForm)
+	form.Required("latitude", "longitude")
+	if !form.Valid() {
+		return errMissingLocation
 	}
-
-	lon, err := strconv.ParseFloat(lonStr, 64)
-	if err != nil {
-		log.Println("Invalid longitude value:", lonStr)
-		helpers.ClientError(w, http.StatusBadRequest)
-		return
+	lat, lon, _ := form.LatLon("latitude", "longitude")
+	if !form.Valid() {
+		return errInvalidLocation.WithCause(fmt.Errorf("invalid location %q, %q: %v", form.Get("latitude"), form.Get("longitude"), form.Errors))
 	}
+	point := geo.Point{Lat: lat, Lon: lon}
 
-	// Call the ParkingRules service
-	rules, err := m.APIClient.ParkingRules(lat, lon)
-	if err != nil && !errors.Is(err, services.ErrNoParkingRules) {
-		log.Println("Error calling ParkingRules service:", err)
-		helpers.ServerError(w, err)
-		return
+	address := form.Get("address")
+	if address == "" && m.Streets != nil {
+		if a, ok := m.Streets.Reverse(point); ok {
+			address = a.String()
+		}
 	}
 
-	// Populate the data map for the template
-	data["address"] = r.Form.Get("address")
-	data["latitude"] = latStr
-	data["longitude"] = lonStr
-	if rules != nil {
-		data["rules"] = rules
-	}
-	if errors.Is(err, services.ErrNoParkingRules) {
-		data["error"] = "No parking rules found for the specified location."
+	// the API only has the parking rules of the city, it answers none for the rest of the AMBA
+	var rules services.SimplifiedRules
+	inCABA := geo.CABA.Contains(point)
+	if inCABA {
+		var err error
+		rules, err = m.APIClient.ParkingRules(r.Context(), lat, lon)
+		if err != nil && !errors.Is(err, services.ErrNoParkingRules) {
+			return apperror.Upstream(fmt.Errorf("error calling ParkingRules service: %w", err))
+		}
 	}
 
-	googleMapsAPIKey := os.Getenv("GOOGLE_MAPS_API_KEY")
-	data["google_maps_api_key"] = googleMapsAPIKey
-
-	// Render the allowed parking template with the data
-	err = render.RenderTemplate(w, r, "allowedparking.page.tmpl", &model.TemplateData{
-		Data: data,
+	// Keep the result in the session for the page the form redirects to
+	m.App.Session.Put(r.Context(), "parking_search", model.ParkingSearch{
+		Address:   address,
+		Latitude:  form.Get("latitude"),
+		Longitude: form.Get("longitude"),
+		Rules:     rules,
 	})
-	if err != nil {
-		log.Println("Error rendering template:", err)
-		helpers.ServerError(w, err)
+	switch {
+	case !inCABA:
+		m.App.Session.Put(r.Context(), "warning", translate(r, "parking.outside_caba"))
+	case len(rules) == 0:
+		m.App.Session.Put(r.Context(), "warning", translate(r, "parking.not_found"))
+	default:
+		m.App.Session.Put(r.Context(), "flash", translate(r, "parking.found", len(rules)))
 	}
+
+	http.Redirect(w, r, "/transit/allowed-parking", http.StatusSeeOther)
+	return nil
 }
 
-// FIXME: this func doesn't work
-// FeedGtfsFrequency fetches GTFS frequency data from the API and prints trip IDs.
-func (m *Repository) FeedGtfsFrequency(w http.ResponseWriter, r *http.Request) {
+// FeedGtfsFrequency fetches the GTFS frequency feed from the API and prints trip IDs.
+func (m *Repository) FeedGtfsFrequency(w http.ResponseWriter, r *http.Request) error {
 	if m == nil {
-		log.Println("Repository is nil in FeedGtfsFrequency")
-		helpers.ServerError(w, fmt.Errorf("repository is nil"))
-		return
+		return errors.New("repository is nil in FeedGtfsFrequency")
 	}
 
-	stringMap := make(map[string]string)
-
-	urlBase := apiBaseURL + "/colectivos/feed-gtfs-frequency"
-
-	req, err := http.NewRequest("GET", urlBase, nil)
+	feed, err := m.APIClient.RealtimeFeed(r.Context(), services.FeedGtfsFrequency)
 	if err != nil {
-		stringMap["error"] = err.Error()
-		return
-	}
-
-	q := req.URL.Query()
-	q.Add("client_id", clientID)
-	q.Add("client_secret", clientSecret)
-
-	req.URL.RawQuery = q.Encode()
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		stringMap["error"] = err.Error()
-	}
-
-	if resp == nil {
-		stringMap["error"] = "Response is nil"
-		return
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		stringMap["error"] = fmt.Sprintf("Error getting request, response code: %d", resp.StatusCode)
-	}
-
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			log.Println("error closing response body:", cerr)
-		}
-	}()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		stringMap["error"] = err.Error()
-	}
-
-	feed := gtfs.FeedMessage{}
-	err = proto.Unmarshal(body, &feed)
-	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("error fetching the GTFS frequency feed: %w", err)
 	}
 
 	for _, entity := range feed.Entity {
 		tripUpdate := entity.GetTripUpdate()
 		trip := tripUpdate.GetTrip()
 		tripId := trip.GetTripId()
-		fmt.Printf("Trip ID: %s\n", tripId)
+		m.logger().DebugContext(r.Context(), "gtfs frequency trip", "trip_id", tripId)
 	}
 
-	// stringMap["response"] = string(body)
-
 	// render.RenderTemplate(w, "positionsimple.page.tmpl", &model.TemplateData{
 	// 	StringMap: stringMap,
 	// })
+	return nil
 }
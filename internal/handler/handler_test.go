@@ -1,28 +1,39 @@
 package handler
 
 import (
-	"log"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/alexedwards/scs/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/mayloo89/bamos/internal/config"
+	"github.com/mayloo89/bamos/internal/geo"
 	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/maps"
+	"github.com/mayloo89/bamos/internal/realtime"
 	"github.com/mayloo89/bamos/internal/render"
 	"github.com/mayloo89/bamos/internal/services"
+	"github.com/mayloo89/bamos/utils"
 )
 
 func setupTestApp(mockAPIClient services.APIClient) (*Repository, *config.AppConfig) {
 	app := &config.AppConfig{
 		InProduction: false,
-		InfoLog:      log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
+		Logger:       slog.New(slog.NewTextHandler(os.Stdout, nil)),
 	}
 	repo := NewRepo(app, mockAPIClient)
 	render.NewTemplates(app)
@@ -41,7 +52,7 @@ func Test_Home(t *testing.T) {
 	require.NoError(t, err)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(repo.Home)
+	handler := helpers.HandlerFunc(repo.Home)
 
 	handler.ServeHTTP(rr, req)
 
@@ -59,13 +70,48 @@ func Test_VehiclePositionsSimple(t *testing.T) {
 	require.NoError(t, err)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(repo.VehiclePositionsSimple)
+	handler := helpers.HandlerFunc(repo.VehiclePositionsSimple)
 
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 }
 
+func Test_VehiclePositionsSimple_Snapshot(t *testing.T) {
+	mockAPIClient := new(services.MockAPIClient)
+	repo, app := setupTestApp(mockAPIClient)
+	app.DataCache.Routes = []utils.Route{{ID: "1426", ShortName: "505R3"}}
+	repo.Realtime = &testRealtimeSource{snapshot: &realtime.Snapshot{Feeds: map[services.Feed]*realtime.FeedSnapshot{
+		services.FeedVehiclePositions: {
+			FetchedAt: time.Now(),
+			Timestamp: time.Now(),
+			Message: &gtfs.FeedMessage{Entity: []*gtfs.FeedEntity{
+				{
+					Id: proto.String("1"),
+					Vehicle: &gtfs.VehiclePosition{
+						Vehicle:  &gtfs.VehicleDescriptor{Id: proto.String("bus-505")},
+						Trip:     &gtfs.TripDescriptor{RouteId: proto.String("1426")},
+						Position: &gtfs.Position{Latitude: proto.Float32(-34.6), Longitude: proto.Float32(-58.4)},
+					},
+				},
+			}},
+		},
+	}}}
+
+	req, err := http.NewRequest("GET", "/colectivos/vehiclePositionsSimple?line=505", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := helpers.HandlerFunc(repo.VehiclePositionsSimple)
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "bus-505")
+	// the upstream API must not be called on page views
+	mockAPIClient.AssertNotCalled(t, "RealtimeFeed", mock.Anything, mock.Anything)
+}
+
 func Test_SearchLine(t *testing.T) {
 	// Create a mock API client
 	mockAPIClient := new(services.MockAPIClient)
@@ -77,7 +123,7 @@ func Test_SearchLine(t *testing.T) {
 	require.NoError(t, err)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(repo.SearchLine)
+	handler := helpers.HandlerFunc(repo.SearchLine)
 
 	handler.ServeHTTP(rr, req)
 
@@ -89,21 +135,47 @@ func Test_PostSearchLine_Success(t *testing.T) {
 	mockAPIClient := new(services.MockAPIClient)
 	mockAPIClient.On("AllowedParking", mock.Anything, mock.Anything).Return(nil, nil)
 
-	repo, _ := setupTestApp(mockAPIClient)
+	repo, app := setupTestApp(mockAPIClient)
+	setupTestSession(app)
 
 	form := url.Values{}
-	form.Add("line", "test-line")
+	form.Add("line", "60")
 
 	req, err := http.NewRequest("POST", "/colectivos/search", strings.NewReader(form.Encode()))
 	require.NoError(t, err)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(repo.PostSearchLine)
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostSearchLine), req)
 
-	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "/colectivos/search?line=60", rr.Header().Get("Location"))
+}
 
-	assert.Equal(t, http.StatusOK, rr.Code)
+func Test_PostSearchLine_Redirect(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	repo, app := setupTestApp(new(services.MockAPIClient))
+	setupTestSession(app)
+	app.DataCache.Routes = []utils.Route{{ID: "1426", ShortName: "505R3"}}
+
+	req, err := http.NewRequest("POST", "/colectivos/search", strings.NewReader("line=505"))
+	required.NoError(err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostSearchLine), req)
+	required.Equal(http.StatusSeeOther, rr.Code)
+
+	// the redirected page shows the result and the flash message once
+	get := redirectRequest(t, rr)
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.SearchLine), get)
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Contains(rr.Body.String(), "Found 1 routes for line 505.")
+	assert.Contains(rr.Body.String(), "505R3")
+
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.SearchLine), get)
+	assert.NotContains(rr.Body.String(), "Found 1 routes for line 505.")
+	assert.Contains(rr.Body.String(), "505R3")
 }
 
 func Test_AllowedParking(t *testing.T) {
@@ -117,61 +189,90 @@ func Test_AllowedParking(t *testing.T) {
 	require.NoError(t, err)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(repo.AllowedParking)
+	handler := helpers.HandlerFunc(repo.AllowedParking)
 
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 }
 
+func Test_AllowedParking_Map(t *testing.T) {
+	assert := assert.New(t)
+
+	repo, app := setupTestApp(new(services.MockAPIClient))
+
+	// the map is drawn with Leaflet over OpenStreetMap by default
+	rr := httptest.NewRecorder()
+	helpers.HandlerFunc(repo.AllowedParking).ServeHTTP(rr, httptest.NewRequest("GET", "/transit/allowed-parking", nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Contains(rr.Body.String(), "leaflet.js")
+	assert.Contains(rr.Body.String(), "tile.openstreetmap.org")
+	assert.NotContains(rr.Body.String(), "maps.googleapis.com")
+
+	app.Map = maps.Provider{Name: maps.Google, APIKey: "test-key"}
+	rr = httptest.NewRecorder()
+	helpers.HandlerFunc(repo.AllowedParking).ServeHTTP(rr, httptest.NewRequest("GET", "/transit/allowed-parking", nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Contains(rr.Body.String(), "maps.googleapis.com")
+	assert.Contains(rr.Body.String(), "test-key")
+	assert.NotContains(rr.Body.String(), "leaflet.js")
+}
+
 func Test_PostAllowedParking_Success(t *testing.T) {
 	// Create a mock API client
 	mockAPIClient := new(services.MockAPIClient)
-	mockAPIClient.On("ParkingRules", 40.7128, -74.0060).Return(
+	mockAPIClient.On("ParkingRules", mock.Anything, -34.6037, -58.3816).Return(
 		services.SimplifiedRules{"Test Rule": {"Detail 1"}}, nil,
 	)
-	repo, _ := setupTestApp(mockAPIClient)
+	repo, app := setupTestApp(mockAPIClient)
+	setupTestSession(app)
 
 	form := url.Values{}
-	form.Add("latitude", "40.7128")
-	form.Add("longitude", "-74.0060")
+	form.Add("latitude", "-34.6037")
+	form.Add("longitude", "-58.3816")
 	form.Add("address", "Test Address")
 
 	req, err := http.NewRequest("POST", "/transit/allowed-parking", strings.NewReader(form.Encode()))
 	require.NoError(t, err)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(repo.PostAllowedParking)
-
-	handler.ServeHTTP(rr, req)
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostAllowedParking), req)
+	require.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "/transit/allowed-parking", rr.Header().Get("Location"))
 
+	// the redirected page shows the rules of the search
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.AllowedParking), redirectRequest(t, rr))
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Found parking rules for 1 addresses within 100 meters.")
+	assert.Contains(t, rr.Body.String(), "Detail 1")
+	assert.Contains(t, rr.Body.String(), "Test Address")
 }
 
 func Test_PostAllowedParking_EmptyRules(t *testing.T) {
 	// Create a mock API client
 	mockAPIClient := new(services.MockAPIClient)
-	mockAPIClient.On("ParkingRules", 40.7128, -74.0060).Return(
+	mockAPIClient.On("ParkingRules", mock.Anything, -34.6037, -58.3816).Return(
 		services.SimplifiedRules{}, nil,
 	)
-	repo, _ := setupTestApp(mockAPIClient)
+	repo, app := setupTestApp(mockAPIClient)
+	setupTestSession(app)
 
 	form := url.Values{}
-	form.Add("latitude", "40.7128")
-	form.Add("longitude", "-74.0060")
+	form.Add("latitude", "-34.6037")
+	form.Add("longitude", "-58.3816")
 	form.Add("address", "Test Address")
 
 	req, err := http.NewRequest("POST", "/transit/allowed-parking", strings.NewReader(form.Encode()))
 	require.NoError(t, err)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(repo.PostAllowedParking)
-
-	handler.ServeHTTP(rr, req)
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostAllowedParking), req)
+	require.Equal(t, http.StatusSeeOther, rr.Code)
 
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.AllowedParking), redirectRequest(t, rr))
 	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "No parking rules found for the specified location.")
+	assert.Contains(t, rr.Body.String(), "alert-warning")
 }
 
 func Test_PostAllowedParking_ValidationLatitudeError(t *testing.T) {
@@ -181,7 +282,7 @@ func Test_PostAllowedParking_ValidationLatitudeError(t *testing.T) {
 
 	form := url.Values{}
 	form.Add("latitude", "ERROR")
-	form.Add("longitude", "-74.0060")
+	form.Add("longitude", "-58.3816")
 	form.Add("address", "Test Address")
 
 	req, err := http.NewRequest("POST", "/transit/allowed-parking", strings.NewReader(form.Encode()))
@@ -189,11 +290,12 @@ func Test_PostAllowedParking_ValidationLatitudeError(t *testing.T) {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(repo.PostAllowedParking)
+	handler := helpers.HandlerFunc(repo.PostAllowedParking)
 
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "The location is not valid")
 }
 
 func Test_PostAllowedParking_ValidationLongitudeError(t *testing.T) {
@@ -202,7 +304,7 @@ func Test_PostAllowedParking_ValidationLongitudeError(t *testing.T) {
 	repo, _ := setupTestApp(mockAPIClient)
 
 	form := url.Values{}
-	form.Add("latitude", "40.7128")
+	form.Add("latitude", "-34.6037")
 	form.Add("longitude", "ERROR")
 	form.Add("address", "Test Address")
 
@@ -211,7 +313,7 @@ func Test_PostAllowedParking_ValidationLongitudeError(t *testing.T) {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(repo.PostAllowedParking)
+	handler := helpers.HandlerFunc(repo.PostAllowedParking)
 
 	handler.ServeHTTP(rr, req)
 
@@ -224,7 +326,7 @@ func Test_PostAllowedParking_ValidationLongitudeEmpty(t *testing.T) {
 	repo, _ := setupTestApp(mockAPIClient)
 
 	form := url.Values{}
-	form.Add("latitude", "40.7128")
+	form.Add("latitude", "-34.6037")
 	form.Add("address", "Test Address")
 
 	req, err := http.NewRequest("POST", "/transit/allowed-parking", strings.NewReader(form.Encode()))
@@ -232,13 +334,147 @@ func Test_PostAllowedParking_ValidationLongitudeEmpty(t *testing.T) {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(repo.PostAllowedParking)
+	handler := helpers.HandlerFunc(repo.PostAllowedParking)
 
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
 }
 
+func Test_PostAllowedParking_UpstreamError(t *testing.T) {
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("ParkingRules", mock.Anything, -34.6037, -58.3816).Return(
+		services.SimplifiedRules(nil), errors.New("dial tcp: connection refused"),
+	)
+	repo, _ := setupTestApp(mockAPIClient)
+
+	form := url.Values{}
+	form.Add("latitude", "-34.6037")
+	form.Add("longitude", "-58.3816")
+
+	req, err := http.NewRequest("POST", "/transit/allowed-parking", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	helpers.HandlerFunc(repo.PostAllowedParking).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Contains(t, rr.Body.String(), "The transport service is not responding")
+	assert.NotContains(t, rr.Body.String(), "connection refused")
+}
+
+func Test_PostSearchLine_OutOfRange(t *testing.T) {
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+
+	req, err := http.NewRequest("POST", "/colectivos/search", strings.NewReader("line=1000"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	helpers.HandlerFunc(repo.PostSearchLine).ServeHTTP(rr, req)
+
+	// the form is rendered again with the error and the value
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "This field must be between 1 and 999")
+	assert.Contains(t, rr.Body.String(), `value="1000"`)
+
+	req, err = http.NewRequest("POST", "/colectivos/search", strings.NewReader("line=1000"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	helpers.HandlerFunc(repo.PostSearchLine).ServeHTTP(rr, req.WithContext(i18n.WithLocale(req.Context(), i18n.Spanish)))
+	assert.Contains(t, rr.Body.String(), "Este campo debe estar entre 1 y 999")
+}
+
+func Test_PostAllowedParking_OutsideAMBA(t *testing.T) {
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+
+	form := url.Values{}
+	form.Add("latitude", "40.7128")
+	form.Add("longitude", "-74.0060")
+
+	req, err := http.NewRequest("POST", "/transit/allowed-parking", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	helpers.HandlerFunc(repo.PostAllowedParking).ServeHTTP(rr, req)
+
+	// the API is not called for places it does not cover
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "The location is not valid")
+}
+
+func Test_PostAllowedParking_OutsideCABA(t *testing.T) {
+	assert := assert.New(t)
+
+	// the API, which only has the rules of the city, is not called
+	repo, app := setupTestApp(new(services.MockAPIClient))
+	setupTestSession(app)
+
+	form := url.Values{}
+	form.Add("latitude", "-34.4708")
+	form.Add("longitude", "-58.5286")
+	form.Add("address", "San Isidro")
+
+	req, err := http.NewRequest("POST", "/transit/allowed-parking", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostAllowedParking), req)
+	require.Equal(t, http.StatusSeeOther, rr.Code)
+
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.AllowedParking), redirectRequest(t, rr))
+	assert.Contains(rr.Body.String(), "Parking rules are only available within the City of Buenos Aires.")
+	assert.Contains(rr.Body.String(), "San Isidro")
+}
+
+func Test_PostAllowedParking_ReverseGeocoding(t *testing.T) {
+	assert := assert.New(t)
+
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("ParkingRules", mock.Anything, -34.6034, -58.3838).Return(services.SimplifiedRules{}, nil)
+	repo, app := setupTestApp(mockAPIClient)
+	setupTestSession(app)
+	repo.Streets = geo.NewGeocoder([]geo.Street{{
+		Name:     "CORRIENTES AV.",
+		Path:     []geo.Point{{Lat: -34.6035, Lon: -58.3830}, {Lat: -34.6036, Lon: -58.3850}},
+		LeftFrom: 1001, LeftTo: 1099, RightFrom: 1000, RightTo: 1098,
+	}})
+
+	// the client did not send the address
+	form := url.Values{}
+	form.Add("latitude", "-34.6034")
+	form.Add("longitude", "-58.3838")
+
+	req, err := http.NewRequest("POST", "/transit/allowed-parking", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostAllowedParking), req)
+	require.Equal(t, http.StatusSeeOther, rr.Code)
+
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.AllowedParking), redirectRequest(t, rr))
+	assert.Contains(rr.Body.String(), "CORRIENTES AV. 1038")
+}
+
+func Test_PostSearchLine_InvalidForm(t *testing.T) {
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+
+	req, err := http.NewRequest("POST", "/colectivos/search", strings.NewReader("line=%zz"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	helpers.HandlerFunc(repo.PostSearchLine).ServeHTTP(rr, req)
+
+	// the search is not run after the form fails to parse
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "The form could not be read")
+	assert.NotContains(t, rr.Body.String(), `name="line"`)
+}
+
 // func Test_FeedGtfsFrequency(t *testing.T) {
 // 	setupTestApp()
 
@@ -246,9 +482,72 @@ func Test_PostAllowedParking_ValidationLongitudeEmpty(t *testing.T) {
 // 	require.NoError(t, err)
 
 // 	rr := httptest.NewRecorder()
-// 	handler := http.HandlerFunc(Repo.FeedGtfsFrequency)
+// 	handler := helpers.HandlerFunc(Repo.FeedGtfsFrequency)
 
 // 	handler.ServeHTTP(rr, req)
 
 // 	assert.Equal(t, http.StatusOK, rr.Code)
 // }
+
+// setupTestSession gives the app a session manager, needed by the handlers
+// redirecting with flash messages.
+func setupTestSession(app *config.AppConfig) {
+	app.Session = scs.New()
+}
+
+// serveWithSession serves the request through the session middleware, as the routes do.
+func serveWithSession(session *scs.SessionManager, h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	session.LoadAndSave(h).ServeHTTP(rr, req)
+	return rr
+}
+
+// redirectRequest returns the GET request of the redirect answered in rr, with its cookies.
+func redirectRequest(t *testing.T, rr *httptest.ResponseRecorder) *http.Request {
+	req, err := http.NewRequest("GET", rr.Header().Get("Location"), nil)
+	require.NoError(t, err)
+	for _, cookie := range rr.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+// testRealtimeSource is a realtime.Source returning a fixed snapshot and
+// forwarding the snapshots sent on updates to its subscriber.
+type testRealtimeSource struct {
+	snapshot *realtime.Snapshot
+	updates  chan *realtime.Snapshot
+}
+
+func (s *testRealtimeSource) Snapshot() *realtime.Snapshot {
+	return s.snapshot
+}
+
+func (s *testRealtimeSource) Subscribe() (<-chan *realtime.Snapshot, func()) {
+	if s.updates == nil {
+		s.updates = make(chan *realtime.Snapshot)
+	}
+	return s.updates, func() {}
+}
+
+func Test_FeedGtfsFrequency(t *testing.T) {
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("RealtimeFeed", mock.Anything, services.FeedGtfsFrequency).Return(&gtfs.FeedMessage{}, nil).Once()
+	mockAPIClient.On("RealtimeFeed", mock.Anything, services.FeedGtfsFrequency).Return(nil, errors.New("upstream error")).Once()
+
+	repo, app := setupTestApp(mockAPIClient)
+	app.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	req, err := http.NewRequest("GET", "/colectivos/feed-gtfs-frequency", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	helpers.HandlerFunc(repo.FeedGtfsFrequency).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	helpers.HandlerFunc(repo.FeedGtfsFrequency).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	mockAPIClient.AssertExpectations(t)
+}
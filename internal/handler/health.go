@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mayloo89/bamos/internal/health"
+)
+
+// Healthz reports the process is alive.
+func (m *Repository) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": health.StatusOK})
+}
+
+// Readyz runs the readiness checks, answering 503 when one of them fails so the
+// load balancer stops routing requests to this instance.
+func (m *Repository) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := health.Report{Status: health.StatusOK, Checks: map[string]health.CheckResult{}}
+	if m.Health != nil {
+		report = m.Health.Run(r.Context())
+	}
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+		for name, check := range report.Checks {
+			if check.Error != "" {
+				m.logger().WarnContext(r.Context(), "readiness check failed", "check", name, "error", check.Error)
+			}
+		}
+	}
+	writeJSON(w, status, report)
+}
+
+// Version reports the build information of the running binary.
+func (m *Repository) Version(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, health.ReadBuild())
+}
+
+// writeJSON writes v as an uncached JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	writeJSONAs(w, status, "application/json", v)
+}
+
+// writeJSONAs writes v as an uncached JSON response of the given media type.
+func writeJSONAs(w http.ResponseWriter, status int, contentType string, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
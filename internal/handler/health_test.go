@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/health"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+func Test_Healthz(t *testing.T) {
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.Healthz).ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{"status":"ok"}`, rr.Body.String())
+}
+
+func Test_Readyz(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	repo, app := setupTestApp(new(services.MockAPIClient))
+	var logs bytes.Buffer
+	app.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	// without checks the application is ready
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.Readyz).ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.JSONEq(`{"status":"ok","checks":{}}`, rr.Body.String())
+
+	ready := true
+	repo.Health = &health.Checker{}
+	repo.Health.Add("routes", func(context.Context) error { return nil })
+	repo.Health.Add("upstream", func(context.Context) error {
+		if !ready {
+			return errors.New("vehicle positions not received yet")
+		}
+		return nil
+	})
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(repo.Readyz).ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(http.StatusOK, rr.Code)
+
+	ready = false
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(repo.Readyz).ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(http.StatusServiceUnavailable, rr.Code)
+
+	var report health.Report
+	required.NoError(json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(health.StatusUnavailable, report.Status)
+	assert.Equal(health.StatusOK, report.Checks["routes"].Status)
+	assert.Equal(health.StatusUnavailable, report.Checks["upstream"].Status)
+	// the errors are logged, the probe is public
+	assert.NotContains(rr.Body.String(), "vehicle positions")
+	assert.Contains(logs.String(), `msg="readiness check failed" check=upstream error="vehicle positions not received yet"`)
+}
+
+func Test_Version(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.Version).ServeHTTP(rr, httptest.NewRequest("GET", "/version", nil))
+	assert.Equal(http.StatusOK, rr.Code)
+
+	var build health.Build
+	required.NoError(json.Unmarshal(rr.Body.Bytes(), &build))
+	assert.NotEmpty(build.Version)
+	assert.NotEmpty(build.GoVersion)
+}
@@ -1,19 +0,0 @@
-package mock
-
-import (
-	"net/http"
-
-	"github.com/stretchr/testify/mock"
-)
-
-type MockAPIClient struct {
-	mock.Mock
-}
-
-func (m *MockAPIClient) Do(req *http.Request) (*http.Response, error) {
-	args := m.Called(req)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*http.Response), args.Error(1)
-}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/apperror"
+	"github.com/mayloo89/bamos/internal/forms"
+	"github.com/mayloo89/bamos/internal/geo"
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/parking"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+// spotStatus is the spot of the user with the first time parking is forbidden there.
+type spotStatus struct {
+	parking.Spot
+	ForbiddenFrom time.Time
+	ForbiddenNow  bool
+	Rule          string
+}
+
+var (
+	errParkingUnavailable = apperror.Unavailable("parking_unavailable", "Saved parking spots are not available right now.")
+	errSpotNotFound       = apperror.BadRequest("spot_not_found", "The address has no parking rules anymore, please search it again.")
+)
+
+// PostParkHere saves an address of the allowed parking results, with its rules,
+// as the spot where the logged in user left the car. The rules are fetched again
+// for the location instead of taken from the form.
+func (m *Repository) PostParkHere(w http.ResponseWriter, r *http.Request) error {
+	if m.Parking == nil {
+		return errParkingUnavailable
+	}
+	if err := r.ParseForm(); err != nil {
+		return errInvalidForm.WithCause(err)
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("address", "latitude", "longitude")
+	if !form.Valid() {
+		return errMissingLocation
+	}
+	lat, lon, _ := form.LatLon("latitude", "longitude")
+	if !form.Valid() {
+		return errInvalidLocation.WithCause(fmt.Errorf("invalid location %q, %q: %v", form.Get("latitude"), form.Get("longitude"), form.Errors))
+	}
+
+	// only the addresses of the city have parking rules, see PostAllowedParking
+	address := form.Get("address")
+	if !geo.CABA.Contains(geo.Point{Lat: lat, Lon: lon}) {
+		return errSpotNotFound.WithCause(fmt.Errorf("%s is outside the city", address))
+	}
+	rules, err := m.APIClient.ParkingRules(r.Context(), lat, lon)
+	if err != nil && !errors.Is(err, services.ErrNoParkingRules) {
+		return apperror.Upstream(fmt.Errorf("error calling ParkingRules service: %w", err))
+	}
+	addressRules, ok := rules[address]
+	if !ok {
+		return errSpotNotFound.WithCause(fmt.Errorf("no parking rules of %s", address))
+	}
+
+	spot := &parking.Spot{
+		UserID:    helpers.UserID(r),
+		Address:   address,
+		Latitude:  lat,
+		Longitude: lon,
+		Rules:     addressRules,
+		ParkedAt:  time.Now(),
+	}
+	if err := m.Parking.Park(r.Context(), spot); err != nil {
+		return err
+	}
+
+	status := newSpotStatus(*spot, spot.ParkedAt)
+	switch {
+	case status.ForbiddenNow:
+		m.App.Session.Put(r.Context(), "warning", translate(r, "spot.forbidden_now", address, locale(r).Rule(status.Rule)))
+	case !status.ForbiddenFrom.IsZero():
+		m.App.Session.Put(r.Context(), "flash", translate(r, "spot.forbidden_from",
+			address, locale(r).DayName(status.ForbiddenFrom.Weekday()), status.ForbiddenFrom.Format("15:04")))
+	default:
+		m.App.Session.Put(r.Context(), "flash", translate(r, "spot.allowed", address))
+	}
+
+	http.Redirect(w, r, "/my", http.StatusSeeOther)
+	return nil
+}
+
+// PostLeaveSpot forgets the spot of the logged in user, who took the car.
+func (m *Repository) PostLeaveSpot(w http.ResponseWriter, r *http.Request) error {
+	if m.Parking == nil {
+		return errParkingUnavailable
+	}
+	if err := m.Parking.Leave(r.Context(), helpers.UserID(r)); err != nil {
+		return err
+	}
+
+	m.App.Session.Put(r.Context(), "flash", translate(r, "spot.removed"))
+	http.Redirect(w, r, "/my", http.StatusSeeOther)
+	return nil
+}
+
+// newSpotStatus returns the status of the spot at the given time.
+func newSpotStatus(spot parking.Spot, now time.Time) spotStatus {
+	status := spotStatus{Spot: spot}
+	if from, rule, ok := spot.ForbiddenFrom(now.In(parking.Location)); ok {
+		status.ForbiddenFrom, status.Rule = from, rule
+		status.ForbiddenNow = !from.After(now)
+	}
+	return status
+}
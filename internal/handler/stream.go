@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/apperror"
+	"github.com/mayloo89/bamos/internal/model"
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/render"
+)
+
+// streamHeartbeatInterval is how often a comment is sent to keep idle streams open.
+var streamHeartbeatInterval = 15 * time.Second
+
+// streamRetry is the reconnection delay, in milliseconds, suggested to stream clients.
+const streamRetry = 5000
+
+// errRealtimeUnavailable is answered by the realtime endpoints without a realtime source.
+var errRealtimeUnavailable = apperror.Unavailable("realtime_unavailable", "The live vehicle positions are not available right now.")
+
+// LiveVehicles renders a map whose vehicle markers are updated live from VehicleStream.
+// The optional "line" query parameter restricts the map to the routes of that bus line.
+func (m *Repository) LiveVehicles(w http.ResponseWriter, r *http.Request) error {
+	stringMap := make(map[string]string)
+	data := make(map[string]interface{})
+
+	line := r.URL.Query().Get("line")
+	data["line"] = line
+
+	routeIDs := m.RouteIDs(line)
+	if line != "" && len(routeIDs) == 0 {
+		stringMap["error"] = translate(r, "line.not_found", line)
+	}
+	data["routes"] = strings.Join(routeIDs, ",")
+
+	return render.RenderTemplate(w, r, "livevehicles.page.tmpl", &model.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// VehicleStream streams vehicle positions as Server-Sent Events. The first event,
+// "snapshot", holds every vehicle; each following "delta" event holds only the
+// vehicles that moved and the ones that left the feed. The optional "route" query
+// parameter, repeated or comma separated, restricts the stream to those route IDs.
+// Errors are only returned before the stream starts, the ones writing it end the
+// stream instead.
+func (m *Repository) VehicleStream(w http.ResponseWriter, r *http.Request) error {
+	if m.Realtime == nil {
+		return errRealtimeUnavailable
+	}
+
+	var routeIDs []string
+	for _, value := range r.URL.Query()["route"] {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				routeIDs = append(routeIDs, id)
+			}
+		}
+	}
+
+	updates, cancel := m.Realtime.Subscribe()
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	// streams are long lived, the server write timeout must not cut them
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry); err != nil {
+		return nil
+	}
+
+	previous := map[string]realtime.Vehicle{}
+	send := func(event string, snapshot *realtime.Snapshot) error {
+		var delta realtime.Delta
+		delta, previous = realtime.Diff(previous, snapshot.Vehicles(routeIDs...))
+		if event == "delta" && delta.Empty() {
+			return nil
+		}
+		if err := writeEvent(w, event, delta); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	if err := send("snapshot", m.Realtime.Snapshot()); err != nil {
+		m.logger().WarnContext(r.Context(), "error writing vehicle stream", "error", err)
+		return nil
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case snapshot, ok := <-updates:
+			if !ok {
+				// the realtime source stopped, the server is shutting down
+				return nil
+			}
+			if err := send("delta", snapshot); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			if err := rc.Flush(); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// writeEvent writes a single Server-Sent Event with a JSON payload.
+func writeEvent(w io.Writer, event string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+	return err
+}
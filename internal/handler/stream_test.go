@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+	"github.com/mayloo89/bamos/utils"
+)
+
+func Test_LiveVehicles(t *testing.T) {
+	repo, app := setupTestApp(new(services.MockAPIClient))
+	app.DataCache.Routes = []utils.Route{{ID: "1426", ShortName: "505R3"}}
+
+	req, err := http.NewRequest("GET", "/colectivos/live?line=505", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := helpers.HandlerFunc(repo.LiveVehicles)
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"1426"`)
+}
+
+func Test_VehicleStream_Unavailable(t *testing.T) {
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+
+	req, err := http.NewRequest("GET", "/colectivos/stream", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := helpers.HandlerFunc(repo.VehicleStream)
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.NotEqual(t, "text/event-stream", rr.Header().Get("Content-Type"), "the stream never started")
+}
+
+func Test_VehicleStream_Deltas(t *testing.T) {
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+	source := &testRealtimeSource{
+		snapshot: testStreamSnapshot(vehicleEntity("bus-1", "1426", -34.6), vehicleEntity("bus-2", "1427", -34.7)),
+		updates:  make(chan *realtime.Snapshot),
+	}
+	repo.Realtime = source
+
+	req, err := http.NewRequest("GET", "/colectivos/stream?route=1426", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := helpers.HandlerFunc(repo.VehicleStream)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(rr, req)
+	}()
+
+	// bus-1 moves and a new bus joins the route, then the source stops
+	source.updates <- testStreamSnapshot(vehicleEntity("bus-1", "1426", -34.5), vehicleEntity("bus-3", "1426", -34.8))
+	close(source.updates)
+	<-done
+
+	body := rr.Body.String()
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	assert.Contains(t, body, "event: snapshot\ndata: {\"vehicles\":[{\"id\":\"bus-1\"")
+	assert.Contains(t, body, "event: delta\n")
+	assert.Contains(t, body, "\"id\":\"bus-3\"")
+	assert.Contains(t, body, "\"removed\":[]")
+	assert.NotContains(t, body, "bus-2", "vehicles of other routes are filtered out")
+}
+
+func Test_VehicleStream_Heartbeat(t *testing.T) {
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+	repo.Realtime = &testRealtimeSource{snapshot: testStreamSnapshot()}
+
+	interval := streamHeartbeatInterval
+	streamHeartbeatInterval = time.Millisecond
+	defer func() { streamHeartbeatInterval = interval }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "/colectivos/stream", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := helpers.HandlerFunc(repo.VehicleStream)
+
+	// returns once the client goes away
+	handler.ServeHTTP(rr, req)
+
+	assert.True(t, strings.Contains(rr.Body.String(), ": heartbeat\n\n"))
+}
+
+// testStreamSnapshot returns a snapshot whose vehicle positions feed holds the given entities.
+func testStreamSnapshot(entities ...*gtfs.FeedEntity) *realtime.Snapshot {
+	return &realtime.Snapshot{Feeds: map[services.Feed]*realtime.FeedSnapshot{
+		services.FeedVehiclePositions: {
+			FetchedAt: time.Now(),
+			Message:   &gtfs.FeedMessage{Entity: entities},
+		},
+	}}
+}
+
+// vehicleEntity returns a feed entity for a vehicle on the given route.
+func vehicleEntity(id, routeID string, lat float32) *gtfs.FeedEntity {
+	return &gtfs.FeedEntity{
+		Id: proto.String(id),
+		Vehicle: &gtfs.VehiclePosition{
+			Vehicle:  &gtfs.VehicleDescriptor{Id: proto.String(id)},
+			Trip:     &gtfs.TripDescriptor{RouteId: proto.String(routeID)},
+			Position: &gtfs.Position{Latitude: proto.Float32(lat), Longitude: proto.Float32(-58.4)},
+		},
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/mayloo89/bamos/internal/apperror"
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/model"
+	"github.com/mayloo89/bamos/internal/render"
+	"github.com/mayloo89/bamos/internal/webhooks"
+)
+
+// maxWebhookDeliveries is the number of attempts shown in the delivery log.
+const maxWebhookDeliveries = 50
+
+var (
+	errWebhooksUnavailable = apperror.Unavailable("webhooks_unavailable", "Webhooks are not available right now.")
+	errWebhookNotFound     = apperror.New(http.StatusNotFound, "webhook_not_found", "The webhook does not exist.")
+)
+
+// PostWebhook subscribes a URL of the logged in user to the service alerts and
+// large delays of some lines and stops, then shows its signing secret.
+func (m *Repository) PostWebhook(w http.ResponseWriter, r *http.Request) error {
+	if m.Webhooks == nil {
+		return errWebhooksUnavailable
+	}
+	if err := r.ParseForm(); err != nil {
+		return errInvalidForm.WithCause(err)
+	}
+
+	sub := &webhooks.Subscription{
+		UserID: helpers.UserID(r),
+		URL:    strings.TrimSpace(r.Form.Get("url")),
+		Secret: webhooks.NewSecret(),
+		Lines:  webhooks.ParseList(r.Form.Get("lines")),
+		Stops:  webhooks.ParseList(r.Form.Get("stops")),
+	}
+	if err := webhooks.CheckURL(r.Context(), m.resolver(), sub.URL); err != nil {
+		key := "webhook.invalid_url"
+		if errors.Is(err, webhooks.ErrPrivateAddress) {
+			key = "webhook.private_url"
+			m.logger().InfoContext(r.Context(), "refused webhook url", "error", err)
+		}
+		m.App.Session.Put(r.Context(), "error", translate(r, key))
+		http.Redirect(w, r, "/my", http.StatusSeeOther)
+		return nil
+	}
+	if len(sub.Lines) == 0 && len(sub.Stops) == 0 {
+		m.App.Session.Put(r.Context(), "error", translate(r, "webhook.no_filters"))
+		http.Redirect(w, r, "/my", http.StatusSeeOther)
+		return nil
+	}
+
+	if err := m.Webhooks.CreateSubscription(r.Context(), sub); err != nil {
+		return err
+	}
+
+	m.App.Session.Put(r.Context(), "flash", translate(r, "webhook.added"))
+	http.Redirect(w, r, "/my/webhooks/"+strconv.FormatInt(sub.ID, 10), http.StatusSeeOther)
+	return nil
+}
+
+// Webhook renders a webhook of the logged in user with its signing secret and
+// last delivery attempts.
+func (m *Repository) Webhook(w http.ResponseWriter, r *http.Request) error {
+	sub, err := m.webhook(r)
+	if err != nil {
+		return err
+	}
+	deliveries, err := m.Webhooks.Deliveries(r.Context(), sub.ID, maxWebhookDeliveries)
+	if err != nil {
+		return err
+	}
+
+	return render.RenderTemplate(w, r, "webhook.page.tmpl", &model.TemplateData{
+		Data: map[string]interface{}{
+			"webhook":    sub,
+			"deliveries": deliveries,
+		},
+	})
+}
+
+// PostDeleteWebhook deletes a webhook of the logged in user.
+func (m *Repository) PostDeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	sub, err := m.webhook(r)
+	if err != nil {
+		return err
+	}
+	err = m.Webhooks.DeleteSubscription(r.Context(), sub.UserID, sub.ID)
+	if errors.Is(err, webhooks.ErrNotFound) {
+		return errWebhookNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	m.App.Session.Put(r.Context(), "flash", translate(r, "webhook.deleted"))
+	http.Redirect(w, r, "/my", http.StatusSeeOther)
+	return nil
+}
+
+// webhook returns the webhook of the logged in user given by the id URL parameter.
+func (m *Repository) webhook(r *http.Request) (*webhooks.Subscription, error) {
+	if m.Webhooks == nil {
+		return nil, errWebhooksUnavailable
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, errWebhookNotFound
+	}
+
+	sub, err := m.Webhooks.Subscription(r.Context(), helpers.UserID(r), id)
+	if errors.Is(err, webhooks.ErrNotFound) {
+		return nil, errWebhookNotFound
+	}
+	return sub, err
+}
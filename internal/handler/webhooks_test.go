@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/services"
+	"github.com/mayloo89/bamos/internal/webhooks"
+)
+
+// testResolver resolves the hosts of its map, failing for the others.
+type testResolver map[string][]netip.Addr
+
+func (res testResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, ok := res[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
+
+func Test_PostWebhook(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	repo, app := setupTestApp(new(services.MockAPIClient))
+	setupTestSession(app)
+	a := setupTestAccounts(repo)
+	store := webhooks.NewMemoryStore()
+	repo.Webhooks = store
+	repo.Resolver = testResolver{"chat.example.com": {netip.MustParseAddr("93.184.215.14")}, "intranet.example.com": {netip.MustParseAddr("10.0.0.8")}}
+	user, err := a.Register(ctx, "ana@example.com", "Ana", "colectivo60", i18n.English)
+	required.NoError(err)
+
+	login := func(w http.ResponseWriter, r *http.Request) error {
+		app.Session.Put(r.Context(), helpers.UserIDKey, user.ID)
+		return nil
+	}
+	session := serveWithSession(app.Session, helpers.HandlerFunc(login), httptest.NewRequest("GET", "/", nil))
+
+	form := url.Values{"url": {"https://chat.example.com/hooks/bamos"}, "lines": {"60, 152"}}
+	rr := serveWithSession(app.Session, helpers.HandlerFunc(repo.PostWebhook), postForm(t, "/my/webhooks", form, session))
+	required.Equal(http.StatusSeeOther, rr.Code)
+
+	subs, err := store.Subscriptions(ctx, user.ID)
+	required.NoError(err)
+	required.Len(subs, 1)
+	sub := subs[0]
+	id := strconv.FormatInt(sub.ID, 10)
+	assert.Equal("/my/webhooks/"+id, rr.Header().Get("Location"))
+	assert.Equal([]string{"60", "152"}, sub.Lines)
+	assert.True(strings.HasPrefix(sub.Secret, "whsec_"))
+
+	// the webhook page shows the secret and the delivery log
+	required.NoError(store.RecordDelivery(ctx, &webhooks.Delivery{SubscriptionID: sub.ID, EventID: "alert:a1", Attempt: 1, StatusCode: 502, Error: "webhook answered with status 502"}))
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.Webhook), withURLParams(redirectRequest(t, rr), map[string]string{"id": id}))
+	required.Equal(http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	assert.Contains(body, sub.Secret)
+	assert.Contains(body, "alert:a1")
+	assert.Contains(body, "webhook answered with status 502")
+
+	// other users can not see it
+	other := httptest.NewRequest("GET", "/my/webhooks/"+id, nil)
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.Webhook), withURLParams(other, map[string]string{"id": id}))
+	assert.Equal(http.StatusNotFound, rr.Code)
+
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostWebhook), postForm(t, "/my/webhooks", url.Values{"url": {"ftp://example.com"}, "stops": {"stop-1"}}, session))
+	required.Equal(http.StatusSeeOther, rr.Code)
+	assert.Equal("/my", rr.Header().Get("Location"))
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.MyBamos), redirectRequest(t, rr))
+	assert.Contains(rr.Body.String(), "The webhook URL must be an http or https URL.")
+	assert.Contains(rr.Body.String(), "https://chat.example.com/hooks/bamos")
+
+	// nor URLs of the internal network
+	for _, private := range []string{"http://127.0.0.1:8080/hooks", "http://169.254.169.254/latest/meta-data", "https://intranet.example.com/hooks"} {
+		rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostWebhook), postForm(t, "/my/webhooks", url.Values{"url": {private}, "stops": {"stop-1"}}, session))
+		required.Equal(http.StatusSeeOther, rr.Code)
+		rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.MyBamos), redirectRequest(t, rr))
+		assert.Contains(rr.Body.String(), "The webhook URL must point to a public internet address.", private)
+	}
+	subs, err = store.Subscriptions(ctx, user.ID)
+	required.NoError(err)
+	assert.Len(subs, 1)
+
+	req := withURLParams(postForm(t, "/my/webhooks/"+id+"/delete", url.Values{}, session), map[string]string{"id": id})
+	rr = serveWithSession(app.Session, helpers.HandlerFunc(repo.PostDeleteWebhook), req)
+	required.Equal(http.StatusSeeOther, rr.Code)
+	subs, err = store.Subscriptions(ctx, user.ID)
+	required.NoError(err)
+	assert.Empty(subs)
+}
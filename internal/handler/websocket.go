@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/mayloo89/bamos/internal/hub"
+)
+
+const (
+	// wsWriteWait is the time allowed to write a message to the peer.
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is the time allowed to read the next pong from the peer.
+	wsPongWait = 60 * time.Second
+	// wsPingPeriod is how often pings are sent, must be less than wsPongWait.
+	wsPingPeriod = wsPongWait * 9 / 10
+	// wsMaxMessageSize is the maximum size of a client request.
+	wsMaxMessageSize = 4096
+)
+
+// upgrader only accepts connections from the same origin, the default of websocket.Upgrader.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// RealtimeWebSocket upgrades the connection to a WebSocket subscribed to the realtime hub.
+// See docs/websocket.md for the message protocol. Errors are only returned before
+// the upgrade, once it is attempted the connection is answered by the upgrader or
+// closed with a close frame.
+func (m *Repository) RealtimeWebSocket(w http.ResponseWriter, r *http.Request) error {
+	if m.Hub == nil {
+		return errRealtimeUnavailable
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied with an error
+		m.logger().InfoContext(r.Context(), "error upgrading websocket connection", "error", err)
+		return nil
+	}
+
+	client, err := m.Hub.Register()
+	if err != nil {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, err.Error()), time.Now().Add(wsWriteWait))
+		_ = conn.Close()
+		return nil
+	}
+
+	go wsWritePump(conn, client)
+	wsReadPump(r.Context(), m.logger(), conn, client)
+	return nil
+}
+
+// wsReadPump reads client requests until the connection fails or is closed.
+func wsReadPump(ctx context.Context, logger *slog.Logger, conn *websocket.Conn, client *hub.Client) {
+	defer client.Close()
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.WarnContext(ctx, "error reading websocket message", "error", err)
+			}
+			return
+		}
+
+		var req hub.Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			client.Reject(fmt.Errorf("invalid request: %w", err))
+			continue
+		}
+		client.Handle(req)
+	}
+}
+
+// wsWritePump writes the hub messages and keepalive pings to the connection,
+// closing it once the client is disconnected from the hub.
+func wsWritePump(conn *websocket.Conn, client *hub.Client) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-client.Messages():
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage, closeMessage(client.Err()), time.Now().Add(wsWriteWait))
+				return
+			}
+
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(msg); err != nil {
+				client.Close()
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				client.Close()
+				return
+			}
+		}
+	}
+}
+
+// closeMessage returns the close frame matching the reason the client was disconnected.
+func closeMessage(reason error) []byte {
+	switch {
+	case errors.Is(reason, hub.ErrSlowClient):
+		return websocket.FormatCloseMessage(websocket.CloseTryAgainLater, reason.Error())
+	case errors.Is(reason, hub.ErrHubClosed):
+		return websocket.FormatCloseMessage(websocket.CloseGoingAway, reason.Error())
+	default:
+		return websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/helpers"
+	"github.com/mayloo89/bamos/internal/hub"
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+func Test_RealtimeWebSocket_Unavailable(t *testing.T) {
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+
+	req, err := http.NewRequest("GET", "/api/v1/realtime", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/json")
+
+	rr := httptest.NewRecorder()
+	handler := helpers.HandlerFunc(repo.RealtimeWebSocket)
+
+	handler.ServeHTTP(rr, req)
+
+	// answered like every other error, before the upgrade
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, helpers.ProblemContentType, rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `"realtime_unavailable"`)
+}
+
+func Test_RealtimeWebSocket_Subscribe(t *testing.T) {
+	repo, _ := setupTestApp(new(services.MockAPIClient))
+	source := &testRealtimeSource{
+		snapshot: testStreamSnapshot(vehicleEntity("bus-1", "1426", -34.6)),
+		updates:  make(chan *realtime.Snapshot),
+	}
+	repo.Hub = hub.New(source)
+	go repo.Hub.Run()
+
+	srv := httptest.NewServer(helpers.HandlerFunc(repo.RealtimeWebSocket))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not json")))
+	var msg hub.Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, hub.MessageError, msg.Type)
+	assert.Contains(t, msg.Error, "invalid request")
+
+	require.NoError(t, conn.WriteJSON(hub.Request{Type: hub.RequestSubscribe, ID: "1", Subscription: hub.Subscription{Routes: []string{"1426"}}}))
+
+	msg = hub.Message{}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, hub.MessageAck, msg.Type)
+	assert.Equal(t, "1", msg.ID)
+
+	msg = hub.Message{}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, hub.MessageVehicles, msg.Type)
+	require.Len(t, msg.Vehicles, 1)
+	assert.Equal(t, "bus-1", msg.Vehicles[0].ID)
+
+	// the realtime source stops, the connection is closed as going away
+	close(source.updates)
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+}
@@ -0,0 +1,48 @@
+package health
+
+import "runtime/debug"
+
+// Build describes the running binary.
+type Build struct {
+	Module    string `json:"module,omitempty"`
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// ReadBuild returns the build information embedded in the binary. The version is
+// "(devel)" for binaries built from a checkout and "unknown" when there is no
+// build information.
+func ReadBuild() Build {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Build{Version: "unknown"}
+	}
+	return newBuild(info)
+}
+
+func newBuild(info *debug.BuildInfo) Build {
+	build := Build{
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+		GoVersion: info.GoVersion,
+	}
+	if build.Version == "" {
+		build.Version = "(devel)"
+	}
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.Time = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+
+	return build
+}
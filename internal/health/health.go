@@ -0,0 +1,136 @@
+// Package health reports whether the application is ready to serve requests
+// and which build is running, for load balancer probes and deployments.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+// DefaultTimeout is the time given to all the checks of a readiness probe when none is set.
+const DefaultTimeout = 2 * time.Second
+
+// Status values of a report and its checks.
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+type (
+	// Check returns an error when a dependency is not ready.
+	Check func(ctx context.Context) error
+
+	// Checker runs the readiness checks of the application.
+	Checker struct {
+		Timeout time.Duration // Time given to all the checks, DefaultTimeout if zero
+		checks  []namedCheck
+	}
+
+	// Report is the result of running every check.
+	Report struct {
+		Status string                 `json:"status"`
+		Checks map[string]CheckResult `json:"checks"`
+	}
+
+	// CheckResult is the result of a single check. The error is for the logs, the
+	// probes are public and only get the status.
+	CheckResult struct {
+		Status string `json:"status"`
+		Error  string `json:"-"`
+	}
+
+	// Pinger is implemented by stores backed by a database.
+	Pinger interface {
+		Ping(ctx context.Context) error
+	}
+
+	namedCheck struct {
+		name  string
+		check Check
+	}
+)
+
+// Add registers a check under the given name.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Run runs every check concurrently and reports them. A check that does not
+// return within the timeout fails with context.DeadlineExceeded.
+func (c *Checker) Run(ctx context.Context) Report {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := run(ctx, nc.check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Status = StatusUnavailable
+				report.Checks[nc.name] = CheckResult{Status: StatusUnavailable, Error: err.Error()}
+				return
+			}
+			report.Checks[nc.name] = CheckResult{Status: StatusOK}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run runs the check, giving up when ctx is done even if the check ignores it.
+func run(ctx context.Context, check Check) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- check(ctx)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Ready reports whether every check passed.
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Upstream returns a check failing while the upstream API is unavailable: the
+// vehicle positions feed was never received, or its polls keep failing and the
+// data is older than maxAge.
+func Upstream(source realtime.Source, maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		feed := source.Snapshot().Feed(services.FeedVehiclePositions)
+		if feed == nil || feed.Message == nil {
+			if feed != nil && feed.Err != nil {
+				return fmt.Errorf("vehicle positions not received yet: %w", feed.Err)
+			}
+			return errors.New("vehicle positions not received yet")
+		}
+		if feed.Err != nil && feed.Stale(time.Now(), maxAge) {
+			return fmt.Errorf("upstream API failing since %s: %w", feed.ErrAt.Format(time.RFC3339), feed.Err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,126 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+func Test_Checker_Run(t *testing.T) {
+	assert := assert.New(t)
+
+	checker := &Checker{}
+	checker.Add("routes", func(context.Context) error { return nil })
+
+	report := checker.Run(context.Background())
+	assert.True(report.Ready())
+	assert.Equal(CheckResult{Status: StatusOK}, report.Checks["routes"])
+
+	checker.Add("database", func(context.Context) error { return errors.New("connection refused") })
+
+	report = checker.Run(context.Background())
+	assert.False(report.Ready())
+	assert.Equal(StatusUnavailable, report.Status)
+	assert.Equal(StatusOK, report.Checks["routes"].Status)
+	assert.Equal(CheckResult{Status: StatusUnavailable, Error: "connection refused"}, report.Checks["database"])
+}
+
+func Test_Checker_Timeout(t *testing.T) {
+	assert := assert.New(t)
+
+	block := make(chan struct{})
+	defer close(block)
+
+	checker := &Checker{Timeout: 10 * time.Millisecond}
+	checker.Add("stuck", func(context.Context) error {
+		<-block // ignores the context
+		return nil
+	})
+
+	start := time.Now()
+	report := checker.Run(context.Background())
+
+	assert.Less(time.Since(start), time.Second)
+	assert.False(report.Ready())
+	assert.Equal(context.DeadlineExceeded.Error(), report.Checks["stuck"].Error)
+}
+
+func Test_Upstream(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		feed  *realtime.FeedSnapshot
+		error string
+	}{
+		{name: "not fetched", error: "vehicle positions not received yet"},
+		{name: "never received", feed: &realtime.FeedSnapshot{Err: errors.New("401"), ErrAt: now}, error: "vehicle positions not received yet: 401"},
+		{name: "fresh", feed: &realtime.FeedSnapshot{Message: &gtfs.FeedMessage{}, FetchedAt: now}},
+		{name: "failing recently", feed: &realtime.FeedSnapshot{Message: &gtfs.FeedMessage{}, FetchedAt: now.Add(-time.Minute), Err: errors.New("timeout"), ErrAt: now}},
+		{name: "failing", feed: &realtime.FeedSnapshot{Message: &gtfs.FeedMessage{}, FetchedAt: now.Add(-time.Hour), Err: errors.New("timeout"), ErrAt: now}, error: "timeout"},
+	}
+
+	for _, tt := range tests {
+		snapshot := &realtime.Snapshot{Feeds: map[services.Feed]*realtime.FeedSnapshot{}}
+		if tt.feed != nil {
+			snapshot.Feeds[services.FeedVehiclePositions] = tt.feed
+		}
+
+		err := Upstream(testSource{snapshot}, 2*time.Minute)(context.Background())
+		if tt.error == "" {
+			assert.NoError(err, tt.name)
+			continue
+		}
+		if assert.Error(err, tt.name) {
+			assert.Contains(err.Error(), tt.error, tt.name)
+		}
+	}
+}
+
+func Test_newBuild(t *testing.T) {
+	assert := assert.New(t)
+
+	info := &debug.BuildInfo{
+		GoVersion: "go1.24.3",
+		Main:      debug.Module{Path: "github.com/mayloo89/bamos", Version: "v1.2.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "499356c"},
+			{Key: "vcs.time", Value: "2026-10-19T12:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	build := newBuild(info)
+	assert.Equal(Build{
+		Module:    "github.com/mayloo89/bamos",
+		Version:   "v1.2.0",
+		GoVersion: "go1.24.3",
+		Revision:  "499356c",
+		Time:      "2026-10-19T12:00:00Z",
+		Modified:  true,
+	}, build)
+
+	assert.Equal("(devel)", newBuild(&debug.BuildInfo{}).Version)
+}
+
+// testSource is a realtime.Source returning a fixed snapshot.
+type testSource struct {
+	snapshot *realtime.Snapshot
+}
+
+func (s testSource) Snapshot() *realtime.Snapshot {
+	return s.snapshot
+}
+
+func (s testSource) Subscribe() (<-chan *realtime.Snapshot, func()) {
+	return make(chan *realtime.Snapshot), func() {}
+}
@@ -0,0 +1,24 @@
+package helpers
+
+import (
+	"net/http"
+
+	"github.com/mayloo89/bamos/internal/config"
+)
+
+// UserIDKey is the session key of the ID of the logged in user.
+const UserIDKey = config.SessionUserIDKey
+
+// IsAuthenticated reports whether a user is logged in the session of the request.
+func IsAuthenticated(r *http.Request) bool {
+	return app != nil && app.Session != nil && app.Session.Exists(r.Context(), UserIDKey)
+}
+
+// UserID returns the ID of the user logged in the session of the request, zero
+// when there is none.
+func UserID(r *http.Request) int64 {
+	if !IsAuthenticated(r) {
+		return 0
+	}
+	return app.Session.GetInt64(r.Context(), UserIDKey)
+}
@@ -0,0 +1,38 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mayloo89/bamos/internal/config"
+)
+
+func Test_IsAuthenticated(t *testing.T) {
+	assert := assert.New(t)
+	NewHelpers(&config.AppConfig{Session: scs.New()})
+
+	var authenticated bool
+	var userID int64
+	h := app.Session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated, userID = IsAuthenticated(r), UserID(r)
+		if r.URL.Query().Has("login") {
+			app.Session.Put(r.Context(), UserIDKey, int64(7))
+			authenticated, userID = IsAuthenticated(r), UserID(r)
+		}
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.False(authenticated)
+	assert.Zero(userID)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?login", nil))
+	assert.True(authenticated)
+	assert.Equal(int64(7), userID)
+
+	NewHelpers(&config.AppConfig{})
+	assert.False(IsAuthenticated(httptest.NewRequest("GET", "/", nil)), "without sessions nobody is logged in")
+}
@@ -0,0 +1,161 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/mayloo89/bamos/internal/apperror"
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/logging"
+	"github.com/mayloo89/bamos/internal/model"
+	"github.com/mayloo89/bamos/internal/render"
+)
+
+// ProblemContentType is the content type of the JSON error responses, RFC 9457.
+const ProblemContentType = "application/problem+json"
+
+// Problem is the JSON error response of clients preferring JSON over HTML.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// jsonErrorsKey marks the requests whose errors are always answered as problem+json.
+type jsonErrorsKey struct{}
+
+// JSONErrors makes the errors of the wrapped handlers always answer as
+// problem+json, whatever the Accept header, for the APIs without pages.
+func JSONErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), jsonErrorsKey{}, true)))
+	})
+}
+
+// HandlerFunc is a handler returning its error, which is answered with Error.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP calls f and answers its error, if any.
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f(w, r); err != nil {
+		Error(w, r, err)
+	}
+}
+
+// Error logs err and answers with its status and user message as an HTML error
+// page, or as problem+json when the client prefers JSON or the request went through
+// JSONErrors. Errors that are not an
+// apperror.Error are answered as internal errors without revealing them.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	appErr := apperror.From(err)
+
+	l := logger()
+	attrs := []any{"status", appErr.Status, "code", appErr.Code, "method", r.Method, "path", r.URL.Path}
+	if appErr.Cause != nil {
+		attrs = append(attrs, "error", appErr.Cause)
+	}
+	if appErr.Status >= http.StatusInternalServerError {
+		l.ErrorContext(r.Context(), "server error", attrs...)
+		if l.Enabled(r.Context(), slog.LevelDebug) {
+			l.DebugContext(r.Context(), "server error stack", "stack", string(debug.Stack()))
+		}
+	} else {
+		l.InfoContext(r.Context(), "client error", attrs...)
+	}
+
+	if jsonOnly, _ := r.Context().Value(jsonErrorsKey{}).(bool); jsonOnly || prefersJSON(r) {
+		writeProblem(w, r, appErr)
+		return
+	}
+
+	// the catalogs translate the status and the message of the error codes, which are
+	// in English, to the locale of the request
+	locale := i18n.FromContext(r.Context())
+	title, ok := locale.Lookup("status." + strconv.Itoa(appErr.Status))
+	if !ok {
+		title = http.StatusText(appErr.Status)
+	}
+	message, ok := locale.Lookup("error." + appErr.Code)
+	if !ok {
+		message = appErr.Message
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = render.RenderTemplateStatus(w, r, appErr.Status, "error.page.tmpl", &model.TemplateData{
+		StringMap: map[string]string{
+			"title":      title,
+			"message":    message,
+			"code":       appErr.Code,
+			"request_id": logging.RequestID(r.Context()),
+		},
+		IntMap: map[string]int{"status": appErr.Status},
+	})
+	if err != nil {
+		l.ErrorContext(r.Context(), "error rendering the error page", "error", err)
+		http.Error(w, appErr.Message, appErr.Status)
+	}
+}
+
+// ClientError logs the client error and answers with its status.
+func ClientError(w http.ResponseWriter, r *http.Request, status int) {
+	Error(w, r, apperror.New(status, strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_")),
+		http.StatusText(status)+"."))
+}
+
+// ServerError logs the error, with its stack trace at debug level, and answers 500.
+func ServerError(w http.ResponseWriter, r *http.Request, err error) {
+	Error(w, r, apperror.Internal(err))
+}
+
+// writeProblem answers the error as problem+json.
+func writeProblem(w http.ResponseWriter, r *http.Request, appErr *apperror.Error) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(appErr.Status)
+	_ = json.NewEncoder(w).Encode(Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(appErr.Status),
+		Status:    appErr.Status,
+		Detail:    appErr.Message,
+		Instance:  r.URL.Path,
+		Code:      appErr.Code,
+		RequestID: logging.RequestID(r.Context()),
+	})
+}
+
+// prefersJSON reports whether the Accept header ranks JSON above HTML. Clients
+// accepting anything get HTML.
+func prefersJSON(r *http.Request) bool {
+	var htmlQ, jsonQ float64
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case "text/html":
+			htmlQ = max(htmlQ, q)
+		case "application/json", ProblemContentType:
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return jsonQ > htmlQ
+}
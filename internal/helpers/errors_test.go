@@ -0,0 +1,114 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/apperror"
+	"github.com/mayloo89/bamos/internal/config"
+	"github.com/mayloo89/bamos/internal/logging"
+	"github.com/mayloo89/bamos/internal/render"
+)
+
+func setupTestApp() {
+	app := &config.AppConfig{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	NewHelpers(app)
+	render.NewTemplates(app)
+}
+
+func Test_Error_HTML(t *testing.T) {
+	assert := assert.New(t)
+	setupTestApp()
+
+	r := httptest.NewRequest("GET", "/colectivos/search", nil)
+	r = r.WithContext(logging.WithRequestID(r.Context(), "req-1"))
+	rr := httptest.NewRecorder()
+
+	Error(rr, r, apperror.BadRequest("invalid_location", "The location is not valid."))
+
+	assert.Equal(http.StatusBadRequest, rr.Code)
+	assert.Contains(rr.Header().Get("Content-Type"), "text/html")
+	assert.Contains(rr.Body.String(), "The location is not valid.")
+	assert.Contains(rr.Body.String(), "req-1")
+}
+
+func Test_Error_Problem(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	setupTestApp()
+
+	r := httptest.NewRequest("GET", "/analytics/lines/1426/export.csv", nil)
+	r.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+
+	Error(rr, r, errors.New("pq: password authentication failed"))
+
+	assert.Equal(http.StatusInternalServerError, rr.Code)
+	assert.Equal(ProblemContentType, rr.Header().Get("Content-Type"))
+
+	var problem Problem
+	required.NoError(json.NewDecoder(rr.Body).Decode(&problem))
+	assert.Equal(http.StatusInternalServerError, problem.Status)
+	assert.Equal("internal_error", problem.Code)
+	assert.Equal("/analytics/lines/1426/export.csv", problem.Instance)
+	assert.NotContains(problem.Detail, "password", "causes are never shown to users")
+}
+
+func Test_JSONErrors(t *testing.T) {
+	assert := assert.New(t)
+	setupTestApp()
+
+	// browsers asking for HTML get problem+json from the APIs too
+	r := httptest.NewRequest("GET", "/api/v1/geocode", nil)
+	r.Header.Set("Accept", "text/html")
+	rr := httptest.NewRecorder()
+
+	JSONErrors(HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return apperror.BadRequest("invalid_query", "Invalid query.")
+	})).ServeHTTP(rr, r)
+
+	assert.Equal(http.StatusBadRequest, rr.Code)
+	assert.Equal(ProblemContentType, rr.Header().Get("Content-Type"))
+	assert.Contains(rr.Body.String(), `"code":"invalid_query"`)
+}
+
+func Test_HandlerFunc(t *testing.T) {
+	setupTestApp()
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return apperror.NotFound()
+	})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/missing", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func Test_prefersJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"application/json", true},
+		{"application/problem+json", true},
+		{"text/html;q=0.5, application/json", true},
+		{"application/json;q=0.5, text/html", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		assert.Equal(t, tt.want, prefersJSON(r), tt.accept)
+	}
+}
@@ -1,9 +1,7 @@
 package helpers
 
 import (
-	"fmt"
-	"net/http"
-	"runtime/debug"
+	"log/slog"
 
 	"github.com/mayloo89/bamos/internal/config"
 )
@@ -15,14 +13,10 @@ func NewHelpers(a *config.AppConfig) {
 	app = a
 }
 
-func ClientError(w http.ResponseWriter, status int) {
-	app.InfoLog.Println("Client error with status of ", status)
-	http.Error(w, http.StatusText(status), status)
-}
-
-func ServerError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-
-	app.ErrorLog.Println(trace)
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+// logger returns the application logger, the default one before it is set up.
+func logger() *slog.Logger {
+	if app == nil || app.Logger == nil {
+		return slog.Default()
+	}
+	return app.Logger
 }
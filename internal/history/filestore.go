@@ -0,0 +1,163 @@
+package history
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// fileLayout is the date layout of the daily record files.
+	fileLayout = "2006-01-02"
+	// fileSuffix is the extension of the record files.
+	fileSuffix = ".jsonl.gz"
+	// maxRecordSize is the maximum size of a single record line.
+	maxRecordSize = 64 * 1024 * 1024
+)
+
+// FileStore stores records as gzip compressed JSON lines, in one file per UTC day.
+// Every Save appends a new gzip member, so files stay readable if the process dies.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create history directory %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save appends the record to the file of the day it was fetched.
+func (s *FileStore) Save(ctx context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.dir, record.FetchedAt.UTC().Format(fileLayout)+fileSuffix)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(f)
+	_, err = zw.Write(append(line, '\n'))
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// Load reads the records of the daily files overlapping the range.
+func (s *FileStore) Load(ctx context.Context, from, to time.Time, fn func(Record) error) error {
+	files, err := s.files(from, to)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range files {
+		if err := s.loadFile(ctx, path, from, to, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// files returns the daily files overlapping the range, in date order.
+func (s *FileStore) files(from, to time.Time) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*"+fileSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, path := range matches {
+		day, err := time.Parse(fileLayout, strings.TrimSuffix(filepath.Base(path), fileSuffix))
+		if err != nil {
+			continue
+		}
+		if !from.IsZero() && day.Add(24*time.Hour).Before(from) {
+			continue
+		}
+		if !to.IsZero() && !day.Before(to) {
+			continue
+		}
+		files = append(files, path)
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// loadFile reads the records of a single file, sorted by fetch time as polls of
+// different feeds may be saved out of order.
+func (s *FileStore) loadFile(ctx context.Context, path string, from, to time.Time, fn func(Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil {
+			slog.WarnContext(ctx, "error closing history file", "path", path, "error", cerr)
+		}
+	}()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("error reading history file %s: %w", path, err)
+	}
+
+	var records []Record
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("malformed record in history file %s: %w", path, err)
+		}
+		if inRange(record.FetchedAt, from, to) {
+			records = append(records, record)
+		}
+	}
+	// a truncated last member is what remains of a crash while saving
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("error reading history file %s: %w", path, err)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].FetchedAt.Before(records[j].FetchedAt)
+	})
+
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
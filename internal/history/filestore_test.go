@@ -0,0 +1,89 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+func Test_FileStore_SaveLoad(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "history"))
+	required.NoError(err)
+
+	day := time.Date(2026, 10, 19, 23, 59, 0, 0, time.UTC)
+	// saved out of order and across two days
+	for _, fetched := range []time.Time{day.Add(time.Minute), day, day.Add(30 * time.Second), day.Add(-time.Hour)} {
+		required.NoError(store.Save(context.Background(), NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700000000), fetched)))
+	}
+
+	files, err := filepath.Glob(filepath.Join(store.dir, "*"+fileSuffix))
+	required.NoError(err)
+	assert.Len(files, 2)
+
+	var loaded []time.Time
+	err = store.Load(context.Background(), day, time.Time{}, func(record Record) error {
+		assert.Len(record.Vehicles, 2)
+		loaded = append(loaded, record.FetchedAt)
+		return nil
+	})
+	required.NoError(err)
+	required.Len(loaded, 3)
+	assert.True(loaded[0].Equal(day))
+	assert.True(loaded[1].Equal(day.Add(30 * time.Second)))
+	assert.True(loaded[2].Equal(day.Add(time.Minute)))
+}
+
+func Test_FileStore_StopLoading(t *testing.T) {
+	required := require.New(t)
+
+	store, err := NewFileStore(t.TempDir())
+	required.NoError(err)
+
+	start := time.Now()
+	for i := range 3 {
+		required.NoError(store.Save(context.Background(), NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700000000), start.Add(time.Duration(i)*time.Second))))
+	}
+
+	count := 0
+	err = store.Load(context.Background(), time.Time{}, time.Time{}, func(Record) error {
+		count++
+		return ErrStopLoading
+	})
+	assert.ErrorIs(t, err, ErrStopLoading)
+	assert.Equal(t, 1, count)
+}
+
+func Test_FileStore_TruncatedFile(t *testing.T) {
+	required := require.New(t)
+
+	store, err := NewFileStore(t.TempDir())
+	required.NoError(err)
+
+	fetched := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(store.dir, "2026-10-19"+fileSuffix)
+	required.NoError(store.Save(context.Background(), NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700000000), fetched)))
+	info, err := os.Stat(path)
+	required.NoError(err)
+	required.NoError(store.Save(context.Background(), NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700000030), fetched.Add(time.Minute))))
+
+	// a crash while saving leaves the last gzip member incomplete
+	required.NoError(os.Truncate(path, info.Size()+20))
+
+	count := 0
+	err = store.Load(context.Background(), time.Time{}, time.Time{}, func(Record) error {
+		count++
+		return nil
+	})
+	required.NoError(err)
+	assert.Equal(t, 1, count)
+}
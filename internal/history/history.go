@@ -0,0 +1,131 @@
+// Package history records the polled realtime feeds and replays them back
+// through the realtime pipeline.
+package history
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+// ErrStopLoading can be returned by a Load callback to stop loading without error.
+var ErrStopLoading = errors.New("stop loading")
+
+type (
+	// Store persists records and reads them back in order.
+	Store interface {
+		// Save persists a record.
+		Save(ctx context.Context, record Record) error
+		// Load calls fn for every record fetched within [from, to), ordered by
+		// fetch time. A zero from or to leaves that side of the range open.
+		Load(ctx context.Context, from, to time.Time, fn func(Record) error) error
+	}
+
+	// Record is the content of a feed at the time it was polled. Only the vehicle
+	// positions and trip updates feeds are recorded.
+	Record struct {
+		Feed      services.Feed      `json:"feed"`
+		FetchedAt time.Time          `json:"fetched_at"`
+		Timestamp time.Time          `json:"timestamp,omitempty"` // Feed header timestamp
+		Vehicles  []realtime.Vehicle `json:"vehicles,omitempty"`
+		Arrivals  []realtime.Arrival `json:"arrivals,omitempty"`
+	}
+)
+
+// Recorded reports whether the feed is recorded.
+func Recorded(feed services.Feed) bool {
+	return feed == services.FeedVehiclePositions || feed == services.FeedTripUpdates
+}
+
+// NewRecord creates the record of a feed message fetched at the given time.
+func NewRecord(feed services.Feed, message *gtfs.FeedMessage, fetchedAt time.Time) Record {
+	snapshot := &realtime.Snapshot{Feeds: map[services.Feed]*realtime.FeedSnapshot{
+		feed: {Message: message},
+	}}
+
+	record := Record{
+		Feed:      feed,
+		FetchedAt: fetchedAt,
+	}
+	if ts := message.GetHeader().GetTimestamp(); ts != 0 {
+		record.Timestamp = time.Unix(int64(ts), 0)
+	}
+
+	switch feed {
+	case services.FeedVehiclePositions:
+		record.Vehicles = snapshot.Vehicles()
+	case services.FeedTripUpdates:
+		record.Arrivals = snapshot.Arrivals()
+	}
+
+	return record
+}
+
+// Message rebuilds a GTFS realtime feed message from the record.
+func (r Record) Message() *gtfs.FeedMessage {
+	message := &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")},
+	}
+	if !r.Timestamp.IsZero() {
+		message.Header.Timestamp = proto.Uint64(uint64(r.Timestamp.Unix()))
+	}
+
+	for _, v := range r.Vehicles {
+		position := &gtfs.VehiclePosition{
+			Vehicle: &gtfs.VehicleDescriptor{Id: proto.String(v.ID)},
+			Trip:    &gtfs.TripDescriptor{RouteId: proto.String(v.RouteID)},
+			Position: &gtfs.Position{
+				Latitude:  proto.Float32(float32(v.Latitude)),
+				Longitude: proto.Float32(float32(v.Longitude)),
+				Bearing:   proto.Float32(float32(v.Bearing)),
+				Speed:     proto.Float32(float32(v.Speed)),
+			},
+		}
+		if v.Label != "" {
+			position.Vehicle.Label = proto.String(v.Label)
+		}
+		if v.TripID != "" {
+			position.Trip.TripId = proto.String(v.TripID)
+		}
+		if !v.Timestamp.IsZero() {
+			position.Timestamp = proto.Uint64(uint64(v.Timestamp.Unix()))
+		}
+
+		message.Entity = append(message.Entity, &gtfs.FeedEntity{Id: proto.String(v.ID), Vehicle: position})
+	}
+
+	// arrivals of the same trip are grouped back into a single trip update
+	updates := map[string]*gtfs.TripUpdate{}
+	for _, a := range r.Arrivals {
+		update, ok := updates[a.TripID]
+		if !ok {
+			update = &gtfs.TripUpdate{
+				Trip: &gtfs.TripDescriptor{TripId: proto.String(a.TripID), RouteId: proto.String(a.RouteID)},
+			}
+			updates[a.TripID] = update
+			message.Entity = append(message.Entity, &gtfs.FeedEntity{Id: proto.String(a.TripID), TripUpdate: update})
+		}
+
+		event := &gtfs.TripUpdate_StopTimeEvent{Delay: proto.Int32(a.Delay)}
+		if !a.Time.IsZero() {
+			event.Time = proto.Int64(a.Time.Unix())
+		}
+		update.StopTimeUpdate = append(update.StopTimeUpdate, &gtfs.TripUpdate_StopTimeUpdate{
+			StopId:  proto.String(a.StopID),
+			Arrival: event,
+		})
+	}
+
+	return message
+}
+
+// inRange reports whether t is within [from, to), zero bounds being open.
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
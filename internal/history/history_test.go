@@ -0,0 +1,198 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+func Test_NewRecord_MessageRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	fetched := time.Unix(1700000030, 0)
+	record := NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700000000), fetched)
+
+	assert.Equal(services.FeedVehiclePositions, record.Feed)
+	assert.True(record.Timestamp.Equal(time.Unix(1700000000, 0)))
+	required.Len(record.Vehicles, 2)
+	assert.Empty(record.Arrivals)
+
+	snapshot := &realtime.Snapshot{Feeds: map[services.Feed]*realtime.FeedSnapshot{
+		services.FeedVehiclePositions: {Message: record.Message()},
+	}}
+	assert.Equal(record.Vehicles, snapshot.Vehicles())
+}
+
+func Test_NewRecord_TripUpdates(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	message := &gtfs.FeedMessage{Entity: []*gtfs.FeedEntity{
+		{
+			Id: proto.String("trip-1"),
+			TripUpdate: &gtfs.TripUpdate{
+				Trip: &gtfs.TripDescriptor{TripId: proto.String("trip-1"), RouteId: proto.String("1426")},
+				StopTimeUpdate: []*gtfs.TripUpdate_StopTimeUpdate{
+					{StopId: proto.String("stop-1"), Arrival: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(1700000000), Delay: proto.Int32(60)}},
+					{StopId: proto.String("stop-2"), Arrival: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(1700000120), Delay: proto.Int32(90)}},
+				},
+			},
+		},
+	}}
+
+	record := NewRecord(services.FeedTripUpdates, message, time.Now())
+	required.Len(record.Arrivals, 2)
+	assert.Equal(int32(90), record.Arrivals[1].Delay)
+
+	snapshot := &realtime.Snapshot{Feeds: map[services.Feed]*realtime.FeedSnapshot{
+		services.FeedTripUpdates: {Message: record.Message()},
+	}}
+	assert.Equal(record.Arrivals, snapshot.Arrivals())
+}
+
+func Test_Recorder_SavesRecordedFeeds(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("RealtimeFeed", mock.Anything, services.FeedVehiclePositions).Return(testVehicleFeed(1700000000), nil)
+	mockAPIClient.On("RealtimeFeed", mock.Anything, services.FeedServiceAlerts).Return(&gtfs.FeedMessage{}, nil)
+	mockAPIClient.On("RealtimeFeed", mock.Anything, services.FeedTripUpdates).Return(nil, errors.New("upstream error"))
+
+	store := &memoryStore{}
+	recorder := NewRecorder(mockAPIClient, store, nil)
+
+	message, err := recorder.RealtimeFeed(context.Background(), services.FeedVehiclePositions)
+	required.NoError(err)
+	assert.Len(message.Entity, 2)
+	_, err = recorder.RealtimeFeed(context.Background(), services.FeedServiceAlerts)
+	required.NoError(err)
+	_, err = recorder.RealtimeFeed(context.Background(), services.FeedTripUpdates)
+	required.Error(err)
+
+	// alerts are not recorded, failed polls have nothing to record
+	required.Len(store.records, 1)
+	assert.Equal(services.FeedVehiclePositions, store.records[0].Feed)
+	assert.Len(store.records[0].Vehicles, 2)
+
+	mockAPIClient.AssertExpectations(t)
+}
+
+func Test_Recorder_SaveErrorDoesNotFailFetch(t *testing.T) {
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("RealtimeFeed", mock.Anything, services.FeedVehiclePositions).Return(testVehicleFeed(1700000000), nil)
+
+	recorder := NewRecorder(mockAPIClient, &memoryStore{err: errors.New("disk full")}, nil)
+
+	message, err := recorder.RealtimeFeed(context.Background(), services.FeedVehiclePositions)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+}
+
+func Test_Replayer_Run(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	start := time.Unix(1700000000, 0)
+	store := &memoryStore{records: []Record{
+		NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700000000), start),
+		NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700000030), start.Add(30*time.Second)),
+		NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700000060), start.Add(60*time.Second)),
+	}}
+	target := &testUpdater{}
+
+	// the last record is out of range, the 30 seconds left replayed at 3000x take 10ms
+	replayer := &Replayer{Store: store, Target: target, Speed: 3000, To: start.Add(time.Minute)}
+	begin := time.Now()
+	required.NoError(replayer.Run(context.Background()))
+
+	assert.GreaterOrEqual(time.Since(begin), 10*time.Millisecond)
+	required.Len(target.messages, 2)
+	assert.Equal(uint64(1700000030), target.messages[1].GetHeader().GetTimestamp())
+}
+
+func Test_Replayer_Cancel(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	store := &memoryStore{records: []Record{
+		NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700000000), start),
+		NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700003600), start.Add(time.Hour)),
+	}}
+	target := &testUpdater{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := (&Replayer{Store: store, Target: target}).Run(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, target.messages, 1)
+}
+
+// memoryStore is a Store keeping the records in memory, failing every Save with err.
+type memoryStore struct {
+	records []Record
+	err     error
+}
+
+func (s *memoryStore) Save(ctx context.Context, record Record) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *memoryStore) Load(ctx context.Context, from, to time.Time, fn func(Record) error) error {
+	for _, record := range s.records {
+		if !inRange(record.FetchedAt, from, to) {
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testUpdater keeps the messages it is updated with.
+type testUpdater struct {
+	mu       sync.Mutex
+	messages []*gtfs.FeedMessage
+}
+
+func (u *testUpdater) Update(feed services.Feed, message *gtfs.FeedMessage, fetchedAt time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.messages = append(u.messages, message)
+}
+
+// testVehicleFeed returns a vehicle positions feed with two vehicles of route 1426.
+func testVehicleFeed(timestamp uint64) *gtfs.FeedMessage {
+	entity := func(id string, lat, lon float32) *gtfs.FeedEntity {
+		return &gtfs.FeedEntity{
+			Id: proto.String(id),
+			Vehicle: &gtfs.VehiclePosition{
+				Vehicle:   &gtfs.VehicleDescriptor{Id: proto.String(id), Label: proto.String("L" + id)},
+				Trip:      &gtfs.TripDescriptor{TripId: proto.String("trip-" + id), RouteId: proto.String("1426")},
+				Position:  &gtfs.Position{Latitude: proto.Float32(lat), Longitude: proto.Float32(lon), Bearing: proto.Float32(90), Speed: proto.Float32(8.5)},
+				Timestamp: proto.Uint64(timestamp),
+			},
+		}
+	}
+
+	return &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{GtfsRealtimeVersion: proto.String("2.0"), Timestamp: proto.Uint64(timestamp)},
+		Entity: []*gtfs.FeedEntity{entity("1", -34.6, -58.4), entity("2", -34.7, -58.5)},
+	}
+}
@@ -0,0 +1,36 @@
+package history
+
+import (
+	"fmt"
+
+	"github.com/mayloo89/bamos/internal/driver"
+)
+
+// DefaultDir is the directory of the file store when none is given.
+const DefaultDir = "data/history"
+
+// OpenStore opens the store of the given kind, "file" or "postgres", along with a
+// function releasing it. An empty kind returns a nil store, recording disabled.
+func OpenStore(kind, dir, databaseURL string) (Store, func(), error) {
+	switch kind {
+	case "":
+		return nil, func() {}, nil
+	case "file":
+		if dir == "" {
+			dir = DefaultDir
+		}
+		store, err := NewFileStore(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, func() {}, nil
+	case "postgres":
+		db, err := driver.ConnectSQL(databaseURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can not connect to the history database: %w", err)
+		}
+		return NewPostgresStore(db.SQL), func() { _ = db.SQL.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid history store %q, must be file or postgres", kind)
+	}
+}
@@ -0,0 +1,258 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+// PostgresStore stores records in the vehicle_positions and trip_updates tables,
+// one row per vehicle position or predicted arrival.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a PostgresStore using the given connection pool.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Ping checks the database is reachable.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Save inserts the rows of the record in a single transaction.
+func (s *PostgresStore) Save(ctx context.Context, record Record) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rerr := tx.Rollback(); rerr != nil {
+				slog.ErrorContext(ctx, "error rolling back history transaction", "error", rerr)
+			}
+		}
+	}()
+
+	switch record.Feed {
+	case services.FeedVehiclePositions:
+		err = s.saveVehicles(ctx, tx, record)
+	case services.FeedTripUpdates:
+		err = s.saveArrivals(ctx, tx, record)
+	default:
+		err = fmt.Errorf("feed %s can not be recorded", record.Feed)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) saveVehicles(ctx context.Context, tx *sql.Tx, record Record) error {
+	stmt, err := tx.PrepareContext(ctx, `insert into vehicle_positions
+		(vehicle_id, label, route_id, trip_id, latitude, longitude, bearing, speed, position_at, feed_timestamp, fetched_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`)
+	if err != nil {
+		return err
+	}
+	defer closeStmt(stmt)
+
+	for _, v := range record.Vehicles {
+		_, err := stmt.ExecContext(ctx, v.ID, nullString(v.Label), v.RouteID, nullString(v.TripID),
+			v.Latitude, v.Longitude, v.Bearing, v.Speed, nullTime(v.Timestamp), nullTime(record.Timestamp), record.FetchedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) saveArrivals(ctx context.Context, tx *sql.Tx, record Record) error {
+	stmt, err := tx.PrepareContext(ctx, `insert into trip_updates
+		(trip_id, route_id, stop_id, arrival_at, delay, feed_timestamp, fetched_at)
+		values ($1, $2, $3, $4, $5, $6, $7)`)
+	if err != nil {
+		return err
+	}
+	defer closeStmt(stmt)
+
+	for _, a := range record.Arrivals {
+		_, err := stmt.ExecContext(ctx, a.TripID, a.RouteID, a.StopID,
+			nullTime(a.Time), a.Delay, nullTime(record.Timestamp), record.FetchedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load streams both tables at once, merging their records by fetch time.
+func (s *PostgresStore) Load(ctx context.Context, from, to time.Time, fn func(Record) error) error {
+	vehicles, err := s.queryRecords(ctx, services.FeedVehiclePositions, `select
+		vehicle_id, coalesce(label, ''), route_id, coalesce(trip_id, ''), latitude, longitude, bearing, speed,
+		position_at, feed_timestamp, fetched_at
+		from vehicle_positions
+		where ($1::timestamptz is null or fetched_at >= $1) and ($2::timestamptz is null or fetched_at < $2)
+		order by fetched_at, id`, from, to)
+	if err != nil {
+		return err
+	}
+	defer vehicles.close()
+
+	arrivals, err := s.queryRecords(ctx, services.FeedTripUpdates, `select
+		trip_id, route_id, stop_id, arrival_at, delay, feed_timestamp, fetched_at
+		from trip_updates
+		where ($1::timestamptz is null or fetched_at >= $1) and ($2::timestamptz is null or fetched_at < $2)
+		order by fetched_at, id`, from, to)
+	if err != nil {
+		return err
+	}
+	defer arrivals.close()
+
+	for {
+		v, vok, err := vehicles.peek()
+		if err != nil {
+			return err
+		}
+		a, aok, err := arrivals.peek()
+		if err != nil {
+			return err
+		}
+
+		var next Record
+		switch {
+		case !vok && !aok:
+			return nil
+		case vok && (!aok || !a.FetchedAt.Before(v.FetchedAt)):
+			next = vehicles.pop()
+		default:
+			next = arrivals.pop()
+		}
+
+		if err := fn(next); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *PostgresStore) queryRecords(ctx context.Context, feed services.Feed, query string, from, to time.Time) (*recordRows, error) {
+	rows, err := s.db.QueryContext(ctx, query, nullTime(from), nullTime(to))
+	if err != nil {
+		return nil, err
+	}
+	return &recordRows{feed: feed, rows: rows}, nil
+}
+
+// recordRows groups consecutive rows with the same fetch time into records.
+type recordRows struct {
+	feed    services.Feed
+	rows    *sql.Rows
+	current *Record // record being built
+	ready   *Record // complete record, returned by pop
+	done    bool
+}
+
+// peek returns the next complete record without consuming it.
+func (r *recordRows) peek() (Record, bool, error) {
+	for r.ready == nil && !r.done {
+		if !r.rows.Next() {
+			if err := r.rows.Err(); err != nil {
+				return Record{}, false, err
+			}
+			r.done = true
+			r.ready, r.current = r.current, nil
+			break
+		}
+
+		if err := r.scan(); err != nil {
+			return Record{}, false, err
+		}
+	}
+
+	if r.ready == nil {
+		return Record{}, false, nil
+	}
+	return *r.ready, true, nil
+}
+
+// scan reads the current row into the record it belongs to.
+func (r *recordRows) scan() error {
+	var fetchedAt time.Time
+	var feedTimestamp sql.NullTime
+
+	if r.feed == services.FeedVehiclePositions {
+		var v realtime.Vehicle
+		var positionAt sql.NullTime
+		err := r.rows.Scan(&v.ID, &v.Label, &v.RouteID, &v.TripID, &v.Latitude, &v.Longitude,
+			&v.Bearing, &v.Speed, &positionAt, &feedTimestamp, &fetchedAt)
+		if err != nil {
+			return err
+		}
+		v.Timestamp = positionAt.Time
+
+		record := r.add(fetchedAt, feedTimestamp.Time)
+		record.Vehicles = append(record.Vehicles, v)
+		return nil
+	}
+
+	var a realtime.Arrival
+	var arrivalAt sql.NullTime
+	err := r.rows.Scan(&a.TripID, &a.RouteID, &a.StopID, &arrivalAt, &a.Delay, &feedTimestamp, &fetchedAt)
+	if err != nil {
+		return err
+	}
+	a.Time = arrivalAt.Time
+
+	record := r.add(fetchedAt, feedTimestamp.Time)
+	record.Arrivals = append(record.Arrivals, a)
+	return nil
+}
+
+// add returns the record for the row fetch time, completing the current one when it differs.
+func (r *recordRows) add(fetchedAt, feedTimestamp time.Time) *Record {
+	if r.current != nil && !r.current.FetchedAt.Equal(fetchedAt) {
+		r.ready, r.current = r.current, nil
+	}
+	if r.current == nil {
+		r.current = &Record{Feed: r.feed, FetchedAt: fetchedAt, Timestamp: feedTimestamp}
+	}
+	return r.current
+}
+
+// pop consumes the record returned by peek.
+func (r *recordRows) pop() Record {
+	record := *r.ready
+	r.ready = nil
+	return record
+}
+
+func (r *recordRows) close() {
+	if err := r.rows.Close(); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Warn("error closing history rows", "error", err)
+	}
+}
+
+func closeStmt(stmt *sql.Stmt) {
+	if err := stmt.Close(); err != nil {
+		slog.Warn("error closing statement", "error", err)
+	}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,50 @@
+package history
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/driver"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+// Test_PostgresStore_SaveLoad needs a database migrated with the migrations directory.
+func Test_PostgresStore_SaveLoad(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	assert := assert.New(t)
+	required := require.New(t)
+
+	db, err := driver.ConnectSQL(dsn)
+	required.NoError(err)
+	t.Cleanup(func() { _ = db.SQL.Close() })
+
+	// records far in the future to not collide with real data
+	start := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	t.Cleanup(func() {
+		_, _ = db.SQL.Exec(`delete from vehicle_positions where fetched_at >= $1`, start)
+	})
+
+	store := NewPostgresStore(db.SQL)
+	ctx := context.Background()
+	required.NoError(store.Save(ctx, NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700000000), start)))
+	required.NoError(store.Save(ctx, NewRecord(services.FeedVehiclePositions, testVehicleFeed(1700000030), start.Add(30*time.Second))))
+
+	var records []Record
+	err = store.Load(ctx, start, start.Add(time.Minute), func(record Record) error {
+		records = append(records, record)
+		return nil
+	})
+	required.NoError(err)
+	required.Len(records, 2)
+	assert.Len(records[0].Vehicles, 2)
+	assert.True(records[1].FetchedAt.Equal(start.Add(30 * time.Second)))
+}
@@ -0,0 +1,114 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+// DefaultSpeed is the default replay speed, in real time.
+const DefaultSpeed = 1.0
+
+type (
+	// Recorder is a realtime.FeedFetcher that saves every fetched vehicle
+	// positions and trip updates feed to a Store.
+	Recorder struct {
+		fetcher realtime.FeedFetcher
+		store   Store
+		logger  *slog.Logger
+	}
+
+	// Updater receives replayed feed messages, implemented by realtime.Poller.
+	Updater interface {
+		Update(feed services.Feed, message *gtfs.FeedMessage, fetchedAt time.Time)
+	}
+
+	// Replayer feeds recorded records back to an Updater, waiting between them as
+	// long as they were apart when recorded, divided by Speed.
+	Replayer struct {
+		Store  Store
+		Target Updater
+		Speed  float64   // Replay speed, DefaultSpeed when zero
+		From   time.Time // Start of the replayed range, open when zero
+		To     time.Time // End of the replayed range, open when zero
+	}
+)
+
+// NewRecorder creates a Recorder saving the feeds fetched by fetcher to store.
+// Errors saving are reported to logger, or to the default logger when nil.
+func NewRecorder(fetcher realtime.FeedFetcher, store Store, logger *slog.Logger) *Recorder {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Recorder{
+		fetcher: fetcher,
+		store:   store,
+		logger:  logger,
+	}
+}
+
+// RealtimeFeed fetches the feed and records it. Errors saving the record are
+// logged but do not fail the fetch.
+func (r *Recorder) RealtimeFeed(ctx context.Context, feed services.Feed) (*gtfs.FeedMessage, error) {
+	message, err := r.fetcher.RealtimeFeed(ctx, feed)
+	if err != nil || !Recorded(feed) {
+		return message, err
+	}
+
+	if err := r.store.Save(ctx, NewRecord(feed, message, time.Now())); err != nil {
+		r.logger.ErrorContext(ctx, "error recording realtime feed", "feed", feed, "error", err)
+	}
+
+	return message, nil
+}
+
+// Run replays the records until they are exhausted or ctx is cancelled. Replayed
+// messages are published as fetched at the time they are replayed.
+func (r *Replayer) Run(ctx context.Context) error {
+	speed := r.Speed
+	if speed <= 0 {
+		speed = DefaultSpeed
+	}
+
+	var previous time.Time
+	err := r.Store.Load(ctx, r.From, r.To, func(record Record) error {
+		if !previous.IsZero() {
+			wait := time.Duration(float64(record.FetchedAt.Sub(previous)) / speed)
+			if err := sleep(ctx, wait); err != nil {
+				return err
+			}
+		}
+		previous = record.FetchedAt
+
+		r.Target.Update(record.Feed, record.Message(), time.Now())
+		return nil
+	})
+	if errors.Is(err, ErrStopLoading) {
+		return nil
+	}
+
+	return err
+}
+
+// sleep waits for d or until ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,308 @@
+// Package hub fans out realtime transit updates to subscribed clients.
+//
+// A Hub reads every new snapshot from a realtime.Source and sends each client
+// only the vehicles, arrivals and alerts matching its subscription. The JSON
+// message protocol is documented in docs/websocket.md.
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+// DefaultBufferSize is the number of messages queued per client before it is
+// considered too slow and disconnected.
+const DefaultBufferSize = 64
+
+var (
+	// ErrSlowClient is the reason a client is disconnected when its queue is full.
+	ErrSlowClient = errors.New("client too slow, queue full")
+	// ErrHubClosed is the reason clients are disconnected when the hub stops.
+	ErrHubClosed = errors.New("hub closed")
+	// ErrClientClosed is the reason of a client closed by its owner.
+	ErrClientClosed = errors.New("client closed")
+)
+
+type (
+	// Hub keeps track of the connected clients and sends them realtime updates.
+	Hub struct {
+		source     realtime.Source
+		BufferSize int // Messages queued per client, DefaultBufferSize when zero
+
+		mu      sync.Mutex
+		clients map[*Client]struct{}
+		closed  bool
+	}
+
+	// Client is a hub subscriber. Messages are read from Messages until it is
+	// closed, either by Close or by the hub.
+	Client struct {
+		hub  *Hub
+		send chan Message
+
+		mu           sync.Mutex
+		subscription Subscription
+		vehicles     map[string]realtime.Vehicle // Vehicles last sent, by ID
+		arrivalsAt   time.Time                   // Fetch time of the last arrivals sent
+		arrivalCount int
+		alertsAt     time.Time // Fetch time of the last alerts sent
+		alertCount   int
+		closed       bool
+		err          error
+	}
+
+	// state is the client independent data of a snapshot, computed once per broadcast.
+	state struct {
+		vehicles   []realtime.Vehicle
+		arrivals   []realtime.Arrival
+		arrivalsAt time.Time
+		alerts     []realtime.Alert
+		alertsAt   time.Time
+	}
+)
+
+// New creates a Hub fed by the given realtime source.
+func New(source realtime.Source) *Hub {
+	return &Hub{
+		source:  source,
+		clients: map[*Client]struct{}{},
+	}
+}
+
+// Run sends every new snapshot of the source to the clients. It blocks until the
+// source stops, then disconnects every client.
+func (h *Hub) Run() {
+	updates, cancel := h.source.Subscribe()
+	defer cancel()
+
+	for snapshot := range updates {
+		h.broadcast(newState(snapshot))
+	}
+
+	h.close()
+}
+
+// Register adds a new client with an empty subscription.
+func (h *Hub) Register() (*Client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+
+	size := h.BufferSize
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+
+	c := &Client{
+		hub:      h,
+		send:     make(chan Message, size),
+		vehicles: map[string]realtime.Vehicle{},
+	}
+	h.clients[c] = struct{}{}
+
+	return c, nil
+}
+
+// Len returns the number of connected clients.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// broadcast sends the state to every client.
+func (h *Hub) broadcast(s state) {
+	h.mu.Lock()
+	clients := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range clients {
+		c.update(s, false)
+	}
+}
+
+// close disconnects every client and rejects new ones.
+func (h *Hub) close() {
+	h.mu.Lock()
+	h.closed = true
+	clients := h.clients
+	h.clients = map[*Client]struct{}{}
+	h.mu.Unlock()
+
+	for c := range clients {
+		c.mu.Lock()
+		c.drop(ErrHubClosed)
+		c.mu.Unlock()
+	}
+}
+
+// unregister removes the client from the hub.
+func (h *Hub) unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, c)
+}
+
+// Messages returns the channel of messages for the client, closed on disconnection.
+func (c *Client) Messages() <-chan Message {
+	return c.send
+}
+
+// Err returns why the client was disconnected, nil while it is connected.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close disconnects the client from the hub.
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drop(ErrClientClosed)
+}
+
+// Handle processes a client request, queuing the reply and, after a subscription
+// change, the current data matching the new subscription.
+func (c *Client) Handle(req Request) {
+	switch req.Type {
+	case RequestSubscribe, RequestUnsubscribe:
+		for _, bbox := range req.BBoxes {
+			if !bbox.Valid() {
+				c.reply(Message{Type: MessageError, ID: req.ID, Error: fmt.Sprintf("invalid bounding box %v", bbox)})
+				return
+			}
+		}
+
+		c.mu.Lock()
+		if req.Type == RequestSubscribe {
+			c.subscription = c.subscription.add(req.Subscription)
+		} else {
+			c.subscription = c.subscription.remove(req.Subscription)
+		}
+		subscription := c.subscription
+		c.enqueue(Message{Type: MessageAck, ID: req.ID, Subscription: &subscription})
+		c.mu.Unlock()
+
+		c.update(newState(c.hub.source.Snapshot()), true)
+	case RequestPing:
+		c.reply(Message{Type: MessagePong, ID: req.ID})
+	default:
+		c.reply(Message{Type: MessageError, ID: req.ID, Error: fmt.Sprintf("unknown request type %q", req.Type)})
+	}
+}
+
+// Reject queues an error message for a request that could not be decoded.
+func (c *Client) Reject(err error) {
+	c.reply(Message{Type: MessageError, Error: err.Error()})
+}
+
+// reply queues a single message for the client.
+func (c *Client) reply(msg Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enqueue(msg)
+}
+
+// update queues the changes of the state matching the client subscription.
+// With refresh the current arrivals and alerts are sent even if unchanged.
+func (c *Client) update(s state, refresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	var vehicles []realtime.Vehicle
+	for _, v := range s.vehicles {
+		if c.subscription.matchVehicle(v) {
+			vehicles = append(vehicles, v)
+		}
+	}
+	var delta realtime.Delta
+	delta, c.vehicles = realtime.Diff(c.vehicles, vehicles)
+	if !delta.Empty() {
+		c.enqueue(Message{Type: MessageVehicles, Vehicles: delta.Vehicles, Removed: delta.Removed})
+	}
+
+	if !s.arrivalsAt.IsZero() && (refresh || s.arrivalsAt.After(c.arrivalsAt)) {
+		var arrivals []realtime.Arrival
+		for _, a := range s.arrivals {
+			if c.subscription.matchArrival(a) {
+				arrivals = append(arrivals, a)
+			}
+		}
+		// an empty list is only sent to clear arrivals sent before
+		if len(arrivals) > 0 || c.arrivalCount > 0 {
+			c.enqueue(Message{Type: MessageArrivals, Arrivals: arrivals})
+		}
+		c.arrivalsAt, c.arrivalCount = s.arrivalsAt, len(arrivals)
+	}
+
+	if !s.alertsAt.IsZero() && (refresh || s.alertsAt.After(c.alertsAt)) {
+		var alerts []realtime.Alert
+		for _, a := range s.alerts {
+			if c.subscription.matchAlert(a) {
+				alerts = append(alerts, a)
+			}
+		}
+		if len(alerts) > 0 || c.alertCount > 0 {
+			c.enqueue(Message{Type: MessageAlerts, Alerts: alerts})
+		}
+		c.alertsAt, c.alertCount = s.alertsAt, len(alerts)
+	}
+}
+
+// enqueue queues a message without blocking, disconnecting the client when its
+// queue is full. It must be called with c.mu held.
+func (c *Client) enqueue(msg Message) {
+	if c.closed {
+		return
+	}
+
+	select {
+	case c.send <- msg:
+	default:
+		c.drop(ErrSlowClient)
+	}
+}
+
+// drop disconnects the client. It must be called with c.mu held.
+func (c *Client) drop(reason error) {
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	c.err = reason
+	close(c.send)
+	c.hub.unregister(c)
+}
+
+// newState extracts the client independent data of a snapshot.
+func newState(snapshot *realtime.Snapshot) state {
+	s := state{
+		vehicles: snapshot.Vehicles(),
+		arrivals: snapshot.Arrivals(),
+		alerts:   snapshot.Alerts(),
+	}
+	if feed := snapshot.Feed(services.FeedTripUpdates); feed != nil && feed.Message != nil {
+		s.arrivalsAt = feed.FetchedAt
+	}
+	if feed := snapshot.Feed(services.FeedServiceAlerts); feed != nil && feed.Message != nil {
+		s.alertsAt = feed.FetchedAt
+	}
+	return s
+}
@@ -0,0 +1,229 @@
+package hub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+func Test_Subscribe_SendsCurrentData(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	source := newTestSource(testSnapshot(time.Now(), vehicle("bus-1", "1426", -34.6, -58.4), vehicle("bus-2", "1427", -34.7, -58.5)))
+	h := New(source)
+
+	client, err := h.Register()
+	required.NoError(err)
+
+	client.Handle(Request{Type: RequestSubscribe, ID: "1", Subscription: Subscription{Routes: []string{"1426"}}})
+
+	ack := <-client.Messages()
+	assert.Equal(MessageAck, ack.Type)
+	assert.Equal("1", ack.ID)
+	assert.Equal([]string{"1426"}, ack.Subscription.Routes)
+
+	vehicles := <-client.Messages()
+	assert.Equal(MessageVehicles, vehicles.Type)
+	required.Len(vehicles.Vehicles, 1)
+	assert.Equal("bus-1", vehicles.Vehicles[0].ID)
+
+	arrivals := <-client.Messages()
+	assert.Equal(MessageArrivals, arrivals.Type)
+	assert.Len(arrivals.Arrivals, 1)
+
+	alerts := <-client.Messages()
+	assert.Equal(MessageAlerts, alerts.Type)
+	assert.Len(alerts.Alerts, 1)
+}
+
+func Test_Run_BroadcastsDeltas(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	fetched := time.Now()
+	source := newTestSource(testSnapshot(fetched))
+	h := New(source)
+	go h.Run()
+
+	client, err := h.Register()
+	required.NoError(err)
+	client.Handle(Request{Type: RequestSubscribe, Subscription: Subscription{BBoxes: []BBox{{-58.45, -34.65, -58.35, -34.55}}}})
+	assert.Equal(MessageAck, (<-client.Messages()).Type)
+
+	// bus-1 enters the area, bus-2 is outside of it
+	source.updates <- testSnapshot(fetched, vehicle("bus-1", "1426", -34.6, -58.4), vehicle("bus-2", "1427", -34.7, -58.5))
+	msg := <-client.Messages()
+	assert.Equal(MessageVehicles, msg.Type)
+	required.Len(msg.Vehicles, 1)
+	assert.Equal("bus-1", msg.Vehicles[0].ID)
+
+	// bus-1 leaves the feed
+	source.updates <- testSnapshot(fetched, vehicle("bus-2", "1427", -34.7, -58.5))
+	msg = <-client.Messages()
+	assert.Equal(MessageVehicles, msg.Type)
+	assert.Equal([]string{"bus-1"}, msg.Removed)
+
+	// the source stops, clients are disconnected
+	close(source.updates)
+	_, ok := <-client.Messages()
+	assert.False(ok)
+	assert.ErrorIs(client.Err(), ErrHubClosed)
+
+	_, err = h.Register()
+	assert.ErrorIs(err, ErrHubClosed)
+}
+
+func Test_Unsubscribe(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	source := newTestSource(testSnapshot(time.Now(), vehicle("bus-1", "1426", -34.6, -58.4)))
+	h := New(source)
+
+	client, err := h.Register()
+	required.NoError(err)
+	client.Handle(Request{Type: RequestSubscribe, Subscription: Subscription{Routes: []string{"1426"}}})
+	for range 4 {
+		<-client.Messages()
+	}
+
+	client.Handle(Request{Type: RequestUnsubscribe, Subscription: Subscription{Routes: []string{"1426"}}})
+
+	ack := <-client.Messages()
+	assert.Equal(MessageAck, ack.Type)
+	assert.True(ack.Subscription.Empty())
+	vehicles := <-client.Messages()
+	assert.Equal([]string{"bus-1"}, vehicles.Removed)
+	// previously sent arrivals and alerts are cleared
+	assert.Empty((<-client.Messages()).Arrivals)
+	assert.Empty((<-client.Messages()).Alerts)
+}
+
+func Test_Handle_Errors(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	h := New(newTestSource(testSnapshot(time.Now())))
+	client, err := h.Register()
+	required.NoError(err)
+
+	client.Handle(Request{Type: "unknown", ID: "1"})
+	client.Handle(Request{Type: RequestSubscribe, ID: "2", Subscription: Subscription{BBoxes: []BBox{{10, 10, 0, 0}}}})
+	client.Handle(Request{Type: RequestPing, ID: "3"})
+
+	msg := <-client.Messages()
+	assert.Equal(MessageError, msg.Type)
+	assert.Equal("1", msg.ID)
+	msg = <-client.Messages()
+	assert.Equal(MessageError, msg.Type)
+	assert.Contains(msg.Error, "invalid bounding box")
+	msg = <-client.Messages()
+	assert.Equal(MessagePong, msg.Type)
+
+	client.Reject(errors.New("invalid request"))
+	msg = <-client.Messages()
+	assert.Equal(Message{Type: MessageError, Error: "invalid request"}, msg)
+}
+
+func Test_SlowClientIsDisconnected(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	h := New(newTestSource(testSnapshot(time.Now())))
+	h.BufferSize = 2
+
+	client, err := h.Register()
+	required.NoError(err)
+	assert.Equal(1, h.Len())
+
+	for range 3 {
+		client.Handle(Request{Type: RequestPing})
+	}
+
+	assert.ErrorIs(client.Err(), ErrSlowClient)
+	assert.Equal(0, h.Len())
+	// queued messages can still be drained before the channel is closed
+	assert.Len(client.Messages(), 2)
+}
+
+func Test_Close(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	h := New(newTestSource(testSnapshot(time.Now())))
+	client, err := h.Register()
+	required.NoError(err)
+
+	client.Close()
+	client.Close()
+
+	_, ok := <-client.Messages()
+	assert.False(ok)
+	assert.ErrorIs(client.Err(), ErrClientClosed)
+	assert.Equal(0, h.Len())
+}
+
+// testSource is a realtime.Source sending the snapshots written to updates.
+type testSource struct {
+	snapshot *realtime.Snapshot
+	updates  chan *realtime.Snapshot
+}
+
+func newTestSource(snapshot *realtime.Snapshot) *testSource {
+	return &testSource{snapshot: snapshot, updates: make(chan *realtime.Snapshot)}
+}
+
+func (s *testSource) Snapshot() *realtime.Snapshot {
+	return s.snapshot
+}
+
+func (s *testSource) Subscribe() (<-chan *realtime.Snapshot, func()) {
+	return s.updates, func() {}
+}
+
+// testSnapshot returns a snapshot with the given vehicles, an arrival and an alert for route 1426.
+func testSnapshot(fetched time.Time, vehicles ...*gtfs.FeedEntity) *realtime.Snapshot {
+	return &realtime.Snapshot{Feeds: map[services.Feed]*realtime.FeedSnapshot{
+		services.FeedVehiclePositions: {FetchedAt: fetched, Message: &gtfs.FeedMessage{Entity: vehicles}},
+		services.FeedTripUpdates: {FetchedAt: fetched, Message: &gtfs.FeedMessage{Entity: []*gtfs.FeedEntity{
+			{
+				Id: proto.String("trip-1"),
+				TripUpdate: &gtfs.TripUpdate{
+					Trip: &gtfs.TripDescriptor{TripId: proto.String("trip-1"), RouteId: proto.String("1426")},
+					StopTimeUpdate: []*gtfs.TripUpdate_StopTimeUpdate{
+						{StopId: proto.String("stop-1"), Arrival: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(1700000000)}},
+					},
+				},
+			},
+		}}},
+		services.FeedServiceAlerts: {FetchedAt: fetched, Message: &gtfs.FeedMessage{Entity: []*gtfs.FeedEntity{
+			{
+				Id: proto.String("alert-1"),
+				Alert: &gtfs.Alert{
+					InformedEntity: []*gtfs.EntitySelector{{RouteId: proto.String("1426")}},
+				},
+			},
+		}}},
+	}}
+}
+
+// vehicle returns a feed entity for a vehicle on the given route and position.
+func vehicle(id, routeID string, lat, lon float32) *gtfs.FeedEntity {
+	return &gtfs.FeedEntity{
+		Id: proto.String(id),
+		Vehicle: &gtfs.VehiclePosition{
+			Vehicle:  &gtfs.VehicleDescriptor{Id: proto.String(id)},
+			Trip:     &gtfs.TripDescriptor{RouteId: proto.String(routeID)},
+			Position: &gtfs.Position{Latitude: proto.Float32(lat), Longitude: proto.Float32(lon)},
+		},
+	}
+}
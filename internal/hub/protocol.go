@@ -0,0 +1,138 @@
+package hub
+
+import (
+	"slices"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+)
+
+// Request types sent by clients.
+const (
+	RequestSubscribe   = "subscribe"
+	RequestUnsubscribe = "unsubscribe"
+	RequestPing        = "ping"
+)
+
+// Message types sent to clients.
+const (
+	MessageAck      = "ack"
+	MessageError    = "error"
+	MessagePong     = "pong"
+	MessageVehicles = "vehicles"
+	MessageArrivals = "arrivals"
+	MessageAlerts   = "alerts"
+)
+
+type (
+	// Request is a message sent by a client.
+	Request struct {
+		Type string `json:"type"`
+		ID   string `json:"id,omitempty"` // Echoed back in the reply
+		Subscription
+	}
+
+	// Message is a message sent to a client.
+	Message struct {
+		Type         string             `json:"type"`
+		ID           string             `json:"id,omitempty"`
+		Subscription *Subscription      `json:"subscription,omitempty"`
+		Vehicles     []realtime.Vehicle `json:"vehicles,omitempty"`
+		Removed      []string           `json:"removed,omitempty"`
+		Arrivals     []realtime.Arrival `json:"arrivals,omitempty"`
+		Alerts       []realtime.Alert   `json:"alerts,omitempty"`
+		Error        string             `json:"error,omitempty"`
+	}
+
+	// Subscription selects the realtime data a client receives.
+	Subscription struct {
+		Routes []string `json:"routes,omitempty"` // Route IDs
+		Stops  []string `json:"stops,omitempty"`  // Stop IDs
+		BBoxes []BBox   `json:"bboxes,omitempty"` // Areas, only apply to vehicles
+	}
+
+	// BBox is a bounding box as [min longitude, min latitude, max longitude, max latitude].
+	BBox [4]float64
+)
+
+// Valid reports whether the bounding box corners are in order and within range.
+func (b BBox) Valid() bool {
+	return b[0] <= b[2] && b[1] <= b[3] &&
+		b[0] >= -180 && b[2] <= 180 && b[1] >= -90 && b[3] <= 90
+}
+
+// Contains reports whether the point is inside the bounding box.
+func (b BBox) Contains(lat, lon float64) bool {
+	return lon >= b[0] && lon <= b[2] && lat >= b[1] && lat <= b[3]
+}
+
+// Empty reports whether the subscription selects nothing.
+func (s Subscription) Empty() bool {
+	return len(s.Routes) == 0 && len(s.Stops) == 0 && len(s.BBoxes) == 0
+}
+
+// add returns the union of both subscriptions.
+func (s Subscription) add(other Subscription) Subscription {
+	result := Subscription{
+		Routes: slices.Clone(s.Routes),
+		Stops:  slices.Clone(s.Stops),
+		BBoxes: slices.Clone(s.BBoxes),
+	}
+	for _, id := range other.Routes {
+		if !slices.Contains(result.Routes, id) {
+			result.Routes = append(result.Routes, id)
+		}
+	}
+	for _, id := range other.Stops {
+		if !slices.Contains(result.Stops, id) {
+			result.Stops = append(result.Stops, id)
+		}
+	}
+	for _, bbox := range other.BBoxes {
+		if !slices.Contains(result.BBoxes, bbox) {
+			result.BBoxes = append(result.BBoxes, bbox)
+		}
+	}
+	return result
+}
+
+// remove returns the subscription without the routes, stops and areas of other.
+func (s Subscription) remove(other Subscription) Subscription {
+	return Subscription{
+		Routes: slices.DeleteFunc(slices.Clone(s.Routes), func(id string) bool { return slices.Contains(other.Routes, id) }),
+		Stops:  slices.DeleteFunc(slices.Clone(s.Stops), func(id string) bool { return slices.Contains(other.Stops, id) }),
+		BBoxes: slices.DeleteFunc(slices.Clone(s.BBoxes), func(b BBox) bool { return slices.Contains(other.BBoxes, b) }),
+	}
+}
+
+// matchVehicle reports whether the vehicle serves a subscribed route or is inside a subscribed area.
+func (s Subscription) matchVehicle(v realtime.Vehicle) bool {
+	if slices.Contains(s.Routes, v.RouteID) {
+		return true
+	}
+	for _, bbox := range s.BBoxes {
+		if bbox.Contains(v.Latitude, v.Longitude) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchArrival reports whether the arrival is for a subscribed route or stop.
+func (s Subscription) matchArrival(a realtime.Arrival) bool {
+	return slices.Contains(s.Routes, a.RouteID) || slices.Contains(s.Stops, a.StopID)
+}
+
+// matchAlert reports whether the alert affects a subscribed route or stop.
+func (s Subscription) matchAlert(a realtime.Alert) bool {
+	for _, id := range a.Routes {
+		if slices.Contains(s.Routes, id) {
+			return true
+		}
+	}
+	for _, id := range a.Stops {
+		if slices.Contains(s.Stops, id) {
+			return true
+		}
+	}
+	return false
+}
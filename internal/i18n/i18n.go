@@ -0,0 +1,216 @@
+// Package i18n translates the user interface to Spanish of Argentina and English,
+// from the message catalogs of the locales directory, and formats times, distances
+// and parking rules for each locale.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale is a supported locale, as a BCP 47 language tag.
+type Locale string
+
+const (
+	// Spanish is the Spanish of Argentina.
+	Spanish Locale = "es-AR"
+	// English is the language the catalog messages fall back to.
+	English Locale = "en"
+)
+
+// Locales are the supported locales, in the order they are offered to the users.
+var Locales = []Locale{Spanish, English}
+
+//go:embed locales/*.json
+var files embed.FS
+
+// catalogs are the messages of every locale by key.
+var catalogs = mustLoad(files)
+
+type contextKey struct{}
+
+// mustLoad reads the catalog of every locale, locales/<locale>.json, panicking
+// when one is missing or invalid, as the catalogs are embedded.
+func mustLoad(fsys fs.FS) map[Locale]map[string]string {
+	c, err := load(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func load(fsys fs.FS) (map[Locale]map[string]string, error) {
+	c := make(map[Locale]map[string]string, len(Locales))
+	for _, l := range Locales {
+		b, err := fs.ReadFile(fsys, "locales/"+string(l)+".json")
+		if err != nil {
+			return nil, fmt.Errorf("can not read the %s catalog: %w", l, err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(b, &messages); err != nil {
+			return nil, fmt.Errorf("can not parse the %s catalog: %w", l, err)
+		}
+		c[l] = messages
+	}
+	return c, nil
+}
+
+// Parse returns the supported locale of a language tag, e.g. Spanish for "es",
+// "es-AR" or "es_ar", and whether there is one.
+func Parse(tag string) (Locale, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if tag == "" {
+		return "", false
+	}
+	for _, l := range Locales {
+		if tag == strings.ToLower(string(l)) {
+			return l, true
+		}
+	}
+	language, _, _ := strings.Cut(tag, "-")
+	for _, l := range Locales {
+		if base, _, _ := strings.Cut(string(l), "-"); language == base {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// Negotiate returns the supported locale the client prefers the most from an
+// Accept-Language header, e.g. "en-US,en;q=0.9,es;q=0.8", and whether there is one.
+func Negotiate(acceptLanguage string) (Locale, bool) {
+	type candidate struct {
+		locale Locale
+		q      float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		l, ok := Parse(tag)
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{l, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].locale, true
+}
+
+// WithLocale returns a copy of ctx holding the locale of the request.
+func WithLocale(ctx context.Context, l Locale) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the locale of the request, English when there is none.
+func FromContext(ctx context.Context) Locale {
+	if l, ok := ctx.Value(contextKey{}).(Locale); ok {
+		return l
+	}
+	return English
+}
+
+// Lookup returns the message of key in the catalog of l, or in the English catalog
+// when l does not translate it.
+func (l Locale) Lookup(key string) (string, bool) {
+	if message, ok := catalogs[l][key]; ok {
+		return message, true
+	}
+	message, ok := catalogs[English][key]
+	return message, ok
+}
+
+// T returns the message of key formatted with args, as by fmt.Sprintf, or the key
+// itself when no catalog has it.
+func (l Locale) T(key string, args ...interface{}) string {
+	message, ok := l.Lookup(key)
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Name returns the name of the locale in its own language, e.g. "Español".
+func (l Locale) Name() string {
+	return l.T("locale.name")
+}
+
+// Relative returns a duration from now, rounded to the minute, such as "in 5 min",
+// "2 h 10 min ago" or "now" within a minute. Negative durations are in the past.
+func (l Locale) Relative(d time.Duration) string {
+	d = d.Round(time.Minute)
+	past := d < 0
+	if past {
+		d = -d
+	}
+
+	var s string
+	switch {
+	case d < time.Minute:
+		return l.T("time.now")
+	case d < time.Hour:
+		s = l.T("time.minutes", int(d.Minutes()))
+	case d < 24*time.Hour:
+		s = l.T("time.hours", int(d.Hours()))
+		if m := int(d.Minutes()) % 60; m > 0 {
+			s += " " + l.T("time.minutes", m)
+		}
+	default:
+		days := int(d.Hours()) / 24
+		s = l.T("time.days", days)
+		if days == 1 {
+			s = l.T("time.day")
+		}
+	}
+
+	if past {
+		return l.T("time.ago", s)
+	}
+	return l.T("time.in", s)
+}
+
+// Distance formats a distance in meters, rounded to 10 m below a kilometer, to
+// 100 m below 10 km and to the kilometer above, such as "850 m" or "1.2 km".
+func (l Locale) Distance(meters float64) string {
+	switch {
+	case meters < 0 || math.IsNaN(meters):
+		return ""
+	case meters < 995:
+		return fmt.Sprintf("%.0f m", math.Round(meters/10)*10)
+	case meters < 9950:
+		km := strconv.FormatFloat(math.Round(meters/100)/10, 'f', 1, 64)
+		return strings.Replace(km, ".", l.T("number.decimal_separator"), 1) + " km"
+	default:
+		return fmt.Sprintf("%.0f km", math.Round(meters/1000))
+	}
+}
+
+// DayName returns the name of a day of the week, empty for invalid days.
+func (l Locale) DayName(d time.Weekday) string {
+	if d < time.Sunday || d > time.Saturday {
+		return ""
+	}
+	return l.T("day." + strings.ToLower(d.String()))
+}
@@ -0,0 +1,146 @@
+package i18n
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Catalogs(t *testing.T) {
+	assert := assert.New(t)
+
+	for key := range catalogs[English] {
+		assert.Contains(catalogs[Spanish], key, "the Spanish catalog must translate every message")
+	}
+	for key := range catalogs[Spanish] {
+		if _, ok := catalogs[English][key]; !ok {
+			// the English messages of the errors are the ones of the apperror values
+			assert.True(strings.HasPrefix(key, "error.") || strings.HasPrefix(key, "status."), key)
+		}
+	}
+}
+
+func Test_load(t *testing.T) {
+	_, err := load(fstest.MapFS{"locales/en.json": {Data: []byte(`{"a": "b"}`)}})
+	assert.ErrorContains(t, err, "can not read the es-AR catalog")
+
+	_, err = load(fstest.MapFS{
+		"locales/es-AR.json": {Data: []byte(`{"a": "b"}`)},
+		"locales/en.json":    {Data: []byte(`{"a": 1}`)},
+	})
+	assert.ErrorContains(t, err, "can not parse the en catalog")
+}
+
+func Test_Parse(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]Locale{
+		"es-AR": Spanish,
+		"es_ar": Spanish,
+		"es":    Spanish,
+		"es-ES": Spanish,
+		"en":    English,
+		" EN ":  English,
+		"en-US": English,
+	}
+	for tag, expected := range tests {
+		l, ok := Parse(tag)
+		assert.True(ok, tag)
+		assert.Equal(expected, l, tag)
+	}
+
+	for _, tag := range []string{"", "pt-BR", "*", "e"} {
+		_, ok := Parse(tag)
+		assert.False(ok, tag)
+	}
+}
+
+func Test_Negotiate(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]Locale{
+		"es-AR,es;q=0.9,en;q=0.8":      Spanish,
+		"en-US,en;q=0.9,es;q=0.8":      English,
+		"pt-BR,pt;q=0.9,es;q=0.8":      Spanish,
+		"es;q=0.5, en;q=0.7":           English,
+		"fr, es-AR;q=0.3, en;q=0":      Spanish,
+		"en;q=invalid, es-AR;q=0.2":    Spanish,
+		"es-MX , en-GB;q=0.9, *;q=0.1": Spanish,
+	}
+	for header, expected := range tests {
+		l, ok := Negotiate(header)
+		assert.True(ok, header)
+		assert.Equal(expected, l, header)
+	}
+
+	for _, header := range []string{"", "*", "pt-BR,fr;q=0.5", "en;q=0"} {
+		_, ok := Negotiate(header)
+		assert.False(ok, header)
+	}
+}
+
+func Test_FromContext(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(English, FromContext(context.Background()))
+	assert.Equal(Spanish, FromContext(WithLocale(context.Background(), Spanish)))
+}
+
+func Test_T(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("Search", English.T("nav.search"))
+	assert.Equal("Buscar", Spanish.T("nav.search"))
+	assert.Equal("Se encontraron 2 recorridos de la línea 60.", Spanish.T("search.found", 2, "60"))
+	assert.Equal("Español", Spanish.Name())
+	assert.Equal("no.such.key", Spanish.T("no.such.key"))
+	// unknown locales fall back to English
+	assert.Equal("Search", Locale("").T("nav.search"))
+
+	message, ok := Spanish.Lookup("error.not_found")
+	assert.True(ok)
+	assert.Equal("La página que buscás no existe.", message)
+	_, ok = English.Lookup("error.not_found")
+	assert.False(ok)
+}
+
+func Test_Relative(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[time.Duration][2]string{
+		20 * time.Second:               {"now", "ahora"},
+		5*time.Minute + 40*time.Second: {"in 6 min", "en 6 min"},
+		-12 * time.Minute:              {"12 min ago", "hace 12 min"},
+		2*time.Hour + 10*time.Minute:   {"in 2 h 10 min", "en 2 h 10 min"},
+		26 * time.Hour:                 {"in 1 day", "en 1 día"},
+		-72 * time.Hour:                {"3 days ago", "hace 3 días"},
+	}
+	for d, expected := range tests {
+		assert.Equal(expected[0], English.Relative(d), d.String())
+		assert.Equal(expected[1], Spanish.Relative(d), d.String())
+	}
+}
+
+func Test_Distance(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("850 m", English.Distance(847))
+	assert.Equal("850 m", Spanish.Distance(847))
+	assert.Equal("1.2 km", English.Distance(1240))
+	assert.Equal("1,2 km", Spanish.Distance(1240))
+	assert.Equal("13 km", Spanish.Distance(12600))
+	assert.Empty(English.Distance(-1))
+}
+
+func Test_DayName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("Monday", English.DayName(time.Monday))
+	assert.Equal("miércoles", Spanish.DayName(time.Wednesday))
+	assert.Equal("sábado", Spanish.DayName(time.Saturday))
+	assert.Empty(English.DayName(time.Weekday(7)))
+}
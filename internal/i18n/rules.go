@@ -0,0 +1,87 @@
+package i18n
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// ruleRe matches the parking rules simplified by the services package,
+	// "Lado <side> (<parity>): <permission> las <schedule>.", where only the
+	// permission is required.
+	ruleRe = regexp.MustCompile(`(?is)^(?:lado\s*([^:(]*?)\s*(?:\(([^)]*)\))?\s*:\s*)?(.*?)(?:\s+las\s+(.*?))?\.?$`)
+	wordRe = regexp.MustCompile(`\pL+`)
+
+	// rulePhrases and ruleWords translate the Spanish of the CABA API rules to English,
+	// phrases first. Words without a translation are kept.
+	rulePhrases = strings.NewReplacer(
+		"prohibido estacionar", "no parking",
+		"prohibido detenerse", "no stopping",
+		"estacionamiento medido", "metered parking",
+		"carga y descarga", "loading and unloading",
+		"sin horario", "no schedule",
+	)
+	ruleWords = map[string]string{
+		"izquierdo": "left", "derecho": "right", "ambos": "both",
+		"par": "even", "pares": "even", "impar": "odd", "impares": "odd",
+		"permitido": "allowed", "prohibido": "forbidden", "estacionar": "parking", "estacionamiento": "parking",
+		"excepto": "except", "salvo": "except", "sin": "no", "horario": "schedule",
+		"lunes": "Monday", "lun": "Mon", "martes": "Tuesday", "mar": "Tue",
+		"miércoles": "Wednesday", "miercoles": "Wednesday", "mie": "Wed", "mié": "Wed",
+		"jueves": "Thursday", "jue": "Thu", "viernes": "Friday", "vie": "Fri",
+		"sábado": "Saturday", "sabado": "Saturday", "sábados": "Saturdays", "sabados": "Saturdays", "sab": "Sat", "sáb": "Sat",
+		"domingo": "Sunday", "domingos": "Sundays", "dom": "Sun",
+		"feriado": "holiday", "feriados": "holidays", "todos": "every", "los": "", "días": "days", "dias": "days",
+		"de": "from", "desde": "from", "a": "to", "al": "to", "hasta": "until", "y": "and",
+		"hs": "h", "horas": "hours",
+	}
+)
+
+// Rule returns a parking rule simplified by the services package, which are in
+// Spanish, in the language of l.
+func (l Locale) Rule(text string) string {
+	if l == Spanish {
+		return text
+	}
+
+	m := ruleRe.FindStringSubmatch(strings.TrimSpace(text))
+	side, parity, permission, schedule := m[1], m[2], m[3], m[4]
+
+	var s string
+	if side != "" {
+		s = l.T("rule.side", translateRule(side))
+	}
+	if parity != "" {
+		s = strings.TrimSpace(s + " (" + translateRule(parity) + ")")
+	}
+	if s != "" {
+		s += ": "
+	}
+	s += translateRule(permission)
+	if schedule != "" {
+		s += l.T("rule.schedule", translateRule(schedule))
+	}
+	return capitalize(s) + "."
+}
+
+// translateRule translates the words of a rule to English.
+func translateRule(text string) string {
+	text = rulePhrases.Replace(strings.ToLower(text))
+	text = wordRe.ReplaceAllStringFunc(text, func(word string) string {
+		if translation, ok := ruleWords[word]; ok {
+			return translation
+		}
+		return word
+	})
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
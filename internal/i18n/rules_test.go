@@ -0,0 +1,26 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Rule(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]string{
+		"Lado izquierdo: permitido las 08:00-20:00.":                                     "Left side: allowed, 08:00-20:00.",
+		"Lado izquierdo (impar): permitido las 08:00-20:00.":                             "Left side (odd): allowed, 08:00-20:00.",
+		"Lado par: prohibido estacionar las lunes a viernes de 7 a 21 hs.":               "Even side: no parking, Monday to Friday from 7 to 21 h.",
+		"Lado derecho: prohibido las 24 hs.":                                             "Right side: forbidden, 24 h.",
+		"Prohibido estacionar las Lunes a Viernes de 7 a 21 hs. y Sábados de 8 a 13 hs.": "No parking, Monday to Friday from 7 to 21 h. and Saturdays from 8 to 13 h.",
+		"Prohibido estacionar":                                                           "No parking.",
+		"Sin horario":                                                                    "No schedule.",
+	}
+	for rule, expected := range tests {
+		assert.Equal(expected, English.Rule(rule), rule)
+		// the rules are in Spanish already
+		assert.Equal(rule, Spanish.Rule(rule), rule)
+	}
+}
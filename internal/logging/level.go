@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// LevelHandler reads the log level on GET and changes it on PUT, the new level
+// name being the request body. It must be served on an admin listener that is
+// not reachable through the load balancer.
+func LevelHandler(level *slog.LevelVar, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			body, err := io.ReadAll(io.LimitReader(r.Body, 16))
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			newLevel, err := ParseLevel(strings.TrimSpace(string(body)))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			logger.InfoContext(r.Context(), "log level changed", "from", LevelName(level.Level()), "to", LevelName(newLevel))
+			level.Set(newLevel)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, LevelName(level.Level())+"\n")
+	})
+}
@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LevelHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+	logger, level, err := New(&out, FormatText, "info")
+	require.NoError(t, err)
+	handler := LevelHandler(level, logger)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/debug/log-level", nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Equal("info\n", rr.Body.String())
+
+	req := httptest.NewRequest("PUT", "/debug/log-level", strings.NewReader("debug\n"))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Equal("debug\n", rr.Body.String())
+	assert.Equal(slog.LevelDebug, level.Level())
+	assert.Contains(out.String(), "msg=\"log level changed\" from=info to=debug")
+
+	req = httptest.NewRequest("PUT", "/debug/log-level", strings.NewReader("loud"))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(http.StatusBadRequest, rr.Code)
+	assert.Equal(slog.LevelDebug, level.Level())
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/debug/log-level", nil))
+	assert.Equal(http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal("GET, PUT", rr.Header().Get("Allow"))
+}
@@ -0,0 +1,81 @@
+// Package logging sets up the structured logger of the application and tags
+// every log record of a request with its request ID.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/mayloo89/bamos/internal/tracing"
+)
+
+// Output formats of the logger.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New creates a logger writing to w in the given format, FormatText or FormatJSON,
+// at the named level. The returned LevelVar changes the level while the
+// application runs. Records logged with a context carrying a request ID include it.
+func New(w io.Writer, format, levelName string) (*slog.Logger, *slog.LevelVar, error) {
+	initial, err := ParseLevel(levelName)
+	if err != nil {
+		return nil, nil, err
+	}
+	level := new(slog.LevelVar)
+	level.Set(initial)
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch format {
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, nil, fmt.Errorf("invalid log format %q, must be %s or %s", format, FormatText, FormatJSON)
+	}
+
+	return slog.New(contextHandler{handler}), level, nil
+}
+
+// ParseLevel parses a level name: debug, info, warn or error.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, must be debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// LevelName returns the lower case name of a level, as accepted by ParseLevel.
+func LevelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// contextHandler adds the request ID and trace ID of the context to the records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if id := tracing.TraceIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("trace_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
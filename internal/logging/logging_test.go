@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_New_JSON(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	var out bytes.Buffer
+	logger, level, err := New(&out, FormatJSON, "info")
+	required.NoError(err)
+
+	logger.Debug("hidden")
+	logger.InfoContext(WithRequestID(context.Background(), "abc-123"), "visible", "route", "1426")
+
+	var record map[string]any
+	required.NoError(json.Unmarshal(out.Bytes(), &record))
+	assert.Equal("visible", record["msg"])
+	assert.Equal("INFO", record["level"])
+	assert.Equal("1426", record["route"])
+	assert.Equal("abc-123", record["request_id"])
+
+	// the level changes at runtime, also for derived loggers
+	out.Reset()
+	derived := logger.With("component", "poller")
+	level.Set(slog.LevelDebug)
+	derived.DebugContext(WithRequestID(context.Background(), "def-456"), "now visible")
+	assert.Contains(out.String(), `"request_id":"def-456"`)
+	assert.Contains(out.String(), `"component":"poller"`)
+}
+
+func Test_New_Text(t *testing.T) {
+	var out bytes.Buffer
+	logger, _, err := New(&out, FormatText, "warn")
+	require.NoError(t, err)
+
+	logger.Info("hidden")
+	logger.Warn("visible", "feed", "/colectivos/vehiclePositions")
+
+	assert.NotContains(t, out.String(), "hidden")
+	assert.Contains(t, out.String(), `level=WARN msg=visible feed=/colectivos/vehiclePositions`)
+}
+
+func Test_New_Invalid(t *testing.T) {
+	_, _, err := New(&bytes.Buffer{}, "xml", "info")
+	assert.ErrorContains(t, err, `invalid log format "xml"`)
+
+	_, _, err = New(&bytes.Buffer{}, FormatText, "verbose")
+	assert.ErrorContains(t, err, `invalid log level "verbose"`)
+}
+
+func Test_ParseLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug, "info": slog.LevelInfo, "WARN": slog.LevelWarn, "error": slog.LevelError,
+	} {
+		level, err := ParseLevel(name)
+		assert.NoError(err, name)
+		assert.Equal(want, level, name)
+	}
+
+	assert.Equal("warn", LevelName(slog.LevelWarn))
+}
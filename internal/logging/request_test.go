@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RequestIDMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	var seen string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "generated"},
+		{name: "from the load balancer", header: "lb-7f3a.42_x", keep: true},
+		{name: "injection", header: "abc\" level=ERROR"},
+		{name: "too long", header: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestIDHeader, tt.header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.NotEmpty(seen, tt.name)
+		assert.Equal(seen, rr.Header().Get(RequestIDHeader), tt.name)
+		if tt.keep {
+			assert.Equal(tt.header, seen, tt.name)
+		} else {
+			assert.NotEqual(tt.header, seen, tt.name)
+			assert.Len(seen, 32, tt.name)
+		}
+	}
+}
+
+func Test_AccessLog(t *testing.T) {
+	required := require.New(t)
+
+	var out bytes.Buffer
+	logger, level, err := New(&out, FormatText, "info")
+	required.NoError(err)
+
+	handler := RequestIDMiddleware(AccessLog(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})))
+
+	req := httptest.NewRequest("GET", "/colectivos/search", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, out.String(), "requests are logged at debug level")
+
+	level.Set(slog.LevelDebug)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Contains(t, out.String(), `msg="request handled" method=GET path=/colectivos/search status=418`)
+	assert.Contains(t, out.String(), "request_id=req-1")
+}
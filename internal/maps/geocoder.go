@@ -0,0 +1,231 @@
+package maps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/cache"
+	"github.com/mayloo89/bamos/internal/tracing"
+)
+
+// Geocoders.
+const (
+	GoogleGeocoding = "google"
+	Nominatim       = "nominatim"
+)
+
+const (
+	// GoogleGeocodingURL is the endpoint of the Google Geocoding API.
+	GoogleGeocodingURL = "https://maps.googleapis.com/maps/api/geocode/json"
+	// NominatimURL is the public Nominatim server of OpenStreetMap, limited to a
+	// request per second.
+	NominatimURL = "https://nominatim.openstreetmap.org"
+	// DefaultTimeout is the default timeout of the geocoding requests.
+	DefaultTimeout = 5 * time.Second
+	// MaxPlaces is the largest number of places a search returns.
+	MaxPlaces = 5
+	// GeocodeCacheSize is the number of searches a CachedGeocoder keeps.
+	GeocodeCacheSize = 1000
+
+	// ambaViewbox bounds the searches, as "west,north,east,south" longitudes and latitudes.
+	ambaViewbox = "-59.3,-34.1,-57.8,-35.3"
+	// ambaBounds bounds the searches, as "south,west|north,east" latitudes and longitudes.
+	ambaBounds = "-35.3,-59.3|-34.1,-57.8"
+)
+
+// Place is a geocoded address.
+type Place struct {
+	Address string  `json:"address"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+}
+
+// Geocoder finds the places of an address, the best match first.
+type Geocoder interface {
+	Geocode(ctx context.Context, query string) ([]Place, error)
+}
+
+// GoogleGeocoder geocodes with the Google Geocoding API.
+type GoogleGeocoder struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+// NewGoogleGeocoder returns a GoogleGeocoder with the given API key and request
+// timeout, DefaultTimeout when zero.
+func NewGoogleGeocoder(apiKey string, timeout time.Duration) *GoogleGeocoder {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &GoogleGeocoder{URL: GoogleGeocodingURL, APIKey: apiKey, Client: &http.Client{Timeout: timeout}}
+}
+
+// Geocode returns the Argentinian places of the address, preferring the AMBA.
+func (g *GoogleGeocoder) Geocode(ctx context.Context, query string) ([]Place, error) {
+	ctx, span := tracing.Start(ctx, "maps.GoogleGeocode")
+	defer span.End()
+
+	params := url.Values{
+		"address":    {query},
+		"components": {"country:AR"},
+		"bounds":     {ambaBounds},
+		"key":        {g.APIKey},
+	}
+	var body struct {
+		Status       string `json:"status"`
+		ErrorMessage string `json:"error_message"`
+		Results      []struct {
+			FormattedAddress string `json:"formatted_address"`
+			Geometry         struct {
+				Location struct {
+					Lat float64 `json:"lat"`
+					Lng float64 `json:"lng"`
+				} `json:"location"`
+			} `json:"geometry"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, g.Client, g.URL+"?"+params.Encode(), nil, &body); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	switch body.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return nil, nil
+	default:
+		err := fmt.Errorf("google geocoding answered %s: %s", body.Status, body.ErrorMessage)
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	places := make([]Place, 0, min(len(body.Results), MaxPlaces))
+	for _, r := range body.Results[:min(len(body.Results), MaxPlaces)] {
+		places = append(places, Place{Address: r.FormattedAddress, Lat: r.Geometry.Location.Lat, Lon: r.Geometry.Location.Lng})
+	}
+	return places, nil
+}
+
+// NominatimGeocoder geocodes with a Nominatim server, the search engine of
+// OpenStreetMap.
+type NominatimGeocoder struct {
+	URL       string
+	UserAgent string // identifies the application, as the usage policy of the public server requires
+	Client    *http.Client
+}
+
+// NewNominatimGeocoder returns a NominatimGeocoder of the server at baseURL,
+// NominatimURL when empty, with the given request timeout, DefaultTimeout when zero.
+func NewNominatimGeocoder(baseURL string, timeout time.Duration) *NominatimGeocoder {
+	if baseURL == "" {
+		baseURL = NominatimURL
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &NominatimGeocoder{URL: baseURL, UserAgent: "bamos", Client: &http.Client{Timeout: timeout}}
+}
+
+// Geocode returns the places of the address within the AMBA.
+func (g *NominatimGeocoder) Geocode(ctx context.Context, query string) ([]Place, error) {
+	ctx, span := tracing.Start(ctx, "maps.NominatimGeocode")
+	defer span.End()
+
+	params := url.Values{
+		"q":            {query},
+		"format":       {"jsonv2"},
+		"countrycodes": {"ar"},
+		"viewbox":      {ambaViewbox},
+		"bounded":      {"1"},
+		"limit":        {strconv.Itoa(MaxPlaces)},
+	}
+	header := http.Header{"User-Agent": {g.UserAgent}}
+	var body []struct {
+		DisplayName string `json:"display_name"`
+		Lat         string `json:"lat"`
+		Lon         string `json:"lon"`
+	}
+	if err := getJSON(ctx, g.Client, g.URL+"/search?"+params.Encode(), header, &body); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	places := make([]Place, 0, len(body))
+	for _, r := range body {
+		lat, latErr := strconv.ParseFloat(r.Lat, 64)
+		lon, lonErr := strconv.ParseFloat(r.Lon, 64)
+		if latErr != nil || lonErr != nil {
+			continue
+		}
+		places = append(places, Place{Address: r.DisplayName, Lat: lat, Lon: lon})
+	}
+	return places, nil
+}
+
+// CachedGeocoder keeps the places of the searched addresses of a Geocoder for a
+// while, so the same search does not call it again. Failed searches are not kept.
+type CachedGeocoder struct {
+	Geocoder Geocoder
+	cache    *cache.Cache[string, []Place]
+}
+
+// NewCachedGeocoder returns a CachedGeocoder keeping the places of the last
+// GeocodeCacheSize searches of g for ttl.
+func NewCachedGeocoder(g Geocoder, ttl time.Duration) *CachedGeocoder {
+	return &CachedGeocoder{Geocoder: g, cache: cache.New[string, []Place]("geocode", ttl, GeocodeCacheSize)}
+}
+
+// Geocode returns the cached places of the address, searching it otherwise. The
+// searches differing in case and spacing only are the same.
+func (g *CachedGeocoder) Geocode(ctx context.Context, query string) ([]Place, error) {
+	key := strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if places, ok := g.cache.Get(key); ok {
+		return places, nil
+	}
+	places, err := g.Geocoder.Geocode(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	g.cache.Set(key, places)
+	return places, nil
+}
+
+// getJSON decodes the JSON answered to a GET of rawURL into v, failing unless the
+// status is 200.
+func getJSON(ctx context.Context, client *http.Client, rawURL string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	for k, values := range header {
+		req.Header[k] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// the URL of the error has the API key
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("can not geocode: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("geocoder answered with status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("can not decode the geocoder response: %w", err)
+	}
+	return nil
+}
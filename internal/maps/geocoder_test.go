@@ -0,0 +1,124 @@
+package maps
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GoogleGeocoder(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	var query string
+	status := "OK"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "` + status + `", "error_message": "The provided API key is invalid.", "results": [
+			{"formatted_address": "Av. Corrientes 1234, C1043 CABA, Argentina", "geometry": {"location": {"lat": -34.6037, "lng": -58.3816}}}
+		]}`))
+	}))
+	defer server.Close()
+
+	g := NewGoogleGeocoder("secret-key", 0)
+	g.URL = server.URL
+	places, err := g.Geocode(context.Background(), "Corrientes 1234")
+	required.NoError(err)
+	assert.Equal([]Place{{Address: "Av. Corrientes 1234, C1043 CABA, Argentina", Lat: -34.6037, Lon: -58.3816}}, places)
+	assert.Contains(query, "address=Corrientes+1234")
+	assert.Contains(query, "components=country%3AAR")
+	assert.Contains(query, "key=secret-key")
+
+	status = "ZERO_RESULTS"
+	places, err = g.Geocode(context.Background(), "nowhere")
+	assert.NoError(err)
+	assert.Empty(places)
+
+	status = "REQUEST_DENIED"
+	_, err = g.Geocode(context.Background(), "Corrientes 1234")
+	assert.ErrorContains(err, "google geocoding answered REQUEST_DENIED: The provided API key is invalid.")
+
+	// the key in the URL is not in the errors
+	server.Close()
+	_, err = g.Geocode(context.Background(), "Corrientes 1234")
+	required.Error(err)
+	assert.NotContains(err.Error(), "secret-key")
+}
+
+func Test_NominatimGeocoder(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	var r *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r = req
+		if req.URL.Query().Get("q") == "error" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`[
+			{"display_name": "1234, Avenida Corrientes, San Nicolás, Buenos Aires", "lat": "-34.6037", "lon": "-58.3816"},
+			{"display_name": "invalid", "lat": "north", "lon": "-58.3816"}
+		]`))
+	}))
+	defer server.Close()
+
+	g := NewNominatimGeocoder(server.URL, 0)
+	places, err := g.Geocode(context.Background(), "Corrientes 1234")
+	required.NoError(err)
+	assert.Equal([]Place{{Address: "1234, Avenida Corrientes, San Nicolás, Buenos Aires", Lat: -34.6037, Lon: -58.3816}}, places)
+	assert.Equal("/search", r.URL.Path)
+	assert.Equal("Corrientes 1234", r.URL.Query().Get("q"))
+	assert.Equal("1", r.URL.Query().Get("bounded"))
+	assert.Equal("bamos", r.Header.Get("User-Agent"))
+
+	_, err = g.Geocode(context.Background(), "error")
+	assert.ErrorContains(err, "geocoder answered with status 429")
+
+	assert.Equal(NominatimURL, NewNominatimGeocoder("", 0).URL)
+}
+
+// countingGeocoder counts its searches, failing the ones of failing.
+type countingGeocoder struct {
+	searches int
+	failing  string
+}
+
+func (g *countingGeocoder) Geocode(_ context.Context, query string) ([]Place, error) {
+	g.searches++
+	if query == g.failing {
+		return nil, errors.New("geocoder answered with status 503")
+	}
+	return []Place{{Address: query, Lat: -34.6037, Lon: -58.3816}}, nil
+}
+
+func Test_CachedGeocoder(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	counting := &countingGeocoder{failing: "Florida 1"}
+	g := NewCachedGeocoder(counting, time.Hour)
+
+	places, err := g.Geocode(ctx, "Corrientes 1234")
+	required.NoError(err)
+	assert.Equal("Corrientes 1234", places[0].Address)
+	places, err = g.Geocode(ctx, "  corrientes   1234 ")
+	required.NoError(err)
+	assert.Equal("Corrientes 1234", places[0].Address)
+	assert.Equal(1, counting.searches, "the same search is answered from the cache")
+
+	// failed searches are not kept
+	_, err = g.Geocode(ctx, "Florida 1")
+	assert.Error(err)
+	_, err = g.Geocode(ctx, "Florida 1")
+	assert.Error(err)
+	assert.Equal(3, counting.searches)
+}
@@ -0,0 +1,75 @@
+// Package maps configures the map drawn by the pages, with the Google Maps
+// JavaScript API or with Leaflet over OpenStreetMap or self-hosted tiles, and
+// geocodes the addresses searched on it.
+package maps
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Map providers.
+const (
+	Google        = "google"
+	OpenStreetMap = "osm"
+	Tiles         = "tiles"
+)
+
+const (
+	// OpenStreetMapTileURL is the tile server of the osm provider.
+	OpenStreetMapTileURL = "https://tile.openstreetmap.org/{z}/{x}/{y}.png"
+	// OpenStreetMapAttribution credits the OpenStreetMap tiles, as their license requires.
+	OpenStreetMapAttribution = `&copy; <a href="https://www.openstreetmap.org/copyright">OpenStreetMap</a> contributors`
+)
+
+// Provider is the map drawn by the pages.
+type Provider struct {
+	Name        string
+	APIKey      string // Key of the Google Maps JavaScript API
+	TileURL     string // Tiles of Leaflet, with the {z}, {x} and {y} placeholders
+	Attribution string // HTML crediting the tiles
+}
+
+// NewProvider returns the map provider with the given name: Google, which needs an
+// API key, OpenStreetMap or Tiles, which needs the URL of the tiles.
+func NewProvider(name, apiKey, tileURL, attribution string) (Provider, error) {
+	p := Provider{Name: name, APIKey: apiKey, TileURL: tileURL, Attribution: attribution}
+	switch name {
+	case Google:
+		if apiKey == "" {
+			return Provider{}, errors.New("the google map requires a Google Maps API key")
+		}
+	case OpenStreetMap:
+	case Tiles:
+		if !strings.HasPrefix(tileURL, "https://") && !strings.HasPrefix(tileURL, "http://") && !strings.HasPrefix(tileURL, "/") {
+			return Provider{}, fmt.Errorf("the tiles map requires an http, https or relative tile URL, got %q", tileURL)
+		}
+	default:
+		return Provider{}, fmt.Errorf("unknown map provider %q, expected google, osm or tiles", name)
+	}
+	return p, nil
+}
+
+// Leaflet reports whether the map is drawn with Leaflet over the tiles, as every
+// map but the Google one.
+func (p Provider) Leaflet() bool {
+	return p.Name != Google
+}
+
+// Tiles returns the tile URL of the maps drawn with Leaflet, the OpenStreetMap
+// one unless the provider has its own tiles.
+func (p Provider) Tiles() string {
+	if p.Name == Tiles && p.TileURL != "" {
+		return p.TileURL
+	}
+	return OpenStreetMapTileURL
+}
+
+// TilesAttribution returns the HTML crediting the tiles of Tiles.
+func (p Provider) TilesAttribution() string {
+	if p.Name == Tiles && p.TileURL != "" {
+		return p.Attribution
+	}
+	return OpenStreetMapAttribution
+}
@@ -0,0 +1,43 @@
+package maps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewProvider(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	p, err := NewProvider(Google, "key", "", "")
+	required.NoError(err)
+	assert.False(p.Leaflet())
+	assert.Equal("key", p.APIKey)
+	// the pages drawn with Leaflet anyway use the OpenStreetMap tiles
+	assert.Equal(OpenStreetMapTileURL, p.Tiles())
+
+	p, err = NewProvider(OpenStreetMap, "", "", "")
+	required.NoError(err)
+	assert.True(p.Leaflet())
+	assert.Equal(OpenStreetMapTileURL, p.Tiles())
+	assert.Equal(OpenStreetMapAttribution, p.TilesAttribution())
+
+	p, err = NewProvider(Tiles, "", "https://tiles.example.com/{z}/{x}/{y}.png", "Example")
+	required.NoError(err)
+	assert.True(p.Leaflet())
+	assert.Equal("https://tiles.example.com/{z}/{x}/{y}.png", p.Tiles())
+	assert.Equal("Example", p.TilesAttribution())
+
+	_, err = NewProvider(Google, "", "", "")
+	assert.ErrorContains(err, "requires a Google Maps API key")
+	_, err = NewProvider(Tiles, "", "tiles.example.com", "")
+	assert.ErrorContains(err, `requires an http, https or relative tile URL, got "tiles.example.com"`)
+	_, err = NewProvider("bing", "", "", "")
+	assert.ErrorContains(err, `unknown map provider "bing"`)
+
+	// the zero provider is drawn with OpenStreetMap
+	assert.True(Provider{}.Leaflet())
+	assert.Equal(OpenStreetMapTileURL, Provider{}.Tiles())
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var factory = promauto.With(Default)
+
+var (
+	// HTTPRequests counts the handled requests by method, route pattern and status code.
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "bamos_http_requests_total",
+		Help: "HTTP requests handled, by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+	// HTTPDuration observes the time to handle a request by method, route pattern and status code.
+	HTTPDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bamos_http_request_duration_seconds",
+		Help:    "Time to handle an HTTP request, by method, route pattern and status code.",
+		Buckets: DefaultBuckets,
+	}, []string{"method", "route", "status"})
+
+	// UpstreamRequests counts the upstream API attempts by endpoint and status
+	// code, "error" when no response was received.
+	UpstreamRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "bamos_upstream_requests_total",
+		Help: "Upstream API request attempts, by endpoint and status code or error.",
+	}, []string{"endpoint", "status"})
+	// UpstreamRetries counts the upstream API attempts retrying a failed one, by endpoint.
+	UpstreamRetries = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "bamos_upstream_retries_total",
+		Help: "Upstream API request retries, by endpoint.",
+	}, []string{"endpoint"})
+	// UpstreamDuration observes the latency of the upstream API attempts by endpoint.
+	UpstreamDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bamos_upstream_request_duration_seconds",
+		Help:    "Latency of the upstream API request attempts, by endpoint.",
+		Buckets: DefaultBuckets,
+	}, []string{"endpoint"})
+
+	// CacheRequests counts the lookups of the in-memory caches by cache and result,
+	// "hit" or "miss".
+	CacheRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "bamos_cache_requests_total",
+		Help: "Cache lookups, by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+)
+
+// CacheLookup records a lookup of the given cache.
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheRequests.WithLabelValues(cache, result).Inc()
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatchedRoute labels the requests matching no route, keeping the label
+// values bounded whatever paths clients request.
+const unmatchedRoute = "unmatched"
+
+// Middleware records the count and duration of the requests by route pattern and
+// status code. It must be used on a chi router.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				// nothing written, or a hijacked WebSocket connection
+				status = http.StatusOK
+			}
+			values := []string{r.Method, routePattern(r), strconv.Itoa(status)}
+			HTTPRequests.WithLabelValues(values...).Inc()
+			HTTPDuration.WithLabelValues(values...).Observe(time.Since(start).Seconds())
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// routePattern returns the pattern of the route that handled the request.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return unmatchedRoute
+	}
+	if pattern := rctx.RoutePattern(); pattern != "" {
+		return pattern
+	}
+	return unmatchedRoute
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Middleware(t *testing.T) {
+	assert := assert.New(t)
+
+	mux := chi.NewRouter()
+	mux.Use(Middleware)
+	mux.Use(middleware.Recoverer)
+	mux.Get("/test/lines/{route_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.Get("/test/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	requests := func(values ...string) float64 {
+		return testutil.ToFloat64(HTTPRequests.WithLabelValues(values...))
+	}
+	before := requests("GET", "/test/lines/{route_id}", "202")
+	unmatched := requests("GET", unmatchedRoute, "404")
+	panics := requests("GET", "/test/panic", "500")
+
+	for _, path := range []string{"/test/lines/1426", "/test/lines/1427", "/not-found", "/test/panic"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	assert.Equal(before+2, requests("GET", "/test/lines/{route_id}", "202"), "requests are labeled by route pattern")
+	assert.Equal(unmatched+1, requests("GET", unmatchedRoute, "404"))
+	assert.Equal(panics+1, requests("GET", "/test/panic", "500"))
+	assert.NotZero(sampleCount(t, HTTPDuration.WithLabelValues("GET", "/test/lines/{route_id}", "202")))
+}
+
+// sampleCount returns the number of observations of a histogram.
+func sampleCount(t *testing.T, h prometheus.Observer) uint64 {
+	var m dto.Metric
+	require.NoError(t, h.(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
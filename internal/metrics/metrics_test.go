@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CacheLookup(t *testing.T) {
+	assert := assert.New(t)
+
+	hits := testutil.ToFloat64(CacheRequests.WithLabelValues("test", "hit"))
+	misses := testutil.ToFloat64(CacheRequests.WithLabelValues("test", "miss"))
+
+	CacheLookup("test", true)
+	CacheLookup("test", false)
+	CacheLookup("test", true)
+
+	assert.Equal(hits+2, testutil.ToFloat64(CacheRequests.WithLabelValues("test", "hit")))
+	assert.Equal(misses+1, testutil.ToFloat64(CacheRequests.WithLabelValues("test", "miss")))
+}
+
+func Test_Default(t *testing.T) {
+	// every metric is valid for Prometheus
+	problems, err := testutil.GatherAndLint(Default)
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+}
+
+func Test_Handler(t *testing.T) {
+	assert := assert.New(t)
+
+	UpstreamRetries.WithLabelValues("/test/handler").Inc()
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.True(strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain"))
+	assert.Contains(rr.Body.String(), `bamos_upstream_retries_total{endpoint="/test/handler"} 1`)
+	assert.Contains(rr.Body.String(), "go_goroutines")
+}
@@ -0,0 +1,17 @@
+package model
+
+import "encoding/gob"
+
+// ParkingSearch holds an allowed parking search kept in the session between the
+// POST of the form and the redirected GET showing its result.
+type ParkingSearch struct {
+	Address   string
+	Latitude  string
+	Longitude string
+	Rules     map[string][]string
+}
+
+func init() {
+	// session values are gob encoded as interfaces, which requires registering their type
+	gob.Register(ParkingSearch{})
+}
@@ -4,18 +4,33 @@ import (
 	"html/template"
 
 	"github.com/mayloo89/bamos/internal/forms"
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/maps"
 )
 
 // TemplateData holds data sent from handlers to templates
 type TemplateData struct {
-	StringMap   map[string]string
-	IntMap      map[string]int
-	FloatMap    map[string]float32
-	Data        map[string]interface{}
-	TemplateMap map[string]template.HTML
-	CSRFToken   string
-	Flash       string
-	Warning     string
-	Error       string
-	Form        *forms.Form
+	StringMap       map[string]string
+	IntMap          map[string]int
+	FloatMap        map[string]float32
+	Data            map[string]interface{}
+	TemplateMap     map[string]template.HTML
+	CSRFToken       string
+	Flash           string
+	Warning         string
+	Error           string
+	Form            *forms.Form
+	IsAuthenticated bool
+	Locale          i18n.Locale
+	Map             maps.Provider
+}
+
+// T returns the message of key in the locale of the page, see i18n.Locale.T.
+func (td *TemplateData) T(key string, args ...interface{}) string {
+	return td.Locale.T(key, args...)
+}
+
+// Locales returns the locales the user can switch the page to.
+func (td *TemplateData) Locales() []i18n.Locale {
+	return i18n.Locales
 }
@@ -0,0 +1,153 @@
+// Package notify sends notifications to users through pluggable channels: the
+// application log, email over SMTP or a webhook.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type (
+	// Message is a notification to a user.
+	Message struct {
+		Event   string // Kind of notification, such as "parking.reminder"
+		To      string // Email address of the user
+		Subject string
+		Body    string // Plain text
+		Data    any    // Details sent as JSON by the webhook notifier
+	}
+
+	// Notifier sends notifications.
+	Notifier interface {
+		Notify(ctx context.Context, msg Message) error
+	}
+
+	// LogNotifier writes the notifications to the log, for development.
+	LogNotifier struct {
+		Logger *slog.Logger
+	}
+
+	// SMTPNotifier emails the notifications, e.g. through a local SMTP stand-in such
+	// as Mailpit in development.
+	SMTPNotifier struct {
+		Addr string // host:port of the SMTP server
+		From string
+		Auth smtp.Auth // nil to send without authentication
+
+		send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	}
+
+	// WebhookNotifier posts the notifications as JSON to a URL.
+	WebhookNotifier struct {
+		URL    string
+		Client *http.Client
+	}
+
+	// webhookPayload is the JSON body posted by the WebhookNotifier.
+	webhookPayload struct {
+		Event   string    `json:"event"`
+		To      string    `json:"to"`
+		Subject string    `json:"subject"`
+		Body    string    `json:"body"`
+		Data    any       `json:"data,omitempty"`
+		SentAt  time.Time `json:"sent_at"`
+	}
+)
+
+// DefaultTimeout is the default timeout of the webhook requests.
+const DefaultTimeout = 10 * time.Second
+
+// Notify logs the message.
+func (n *LogNotifier) Notify(ctx context.Context, msg Message) error {
+	logger := n.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.InfoContext(ctx, "notification", "event", msg.Event, "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// NewSMTPNotifier returns an SMTPNotifier sending from the given address, with
+// PLAIN authentication when username is not empty.
+func NewSMTPNotifier(addr, from, username, password string) *SMTPNotifier {
+	n := &SMTPNotifier{Addr: addr, From: from, send: smtp.SendMail}
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		n.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n
+}
+
+// Notify emails the message to its recipient.
+func (n *SMTPNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.To == "" {
+		return fmt.Errorf("notification %s has no recipient", msg.Event)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	send := n.send
+	if send == nil {
+		send = smtp.SendMail
+	}
+	if err := send(n.Addr, n.Auth, n.From, []string{msg.To}, []byte(b.String())); err != nil {
+		return fmt.Errorf("can not email the %s notification: %w", msg.Event, err)
+	}
+	return nil
+}
+
+// NewWebhookNotifier returns a WebhookNotifier posting to url with the given
+// request timeout, DefaultTimeout when zero.
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &WebhookNotifier{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+// Notify posts the message, failing unless the webhook answers with a 2xx status.
+func (n *WebhookNotifier) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(webhookPayload{
+		Event:   msg.Event,
+		To:      msg.To,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+		Data:    msg.Data,
+		SentAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("can not post the %s notification: %w", msg.Event, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered the %s notification with status %d", msg.Event, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMessage = Message{
+	Event:   "parking.reminder",
+	To:      "ana@example.com",
+	Subject: "Move your car before 07:00",
+	Body:    "Parking becomes forbidden at Corrientes 1000.\nLado par: prohibido.",
+	Data:    map[string]string{"address": "Corrientes 1000"},
+}
+
+func Test_LogNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	n := &LogNotifier{Logger: slog.New(slog.NewTextHandler(&buf, nil))}
+
+	require.NoError(t, n.Notify(context.Background(), testMessage))
+	assert.Contains(t, buf.String(), "event=parking.reminder")
+	assert.Contains(t, buf.String(), "to=ana@example.com")
+}
+
+func Test_SMTPNotifier(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+	n := NewSMTPNotifier("localhost:1025", "bamos@localhost", "", "")
+	assert.Nil(n.Auth, "no authentication without username")
+	n.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, msg
+		return nil
+	}
+
+	required.NoError(n.Notify(context.Background(), testMessage))
+	assert.Equal("localhost:1025", gotAddr)
+	assert.Equal("bamos@localhost", gotFrom)
+	assert.Equal([]string{"ana@example.com"}, gotTo)
+	assert.Contains(string(gotMsg), "Subject: Move your car before 07:00\r\n")
+	assert.Contains(string(gotMsg), "Corrientes 1000.\r\nLado par: prohibido.")
+
+	n.send = func(string, smtp.Auth, string, []string, []byte) error { return errors.New("connection refused") }
+	assert.ErrorContains(n.Notify(context.Background(), testMessage), "connection refused")
+
+	assert.ErrorContains(n.Notify(context.Background(), Message{Event: "parking.reminder"}), "has no recipient")
+
+	assert.NotNil(NewSMTPNotifier("smtp.example.com:587", "bamos@example.com", "bamos", "secret").Auth)
+}
+
+func Test_WebhookNotifier(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	var payload map[string]any
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &payload)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL, 0)
+	required.NoError(n.Notify(context.Background(), testMessage))
+	assert.Equal("parking.reminder", payload["event"])
+	assert.Equal("ana@example.com", payload["to"])
+	assert.Equal(map[string]any{"address": "Corrientes 1000"}, payload["data"])
+	assert.NotEmpty(payload["sent_at"])
+
+	status = http.StatusBadGateway
+	assert.ErrorContains(n.Notify(context.Background(), testMessage), "status 502")
+}
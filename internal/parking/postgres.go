@@ -0,0 +1,108 @@
+package parking
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// rulesSeparator joins the rules of a spot in the rules column.
+const rulesSeparator = "\n"
+
+// PostgresStore stores the spots in the parked_spots table.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a PostgresStore using the given connection pool.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Ping checks the database is reachable.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Park saves the spot of the user, replacing the previous one.
+func (s *PostgresStore) Park(ctx context.Context, spot *Spot) error {
+	return s.db.QueryRowContext(ctx, `insert into parked_spots
+		(user_id, address, latitude, longitude, rules, parked_at, reminded_at, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, null, current_timestamp, current_timestamp)
+		on conflict (user_id) do update set address = excluded.address, latitude = excluded.latitude,
+			longitude = excluded.longitude, rules = excluded.rules, parked_at = excluded.parked_at,
+			reminded_at = null, updated_at = current_timestamp
+		returning id`,
+		spot.UserID, spot.Address, spot.Latitude, spot.Longitude,
+		strings.Join(spot.Rules, rulesSeparator), spot.ParkedAt.UTC()).Scan(&spot.ID)
+}
+
+// Spot returns the spot of the user.
+func (s *PostgresStore) Spot(ctx context.Context, userID int64) (*Spot, error) {
+	spots, err := s.query(ctx, `where user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(spots) == 0 {
+		return nil, ErrNotFound
+	}
+	return &spots[0], nil
+}
+
+// Leave removes the spot of the user.
+func (s *PostgresStore) Leave(ctx context.Context, userID int64) error {
+	_, err := s.db.ExecContext(ctx, `delete from parked_spots where user_id = $1`, userID)
+	return err
+}
+
+// Pending returns the spots whose reminder was not sent yet.
+func (s *PostgresStore) Pending(ctx context.Context) ([]Spot, error) {
+	return s.query(ctx, `where reminded_at is null order by id`)
+}
+
+// MarkReminded records that the reminder of the spot was sent.
+func (s *PostgresStore) MarkReminded(ctx context.Context, id int64, at time.Time) error {
+	result, err := s.db.ExecContext(ctx,
+		`update parked_spots set reminded_at = $1, updated_at = current_timestamp where id = $2`, at.UTC(), id)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) query(ctx context.Context, where string, args ...any) ([]Spot, error) {
+	rows, err := s.db.QueryContext(ctx, `select id, user_id, address, latitude, longitude, rules, parked_at, reminded_at
+		from parked_spots `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var spots []Spot
+	for rows.Next() {
+		var spot Spot
+		var rules string
+		var reminded sql.NullTime
+		if err := rows.Scan(&spot.ID, &spot.UserID, &spot.Address, &spot.Latitude, &spot.Longitude,
+			&rules, &spot.ParkedAt, &reminded); err != nil {
+			return nil, err
+		}
+		if rules != "" {
+			spot.Rules = strings.Split(rules, rulesSeparator)
+		}
+		spot.RemindedAt = reminded.Time
+		spots = append(spots, spot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return spots, nil
+}
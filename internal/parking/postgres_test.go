@@ -0,0 +1,61 @@
+package parking
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/accounts"
+	"github.com/mayloo89/bamos/internal/driver"
+)
+
+// Test_PostgresStore needs a database migrated with the migrations directory.
+func Test_PostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	db, err := driver.ConnectSQL(dsn)
+	required.NoError(err)
+	t.Cleanup(func() { _ = db.SQL.Close() })
+
+	user := &accounts.User{Email: "test-" + time.Now().Format("20060102150405.000000000") + "@example.com", PasswordHash: []byte("-")}
+	required.NoError(accounts.NewPostgresStore(db.SQL).CreateUser(ctx, user))
+	t.Cleanup(func() {
+		_, _ = db.SQL.Exec(`delete from users where id = $1`, user.ID)
+	})
+
+	store := NewPostgresStore(db.SQL)
+	required.NoError(store.Ping(ctx))
+	rules := []string{"Lado par: prohibido las 24 hs.", "Lado impar: permitido las 08:00-20:00."}
+	required.NoError(store.Park(ctx, &Spot{UserID: user.ID, Address: "Corrientes 1000", ParkedAt: time.Now()}))
+	spot := &Spot{UserID: user.ID, Address: "Florida 100", Latitude: -34.6, Longitude: -58.37, Rules: rules, ParkedAt: time.Now()}
+	required.NoError(store.Park(ctx, spot))
+
+	found, err := store.Spot(ctx, user.ID)
+	required.NoError(err)
+	assert.Equal(spot.ID, found.ID)
+	assert.Equal("Florida 100", found.Address)
+	assert.Equal(rules, found.Rules)
+	assert.True(found.RemindedAt.IsZero())
+
+	required.NoError(store.MarkReminded(ctx, spot.ID, time.Now()))
+	pending, err := store.Pending(ctx)
+	required.NoError(err)
+	for _, p := range pending {
+		assert.NotEqual(spot.ID, p.ID)
+	}
+
+	required.NoError(store.Leave(ctx, user.ID))
+	_, err = store.Spot(ctx, user.ID)
+	assert.ErrorIs(err, ErrNotFound)
+}
@@ -0,0 +1,162 @@
+package parking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/accounts"
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/notify"
+)
+
+type (
+	// Users finds the users to notify, an accounts.Store.
+	Users interface {
+		UserByID(ctx context.Context, id int64) (*accounts.User, error)
+	}
+
+	// Reminders warns the users before parking becomes forbidden at their spots,
+	// once per spot.
+	Reminders struct {
+		Store    Store
+		Users    Users
+		Notifier notify.Notifier
+		Lead     time.Duration // How long before parking becomes forbidden the users are warned
+		Locale   i18n.Locale   // Language of the users without a supported one
+		Logger   *slog.Logger
+
+		now func() time.Time
+	}
+
+	// Reminder is the data of the reminder notifications.
+	Reminder struct {
+		Address       string    `json:"address"`
+		Latitude      float64   `json:"latitude"`
+		Longitude     float64   `json:"longitude"`
+		Rule          string    `json:"rule"`
+		ForbiddenFrom time.Time `json:"forbidden_from"`
+		ParkedAt      time.Time `json:"parked_at"`
+	}
+)
+
+const (
+	// ReminderEvent is the event of the reminder notifications.
+	ReminderEvent = "parking.reminder"
+	// DefaultLead is the default time the users are warned in advance.
+	DefaultLead = 30 * time.Minute
+	// DefaultInterval is the default interval between two checks of the spots.
+	DefaultInterval = time.Minute
+)
+
+// NewReminders returns Reminders of the spots of store, notifying the users with
+// notifier DefaultLead in advance, in English unless they chose another language.
+func NewReminders(store Store, users Users, notifier notify.Notifier, logger *slog.Logger) *Reminders {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Reminders{
+		Store:    store,
+		Users:    users,
+		Notifier: notifier,
+		Lead:     DefaultLead,
+		Locale:   i18n.English,
+		Logger:   logger,
+		now:      time.Now,
+	}
+}
+
+// Check sends the reminders of the spots where parking becomes forbidden within
+// the lead time, returning how many were sent. Failed reminders are retried at the
+// next check.
+func (r *Reminders) Check(ctx context.Context) (int, error) {
+	now := r.now().In(Location)
+	spots, err := r.Store.Pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var errs []error
+	for _, spot := range spots {
+		from, rule, ok := spot.ForbiddenFrom(now)
+		if !ok || from.Sub(now) > r.Lead {
+			continue
+		}
+
+		user, err := r.Users.UserByID(ctx, spot.UserID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("can not find the user of spot %d: %w", spot.ID, err))
+			continue
+		}
+		locale, ok := i18n.Parse(string(user.Locale))
+		if !ok {
+			locale = r.Locale
+		}
+		if err := r.Notifier.Notify(ctx, reminderMessage(locale, user, spot, rule, from, now)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := r.Store.MarkReminded(ctx, spot.ID, now); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+// Run checks the spots every interval until ctx is done.
+func (r *Reminders) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		sent, err := r.Check(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.Logger.ErrorContext(ctx, "error sending parking reminders", "error", err)
+		}
+		if sent > 0 {
+			r.Logger.InfoContext(ctx, "parking reminders sent", "reminders", sent)
+		}
+	}
+}
+
+// reminderMessage returns the notification warning the user, in locale l, that
+// parking becomes forbidden at the spot.
+func reminderMessage(l i18n.Locale, user *accounts.User, spot Spot, rule string, from, now time.Time) notify.Message {
+	subject := l.T("reminder.subject", spot.Address, from.Format("15:04"))
+	if !from.After(now) {
+		subject = l.T("reminder.subject_forbidden", spot.Address)
+	}
+
+	return notify.Message{
+		Event:   ReminderEvent,
+		To:      user.Email,
+		Subject: subject,
+		Body: l.T("reminder.body", user.Name, spot.Address, reminderTime(l, from), l.Rule(rule),
+			reminderTime(l, spot.ParkedAt.In(Location))),
+		Data: Reminder{
+			Address:       spot.Address,
+			Latitude:      spot.Latitude,
+			Longitude:     spot.Longitude,
+			Rule:          rule,
+			ForbiddenFrom: from,
+			ParkedAt:      spot.ParkedAt,
+		},
+	}
+}
+
+// reminderTime formats t in locale l, such as "Monday 19/10 at 15:04".
+func reminderTime(l i18n.Locale, t time.Time) string {
+	return l.T("reminder.time", l.DayName(t.Weekday()), t.Day(), int(t.Month()), t.Format("15:04"))
+}
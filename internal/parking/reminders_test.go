@@ -0,0 +1,168 @@
+package parking
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/accounts"
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/notify"
+)
+
+// testNotifier records the notifications, failing while err is set.
+type testNotifier struct {
+	messages []notify.Message
+	err      error
+}
+
+func (n *testNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func Test_Reminders_Check(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	users := accounts.NewMemoryStore()
+	user := &accounts.User{Email: "ana@example.com", Name: "Ana"}
+	required.NoError(users.CreateUser(ctx, user))
+
+	store := NewMemoryStore()
+	required.NoError(store.Park(ctx, &Spot{
+		UserID:   user.ID,
+		Address:  "Corrientes 1000",
+		Rules:    []string{"Lado par: prohibido estacionar las lunes a viernes de 7 a 21 hs.", "Sin horario"},
+		ParkedAt: at(time.Sunday, 22, 0),
+	}))
+
+	notifier := &testNotifier{}
+	reminders := NewReminders(store, users, notifier, nil)
+	clock := at(time.Monday, 6, 0)
+	reminders.now = func() time.Time { return clock }
+
+	sent, err := reminders.Check(ctx)
+	required.NoError(err)
+	assert.Zero(sent, "an hour before, out of the lead time")
+
+	clock = at(time.Monday, 6, 31)
+	notifier.err = errors.New("smtp unavailable")
+	sent, err = reminders.Check(ctx)
+	assert.ErrorContains(err, "smtp unavailable")
+	assert.Zero(sent)
+
+	// failed reminders are retried
+	notifier.err = nil
+	sent, err = reminders.Check(ctx)
+	required.NoError(err)
+	assert.Equal(1, sent)
+	required.Len(notifier.messages, 1)
+	msg := notifier.messages[0]
+	assert.Equal(ReminderEvent, msg.Event)
+	assert.Equal("ana@example.com", msg.To)
+	assert.Equal("Move your car from Corrientes 1000 before 07:00", msg.Subject)
+	assert.Equal("Hi Ana,\n\nparking becomes forbidden at Corrientes 1000 on Monday 19/10 at 07:00:\n"+
+		"Even side: no parking, Monday to Friday from 7 to 21 h.\n\nYou parked there on Sunday 18/10 at 22:00.", msg.Body)
+	assert.Equal(at(time.Monday, 7, 0), msg.Data.(Reminder).ForbiddenFrom)
+
+	// the reminder is sent once
+	clock = at(time.Monday, 8, 0)
+	sent, err = reminders.Check(ctx)
+	required.NoError(err)
+	assert.Zero(sent)
+	spot, err := store.Spot(ctx, user.ID)
+	required.NoError(err)
+	assert.Equal(at(time.Monday, 6, 31), spot.RemindedAt)
+}
+
+func Test_Reminders_AlreadyForbidden(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	users := accounts.NewMemoryStore()
+	user := &accounts.User{Email: "ana@example.com", Name: "Ana"}
+	required.NoError(users.CreateUser(ctx, user))
+
+	store := NewMemoryStore()
+	required.NoError(store.Park(ctx, &Spot{UserID: user.ID, Address: "Florida 100", Rules: []string{"Prohibido las 24 hs."}}))
+	required.NoError(store.Park(ctx, &Spot{UserID: user.ID + 1, Address: "Libertador 2000", Rules: []string{"Permitido las 24 hs."}}))
+
+	notifier := &testNotifier{}
+	reminders := NewReminders(store, users, notifier, nil)
+	reminders.now = func() time.Time { return at(time.Tuesday, 12, 0) }
+
+	sent, err := reminders.Check(ctx)
+	required.NoError(err)
+	assert.Equal(1, sent, "spots where parking is always allowed need no reminder")
+	required.Len(notifier.messages, 1)
+	assert.Equal("Parking is forbidden at Florida 100", notifier.messages[0].Subject)
+}
+
+func Test_Reminders_Locale(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	users := accounts.NewMemoryStore()
+	ana := &accounts.User{Email: "ana@example.com", Name: "Ana"}
+	required.NoError(users.CreateUser(ctx, ana))
+	bob := &accounts.User{Email: "bob@example.com", Name: "Bob", Locale: i18n.English}
+	required.NoError(users.CreateUser(ctx, bob))
+
+	store := NewMemoryStore()
+	rules := []string{"Lado par: prohibido estacionar las lunes a viernes de 7 a 21 hs."}
+	required.NoError(store.Park(ctx, &Spot{UserID: ana.ID, Address: "Corrientes 1000", Rules: rules, ParkedAt: at(time.Sunday, 22, 0)}))
+	required.NoError(store.Park(ctx, &Spot{UserID: bob.ID, Address: "Florida 100", Rules: rules, ParkedAt: at(time.Sunday, 22, 0)}))
+
+	// the users are notified in the language they chose, the others in the default one
+	notifier := &testNotifier{}
+	reminders := NewReminders(store, users, notifier, nil)
+	reminders.Locale = i18n.Spanish
+	reminders.now = func() time.Time { return at(time.Monday, 6, 45) }
+
+	sent, err := reminders.Check(ctx)
+	required.NoError(err)
+	required.Equal(2, sent)
+	messages := map[string]notify.Message{}
+	for _, msg := range notifier.messages {
+		messages[msg.To] = msg
+	}
+	assert.Equal("Mové tu auto de Corrientes 1000 antes de las 07:00", messages["ana@example.com"].Subject)
+	assert.Equal("Hola Ana,\n\nse prohíbe estacionar en Corrientes 1000 el lunes 19/10 a las 07:00:\n"+
+		"Lado par: prohibido estacionar las lunes a viernes de 7 a 21 hs.\n\nEstacionaste ahí el domingo 18/10 a las 22:00.", messages["ana@example.com"].Body)
+	assert.Equal("Move your car from Florida 100 before 07:00", messages["bob@example.com"].Subject)
+}
+
+func Test_MemoryStore(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	required.NoError(store.Park(ctx, &Spot{UserID: 1, Address: "Corrientes 1000"}))
+	required.NoError(store.Park(ctx, &Spot{UserID: 1, Address: "Florida 100"}))
+
+	spot, err := store.Spot(ctx, 1)
+	required.NoError(err)
+	assert.Equal("Florida 100", spot.Address, "a user has one spot")
+
+	required.NoError(store.MarkReminded(ctx, spot.ID, at(time.Monday, 8, 0)))
+	pending, err := store.Pending(ctx)
+	required.NoError(err)
+	assert.Empty(pending)
+	assert.ErrorIs(store.MarkReminded(ctx, 99, at(time.Monday, 8, 0)), ErrNotFound)
+
+	required.NoError(store.Leave(ctx, 1))
+	_, err = store.Spot(ctx, 1)
+	assert.ErrorIs(err, ErrNotFound)
+}
@@ -0,0 +1,238 @@
+// Package parking keeps the spots where users left their cars and reminds them
+// before parking becomes forbidden there, evaluating the schedules of the parking
+// rules of the CABA API.
+package parking
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Rule is a parsed parking rule, such as "Lado izquierdo: prohibido estacionar las
+	// lunes a viernes de 7 a 21 hs." as simplified by the services package.
+	Rule struct {
+		Text     string // Rule as given by the API
+		Allowed  bool   // Whether parking is allowed, rather than forbidden, during the schedule
+		Schedule Schedule
+	}
+
+	// Schedule is the set of weekly windows a rule applies in.
+	Schedule struct {
+		Windows []Window
+	}
+
+	// Window is a daily time window on some days of the week. It ends the next day
+	// when End is not after Start, e.g. from 22 to 6.
+	Window struct {
+		Days  [7]bool       // Indexed by time.Weekday
+		Start time.Duration // Since midnight
+		End   time.Duration // Since midnight, up to 24h
+	}
+)
+
+// Location is the time zone of the parking schedules, Argentina has no daylight saving time.
+var Location = time.FixedZone("ART", -3*60*60)
+
+// ErrNoSchedule is returned when a rule has no schedule that can be parsed.
+var ErrNoSchedule = errors.New("no schedule found")
+
+var (
+	accents = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u")
+
+	dayNames = map[string]time.Weekday{
+		"domingo": time.Sunday, "dom": time.Sunday,
+		"lunes": time.Monday, "lun": time.Monday,
+		"martes": time.Tuesday, "mar": time.Tuesday,
+		"miercoles": time.Wednesday, "mie": time.Wednesday,
+		"jueves": time.Thursday, "jue": time.Thursday,
+		"viernes": time.Friday, "vie": time.Friday,
+		"sabado": time.Saturday, "sab": time.Saturday,
+	}
+
+	dayPattern   = `(domingo|dom|lunes|lun|martes|mar|miercoles|mie|jueves|jue|viernes|vie|sabado|sab)s?\b`
+	dayRangeRe   = regexp.MustCompile(dayPattern + `(?:\s*(?:a|al|-)\s*` + dayPattern + `)?`)
+	timeRangeRe  = regexp.MustCompile(`(\d{1,2})(?:[:.](\d{2}))?\s*(?:hs?\.?|horas)?\s*(?:a|al|-|hasta)\s*(\d{1,2})(?:[:.](\d{2}))?`)
+	allDayRe     = regexp.MustCompile(`\b24\s*(?:hs?\b|horas)`)
+	segmentSplit = regexp.MustCompile(`\s+y\s+|[;,]`)
+)
+
+// ParseRule parses a simplified parking rule, "Lado <side>: <permission> las <schedule>.".
+// The side is optional, rules without the "las" separator are parsed as a whole.
+func ParseRule(text string) (Rule, error) {
+	normalized := accents.Replace(strings.ToLower(strings.TrimSpace(text)))
+
+	permission, schedule := normalized, normalized
+	if _, after, ok := strings.Cut(normalized, ":"); ok && strings.HasPrefix(normalized, "lado") {
+		permission, schedule = after, after
+	}
+	if before, after, ok := strings.Cut(permission, " las "); ok {
+		permission, schedule = before, after
+	}
+
+	s, err := ParseSchedule(schedule)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid parking rule %q: %w", text, err)
+	}
+
+	return Rule{
+		Text:     text,
+		Allowed:  !strings.Contains(permission, "prohibido"),
+		Schedule: s,
+	}, nil
+}
+
+// ParseSchedule parses a schedule in Spanish, e.g. "24 hs", "08:00-20:00" or
+// "lunes a viernes de 7 a 21 hs y sabados de 8 a 13 hs". Days default to the whole week.
+func ParseSchedule(text string) (Schedule, error) {
+	text = strings.TrimSuffix(accents.Replace(strings.ToLower(strings.TrimSpace(text))), ".")
+
+	var s Schedule
+	var days [7]bool
+	onlyDays := false // the previous segment listed days without times, as in "lunes, miercoles y viernes de 8 a 12"
+	for _, segment := range segmentSplit.Split(text, -1) {
+		segmentDays, hasDays := parseDays(segment)
+		if hasDays {
+			if !onlyDays {
+				days = [7]bool{}
+			}
+			for d, ok := range segmentDays {
+				days[d] = days[d] || ok
+			}
+		}
+		windows := len(s.Windows)
+		windowDays := days
+		if windowDays == ([7]bool{}) {
+			windowDays = allDays()
+		}
+
+		ranges := timeRangeRe.FindAllStringSubmatch(segment, -1)
+		if len(ranges) == 0 && allDayRe.MatchString(segment) {
+			s.Windows = append(s.Windows, Window{Days: windowDays, End: 24 * time.Hour})
+		}
+		for _, r := range ranges {
+			start, err := timeOfDay(r[1], r[2])
+			if err != nil {
+				return Schedule{}, err
+			}
+			end, err := timeOfDay(r[3], r[4])
+			if err != nil {
+				return Schedule{}, err
+			}
+			s.Windows = append(s.Windows, Window{Days: windowDays, Start: start, End: end})
+		}
+		onlyDays = hasDays && len(s.Windows) == windows
+	}
+
+	if len(s.Windows) == 0 {
+		return Schedule{}, ErrNoSchedule
+	}
+	return s, nil
+}
+
+// parseDays returns the days named in the segment, single or as ranges such as
+// "lunes a viernes".
+func parseDays(segment string) ([7]bool, bool) {
+	var days [7]bool
+	matches := dayRangeRe.FindAllStringSubmatch(segment, -1)
+	for _, m := range matches {
+		from := dayNames[m[1]]
+		to := from
+		if m[2] != "" {
+			to = dayNames[m[2]]
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return days, len(matches) > 0
+}
+
+func allDays() [7]bool {
+	return [7]bool{true, true, true, true, true, true, true}
+}
+
+func timeOfDay(hours, minutes string) (time.Duration, error) {
+	h, _ := strconv.Atoi(hours)
+	m := 0
+	if minutes != "" {
+		m, _ = strconv.Atoi(minutes)
+	}
+	if h > 24 || m > 59 || (h == 24 && m > 0) {
+		return 0, fmt.Errorf("invalid time %s:%02d", hours, m)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// Active reports whether t is inside a window of the schedule.
+func (s Schedule) Active(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	since := t.Sub(midnight)
+	day := t.Weekday()
+	yesterday := (day + 6) % 7
+
+	for _, w := range s.Windows {
+		if w.Start < w.End {
+			if w.Days[day] && since >= w.Start && since < w.End {
+				return true
+			}
+			continue
+		}
+		// windows ending the next day
+		if (w.Days[day] && since >= w.Start) || (w.Days[yesterday] && since < w.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the first time from t on when the schedule is active, or inactive,
+// as given. It is false when that never happens within a week.
+func (s Schedule) Next(t time.Time, active bool) (time.Time, bool) {
+	if s.Active(t) == active {
+		return t, true
+	}
+	for _, b := range s.boundaries(t) {
+		if s.Active(b) == active {
+			return b, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// boundaries returns the sorted instants after t, within a week, where the windows
+// start or end, the only ones where Active can change.
+func (s Schedule) boundaries(t time.Time) []time.Time {
+	var instants []time.Time
+	for offset := -1; offset <= 8; offset++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+offset, 0, 0, 0, 0, t.Location())
+		for _, w := range s.Windows {
+			end := w.End
+			if w.End <= w.Start {
+				end += 24 * time.Hour
+			}
+			for _, b := range []time.Time{day.Add(w.Start), day.Add(end)} {
+				if b.After(t) {
+					instants = append(instants, b)
+				}
+			}
+		}
+	}
+	slices.SortFunc(instants, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(instants, time.Time.Equal)
+}
+
+// ForbiddenFrom returns the first time from t on when the rule forbids parking: the
+// start of its schedule for forbidding rules, its end for allowing ones. It is
+// false when the rule never forbids parking.
+func (r Rule) ForbiddenFrom(t time.Time) (time.Time, bool) {
+	return r.Schedule.Next(t, !r.Allowed)
+}
@@ -0,0 +1,99 @@
+package parking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// at returns a time of the week of Monday 2026-10-19 in Buenos Aires.
+func at(weekday time.Weekday, hour, minute int) time.Time {
+	return time.Date(2026, 10, 18+int(weekday), hour, minute, 0, 0, Location)
+}
+
+func Test_ParseRule(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	rule, err := ParseRule("Lado izquierdo: permitido las 08:00-20:00.")
+	required.NoError(err)
+	assert.True(rule.Allowed)
+	required.Len(rule.Schedule.Windows, 1)
+	assert.Equal(8*time.Hour, rule.Schedule.Windows[0].Start)
+	assert.Equal(20*time.Hour, rule.Schedule.Windows[0].End)
+	assert.Equal(allDays(), rule.Schedule.Windows[0].Days)
+
+	rule, err = ParseRule("Lado derecho (pares): prohibido estacionar las 24 hs.")
+	required.NoError(err)
+	assert.False(rule.Allowed)
+	assert.True(rule.Schedule.Active(at(time.Sunday, 3, 0)))
+
+	rule, err = ParseRule("Prohibido estacionar las Lunes a Viernes de 7 a 21 hs. y Sábados de 8 a 13 hs.")
+	required.NoError(err)
+	assert.False(rule.Allowed)
+	assert.True(rule.Schedule.Active(at(time.Wednesday, 7, 0)))
+	assert.False(rule.Schedule.Active(at(time.Wednesday, 21, 0)))
+	assert.True(rule.Schedule.Active(at(time.Saturday, 12, 59)))
+	assert.False(rule.Schedule.Active(at(time.Saturday, 14, 0)))
+	assert.False(rule.Schedule.Active(at(time.Sunday, 10, 0)))
+
+	_, err = ParseRule("Lado izquierdo: permitido las sin horario.")
+	assert.ErrorIs(err, ErrNoSchedule)
+
+	_, err = ParseRule("Lado izquierdo: prohibido las 08:00-25:00.")
+	assert.ErrorContains(err, "invalid time 25:00")
+}
+
+func Test_ParseSchedule_Days(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	s, err := ParseSchedule("lunes, miércoles y viernes de 8 a 12")
+	required.NoError(err)
+	required.Len(s.Windows, 1)
+	assert.Equal([7]bool{false, true, false, true, false, true, false}, s.Windows[0].Days)
+
+	s, err = ParseSchedule("lun a vie 7:30 a 9:30 y 17 a 19")
+	required.NoError(err)
+	required.Len(s.Windows, 2)
+	assert.Equal(s.Windows[0].Days, s.Windows[1].Days, "the days apply to the following times")
+	assert.Equal(7*time.Hour+30*time.Minute, s.Windows[0].Start)
+
+	s, err = ParseSchedule("viernes a lunes de 22 a 6")
+	required.NoError(err)
+	assert.True(s.Active(at(time.Saturday, 23, 0)))
+	assert.True(s.Active(at(time.Tuesday, 5, 59)), "monday nights end on tuesday")
+	assert.False(s.Active(at(time.Wednesday, 5, 0)))
+}
+
+func Test_Rule_ForbiddenFrom(t *testing.T) {
+	assert := assert.New(t)
+
+	forbidding, err := ParseRule("Prohibido estacionar las lunes a viernes de 7 a 21 hs.")
+	require.NoError(t, err)
+
+	from, ok := forbidding.ForbiddenFrom(at(time.Monday, 6, 0))
+	assert.True(ok)
+	assert.Equal(at(time.Monday, 7, 0), from)
+
+	from, ok = forbidding.ForbiddenFrom(at(time.Monday, 10, 0))
+	assert.True(ok)
+	assert.Equal(at(time.Monday, 10, 0), from, "already forbidden")
+
+	from, ok = forbidding.ForbiddenFrom(at(time.Friday, 22, 0))
+	assert.True(ok)
+	assert.Equal(at(time.Monday, 7, 0).AddDate(0, 0, 7), from, "after the weekend")
+
+	allowing, err := ParseRule("Lado par: permitido las 08:00-20:00.")
+	require.NoError(t, err)
+	from, ok = allowing.ForbiddenFrom(at(time.Thursday, 9, 0))
+	assert.True(ok)
+	assert.Equal(at(time.Thursday, 20, 0), from, "forbidden when the allowed hours end")
+
+	always, err := ParseRule("Lado impar: permitido las 24 hs.")
+	require.NoError(t, err)
+	_, ok = always.ForbiddenFrom(at(time.Thursday, 9, 0))
+	assert.False(ok)
+}
@@ -0,0 +1,148 @@
+package parking
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type (
+	// Spot is where a user left the car, with the parking rules found there at the time.
+	Spot struct {
+		ID         int64
+		UserID     int64
+		Address    string
+		Latitude   float64
+		Longitude  float64
+		Rules      []string // Snapshot of the rules of the address
+		ParkedAt   time.Time
+		RemindedAt time.Time // Zero until the reminder is sent
+	}
+
+	// Store keeps the spot of each user, a user has at most one.
+	Store interface {
+		// Park saves the spot of the user, replacing the previous one. It sets the spot ID.
+		Park(ctx context.Context, spot *Spot) error
+		// Spot returns the spot of the user, or ErrNotFound.
+		Spot(ctx context.Context, userID int64) (*Spot, error)
+		// Leave removes the spot of the user, if any.
+		Leave(ctx context.Context, userID int64) error
+		// Pending returns the spots whose reminder was not sent yet.
+		Pending(ctx context.Context) ([]Spot, error)
+		// MarkReminded records that the reminder of the spot was sent.
+		MarkReminded(ctx context.Context, id int64, at time.Time) error
+	}
+
+	// MemoryStore keeps the spots in memory, they are lost on restart.
+	MemoryStore struct {
+		mu     sync.Mutex
+		nextID int64
+		spots  map[int64]Spot // By user ID
+	}
+)
+
+// ErrNotFound is returned when a user has no spot.
+var ErrNotFound = errors.New("spot not found")
+
+// ForbiddenFrom returns the first time from t on when a rule of the spot forbids
+// parking, along with that rule. Rules that can not be parsed are skipped. It is
+// false when no rule ever forbids parking.
+func (s Spot) ForbiddenFrom(t time.Time) (time.Time, string, bool) {
+	var first time.Time
+	var rule string
+	for _, text := range s.Rules {
+		r, err := ParseRule(text)
+		if err != nil {
+			continue
+		}
+		if from, ok := r.ForbiddenFrom(t); ok && (rule == "" || from.Before(first)) {
+			first, rule = from, text
+		}
+	}
+	return first, rule, rule != ""
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{spots: map[int64]Spot{}}
+}
+
+// Park saves the spot of the user.
+func (s *MemoryStore) Park(ctx context.Context, spot *Spot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	spot.ID = s.nextID
+	s.spots[spot.UserID] = *spot
+	return nil
+}
+
+// Spot returns the spot of the user.
+func (s *MemoryStore) Spot(ctx context.Context, userID int64) (*Spot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spot, ok := s.spots[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &spot, nil
+}
+
+// Leave removes the spot of the user.
+func (s *MemoryStore) Leave(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.spots, userID)
+	return nil
+}
+
+// Pending returns the spots whose reminder was not sent yet.
+func (s *MemoryStore) Pending(ctx context.Context) ([]Spot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending []Spot
+	for _, spot := range s.spots {
+		if spot.RemindedAt.IsZero() {
+			pending = append(pending, spot)
+		}
+	}
+	return pending, nil
+}
+
+// MarkReminded records that the reminder of the spot was sent.
+func (s *MemoryStore) MarkReminded(ctx context.Context, id int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, spot := range s.spots {
+		if spot.ID == id {
+			spot.RemindedAt = at
+			s.spots[userID] = spot
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// Open opens the store of the given kind, "memory" or "postgres" on db, the
+// accounts database whose pool the caller releases.
+func Open(kind string, db *sql.DB) (Store, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "postgres":
+		if db == nil {
+			return nil, errors.New("the postgres parking store requires a database")
+		}
+		return NewPostgresStore(db), nil
+	default:
+		return nil, fmt.Errorf("invalid parking store %q, must be memory or postgres", kind)
+	}
+}
@@ -0,0 +1,23 @@
+package parking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Open(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	store, err := Open("memory", nil)
+	required.NoError(err)
+	assert.IsType(&MemoryStore{}, store)
+
+	_, err = Open("postgres", nil)
+	assert.ErrorContains(err, "the postgres parking store requires a database")
+
+	_, err = Open("redis", nil)
+	assert.ErrorContains(err, `invalid parking store "redis"`)
+}
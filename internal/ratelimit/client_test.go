@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ClientIP(t *testing.T) {
+	trusted, err := ParsePrefixes("10.0.0.0/8, 192.168.1.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name, remote, forwarded, want string
+	}{
+		{"direct", "203.0.113.7:5000", "", "203.0.113.7"},
+		{"untrusted proxy", "203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:5000", "198.51.100.1", "198.51.100.1"},
+		{"made up hops", "10.0.0.2:5000", "1.1.1.1, 198.51.100.1", "198.51.100.1"},
+		{"proxy chain", "10.0.0.2:5000", "198.51.100.1, 192.168.1.1, 10.1.2.3", "198.51.100.1"},
+		{"only proxies", "10.0.0.2:5000", "10.1.2.3", "10.1.2.3"},
+		{"no header", "10.0.0.2:5000", "", "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		assert.Equal(t, tt.want, ClientIP(r, trusted), tt.name)
+	}
+}
+
+func Test_ParsePrefixes(t *testing.T) {
+	assert := assert.New(t)
+
+	prefixes, err := ParsePrefixes("10.1.2.3/8, 192.168.1.1, ::1")
+	assert.NoError(err)
+	assert.Equal("[10.0.0.0/8 192.168.1.1/32 ::1/128]", fmt.Sprint(prefixes))
+
+	prefixes, err = ParsePrefixes("")
+	assert.NoError(err)
+	assert.Empty(prefixes)
+
+	_, err = ParsePrefixes("10.0.0.0/8, load-balancer")
+	assert.Error(err)
+}
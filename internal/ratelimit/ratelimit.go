@@ -0,0 +1,76 @@
+// Package ratelimit limits the requests of each client of the endpoints calling
+// rate limited or paid upstream services.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter allows every client a number of requests per minute with a token
+// bucket: a client can spend its whole minute at once, then one request every
+// minute divided by the rate. It is safe for concurrent use.
+type Limiter struct {
+	perMinute int
+	now       func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+// bucket holds the requests a client can still make, as of last.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New returns a Limiter allowing perMinute requests per minute to every client.
+func New(perMinute int) *Limiter {
+	return &Limiter{perMinute: perMinute, now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// Allow reports whether the client can make a request now, spending one of its
+// requests when it can.
+func (l *Limiter) Allow(client string) bool {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+
+	rate := float64(l.perMinute)
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: rate, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(rate, b.tokens+now.Sub(b.last).Minutes()*rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RetryAfter returns the time it takes a client to be allowed another request
+// once it spent them all.
+func (l *Limiter) RetryAfter() time.Duration {
+	return time.Minute / time.Duration(l.perMinute)
+}
+
+// prune forgets, once a minute, the clients idle for a minute, whose buckets are
+// full again like the ones of new clients.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < time.Minute {
+		return
+	}
+	l.pruned = now
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= time.Minute {
+			delete(l.buckets, client)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Limiter_Allow(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	l := New(3)
+	l.now = func() time.Time { return now }
+
+	// the whole minute can be spent at once
+	for i := 0; i < 3; i++ {
+		assert.True(l.Allow("10.0.0.1"), i)
+	}
+	assert.False(l.Allow("10.0.0.1"))
+	assert.True(l.Allow("10.0.0.2"), "every client has its own requests")
+
+	// then a request every 20 seconds
+	assert.Equal(20*time.Second, l.RetryAfter())
+	now = now.Add(19 * time.Second)
+	assert.False(l.Allow("10.0.0.1"))
+	now = now.Add(time.Second)
+	assert.True(l.Allow("10.0.0.1"))
+	assert.False(l.Allow("10.0.0.1"))
+
+	// idle clients are forgotten with a full bucket
+	now = now.Add(time.Hour)
+	l.Allow("10.0.0.3")
+	assert.Len(l.buckets, 1)
+	for i := 0; i < 3; i++ {
+		assert.True(l.Allow("10.0.0.1"), i)
+	}
+	assert.False(l.Allow("10.0.0.1"))
+}
@@ -0,0 +1,124 @@
+package realtime
+
+import (
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+type (
+	// Arrival is a predicted arrival of a trip at a stop taken from the trip updates feed.
+	Arrival struct {
+		TripID  string    `json:"trip_id"`
+		RouteID string    `json:"route_id"`
+		StopID  string    `json:"stop_id"`
+		Time    time.Time `json:"time"`
+		Delay   int32     `json:"delay"` // Delay in seconds, negative when early
+	}
+
+	// Alert is a service alert taken from the service alerts feed.
+	Alert struct {
+		ID          string   `json:"id"`
+		Header      string   `json:"header"`
+		Description string   `json:"description,omitempty"`
+		Cause       string   `json:"cause,omitempty"`
+		Effect      string   `json:"effect,omitempty"`
+		Routes      []string `json:"routes,omitempty"`
+		Stops       []string `json:"stops,omitempty"`
+	}
+)
+
+// alertLanguage is the preferred language of the alert texts.
+const alertLanguage = "es"
+
+// Arrivals returns the predicted arrivals of the trip updates feed.
+func (s *Snapshot) Arrivals() []Arrival {
+	feed := s.Feed(services.FeedTripUpdates)
+	if feed == nil || feed.Message == nil {
+		return nil
+	}
+
+	var arrivals []Arrival
+	for _, entity := range feed.Message.GetEntity() {
+		update := entity.GetTripUpdate()
+		if update == nil {
+			continue
+		}
+
+		for _, stopTime := range update.GetStopTimeUpdate() {
+			event := stopTime.GetArrival()
+			if event == nil {
+				event = stopTime.GetDeparture()
+			}
+
+			delay := update.GetDelay()
+			if event != nil && event.Delay != nil {
+				delay = event.GetDelay()
+			}
+
+			arrivals = append(arrivals, Arrival{
+				TripID:  update.GetTrip().GetTripId(),
+				RouteID: update.GetTrip().GetRouteId(),
+				StopID:  stopTime.GetStopId(),
+				Time:    unixTime(uint64(event.GetTime())),
+				Delay:   delay,
+			})
+		}
+	}
+
+	return arrivals
+}
+
+// Alerts returns the service alerts of the service alerts feed.
+func (s *Snapshot) Alerts() []Alert {
+	feed := s.Feed(services.FeedServiceAlerts)
+	if feed == nil || feed.Message == nil {
+		return nil
+	}
+
+	var alerts []Alert
+	for _, entity := range feed.Message.GetEntity() {
+		alert := entity.GetAlert()
+		if alert == nil {
+			continue
+		}
+
+		result := Alert{
+			ID:          entity.GetId(),
+			Header:      translatedText(alert.GetHeaderText()),
+			Description: translatedText(alert.GetDescriptionText()),
+			Cause:       alert.GetCause().String(),
+			Effect:      alert.GetEffect().String(),
+		}
+		for _, informed := range alert.GetInformedEntity() {
+			if id := informed.GetRouteId(); id != "" {
+				result.Routes = append(result.Routes, id)
+			} else if id := informed.GetTrip().GetRouteId(); id != "" {
+				result.Routes = append(result.Routes, id)
+			}
+			if id := informed.GetStopId(); id != "" {
+				result.Stops = append(result.Stops, id)
+			}
+		}
+
+		alerts = append(alerts, result)
+	}
+
+	return alerts
+}
+
+// translatedText returns the text in alertLanguage, or the first one available.
+func translatedText(text *gtfs.TranslatedString) string {
+	translations := text.GetTranslation()
+	for _, translation := range translations {
+		if translation.GetLanguage() == alertLanguage {
+			return translation.GetText()
+		}
+	}
+	if len(translations) > 0 {
+		return translations[0].GetText()
+	}
+	return ""
+}
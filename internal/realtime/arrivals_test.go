@@ -0,0 +1,89 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+func Test_Arrivals(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	snapshot := &Snapshot{Feeds: map[services.Feed]*FeedSnapshot{
+		services.FeedTripUpdates: {Message: &gtfs.FeedMessage{Entity: []*gtfs.FeedEntity{
+			{
+				Id: proto.String("1"),
+				TripUpdate: &gtfs.TripUpdate{
+					Trip:  &gtfs.TripDescriptor{TripId: proto.String("trip-1"), RouteId: proto.String("1426")},
+					Delay: proto.Int32(60),
+					StopTimeUpdate: []*gtfs.TripUpdate_StopTimeUpdate{
+						{
+							StopId:  proto.String("stop-1"),
+							Arrival: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(1700000000), Delay: proto.Int32(120)},
+						},
+						{
+							StopId:    proto.String("stop-2"),
+							Departure: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(1700000300)},
+						},
+					},
+				},
+			},
+		}}},
+	}}
+
+	arrivals := snapshot.Arrivals()
+
+	required.Len(arrivals, 2)
+	assert.Equal(Arrival{TripID: "trip-1", RouteID: "1426", StopID: "stop-1", Time: time.Unix(1700000000, 0), Delay: 120}, arrivals[0])
+	// falls back to the departure time and the trip delay
+	assert.Equal(time.Unix(1700000300, 0), arrivals[1].Time)
+	assert.Equal(int32(60), arrivals[1].Delay)
+}
+
+func Test_Alerts(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	snapshot := &Snapshot{Feeds: map[services.Feed]*FeedSnapshot{
+		services.FeedServiceAlerts: {Message: &gtfs.FeedMessage{Entity: []*gtfs.FeedEntity{
+			{
+				Id: proto.String("alert-1"),
+				Alert: &gtfs.Alert{
+					HeaderText: &gtfs.TranslatedString{Translation: []*gtfs.TranslatedString_Translation{
+						{Text: proto.String("Detour"), Language: proto.String("en")},
+						{Text: proto.String("Desvío"), Language: proto.String("es")},
+					}},
+					InformedEntity: []*gtfs.EntitySelector{
+						{RouteId: proto.String("1426")},
+						{Trip: &gtfs.TripDescriptor{RouteId: proto.String("1427")}},
+						{StopId: proto.String("stop-1")},
+					},
+				},
+			},
+		}}},
+	}}
+
+	alerts := snapshot.Alerts()
+
+	required.Len(alerts, 1)
+	assert.Equal("alert-1", alerts[0].ID)
+	assert.Equal("Desvío", alerts[0].Header)
+	assert.Equal([]string{"1426", "1427"}, alerts[0].Routes)
+	assert.Equal([]string{"stop-1"}, alerts[0].Stops)
+}
+
+func Test_ArrivalsAlerts_NoFeed(t *testing.T) {
+	assert := assert.New(t)
+
+	snapshot := &Snapshot{}
+
+	assert.Empty(snapshot.Arrivals())
+	assert.Empty(snapshot.Alerts())
+}
@@ -0,0 +1,35 @@
+package realtime
+
+// Delta describes how a set of vehicles changed between two snapshots.
+type Delta struct {
+	Vehicles []Vehicle `json:"vehicles"` // New or moved vehicles
+	Removed  []string  `json:"removed"`  // IDs of vehicles no longer in the feed
+}
+
+// Empty reports whether the delta has no changes.
+func (d Delta) Empty() bool {
+	return len(d.Vehicles) == 0 && len(d.Removed) == 0
+}
+
+// Diff returns the changes needed to go from the previous vehicles to the current ones.
+// The previous map is keyed by vehicle ID; the returned map holds the current vehicles
+// keyed the same way, ready for the next call.
+func Diff(previous map[string]Vehicle, current []Vehicle) (Delta, map[string]Vehicle) {
+	delta := Delta{Vehicles: []Vehicle{}, Removed: []string{}}
+	next := make(map[string]Vehicle, len(current))
+
+	for _, vehicle := range current {
+		next[vehicle.ID] = vehicle
+		if old, ok := previous[vehicle.ID]; !ok || old != vehicle {
+			delta.Vehicles = append(delta.Vehicles, vehicle)
+		}
+	}
+
+	for id := range previous {
+		if _, ok := next[id]; !ok {
+			delta.Removed = append(delta.Removed, id)
+		}
+	}
+
+	return delta, next
+}
@@ -0,0 +1,32 @@
+package realtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Diff(t *testing.T) {
+	assert := assert.New(t)
+
+	first := []Vehicle{
+		{ID: "a", RouteID: "1", Latitude: -34.6, Longitude: -58.4},
+		{ID: "b", RouteID: "1", Latitude: -34.7, Longitude: -58.5},
+	}
+	delta, previous := Diff(nil, first)
+
+	assert.Len(delta.Vehicles, 2)
+	assert.Empty(delta.Removed)
+
+	second := []Vehicle{
+		{ID: "a", RouteID: "1", Latitude: -34.6, Longitude: -58.4},
+		{ID: "c", RouteID: "1", Latitude: -34.8, Longitude: -58.6},
+	}
+	delta, previous = Diff(previous, second)
+
+	assert.Equal([]Vehicle{second[1]}, delta.Vehicles)
+	assert.Equal([]string{"b"}, delta.Removed)
+
+	delta, _ = Diff(previous, second)
+	assert.True(delta.Empty())
+}
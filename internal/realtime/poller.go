@@ -0,0 +1,234 @@
+package realtime
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+const (
+	// DefaultVehiclePositionsInterval is the default polling interval of the vehicle positions feed
+	DefaultVehiclePositionsInterval = 30 * time.Second
+	// DefaultTripUpdatesInterval is the default polling interval of the trip updates feed
+	DefaultTripUpdatesInterval = 30 * time.Second
+	// DefaultServiceAlertsInterval is the default polling interval of the service alerts feed
+	DefaultServiceAlertsInterval = 5 * time.Minute
+	// DefaultMaxAge is the default age after which a feed is considered stale
+	DefaultMaxAge = 2 * time.Minute
+)
+
+type (
+	// FeedFetcher fetches a decoded GTFS realtime feed, implemented by services.Client.
+	FeedFetcher interface {
+		RealtimeFeed(ctx context.Context, feed services.Feed) (*gtfs.FeedMessage, error)
+	}
+
+	// FeedConfig configures how often a feed is polled.
+	FeedConfig struct {
+		Feed     services.Feed
+		Interval time.Duration
+	}
+
+	// Poller periodically fetches the configured feeds in the background and
+	// publishes the result as a Snapshot that can be read without locking.
+	Poller struct {
+		client FeedFetcher
+		feeds  []FeedConfig
+		logger *slog.Logger
+
+		mu      sync.Mutex // serialises snapshot writers
+		current atomic.Pointer[Snapshot]
+
+		subMu       sync.Mutex
+		subscribers map[chan *Snapshot]struct{}
+		stopped     bool
+
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+	}
+)
+
+// DefaultFeeds returns the vehicle positions, trip updates and service alerts
+// feeds with their default polling intervals.
+func DefaultFeeds() []FeedConfig {
+	return []FeedConfig{
+		{Feed: services.FeedVehiclePositions, Interval: DefaultVehiclePositionsInterval},
+		{Feed: services.FeedTripUpdates, Interval: DefaultTripUpdatesInterval},
+		{Feed: services.FeedServiceAlerts, Interval: DefaultServiceAlertsInterval},
+	}
+}
+
+// NewPoller creates a Poller for the given feeds. Errors are reported to logger,
+// or to the default logger when logger is nil.
+func NewPoller(client FeedFetcher, logger *slog.Logger, feeds ...FeedConfig) *Poller {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	p := &Poller{
+		client:      client,
+		feeds:       feeds,
+		logger:      logger,
+		subscribers: map[chan *Snapshot]struct{}{},
+	}
+	p.current.Store(&Snapshot{Feeds: map[services.Feed]*FeedSnapshot{}})
+
+	return p
+}
+
+// Snapshot returns the latest snapshot. It never returns nil and must not be modified.
+func (p *Poller) Snapshot() *Snapshot {
+	return p.current.Load()
+}
+
+// Start polls every feed once right away and then on its interval until Stop
+// is called or ctx is cancelled.
+func (p *Poller) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	for _, feed := range p.feeds {
+		p.wg.Add(1)
+		go func(feed FeedConfig) {
+			defer p.wg.Done()
+			p.run(ctx, feed)
+		}(feed)
+	}
+}
+
+// Stop stops polling, waits for in-flight fetches to finish and closes every subscription.
+func (p *Poller) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	p.stopped = true
+	for ch := range p.subscribers {
+		delete(p.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Subscribe returns a channel that receives the new snapshot after every poll.
+// Slow subscribers only get the most recent snapshot, older ones are dropped.
+func (p *Poller) Subscribe() (<-chan *Snapshot, func()) {
+	ch := make(chan *Snapshot, 1)
+
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	if p.stopped {
+		close(ch)
+		return ch, func() {}
+	}
+	p.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		p.subMu.Lock()
+		defer p.subMu.Unlock()
+		if _, ok := p.subscribers[ch]; ok {
+			delete(p.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish sends the snapshot to every subscriber without blocking.
+func (p *Poller) publish(snapshot *Snapshot) {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+
+	for ch := range p.subscribers {
+		// replace the pending snapshot, if any, so the subscriber gets the latest one
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- snapshot
+	}
+}
+
+// Poll fetches a single feed and updates the snapshot with the result.
+func (p *Poller) Poll(ctx context.Context, feed services.Feed) error {
+	message, err := p.client.RealtimeFeed(ctx, feed)
+	if err != nil {
+		// keep serving the last good message, but record the failure
+		p.set(feed, false, func(f *FeedSnapshot) {
+			f.Err = err
+			f.ErrAt = time.Now()
+		})
+		return err
+	}
+
+	p.Update(feed, message, time.Now())
+	return nil
+}
+
+// Update replaces the message of a feed as if it had been polled at fetchedAt and
+// notifies the subscribers. It lets recorded messages be replayed through the poller.
+func (p *Poller) Update(feed services.Feed, message *gtfs.FeedMessage, fetchedAt time.Time) {
+	p.set(feed, true, func(f *FeedSnapshot) {
+		*f = FeedSnapshot{
+			Message:   message,
+			FetchedAt: fetchedAt,
+			Timestamp: unixTime(message.GetHeader().GetTimestamp()),
+		}
+	})
+}
+
+// set stores a new snapshot with the given feed modified by fn, sending it to the
+// subscribers when notify is true. Snapshots are copied on write, so previously
+// returned ones never change.
+func (p *Poller) set(feed services.Feed, notify bool, fn func(*FeedSnapshot)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	previous := p.current.Load()
+	next := &Snapshot{Feeds: make(map[services.Feed]*FeedSnapshot, len(previous.Feeds)+1)}
+	for k, v := range previous.Feeds {
+		next.Feeds[k] = v
+	}
+
+	var updated FeedSnapshot
+	if old := previous.Feeds[feed]; old != nil {
+		updated = *old
+	}
+	fn(&updated)
+
+	next.Feeds[feed] = &updated
+	p.current.Store(next)
+
+	if notify {
+		p.publish(next)
+	}
+}
+
+// run polls a feed on its interval until ctx is cancelled.
+func (p *Poller) run(ctx context.Context, feed FeedConfig) {
+	interval := feed.Interval
+	if interval <= 0 {
+		interval = DefaultVehiclePositionsInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Poll(ctx, feed.Feed); err != nil && ctx.Err() == nil {
+			p.logger.ErrorContext(ctx, "error polling realtime feed", "feed", feed.Feed, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
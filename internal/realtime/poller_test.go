@@ -0,0 +1,195 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+func Test_Poll_Success(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("RealtimeFeed", mock.Anything, services.FeedVehiclePositions).
+		Return(testVehicleFeed(time.Now()), nil)
+
+	poller := NewPoller(mockAPIClient, nil)
+
+	err := poller.Poll(context.Background(), services.FeedVehiclePositions)
+	required.NoError(err)
+
+	feed := poller.Snapshot().Feed(services.FeedVehiclePositions)
+	required.NotNil(feed)
+	assert.Nil(feed.Err)
+	assert.False(feed.Stale(time.Now(), DefaultMaxAge))
+	assert.Len(poller.Snapshot().Vehicles(), 2)
+
+	mockAPIClient.AssertExpectations(t)
+}
+
+func Test_Poll_ErrorKeepsLastMessage(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("RealtimeFeed", mock.Anything, services.FeedVehiclePositions).
+		Return(testVehicleFeed(time.Now()), nil).Once()
+	mockAPIClient.On("RealtimeFeed", mock.Anything, services.FeedVehiclePositions).
+		Return(nil, errors.New("upstream error")).Once()
+
+	poller := NewPoller(mockAPIClient, nil)
+
+	required.NoError(poller.Poll(context.Background(), services.FeedVehiclePositions))
+	before := poller.Snapshot()
+	required.Error(poller.Poll(context.Background(), services.FeedVehiclePositions))
+
+	feed := poller.Snapshot().Feed(services.FeedVehiclePositions)
+	required.NotNil(feed)
+	assert.NotNil(feed.Message)
+	assert.EqualError(feed.Err, "upstream error")
+	// previously returned snapshots are never modified
+	assert.Nil(before.Feed(services.FeedVehiclePositions).Err)
+
+	mockAPIClient.AssertExpectations(t)
+}
+
+func Test_Poll_NeverFetched(t *testing.T) {
+	assert := assert.New(t)
+
+	poller := NewPoller(new(services.MockAPIClient), nil)
+
+	assert.NotNil(poller.Snapshot())
+	assert.Nil(poller.Snapshot().Feed(services.FeedTripUpdates))
+	assert.True(poller.Snapshot().Feed(services.FeedTripUpdates).Stale(time.Now(), DefaultMaxAge))
+	assert.Empty(poller.Snapshot().Vehicles())
+}
+
+func Test_StartStop(t *testing.T) {
+	assert := assert.New(t)
+
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("RealtimeFeed", mock.Anything, services.FeedVehiclePositions).
+		Return(testVehicleFeed(time.Now()), nil)
+
+	poller := NewPoller(mockAPIClient, nil, FeedConfig{Feed: services.FeedVehiclePositions, Interval: time.Millisecond})
+	poller.Start(context.Background())
+
+	assert.Eventually(func() bool {
+		return poller.Snapshot().Feed(services.FeedVehiclePositions) != nil
+	}, time.Second, time.Millisecond)
+
+	poller.Stop()
+	calls := len(mockAPIClient.Calls)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(calls, len(mockAPIClient.Calls), "no polls should happen after Stop")
+}
+
+func Test_Subscribe(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	mockAPIClient := new(services.MockAPIClient)
+	mockAPIClient.On("RealtimeFeed", mock.Anything, services.FeedVehiclePositions).
+		Return(testVehicleFeed(time.Now()), nil)
+
+	poller := NewPoller(mockAPIClient, nil)
+	updates, cancel := poller.Subscribe()
+	defer cancel()
+
+	// a slow subscriber only receives the latest snapshot
+	required.NoError(poller.Poll(context.Background(), services.FeedVehiclePositions))
+	required.NoError(poller.Poll(context.Background(), services.FeedVehiclePositions))
+
+	snapshot := <-updates
+	assert.Same(poller.Snapshot(), snapshot)
+	select {
+	case <-updates:
+		t.Fatal("expected a single pending snapshot")
+	default:
+	}
+
+	poller.Stop()
+	_, ok := <-updates
+	assert.False(ok, "subscriptions are closed on Stop")
+
+	late, _ := poller.Subscribe()
+	_, ok = <-late
+	assert.False(ok, "subscribing after Stop returns a closed channel")
+}
+
+func Test_Subscribe_Cancel(t *testing.T) {
+	assert := assert.New(t)
+
+	poller := NewPoller(new(services.MockAPIClient), nil)
+	updates, cancel := poller.Subscribe()
+	cancel()
+	cancel()
+
+	_, ok := <-updates
+	assert.False(ok)
+	poller.Stop()
+}
+
+func Test_Stale(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	fresh := &FeedSnapshot{Message: &gtfs.FeedMessage{}, Timestamp: now.Add(-time.Minute)}
+	old := &FeedSnapshot{Message: &gtfs.FeedMessage{}, Timestamp: now.Add(-time.Hour)}
+	noTimestamp := &FeedSnapshot{Message: &gtfs.FeedMessage{}, FetchedAt: now.Add(-time.Hour)}
+
+	assert.False(fresh.Stale(now, DefaultMaxAge))
+	assert.True(old.Stale(now, DefaultMaxAge))
+	assert.True(noTimestamp.Stale(now, DefaultMaxAge))
+}
+
+func Test_Vehicles_RouteFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	snapshot := &Snapshot{Feeds: map[services.Feed]*FeedSnapshot{
+		services.FeedVehiclePositions: {Message: testVehicleFeed(time.Now())},
+	}}
+
+	vehicles := snapshot.Vehicles("1426")
+
+	assert.Len(vehicles, 1)
+	assert.Equal("bus-1", vehicles[0].ID)
+	assert.InDelta(-34.6, vehicles[0].Latitude, 0.0001)
+}
+
+// testVehicleFeed returns a vehicle positions feed with two buses on different routes.
+func testVehicleFeed(ts time.Time) *gtfs.FeedMessage {
+	return &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{
+			GtfsRealtimeVersion: proto.String("2.0"),
+			Timestamp:           proto.Uint64(uint64(ts.Unix())),
+		},
+		Entity: []*gtfs.FeedEntity{
+			{
+				Id: proto.String("1"),
+				Vehicle: &gtfs.VehiclePosition{
+					Vehicle:  &gtfs.VehicleDescriptor{Id: proto.String("bus-1")},
+					Trip:     &gtfs.TripDescriptor{RouteId: proto.String("1426")},
+					Position: &gtfs.Position{Latitude: proto.Float32(-34.6), Longitude: proto.Float32(-58.4)},
+				},
+			},
+			{
+				Id: proto.String("2"),
+				Vehicle: &gtfs.VehiclePosition{
+					Trip:     &gtfs.TripDescriptor{RouteId: proto.String("1427")},
+					Position: &gtfs.Position{Latitude: proto.Float32(-34.7), Longitude: proto.Float32(-58.5)},
+				},
+			},
+		},
+	}
+}
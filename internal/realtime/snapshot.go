@@ -0,0 +1,132 @@
+// Package realtime keeps an in-memory snapshot of the CABA GTFS realtime feeds
+// so handlers can serve live transit data without calling the upstream API.
+package realtime
+
+import (
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+type (
+	// Source provides the latest realtime snapshot, used for dependency injection.
+	Source interface {
+		// Snapshot returns the latest snapshot.
+		Snapshot() *Snapshot
+		// Subscribe returns a channel receiving every new snapshot and a function
+		// to cancel the subscription. The channel is closed when the source stops.
+		Subscribe() (<-chan *Snapshot, func())
+	}
+
+	// Snapshot is an immutable view of the latest state of every polled feed.
+	Snapshot struct {
+		Feeds map[services.Feed]*FeedSnapshot
+	}
+
+	// FeedSnapshot holds the last decoded message of a single feed.
+	FeedSnapshot struct {
+		Message   *gtfs.FeedMessage // Last successfully decoded message
+		FetchedAt time.Time         // When Message was fetched
+		Timestamp time.Time         // Feed header timestamp of Message
+		Err       error             // Error of the last poll, nil if it succeeded
+		ErrAt     time.Time         // When Err happened
+	}
+
+	// Vehicle is a simplified vehicle position taken from the vehicle positions feed.
+	Vehicle struct {
+		ID        string    `json:"id"`
+		Label     string    `json:"label,omitempty"`
+		RouteID   string    `json:"route_id"`
+		TripID    string    `json:"trip_id,omitempty"`
+		Latitude  float64   `json:"latitude"`
+		Longitude float64   `json:"longitude"`
+		Bearing   float64   `json:"bearing"`
+		Speed     float64   `json:"speed"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+)
+
+// Feed returns the snapshot of the given feed, or nil if it was never fetched.
+func (s *Snapshot) Feed(feed services.Feed) *FeedSnapshot {
+	if s == nil {
+		return nil
+	}
+	return s.Feeds[feed]
+}
+
+// Age returns how old the feed data is according to its header timestamp,
+// falling back to the fetch time when the feed has no timestamp.
+func (f *FeedSnapshot) Age(now time.Time) time.Duration {
+	if f.Timestamp.IsZero() {
+		return now.Sub(f.FetchedAt)
+	}
+	return now.Sub(f.Timestamp)
+}
+
+// Stale reports whether the feed data is missing or older than maxAge.
+func (f *FeedSnapshot) Stale(now time.Time, maxAge time.Duration) bool {
+	if f == nil || f.Message == nil {
+		return true
+	}
+	return f.Age(now) > maxAge
+}
+
+// Vehicles returns the vehicles of the vehicle positions feed.
+// When routeIDs are given only vehicles serving one of those routes are returned.
+func (s *Snapshot) Vehicles(routeIDs ...string) []Vehicle {
+	feed := s.Feed(services.FeedVehiclePositions)
+	if feed == nil || feed.Message == nil {
+		return nil
+	}
+
+	filter := make(map[string]bool, len(routeIDs))
+	for _, id := range routeIDs {
+		filter[id] = true
+	}
+
+	var vehicles []Vehicle
+	for _, entity := range feed.Message.GetEntity() {
+		position := entity.GetVehicle()
+		if position == nil || position.GetPosition() == nil {
+			continue
+		}
+
+		vehicle := NewVehicle(entity.GetId(), position)
+		if len(filter) > 0 && !filter[vehicle.RouteID] {
+			continue
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+
+	return vehicles
+}
+
+// NewVehicle converts a GTFS vehicle position into a Vehicle.
+func NewVehicle(entityID string, position *gtfs.VehiclePosition) Vehicle {
+	id := position.GetVehicle().GetId()
+	if id == "" {
+		id = entityID
+	}
+
+	return Vehicle{
+		ID:        id,
+		Label:     position.GetVehicle().GetLabel(),
+		RouteID:   position.GetTrip().GetRouteId(),
+		TripID:    position.GetTrip().GetTripId(),
+		Latitude:  float64(position.GetPosition().GetLatitude()),
+		Longitude: float64(position.GetPosition().GetLongitude()),
+		Bearing:   float64(position.GetPosition().GetBearing()),
+		Speed:     float64(position.GetPosition().GetSpeed()),
+		Timestamp: unixTime(position.GetTimestamp()),
+	}
+}
+
+// unixTime converts a GTFS POSIX timestamp into a time.Time, zero when unset.
+func unixTime(ts uint64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(ts), 0)
+}
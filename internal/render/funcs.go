@@ -0,0 +1,180 @@
+package render
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/utils"
+)
+
+var (
+	// routePalette colors the routes without a color in routes.txt.
+	routePalette = []string{"0d6efd", "6610f2", "d63384", "dc3545", "fd7e14", "198754", "20c997", "0dcaf0", "6f42c1", "795548"}
+
+	hexColor     = regexp.MustCompile(`^[0-9A-Fa-f]{6}$`)
+	lineNumberRe = regexp.MustCompile(`^\d+`)
+
+	// now returns the current time, replaced in tests.
+	now = time.Now
+)
+
+// Functions returns the functions available in the templates. The ones formatting
+// text take the locale of the page as an optional last argument, e.g.
+// {{relativeTime .Time $.Locale}}.
+func Functions() template.FuncMap {
+	return template.FuncMap{
+		"relativeTime": RelativeTime,
+		"distance":     Distance,
+		"dayName":      DayName,
+		"rule":         Rule,
+		"routeBadge":   RouteBadge,
+		"routeURL":     RouteURL,
+	}
+}
+
+// RelativeTime returns how far t is from now, such as "in 5 min", "2 h 10 min ago"
+// or "now" within a minute, in English unless a locale is given. It is empty for
+// the zero time.
+func RelativeTime(t time.Time, locale ...i18n.Locale) string {
+	if t.IsZero() {
+		return ""
+	}
+	return localeOr(i18n.English, locale).Relative(t.Sub(now()))
+}
+
+// Distance formats a distance in meters, rounded to 10 m below a kilometer, to
+// 100 m below 10 km and to the kilometer above, such as "850 m" or "1.2 km", in
+// English unless a locale is given.
+func Distance(meters float64, locale ...i18n.Locale) string {
+	return localeOr(i18n.English, locale).Distance(meters)
+}
+
+// DayName returns the name of the day of a time.Time, a time.Weekday or an int
+// from 0 for Sunday, empty for other values. It is in Spanish unless a locale is given.
+func DayName(day any, locale ...i18n.Locale) string {
+	var d int
+	switch v := day.(type) {
+	case time.Time:
+		d = int(v.Weekday())
+	case time.Weekday:
+		d = int(v)
+	case int:
+		d = v
+	default:
+		return ""
+	}
+	return localeOr(i18n.Spanish, locale).DayName(time.Weekday(d))
+}
+
+// Rule returns a parking rule of the CABA API, which are in Spanish, in the given
+// locale, see i18n.Locale.Rule.
+func Rule(text string, locale ...i18n.Locale) string {
+	return localeOr(i18n.Spanish, locale).Rule(text)
+}
+
+// localeOr returns the first of the optional locale argument of a function, or
+// fallback when it is not given.
+func localeOr(fallback i18n.Locale, locale []i18n.Locale) i18n.Locale {
+	if len(locale) > 0 && locale[0] != "" {
+		return locale[0]
+	}
+	return fallback
+}
+
+// RouteBadge returns a badge with the short name of a route in its colors, the
+// route being a utils.Route or its ID. Routes without a color in routes.txt get a
+// color of the palette, the same for every route of a line.
+func RouteBadge(route any) (template.HTML, error) {
+	var r utils.Route
+	switch v := route.(type) {
+	case utils.Route:
+		r = v
+	case string:
+		r = findRoute(v)
+	default:
+		return "", fmt.Errorf("routeBadge: invalid route %T", route)
+	}
+
+	label := r.ShortName
+	if label == "" {
+		label = r.ID
+	}
+	background, text := RouteColors(r)
+	return template.HTML(fmt.Sprintf(`<span class="badge" style="background-color: #%s; color: #%s" title="%s">%s</span>`,
+		background, text, template.HTMLEscapeString(r.LongName), template.HTMLEscapeString(label))), nil
+}
+
+// RouteColors returns the background and text colors of a route, as hex RGB.
+func RouteColors(r utils.Route) (string, string) {
+	background := r.Color
+	if !hexColor.MatchString(background) {
+		h := fnv.New32a()
+		h.Write([]byte(lineNumber(r)))
+		background = routePalette[h.Sum32()%uint32(len(routePalette))]
+	}
+
+	text := r.TextColor
+	if !hexColor.MatchString(text) {
+		text = contrastColor(background)
+	}
+	return background, text
+}
+
+// lineNumber returns the number of the line of the route, e.g. 505 for 505R3.
+func lineNumber(r utils.Route) string {
+	if n := lineNumberRe.FindString(r.ShortName); n != "" {
+		return n
+	}
+	if r.ShortName != "" {
+		return r.ShortName
+	}
+	return r.ID
+}
+
+// contrastColor returns black or white, whichever is more readable over background.
+func contrastColor(background string) string {
+	rgb, _ := strconv.ParseUint(background, 16, 32)
+	r, g, b := float64(rgb>>16&0xff), float64(rgb>>8&0xff), float64(rgb&0xff)
+	if 0.299*r+0.587*g+0.114*b > 150 {
+		return "000000"
+	}
+	return "ffffff"
+}
+
+// findRoute returns the route with the given ID from the routes cache, or a route
+// with only the ID when it is not there.
+func findRoute(id string) utils.Route {
+	if app != nil {
+		for _, r := range app.DataCache.Routes {
+			if r.ID == id {
+				return r
+			}
+		}
+	}
+	return utils.Route{ID: id}
+}
+
+// RouteURL returns the URL of a page about a line or route: "positions", "live" and
+// "search" take a line, "analytics" and "analytics.csv" a route ID.
+func RouteURL(page, value string) (string, error) {
+	switch page {
+	case "positions":
+		return "/colectivos/vehiclePositionsSimple?" + url.Values{"line": {value}}.Encode(), nil
+	case "live":
+		return "/colectivos/live?" + url.Values{"line": {value}}.Encode(), nil
+	case "search":
+		return "/colectivos/search?" + url.Values{"line": {value}}.Encode(), nil
+	case "analytics":
+		return "/analytics/lines/" + url.PathEscape(value), nil
+	case "analytics.csv":
+		return "/analytics/lines/" + url.PathEscape(value) + "/export.csv", nil
+	default:
+		return "", fmt.Errorf("routeURL: unknown page %q", page)
+	}
+}
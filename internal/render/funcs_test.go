@@ -0,0 +1,166 @@
+package render
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/utils"
+)
+
+func Test_RelativeTime(t *testing.T) {
+	assert := assert.New(t)
+
+	current := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	tests := map[time.Duration]string{
+		20 * time.Second:               "now",
+		-25 * time.Second:              "now",
+		5 * time.Minute:                "in 5 min",
+		5*time.Minute + 40*time.Second: "in 6 min",
+		-12 * time.Minute:              "12 min ago",
+		time.Hour:                      "in 1 h",
+		2*time.Hour + 10*time.Minute:   "in 2 h 10 min",
+		-(3*time.Hour + 5*time.Minute): "3 h 5 min ago",
+		26 * time.Hour:                 "in 1 day",
+		-72 * time.Hour:                "3 days ago",
+	}
+	for d, expected := range tests {
+		assert.Equal(expected, RelativeTime(current.Add(d)), d.String())
+	}
+	assert.Empty(RelativeTime(time.Time{}))
+
+	assert.Equal("en 5 min", RelativeTime(current.Add(5*time.Minute), i18n.Spanish))
+	assert.Equal("hace 3 días", RelativeTime(current.Add(-72*time.Hour), i18n.Spanish))
+}
+
+func Test_Distance(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[float64]string{
+		0:     "0 m",
+		4:     "0 m",
+		847:   "850 m",
+		994:   "990 m",
+		995:   "1.0 km",
+		1240:  "1.2 km",
+		9949:  "9.9 km",
+		9950:  "10 km",
+		12600: "13 km",
+	}
+	for meters, expected := range tests {
+		assert.Equal(expected, Distance(meters), meters)
+	}
+	assert.Empty(Distance(-1))
+	assert.Equal("1,2 km", Distance(1240, i18n.Spanish))
+}
+
+func Test_DayName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("lunes", DayName(time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)))
+	assert.Equal("miércoles", DayName(time.Wednesday))
+	assert.Equal("sábado", DayName(6))
+	assert.Equal("domingo", DayName(0))
+	assert.Empty(DayName(7))
+	assert.Empty(DayName("lunes"))
+	assert.Equal("Wednesday", DayName(time.Wednesday, i18n.English))
+}
+
+func Test_Rule(t *testing.T) {
+	assert := assert.New(t)
+
+	rule := "Lado par: prohibido estacionar las 24 hs."
+	assert.Equal(rule, Rule(rule))
+	assert.Equal(rule, Rule(rule, i18n.Spanish))
+	assert.Equal("Even side: no parking, 24 h.", Rule(rule, i18n.English))
+}
+
+func Test_RouteBadge(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	colored := utils.Route{ID: "1", ShortName: "60A", LongName: "Constitución <> Tigre", Color: "FFCC00"}
+	badge, err := RouteBadge(colored)
+	required.NoError(err)
+	assert.Equal(template.HTML(`<span class="badge" style="background-color: #FFCC00; color: #000000" title="Constitución &lt;&gt; Tigre">60A</span>`), badge)
+
+	// routes of the same line share the color of the palette
+	a, _ := RouteColors(utils.Route{ShortName: "505R3"})
+	b, _ := RouteColors(utils.Route{ShortName: "505R1"})
+	assert.Equal(a, b)
+	assert.Contains(routePalette, a)
+
+	// route IDs are looked up in the routes cache
+	testApp.DataCache.Routes = []utils.Route{{ID: "1426", ShortName: "505R3"}}
+	defer func() { testApp.DataCache.Routes = nil }()
+	badge, err = RouteBadge("1426")
+	required.NoError(err)
+	assert.Contains(string(badge), ">505R3</span>")
+	badge, err = RouteBadge("999")
+	required.NoError(err)
+	assert.Contains(string(badge), ">999</span>")
+
+	_, err = RouteBadge(1426)
+	assert.Error(err)
+}
+
+func Test_RouteColors(t *testing.T) {
+	assert := assert.New(t)
+
+	background, text := RouteColors(utils.Route{Color: "003366", TextColor: "FFFFFF"})
+	assert.Equal("003366", background)
+	assert.Equal("FFFFFF", text)
+
+	// invalid colors from routes.txt are ignored
+	_, text = RouteColors(utils.Route{Color: "003366", TextColor: "white"})
+	assert.Equal("ffffff", text)
+	_, text = RouteColors(utils.Route{Color: "FFFF99"})
+	assert.Equal("000000", text)
+}
+
+func Test_RouteURL(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[[2]string]string{
+		{"positions", "60"}:        "/colectivos/vehiclePositionsSimple?line=60",
+		{"live", "60 A&b"}:         "/colectivos/live?line=60+A%26b",
+		{"search", "152"}:          "/colectivos/search?line=152",
+		{"analytics", "1426"}:      "/analytics/lines/1426",
+		{"analytics.csv", "14/26"}: "/analytics/lines/14%2F26/export.csv",
+	}
+	for args, expected := range tests {
+		u, err := RouteURL(args[0], args[1])
+		assert.NoError(err)
+		assert.Equal(expected, u)
+	}
+
+	_, err := RouteURL("map", "60")
+	assert.ErrorContains(err, `unknown page "map"`)
+}
+
+func Test_Functions(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	tmpl, err := template.New("test").Funcs(Functions()).Parse(
+		`<a href="{{routeURL "positions" .Line}}">{{routeBadge .Route}}</a> {{distance .Meters}} {{dayName .Day}} {{dayName .Day .Locale}}`)
+	required.NoError(err)
+
+	var b bytes.Buffer
+	required.NoError(tmpl.Execute(&b, map[string]any{
+		"Line":   "60",
+		"Route":  utils.Route{ShortName: "60", Color: "000000"},
+		"Meters": 1500.0,
+		"Day":    time.Friday,
+		"Locale": i18n.English,
+	}))
+	assert.Equal(`<a href="/colectivos/vehiclePositionsSimple?line=60"><span class="badge" style="background-color: #000000; color: #ffffff" title="">60</span></a> 1.5 km viernes Friday`, b.String())
+}
@@ -2,61 +2,140 @@ package render
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"html/template"
-	"log"
+	"io/fs"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
+	"sync"
 
 	"github.com/justinas/nosurf"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mayloo89/bamos"
 	"github.com/mayloo89/bamos/internal/config"
+	"github.com/mayloo89/bamos/internal/i18n"
+	"github.com/mayloo89/bamos/internal/metrics"
 	"github.com/mayloo89/bamos/internal/model"
+	"github.com/mayloo89/bamos/internal/tracing"
 )
 
 var app *config.AppConfig
 
+var (
+	loaderMu   sync.Mutex
+	liveLoader *Loader
+)
+
+// NewTemplates sets the application configuration of the package.
 func NewTemplates(a *config.AppConfig) {
 	app = a
+
+	loaderMu.Lock()
+	liveLoader = nil
+	loaderMu.Unlock()
 }
 
+// AddDefaultData adds the data shared by every page: the CSRF token and the flash,
+// warning and error messages put in the session before a redirect, whether a user is
+// logged in, the map provider and the locale of the request, unless the handler set one.
+// The messages are shown once, RenderTemplateStatus removes them from the session when
+// the page is rendered.
+// Requests that did not go through the session middleware get no session data.
 func AddDefaultData(tmplData *model.TemplateData, r *http.Request) *model.TemplateData {
+	if app.Session != nil && hasSession(r.Context()) {
+		if flash := app.Session.GetString(r.Context(), "flash"); flash != "" {
+			tmplData.Flash = flash
+		}
+		if warning := app.Session.GetString(r.Context(), "warning"); warning != "" {
+			tmplData.Warning = warning
+		}
+		if errorMessage := app.Session.GetString(r.Context(), "error"); errorMessage != "" {
+			tmplData.Error = errorMessage
+		}
+		tmplData.IsAuthenticated = app.Session.Exists(r.Context(), config.SessionUserIDKey)
+	}
+	if tmplData.Locale == "" {
+		tmplData.Locale = i18n.FromContext(r.Context())
+	}
+	tmplData.Map = app.Map
 	tmplData.CSRFToken = nosurf.Token(r)
 	return tmplData
 }
 
+// removeMessages removes the messages shown by a rendered page from the session, so
+// a page failing to render does not lose them.
+func removeMessages(r *http.Request) {
+	if app.Session == nil || !hasSession(r.Context()) {
+		return
+	}
+	for _, key := range []string{"flash", "warning", "error"} {
+		app.Session.Remove(r.Context(), key)
+	}
+}
+
+// hasSession reports whether the session middleware loaded a session into ctx. The
+// session manager panics on the requests without one and has no way to ask.
+func hasSession(ctx context.Context) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	app.Session.Status(ctx)
+	return true
+}
+
 func RenderTemplate(w http.ResponseWriter, r *http.Request, tmpl string, tmplData *model.TemplateData) error {
-	var tc map[string]*template.Template
-	var err error
+	return RenderTemplateStatus(w, r, http.StatusOK, tmpl, tmplData)
+}
 
+// RenderTemplateStatus renders the template with the given response status. Nothing is
+// written when the template fails, so the caller can still answer with an error.
+func RenderTemplateStatus(w http.ResponseWriter, r *http.Request, status int, tmpl string, tmplData *model.TemplateData) error {
+	_, lookup := tracing.Start(r.Context(), "render.Lookup", trace.WithAttributes(
+		attribute.String("template", tmpl), attribute.Bool("templates.cached", app.UseCache)))
+	var t *template.Template
+	var err error
 	if app.UseCache {
-		// get template cache from app config
-		tc = app.TemplateCache
-	} else {
-		tc, err = CreateTemplateCache()
-		if err != nil {
-			// FIXME: return error here
-			log.Fatal(err)
+		// get requested template from cache
+		var ok bool
+		t, ok = app.TemplateCache[tmpl]
+		if !ok {
+			err = errors.New("Template " + tmpl + " does not exist in cache (len " + strconv.Itoa(len(app.TemplateCache)) + ")")
 		}
+	} else {
+		// parse the page again if it changed on disk
+		t, err = loader().Lookup(tmpl)
 	}
-
-	// get requested template from cache
-	t, ok := tc[tmpl]
-	if !ok {
-		return errors.New("Template " + tmpl + " does not exist in cache (len " + strconv.Itoa(len(tc)) + ")")
+	if app.UseCache {
+		// without the cache every page is looked up on disk, there is nothing to count
+		metrics.CacheLookup("templates", err == nil)
+	}
+	lookup.SetAttributes(attribute.Bool("cache.hit", app.UseCache && err == nil))
+	tracing.RecordError(lookup, err)
+	lookup.End()
+	if err != nil {
+		return err
 	}
 
 	buf := new(bytes.Buffer)
 
 	tmplData = AddDefaultData(tmplData, r)
 
+	_, execute := tracing.Start(r.Context(), "render.Execute", trace.WithAttributes(attribute.String("template", tmpl)))
 	err = t.Execute(buf, tmplData)
+	tracing.RecordError(execute, err)
+	execute.End()
 	if err != nil {
 		return err
 	}
+	removeMessages(r)
 
 	// render the template
+	w.WriteHeader(status)
 	_, err = buf.WriteTo(w)
 	if err != nil {
 		return err
@@ -65,72 +144,27 @@ func RenderTemplate(w http.ResponseWriter, r *http.Request, tmpl string, tmplDat
 	return nil
 }
 
+// CreateTemplateCache parses every page of the template directory, see TemplateFS.
 func CreateTemplateCache() (map[string]*template.Template, error) {
-	myCache := map[string]*template.Template{}
-
-	// Get the current working directory
-	rootPath, err := getRootPath()
-	if err != nil {
-		return myCache, err
-	}
-
-	pagesGlob := rootPath + "/templates/*.page.tmpl"    // filepath.Join(dir, "*.page.tmpl")
-	layoutGlob := rootPath + "/templates/*.layout.tmpl" // filepath.Join(dir, "*.layout.tmpl")
-
-	// get all the *page.tmpl files from templates dir
-	pages, err := filepath.Glob(pagesGlob)
-	if err != nil {
-		return myCache, err
-	} else if len(pages) <= 0 {
-		return myCache, errors.New("no pages found")
-	}
-
-	for _, page := range pages {
-		name := filepath.Base(page)
-		ts, err := template.New(name).ParseFiles(page)
-		if err != nil {
-			return myCache, err
-		}
-
-		layouts, err := filepath.Glob(layoutGlob)
-		if err != nil {
-			return myCache, err
-		}
-
-		if len(layouts) > 0 {
-			ts, err = ts.ParseGlob(layoutGlob)
-			if err != nil {
-				return myCache, err
-			}
-		}
-
-		myCache[name] = ts
-	}
-
-	return myCache, nil
+	return ParseTemplates(TemplateFS())
 }
 
-// getRootPath returns the root path of the application
-func getRootPath() (string, error) {
-	// Get the current working directory
-	cwd, err := os.Getwd()
-	if err != nil {
-		return "", err
+// TemplateFS returns the template directory of the application, the embedded
+// templates unless a directory on disk overrides them.
+func TemplateFS() fs.FS {
+	if app != nil && app.Templates != nil {
+		return app.Templates
 	}
+	return bamos.Templates()
+}
 
-	// Find the root directory (assuming the root directory contains a specific file or directory)
-	rootPath := cwd
-	for {
-		if _, err := os.Stat(filepath.Join(rootPath, "go.mod")); err == nil {
-			break
-		}
+// loader returns the Loader of the template directory used without the cache.
+func loader() *Loader {
+	loaderMu.Lock()
+	defer loaderMu.Unlock()
 
-		parent := filepath.Dir(rootPath)
-		if parent == rootPath {
-			return "", errors.New("root path not found")
-		}
-		rootPath = parent
+	if liveLoader == nil {
+		liveLoader = NewLoader(TemplateFS())
 	}
-
-	return rootPath, nil
+	return liveLoader
 }
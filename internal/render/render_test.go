@@ -1,13 +1,20 @@
 package render
 
 import (
+	"html/template"
 	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
+	"github.com/mayloo89/bamos/internal/config"
 	"github.com/mayloo89/bamos/internal/model"
 )
 
@@ -26,6 +33,57 @@ func Test_AddDefaultData_Success(t *testing.T) {
 	assert.NotNil(result.CSRFToken)
 }
 
+func Test_AddDefaultData_Messages(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	r, err := getTestSession()
+	required.NoError(err)
+
+	session.Put(r.Context(), "flash", "Saved.")
+	session.Put(r.Context(), "warning", "Data may be out of date.")
+	session.Put(r.Context(), "error", "Could not save.")
+
+	td := AddDefaultData(&model.TemplateData{}, r)
+	assert.Equal("Saved.", td.Flash)
+	assert.Equal("Data may be out of date.", td.Warning)
+	assert.Equal("Could not save.", td.Error)
+	assert.False(td.IsAuthenticated)
+
+	// the messages stay in the session until a page showing them is rendered
+	app.UseCache = true
+	app.TemplateCache = map[string]*template.Template{
+		"broken.page.tmpl": template.Must(template.New("broken.page.tmpl").Parse(`{{.Flash}}{{template "missing"}}`)),
+		"flash.page.tmpl":  template.Must(template.New("flash.page.tmpl").Parse(`{{.Flash}}`)),
+	}
+	required.Error(RenderTemplate(&testWriter{}, r, "broken.page.tmpl", &model.TemplateData{}))
+	assert.Equal("Saved.", session.GetString(r.Context(), "flash"))
+
+	// then they are shown once, the handler sets its own
+	required.NoError(RenderTemplate(&testWriter{}, r, "flash.page.tmpl", &model.TemplateData{}))
+	td = AddDefaultData(&model.TemplateData{Error: "Invalid form."}, r)
+	assert.Empty(td.Flash)
+	assert.Empty(td.Warning)
+	assert.Equal("Invalid form.", td.Error)
+
+	session.Put(r.Context(), config.SessionUserIDKey, int64(1))
+	td = AddDefaultData(&model.TemplateData{}, r)
+	assert.True(td.IsAuthenticated)
+}
+
+func Test_AddDefaultData_NoSession(t *testing.T) {
+	assert := assert.New(t)
+
+	// requests that did not go through the session middleware render without its data
+	r := httptest.NewRequest("GET", "/api/v1/geocode", nil)
+	assert.Panics(func() { session.Exists(r.Context(), config.SessionUserIDKey) })
+
+	var td *model.TemplateData
+	assert.NotPanics(func() { td = AddDefaultData(&model.TemplateData{}, r) })
+	assert.Empty(td.Flash)
+	assert.False(td.IsAuthenticated)
+}
+
 func Test_RenderTemplate_Success(t *testing.T) {
 	assert := assert.New(t)
 	required := require.New(t)
@@ -47,6 +105,35 @@ func Test_RenderTemplate_Success(t *testing.T) {
 	assert.Nil(err)
 }
 
+func Test_RenderTemplate_Spans(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	tc, err := CreateTemplateCache()
+	required.Nil(err)
+
+	app.UseCache = true
+	app.TemplateCache = tc
+
+	r, err := getTestSession()
+	required.Nil(err)
+
+	var ww testWriter
+	required.NoError(RenderTemplate(&ww, r, "home.page.tmpl", &model.TemplateData{}))
+
+	spans := recorder.Ended()
+	required.Len(spans, 2)
+	assert.Equal("render.Lookup", spans[0].Name())
+	assert.Contains(spans[0].Attributes(), attribute.Bool("cache.hit", true))
+	assert.Equal("render.Execute", spans[1].Name())
+	assert.Contains(spans[1].Attributes(), attribute.String("template", "home.page.tmpl"))
+}
+
 func Test_RenderTemplate_Error(t *testing.T) {
 	assert := assert.New(t)
 	required := require.New(t)
@@ -80,7 +167,7 @@ func Test_CreateTemplateCache(t *testing.T) {
 	tc, err := CreateTemplateCache()
 	required.Nil(err)
 
-	assert.Equal(4, len(tc))
+	assert.Equal(11, len(tc))
 }
 
 func getTestSession() (*http.Request, error) {
@@ -1,7 +1,7 @@
 package render
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"testing"
@@ -26,8 +26,7 @@ func TestMain(m *testing.M) {
 	testApp.Session = session
 
 	// set up the loggers
-	testApp.InfoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	testApp.ErrorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	testApp.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
 
 	app = &testApp
 
@@ -0,0 +1,127 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	pagePattern   = "*.page.tmpl"
+	layoutPattern = "*.layout.tmpl"
+)
+
+// ErrNoPages is returned when a template directory has no pages.
+var ErrNoPages = errors.New("no pages found")
+
+type (
+	// Loader parses the pages of a template directory on demand, for development. A
+	// page is parsed again only when it or a layout changed since it was last parsed,
+	// according to their modification times and sizes, so the pages edited on disk
+	// are reloaded without parsing the others.
+	Loader struct {
+		fsys fs.FS
+
+		mu    sync.Mutex
+		pages map[string]loadedPage
+	}
+
+	// loadedPage is a parsed page with the version of its files it was parsed from.
+	loadedPage struct {
+		tmpl    *template.Template
+		version string
+	}
+)
+
+// ParseTemplates parses every page of the template directory, each one along with
+// every layout, keyed by page file name.
+func ParseTemplates(fsys fs.FS) (map[string]*template.Template, error) {
+	pages, err := fs.Glob(fsys, pagePattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, ErrNoPages
+	}
+	layouts, err := fs.Glob(fsys, layoutPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		ts, err := parsePage(fsys, page, layouts)
+		if err != nil {
+			return nil, err
+		}
+		cache[page] = ts
+	}
+	return cache, nil
+}
+
+// parsePage parses a page along with the layouts.
+func parsePage(fsys fs.FS, page string, layouts []string) (*template.Template, error) {
+	ts, err := template.New(page).Funcs(Functions()).ParseFS(fsys, append([]string{page}, layouts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("can not parse template %s: %w", page, err)
+	}
+	return ts, nil
+}
+
+// NewLoader returns a Loader of the pages of the template directory.
+func NewLoader(fsys fs.FS) *Loader {
+	return &Loader{fsys: fsys, pages: map[string]loadedPage{}}
+}
+
+// Lookup returns the parsed page, parsing it again when it or a layout changed.
+func (l *Loader) Lookup(page string) (*template.Template, error) {
+	if !strings.HasSuffix(page, ".page.tmpl") {
+		return nil, fmt.Errorf("template %s is not a page", page)
+	}
+	layouts, err := fs.Glob(l.fsys, layoutPattern)
+	if err != nil {
+		return nil, err
+	}
+	version, err := l.version(append([]string{page}, layouts...))
+	if err != nil {
+		return nil, fmt.Errorf("template %s does not exist: %w", page, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if loaded, ok := l.pages[page]; ok && loaded.version == version {
+		return loaded.tmpl, nil
+	}
+	ts, err := parsePage(l.fsys, page, layouts)
+	if err != nil {
+		// a broken page is parsed again on the next lookup
+		delete(l.pages, page)
+		return nil, err
+	}
+	l.pages[page] = loadedPage{tmpl: ts, version: version}
+	return ts, nil
+}
+
+// version identifies the state of the files by their names, modification times and
+// sizes. Embedded files have no modification time, they never change.
+func (l *Loader) version(files []string) (string, error) {
+	var b strings.Builder
+	for _, name := range files {
+		info, err := fs.Stat(l.fsys, name)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatInt(info.ModTime().UnixNano(), 10))
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatInt(info.Size(), 10))
+		b.WriteByte(';')
+	}
+	return b.String(), nil
+}
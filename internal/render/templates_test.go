@@ -0,0 +1,113 @@
+package render
+
+import (
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/model"
+)
+
+// testTemplates returns a template directory with two pages and a layout.
+func testTemplates() fstest.MapFS {
+	modified := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	return fstest.MapFS{
+		"base.layout.tmpl": {Data: []byte(`{{define "base"}}<main>{{template "content" .}}</main>{{end}}`), ModTime: modified},
+		"home.page.tmpl":   {Data: []byte(`{{template "base" .}}{{define "content"}}home{{end}}`), ModTime: modified},
+		"about.page.tmpl":  {Data: []byte(`{{template "base" .}}{{define "content"}}about{{end}}`), ModTime: modified},
+	}
+}
+
+func Test_ParseTemplates(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	tc, err := ParseTemplates(testTemplates())
+	required.NoError(err)
+	assert.Len(tc, 2)
+	assert.Contains(tc, "home.page.tmpl")
+
+	broken := testTemplates()
+	broken["about.page.tmpl"] = &fstest.MapFile{Data: []byte(`{{template "base" .}}{{define "content"}}{{.Missing}`)}
+	_, err = ParseTemplates(broken)
+	assert.ErrorContains(err, "can not parse template about.page.tmpl")
+
+	_, err = ParseTemplates(fstest.MapFS{})
+	assert.ErrorIs(err, ErrNoPages)
+}
+
+func Test_Loader_Lookup(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	fsys := testTemplates()
+	l := NewLoader(fsys)
+
+	home, err := l.Lookup("home.page.tmpl")
+	required.NoError(err)
+	about, err := l.Lookup("about.page.tmpl")
+	required.NoError(err)
+
+	again, err := l.Lookup("home.page.tmpl")
+	required.NoError(err)
+	assert.Same(home, again, "unchanged pages are not parsed again")
+
+	// only the changed page is parsed again
+	fsys["home.page.tmpl"] = &fstest.MapFile{Data: []byte(`{{template "base" .}}{{define "content"}}new home{{end}}`), ModTime: time.Now()}
+	changed, err := l.Lookup("home.page.tmpl")
+	required.NoError(err)
+	assert.NotSame(home, changed)
+	again, err = l.Lookup("about.page.tmpl")
+	required.NoError(err)
+	assert.Same(about, again)
+
+	// a changed layout changes every page
+	fsys["base.layout.tmpl"] = &fstest.MapFile{Data: []byte(`{{define "base"}}<div>{{template "content" .}}</div>{{end}}`), ModTime: time.Now()}
+	again, err = l.Lookup("about.page.tmpl")
+	required.NoError(err)
+	assert.NotSame(about, again)
+
+	// broken pages fail until they are fixed
+	fsys["about.page.tmpl"] = &fstest.MapFile{Data: []byte(`{{template "base" .}}{{define "content"}}{{if}}{{end}}`), ModTime: time.Now().Add(time.Second)}
+	_, err = l.Lookup("about.page.tmpl")
+	assert.ErrorContains(err, "can not parse template about.page.tmpl")
+	fsys["about.page.tmpl"] = &fstest.MapFile{Data: []byte(`{{template "base" .}}{{define "content"}}fixed{{end}}`), ModTime: time.Now().Add(2 * time.Second)}
+	_, err = l.Lookup("about.page.tmpl")
+	assert.NoError(err)
+
+	_, err = l.Lookup("missing.page.tmpl")
+	assert.ErrorContains(err, "template missing.page.tmpl does not exist")
+	_, err = l.Lookup("base.layout.tmpl")
+	assert.Error(err)
+}
+
+func Test_RenderTemplate_Live(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	fsys := testTemplates()
+	app.UseCache = false
+	app.Templates = fsys
+	NewTemplates(app)
+	defer func() {
+		app.Templates = nil
+		NewTemplates(app)
+	}()
+
+	r, err := getTestSession()
+	required.NoError(err)
+
+	rr := httptest.NewRecorder()
+	required.NoError(RenderTemplate(rr, r, "home.page.tmpl", &model.TemplateData{}))
+	assert.Equal("<main>home</main>", rr.Body.String())
+
+	// a broken template is an error, not a crash
+	fsys["home.page.tmpl"] = &fstest.MapFile{Data: []byte(`{{template "base" .}}{{define "content"}}{{end`), ModTime: time.Now()}
+	rr = httptest.NewRecorder()
+	assert.ErrorContains(RenderTemplate(rr, r, "home.page.tmpl", &model.TemplateData{}), "can not parse template home.page.tmpl")
+	assert.Empty(rr.Body.String())
+}
@@ -0,0 +1,86 @@
+// Package secrets looks up credentials from pluggable sources: the environment,
+// files referenced by the environment and directories of secret files such as
+// Docker and Kubernetes secret mounts.
+//
+// Errors never include secret values, only their names and paths.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned by a Provider that does not have the secret.
+var ErrNotFound = errors.New("secret not found")
+
+type (
+	// Provider looks up secrets by name, e.g. CABA_CLIENT_SECRET.
+	Provider interface {
+		Secret(name string) (string, error)
+	}
+
+	// Env reads secrets from environment variables through a getenv function.
+	// When NAME is not set, the secret is read from the file at NAME_FILE, the
+	// convention of the official Docker images.
+	Env func(string) string
+
+	// Dir reads secrets from the files of a directory, named after the secret in
+	// upper or lower case. Docker mounts secrets in /run/secrets, Kubernetes in
+	// the mount path of the secret volume.
+	Dir string
+
+	// Chain looks up secrets in each provider in turn, returning the first found.
+	Chain []Provider
+)
+
+// Secret returns the value of the environment variable or the content of its file.
+func (e Env) Secret(name string) (string, error) {
+	if value := e(name); value != "" {
+		return value, nil
+	}
+	if path := e(name + "_FILE"); path != "" {
+		return readFile(name, path)
+	}
+	return "", ErrNotFound
+}
+
+// Secret returns the content of the secret file in the directory.
+func (d Dir) Secret(name string) (string, error) {
+	if d == "" {
+		return "", ErrNotFound
+	}
+	for _, file := range []string{name, strings.ToLower(name)} {
+		path := filepath.Join(string(d), file)
+		if _, err := os.Stat(path); err == nil {
+			return readFile(name, path)
+		}
+	}
+	return "", ErrNotFound
+}
+
+// Secret returns the secret of the first provider that has it.
+func (c Chain) Secret(name string) (string, error) {
+	for _, p := range c {
+		value, err := p.Secret(name)
+		if !errors.Is(err, ErrNotFound) {
+			return value, err
+		}
+	}
+	return "", ErrNotFound
+}
+
+// readFile reads a secret file, without the trailing newline editors add.
+func readFile(name, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("can not read secret %s from %s: %w", name, path, errors.Unwrap(err))
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("secret file %s of %s is empty", path, name)
+	}
+	return value, nil
+}
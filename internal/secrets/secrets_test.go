@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Env(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0o600))
+
+	env := Env(func(key string) string {
+		return map[string]string{
+			"CLIENT_ID":          "from-env",
+			"CLIENT_SECRET_FILE": path,
+			"MISSING_FILE":       path + ".missing",
+		}[key]
+	})
+
+	value, err := env.Secret("CLIENT_ID")
+	assert.NoError(err)
+	assert.Equal("from-env", value)
+
+	value, err = env.Secret("CLIENT_SECRET")
+	assert.NoError(err)
+	assert.Equal("from-file", value)
+
+	_, err = env.Secret("OTHER")
+	assert.ErrorIs(err, ErrNotFound)
+
+	_, err = env.Secret("MISSING")
+	assert.ErrorContains(err, "can not read secret MISSING")
+}
+
+func Test_Dir(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "client_secret"), []byte("s3cr3t\r\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "EMPTY"), nil, 0o600))
+
+	value, err := Dir(dir).Secret("CLIENT_SECRET")
+	assert.NoError(err)
+	assert.Equal("s3cr3t", value)
+
+	_, err = Dir(dir).Secret("CLIENT_ID")
+	assert.ErrorIs(err, ErrNotFound)
+
+	_, err = Dir("").Secret("CLIENT_SECRET")
+	assert.ErrorIs(err, ErrNotFound)
+
+	_, err = Dir(dir).Secret("EMPTY")
+	assert.ErrorContains(err, "is empty")
+}
+
+func Test_Chain(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "CLIENT_ID"), []byte("from-dir"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "CLIENT_SECRET"), []byte("from-dir"), 0o600))
+
+	chain := Chain{
+		Env(func(key string) string {
+			if key == "CLIENT_ID" {
+				return "from-env"
+			}
+			return ""
+		}),
+		Dir(dir),
+	}
+
+	value, err := chain.Secret("CLIENT_ID")
+	assert.NoError(err)
+	assert.Equal("from-env", value, "the first provider wins")
+
+	value, err = chain.Secret("CLIENT_SECRET")
+	assert.NoError(err)
+	assert.Equal("from-dir", value)
+
+	_, err = chain.Secret("OTHER")
+	assert.ErrorIs(err, ErrNotFound)
+}
@@ -2,15 +2,26 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mayloo89/bamos/internal/logging"
+	"github.com/mayloo89/bamos/internal/metrics"
+	"github.com/mayloo89/bamos/internal/tracing"
 )
 
 type (
@@ -19,7 +30,9 @@ type (
 	// APIClient defines the interface for interacting with the CABA transport API.
 	APIClient interface {
 		// ParkingRules fetches parking rules for a given latitude and longitude.
-		ParkingRules(lat, long float64) (SimplifiedRules, error)
+		ParkingRules(ctx context.Context, lat, long float64) (SimplifiedRules, error)
+		// RealtimeFeed fetches and decodes a GTFS realtime feed.
+		RealtimeFeed(ctx context.Context, feed Feed) (*gtfs.FeedMessage, error)
 	}
 
 	// Client implements APIClient and provides methods to interact with the CABA transport API.
@@ -28,6 +41,9 @@ type (
 		ClientSecret string
 		BaseURL      string
 		HTTPClient   HTTPClient
+		// RetryBackoff is the wait before the first retry of a failed request,
+		// doubled on every further retry. Zero retries right away.
+		RetryBackoff time.Duration
 	}
 
 	// HTTPClient is an interface for making HTTP requests, used for dependency injection.
@@ -81,27 +97,45 @@ const (
 	DefaultRetries = 3
 	// DefaultTimeout is the default timeout for API requests
 	DefaultTimeout = 3 * time.Second
+	// DefaultRetryBackoff is the default wait before the first retry of a failed request
+	DefaultRetryBackoff = 200 * time.Millisecond
+
+	parkingRulesEndpoint = "/transito/v1/estacionamientos"
 )
 
-// NewAPIClient creates and returns a new Client for the CABA transport API.
-func NewAPIClient() *Client {
-	clientID := os.Getenv("CABA_CLIENT_ID")
-	clientSecret := os.Getenv("CABA_CLIENT_SECRET")
+// NewAPIClient creates and returns a new Client for the CABA transport API with the
+// given credentials and request timeout, DefaultTimeout when zero.
+func NewAPIClient(clientID, clientSecret string, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
 	return &Client{
 		BaseURL:      BaseURL,
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 		HTTPClient: &http.Client{
-			Timeout: DefaultTimeout,
+			Timeout: timeout,
 		},
+		RetryBackoff: DefaultRetryBackoff,
 	}
 }
 
 // ParkingRules fetches parking rules for the specified latitude and longitude from the CABA API.
 // Returns a SimplifiedRules map or NoParkingRulesError if no rules are found.
-func (c *Client) ParkingRules(lat, long float64) (SimplifiedRules, error) {
+func (c *Client) ParkingRules(ctx context.Context, lat, long float64) (SimplifiedRules, error) {
+	ctx, span := tracing.Start(ctx, "services.ParkingRules")
+	defer span.End()
+
+	rules, err := c.parkingRules(ctx, lat, long)
+	if err != nil && !errors.Is(err, ErrNoParkingRules) {
+		tracing.RecordError(span, err)
+	}
+	return rules, err
+}
+
+func (c *Client) parkingRules(ctx context.Context, lat, long float64) (SimplifiedRules, error) {
 	response := ParkingRulesResponse{}
-	path := c.BaseURL + "/transito/v1/estacionamientos"
+	path := c.BaseURL + parkingRulesEndpoint
 	var resp *http.Response
 	var err error
 
@@ -114,23 +148,45 @@ func (c *Client) ParkingRules(lat, long float64) (SimplifiedRules, error) {
 	params.Add("formato", "json")
 	params.Add("fullInfo", "true")
 
-	// Retry logic
+	// Retry logic, waiting longer before every retry
 	for i := 0; i < DefaultRetries; i++ {
-		req, err := http.NewRequest("GET", fmt.Sprintf("%s?%s", path, params.Encode()), nil)
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s?%s", path, params.Encode()), nil)
 		if err != nil {
 			return nil, fmt.Errorf("error creating request: %w", err)
 		}
 
-		resp, err = c.HTTPClient.Do(req)
-		if err == nil && resp != nil && resp.StatusCode == http.StatusOK {
+		if i > 0 {
+			if err := c.wait(ctx, i); err != nil {
+				return nil, err
+			}
+			metrics.UpstreamRetries.WithLabelValues(parkingRulesEndpoint).Inc()
+		}
+		resp, err = c.do(req, parkingRulesEndpoint, i+1)
+		if err == nil && resp != nil && successful(resp.StatusCode) {
 			break
 		}
+		if i < DefaultRetries-1 {
+			closeBody(resp)
+		}
 	}
 
-	if resp == nil || resp.Body == nil {
+	// only an answer without rules is ErrNoParkingRules, the callers keep it
+	if err != nil {
+		return nil, fmt.Errorf("error fetching parking rules: %w", redact(err))
+	}
+	if resp == nil {
+		return nil, errors.New("empty response fetching parking rules")
+	}
+	defer closeBody(resp)
+	if resp.Body == nil && successful(resp.StatusCode) {
 		return nil, ErrNoParkingRules
 	}
 
+	if !successful(resp.StatusCode) {
+		return nil, fmt.Errorf("error fetching parking rules, response code: %d", resp.StatusCode)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -149,6 +205,78 @@ func (c *Client) ParkingRules(lat, long float64) (SimplifiedRules, error) {
 	return simplifyRules(response.Instances), nil
 }
 
+// wait sleeps the backoff before the retry, RetryBackoff doubled for every
+// previous retry. Returns the error of the context if it is done first.
+func (c *Client) wait(ctx context.Context, retry int) error {
+	if c.RetryBackoff <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(c.RetryBackoff << (retry - 1))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// successful reports whether the status code is a 2xx one.
+func successful(status int) bool {
+	return status >= 200 && status < 300
+}
+
+// closeBody closes the body of the response, if any.
+func closeBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	if err := resp.Body.Close(); err != nil {
+		slog.Warn("error closing response body", "error", err)
+	}
+}
+
+// do sends the request with the request ID and trace of its context, recording
+// the attempt in a client span and the upstream metrics of the endpoint.
+func (c *Client) do(req *http.Request, endpoint string, attempt int) (*http.Response, error) {
+	ctx, span := tracing.Start(req.Context(), req.Method+" "+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("upstream.endpoint", endpoint),
+			attribute.Int("upstream.attempt", attempt)))
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	tracing.Inject(ctx, req.Header)
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+
+	start := time.Now()
+	resp, err := c.HTTPClient.Do(req)
+	duration := time.Since(start)
+	metrics.UpstreamDuration.WithLabelValues(endpoint).Observe(duration.Seconds())
+
+	status := "error"
+	if err == nil && resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.UpstreamRequests.WithLabelValues(endpoint, status).Inc()
+	switch {
+	case err != nil:
+		tracing.RecordError(span, redact(err))
+	case resp != nil:
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		}
+	}
+	slog.DebugContext(ctx, "upstream request", "endpoint", endpoint, "status", status, "duration", duration)
+
+	return resp, err
+}
+
 // simplifyRules converts API response instances to a simplified rules map.
 func simplifyRules(instances []Instance) SimplifiedRules {
 	simplifiedRules := SimplifiedRules{}
@@ -1,26 +1,43 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mayloo89/bamos/internal/metrics"
+	"github.com/mayloo89/bamos/internal/tracing"
 )
 
 // *** NewAPIClient tests ***
 
 func TestNewAPIClient(t *testing.T) {
 	// Call NewAPIClient to create a new API client
-	client := NewAPIClient()
+	client := NewAPIClient("id", "secret", 0)
 
 	// Assertions
 	require.NotNil(t, client)
 	assert.Equal(t, BaseURL, client.BaseURL)
+	assert.Equal(t, "id", client.ClientID)
+	assert.Equal(t, "secret", client.ClientSecret)
+	assert.Equal(t, DefaultRetryBackoff, client.RetryBackoff)
 
 	// Check the HTTP client
 	require.NotNil(t, client.HTTPClient)
@@ -101,7 +118,7 @@ func TestParkingRules_OK(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
 	// Assertions
 	require.NoError(t, err)
@@ -128,12 +145,13 @@ func TestParkingRules_RequestError(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
 	// Assertions
 	require.Error(t, err)
 	assert.Nil(t, rules)
-	assert.Equal(t, "no parking rules found", err.Error())
+	assert.EqualError(t, err, "error fetching parking rules: request error")
+	assert.NotErrorIs(t, err, ErrNoParkingRules)
 
 	// Verify that the mock was called as expected
 	mockClient.AssertExpectations(t)
@@ -159,14 +177,83 @@ func TestParkingRules_ResponseError(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
-	// Assertions
+	// Assertions, the last status is an error instead of being decoded
 	require.Error(t, err)
 	assert.Nil(t, rules)
+	assert.NotErrorIs(t, err, ErrNoParkingRules)
+	assert.Contains(t, err.Error(), "response code: 500")
 
 	// Verify that the mock was called as expected
 	mockClient.AssertExpectations(t)
+	mockClient.AssertNumberOfCalls(t, "Do", DefaultRetries)
+}
+
+func TestParkingRules_ClosesBodies(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	bodies := []*closeRecorder{
+		{Reader: strings.NewReader("")},
+		{Reader: strings.NewReader("")},
+		{Reader: strings.NewReader(ParkingRulesResponseOK)},
+	}
+	mockClient.On("Do", mock.Anything).Return(&http.Response{StatusCode: http.StatusBadGateway, Body: bodies[0]}, nil).Once()
+	mockClient.On("Do", mock.Anything).Return(&http.Response{StatusCode: http.StatusServiceUnavailable, Body: bodies[1]}, nil).Once()
+	mockClient.On("Do", mock.Anything).Return(&http.Response{StatusCode: http.StatusOK, Body: bodies[2]}, nil).Once()
+
+	apiClient := &Client{
+		BaseURL:    BaseURL,
+		HTTPClient: mockClient,
+	}
+
+	rules, err := apiClient.ParkingRules(context.Background(), -34.603722, -58.381592)
+	require.NoError(t, err)
+	assert.NotEmpty(t, rules)
+
+	// the discarded attempts and the decoded response are all closed
+	for i, body := range bodies {
+		assert.True(t, body.closed, "body %d", i)
+	}
+	mockClient.AssertExpectations(t)
+}
+
+func TestParkingRules_Backoff(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockClient.On("Do", mock.Anything).Return(nil, errors.New("temporary error")).Twice()
+	mockClient.On("Do", mock.Anything).Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(ParkingRulesResponseOK)),
+	}, nil).Once()
+
+	apiClient := &Client{
+		BaseURL:      BaseURL,
+		HTTPClient:   mockClient,
+		RetryBackoff: 10 * time.Millisecond,
+	}
+
+	// 10ms before the first retry and 20ms before the second
+	start := time.Now()
+	_, err := apiClient.ParkingRules(context.Background(), -34.603722, -58.381592)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	mockClient.AssertExpectations(t)
+}
+
+func TestParkingRules_BackoffCanceled(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockClient.On("Do", mock.Anything).Return(nil, errors.New("temporary error")).Once()
+
+	apiClient := &Client{
+		BaseURL:      BaseURL,
+		HTTPClient:   mockClient,
+		RetryBackoff: time.Hour,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := apiClient.ParkingRules(ctx, -34.603722, -58.381592)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	mockClient.AssertExpectations(t)
 }
 
 func TestParkingRules_NoRulesFound(t *testing.T) {
@@ -194,7 +281,7 @@ func TestParkingRules_NoRulesFound(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
 	// Assertions
 	require.Error(t, err)
@@ -226,7 +313,7 @@ func TestParkingRules_InvalidJSON(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
 	// Assertions
 	require.Error(t, err)
@@ -307,7 +394,7 @@ func TestParkingRules_RetryLogic(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
 	// Assertions
 	require.NoError(t, err)
@@ -334,12 +421,12 @@ func TestParkingRules_NilResponse(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
 	// Assertions
 	require.Error(t, err)
 	assert.Nil(t, rules)
-	assert.Equal(t, "no parking rules found", err.Error())
+	assert.EqualError(t, err, "empty response fetching parking rules")
 
 	// Verify that the mock was called as expected
 	mockClient.AssertExpectations(t)
@@ -365,7 +452,7 @@ func TestParkingRules_NilResponseBody(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
 	// Assertions
 	require.Error(t, err)
@@ -391,17 +478,96 @@ func TestParkingRules_RetryLogicWithNilResponse(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
 	// Assertions
 	require.Error(t, err)
 	assert.Nil(t, rules)
-	assert.Contains(t, err.Error(), "no parking rules found")
+	assert.EqualError(t, err, "error fetching parking rules: temporary error")
+	assert.NotErrorIs(t, err, ErrNoParkingRules)
 
 	// Verify that the mock was called as expected
 	mockClient.AssertExpectations(t)
 }
 
+func TestParkingRules_Metrics(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockClient.On("Do", mock.Anything).Return(nil, errors.New("temporary error")).Once()
+	mockClient.On("Do", mock.Anything).Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`{"instancias": []}`)),
+	}, nil).Once()
+
+	apiClient := &Client{
+		BaseURL:    BaseURL,
+		HTTPClient: mockClient,
+	}
+
+	requests := func(status string) float64 {
+		return testutil.ToFloat64(metrics.UpstreamRequests.WithLabelValues(parkingRulesEndpoint, status))
+	}
+	observations := func() uint64 {
+		var m dto.Metric
+		require.NoError(t, metrics.UpstreamDuration.WithLabelValues(parkingRulesEndpoint).(prometheus.Metric).Write(&m))
+		return m.GetHistogram().GetSampleCount()
+	}
+	failed, succeeded := requests("error"), requests("200")
+	retries := testutil.ToFloat64(metrics.UpstreamRetries.WithLabelValues(parkingRulesEndpoint))
+	observed := observations()
+
+	_, err := apiClient.ParkingRules(context.Background(), -34.603722, -58.381592)
+	require.ErrorIs(t, err, ErrNoParkingRules)
+
+	// one failed attempt, then a successful retry
+	assert.Equal(t, failed+1, requests("error"))
+	assert.Equal(t, succeeded+1, requests("200"))
+	assert.Equal(t, retries+1, testutil.ToFloat64(metrics.UpstreamRetries.WithLabelValues(parkingRulesEndpoint)))
+	assert.Equal(t, observed+2, observations())
+	mockClient.AssertExpectations(t)
+}
+
+func TestParkingRules_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	mockClient := new(MockAPIClient)
+	mockClient.On("Do", mock.Anything).Return(&http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Body:       io.NopCloser(strings.NewReader("")),
+	}, nil).Once()
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Header.Get(tracing.TraceparentHeader) != ""
+	})).Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(ParkingRulesResponseOK)),
+	}, nil).Once()
+
+	apiClient := &Client{
+		BaseURL:    BaseURL,
+		HTTPClient: mockClient,
+	}
+
+	_, err := apiClient.ParkingRules(context.Background(), -34.603722, -58.381592)
+	require.NoError(t, err)
+
+	// an attempt span per request, children of the ParkingRules span
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	parent := spans[2]
+	assert.Equal(t, "services.ParkingRules", parent.Name())
+	for i, span := range spans[:2] {
+		assert.Equal(t, "GET "+parkingRulesEndpoint, span.Name())
+		assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		assert.Contains(t, span.Attributes(), attribute.Int("upstream.attempt", i+1))
+	}
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Contains(t, spans[1].Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+	mockClient.AssertExpectations(t)
+}
+
 func TestParkingRules_NewRequestError(t *testing.T) {
 	// Create a mock HTTP client
 	mockClient := new(MockAPIClient)
@@ -414,7 +580,7 @@ func TestParkingRules_NewRequestError(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
 	// Assertions
 	require.Error(t, err)
@@ -442,7 +608,7 @@ func TestParkingRules_ReadBodyError(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
 	// Assertions
 	require.Error(t, err)
@@ -535,7 +701,7 @@ func TestParkingRules_OKWithParidad(t *testing.T) {
 
 	// Call the ParkingRules method
 	lat, long := -34.603722, -58.381592
-	rules, err := apiClient.ParkingRules(lat, long)
+	rules, err := apiClient.ParkingRules(context.Background(), lat, long)
 
 	// Assertions
 	require.NoError(t, err)
@@ -550,6 +716,17 @@ func TestParkingRules_OKWithParidad(t *testing.T) {
 // *** Helper functions ***
 
 // Custom faulty reader to simulate io.ReadAll error
+// closeRecorder is a response body recording whether it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
 type faultyReader struct{}
 
 func (f *faultyReader) Read(p []byte) (n int, err error) {
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/cache"
+)
+
+// ParkingRulesCacheSize is the number of locations a CachedClient keeps the
+// parking rules of.
+const ParkingRulesCacheSize = 1000
+
+// CachedClient is an APIClient keeping the parking rules of the looked up
+// locations for a while, so the same location does not call the API again. The
+// realtime feeds, polled in the background, are not cached.
+type CachedClient struct {
+	APIClient
+	rules *cache.Cache[string, parkingRulesResult]
+}
+
+// parkingRulesResult is a cached lookup, whose error is nil or ErrNoParkingRules.
+type parkingRulesResult struct {
+	rules SimplifiedRules
+	err   error
+}
+
+// NewCachedClient returns a CachedClient keeping the parking rules of the last
+// ParkingRulesCacheSize locations looked up with client for ttl.
+func NewCachedClient(client APIClient, ttl time.Duration) *CachedClient {
+	return &CachedClient{APIClient: client, rules: cache.New[string, parkingRulesResult]("parking_rules", ttl, ParkingRulesCacheSize)}
+}
+
+// ParkingRules returns the cached parking rules of the location, fetching them
+// otherwise. The locations are the same up to about a meter. Failed lookups are
+// not kept, the ones without rules are.
+func (c *CachedClient) ParkingRules(ctx context.Context, lat, long float64) (SimplifiedRules, error) {
+	key := fmt.Sprintf("%.5f,%.5f", lat, long)
+	if result, ok := c.rules.Get(key); ok {
+		return result.rules, result.err
+	}
+	rules, err := c.APIClient.ParkingRules(ctx, lat, long)
+	if err != nil && !errors.Is(err, ErrNoParkingRules) {
+		return nil, err
+	}
+	c.rules.Set(key, parkingRulesResult{rules: rules, err: err})
+	return rules, err
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_CachedClient_ParkingRules(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	rules := SimplifiedRules{"Corrientes 1000": {"Lado par: prohibido estacionar las 24 hs."}}
+	client := new(MockAPIClient)
+	client.On("ParkingRules", mock.Anything, -34.6037, -58.3816).Return(rules, nil)
+	client.On("ParkingRules", mock.Anything, -34.6625, -58.365).Return(nil, ErrNoParkingRules)
+	client.On("ParkingRules", mock.Anything, -34.6040, -58.3816).Return(nil, errors.New("upstream error"))
+	cached := NewCachedClient(client, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		got, err := cached.ParkingRules(ctx, -34.6037, -58.3816)
+		assert.NoError(err)
+		assert.Equal(rules, got)
+
+		_, err = cached.ParkingRules(ctx, -34.6625, -58.365)
+		assert.ErrorIs(err, ErrNoParkingRules)
+
+		_, err = cached.ParkingRules(ctx, -34.6040, -58.3816)
+		assert.EqualError(err, "upstream error")
+	}
+
+	// the failed lookups are the only ones repeated
+	client.AssertNumberOfCalls(t, "ParkingRules", 4)
+}
+
+func Test_CachedClient_ParkingRules_TransportError(t *testing.T) {
+	assert := assert.New(t)
+
+	// the requests of every lookup fail before reaching the API
+	transport := new(MockAPIClient)
+	transport.On("Do", mock.Anything).Return(nil, errors.New("connection refused"))
+	cached := NewCachedClient(&Client{BaseURL: BaseURL, HTTPClient: transport}, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		_, err := cached.ParkingRules(context.Background(), -34.6037, -58.3816)
+		assert.EqualError(err, "error fetching parking rules: connection refused")
+		assert.NotErrorIs(err, ErrNoParkingRules)
+	}
+
+	// the failure is not kept, the second lookup retries the API
+	transport.AssertNumberOfCalls(t, "Do", 2*DefaultRetries)
+}
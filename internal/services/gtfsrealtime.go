@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"google.golang.org/protobuf/proto"
+)
+
+// Feed identifies a GTFS realtime feed published by the CABA transport API.
+// Its value is the path of the feed relative to the API base URL.
+type Feed string
+
+const (
+	// FeedVehiclePositions is the GTFS realtime feed with the position of every bus.
+	FeedVehiclePositions Feed = "/colectivos/vehiclePositions"
+	// FeedTripUpdates is the GTFS realtime feed with predicted arrivals and delays.
+	FeedTripUpdates Feed = "/colectivos/tripUpdates"
+	// FeedServiceAlerts is the GTFS realtime feed with service alerts.
+	FeedServiceAlerts Feed = "/colectivos/serviceAlerts"
+	// FeedGtfsFrequency is the GTFS realtime feed of the frequency based trips.
+	FeedGtfsFrequency Feed = "/colectivos/feed-gtfs-frequency"
+)
+
+// RealtimeFeed fetches and decodes the given GTFS realtime feed from the CABA API.
+func (c *Client) RealtimeFeed(ctx context.Context, feed Feed) (*gtfs.FeedMessage, error) {
+	params := url.Values{}
+	params.Add("client_id", c.ClientID)
+	params.Add("client_secret", c.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s%s?%s", c.BaseURL, feed, params.Encode()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	resp, err := c.do(req, string(feed), 1)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching feed %s: %w", feed, redact(err))
+	}
+	if resp == nil || resp.Body == nil {
+		return nil, fmt.Errorf("empty response fetching feed %s", feed)
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			slog.WarnContext(ctx, "error closing response body", "error", cerr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching feed %s, response code: %d", feed, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &gtfs.FeedMessage{}
+	if err := proto.Unmarshal(body, message); err != nil {
+		return nil, fmt.Errorf("error decoding feed %s: %w", feed, err)
+	}
+
+	return message, nil
+}
+
+// redact removes the query, which holds the credentials, from the URL of a request
+// error so it can be logged.
+func redact(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, perr := url.Parse(urlErr.URL); perr == nil {
+			u.RawQuery = ""
+			urlErr.URL = u.String()
+		}
+	}
+	return err
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mayloo89/bamos/internal/logging"
+)
+
+// *** RealtimeFeed tests ***
+
+func TestRealtimeFeed_RequestID(t *testing.T) {
+	mockClient := new(MockAPIClient)
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Header.Get(logging.RequestIDHeader) == "req-42"
+	})).Return(nil, errors.New("unavailable")).Once()
+
+	apiClient := &Client{
+		BaseURL:    BaseURL,
+		HTTPClient: mockClient,
+	}
+
+	ctx := logging.WithRequestID(context.Background(), "req-42")
+	_, err := apiClient.RealtimeFeed(ctx, FeedTripUpdates)
+
+	require.Error(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+func TestRealtimeFeed_OK(t *testing.T) {
+	// Create a mock HTTP client
+	mockClient := new(MockAPIClient)
+
+	// Simulate a protobuf encoded feed with a single vehicle
+	body, err := proto.Marshal(&gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{
+			GtfsRealtimeVersion: proto.String("2.0"),
+			Timestamp:           proto.Uint64(1700000000),
+		},
+		Entity: []*gtfs.FeedEntity{
+			{
+				Id: proto.String("1"),
+				Vehicle: &gtfs.VehiclePosition{
+					Trip:     &gtfs.TripDescriptor{RouteId: proto.String("1426")},
+					Position: &gtfs.Position{Latitude: proto.Float32(-34.6), Longitude: proto.Float32(-58.4)},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	mockResponse := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Header:     make(http.Header),
+	}
+	mockClient.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.URL.Path == string(FeedVehiclePositions)
+	})).Return(mockResponse, nil)
+
+	// Create the API client with the mock HTTP client
+	apiClient := &Client{
+		BaseURL:    BaseURL,
+		HTTPClient: mockClient,
+	}
+
+	feed, err := apiClient.RealtimeFeed(context.Background(), FeedVehiclePositions)
+
+	// Assertions
+	require.NoError(t, err)
+	require.NotNil(t, feed)
+	assert.Equal(t, uint64(1700000000), feed.GetHeader().GetTimestamp())
+	require.Len(t, feed.GetEntity(), 1)
+	assert.Equal(t, "1426", feed.GetEntity()[0].GetVehicle().GetTrip().GetRouteId())
+
+	// Verify that the mock was called as expected
+	mockClient.AssertExpectations(t)
+}
+
+func TestRealtimeFeed_RequestError(t *testing.T) {
+	// Create a mock HTTP client
+	mockClient := new(MockAPIClient)
+	mockClient.On("Do", mock.Anything).Return(nil, errors.New("request error"))
+
+	// Create the API client with the mock HTTP client
+	apiClient := &Client{
+		BaseURL:    BaseURL,
+		HTTPClient: mockClient,
+	}
+
+	feed, err := apiClient.RealtimeFeed(context.Background(), FeedTripUpdates)
+
+	// Assertions
+	require.Error(t, err)
+	assert.Nil(t, feed)
+	assert.Contains(t, err.Error(), "request error")
+
+	// Verify that the mock was called as expected
+	mockClient.AssertExpectations(t)
+}
+
+func TestRealtimeFeed_RequestErrorRedactsCredentials(t *testing.T) {
+	// Create a mock HTTP client failing like http.Client, with the full URL in the error
+	mockClient := new(MockAPIClient)
+	mockClient.On("Do", mock.Anything).Return(nil, &url.Error{
+		Op:  "Get",
+		URL: BaseURL + string(FeedTripUpdates) + "?client_id=id&client_secret=s3cr3t",
+		Err: errors.New("connection refused"),
+	})
+
+	apiClient := &Client{
+		BaseURL:      BaseURL,
+		ClientID:     "id",
+		ClientSecret: "s3cr3t",
+		HTTPClient:   mockClient,
+	}
+
+	_, err := apiClient.RealtimeFeed(context.Background(), FeedTripUpdates)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+	assert.NotContains(t, err.Error(), "s3cr3t")
+}
+
+func TestRealtimeFeed_ResponseError(t *testing.T) {
+	// Create a mock HTTP client
+	mockClient := new(MockAPIClient)
+
+	// Simulate a non-200 response
+	mockResponse := &http.Response{
+		StatusCode: http.StatusInternalServerError,
+		Body:       io.NopCloser(strings.NewReader("")),
+		Header:     make(http.Header),
+	}
+	mockClient.On("Do", mock.Anything).Return(mockResponse, nil)
+
+	// Create the API client with the mock HTTP client
+	apiClient := &Client{
+		BaseURL:    BaseURL,
+		HTTPClient: mockClient,
+	}
+
+	feed, err := apiClient.RealtimeFeed(context.Background(), FeedServiceAlerts)
+
+	// Assertions
+	require.Error(t, err)
+	assert.Nil(t, feed)
+	assert.Contains(t, err.Error(), "response code: 500")
+
+	// Verify that the mock was called as expected
+	mockClient.AssertExpectations(t)
+}
+
+func TestRealtimeFeed_InvalidProtobuf(t *testing.T) {
+	// Create a mock HTTP client
+	mockClient := new(MockAPIClient)
+
+	// Simulate a response that is not a protobuf message
+	mockResponse := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("invalid protobuf")),
+		Header:     make(http.Header),
+	}
+	mockClient.On("Do", mock.Anything).Return(mockResponse, nil)
+
+	// Create the API client with the mock HTTP client
+	apiClient := &Client{
+		BaseURL:    BaseURL,
+		HTTPClient: mockClient,
+	}
+
+	feed, err := apiClient.RealtimeFeed(context.Background(), FeedVehiclePositions)
+
+	// Assertions
+	require.Error(t, err)
+	assert.Nil(t, feed)
+	assert.Contains(t, err.Error(), "error decoding feed")
+
+	// Verify that the mock was called as expected
+	mockClient.AssertExpectations(t)
+}
@@ -1,8 +1,10 @@
 package services
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -69,10 +71,18 @@ func (m *MockAPIClient) Do(req *http.Request) (*http.Response, error) {
 	return args.Get(0).(*http.Response), args.Error(1)
 }
 
-func (m *MockAPIClient) ParkingRules(lat, long float64) (SimplifiedRules, error) {
-	arg := m.Called(lat, long)
+func (m *MockAPIClient) ParkingRules(ctx context.Context, lat, long float64) (SimplifiedRules, error) {
+	arg := m.Called(ctx, lat, long)
 	if arg.Get(0) == nil {
 		return nil, arg.Error(1)
 	}
 	return arg.Get(0).(SimplifiedRules), arg.Error(1)
 }
+
+func (m *MockAPIClient) RealtimeFeed(ctx context.Context, feed Feed) (*gtfs.FeedMessage, error) {
+	arg := m.Called(ctx, feed)
+	if arg.Get(0) == nil {
+		return nil, arg.Error(1)
+	}
+	return arg.Get(0).(*gtfs.FeedMessage), arg.Error(1)
+}
@@ -0,0 +1,151 @@
+package sessionstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// fileSuffix is the extension of the session files.
+	fileSuffix = ".session"
+	// headerSize is the size of the expiry, in Unix nanoseconds, starting every file.
+	headerSize = 8
+)
+
+// FileStore stores every session in its own file, named after the hash of its token
+// so tokens never end up in paths. Files are replaced atomically on commit.
+type FileStore struct {
+	dir string
+	now func() time.Time
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create session directory %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir, now: time.Now}, nil
+}
+
+// Find returns the data of an unexpired session.
+func (s *FileStore) Find(token string) ([]byte, bool, error) {
+	expiry, data, err := s.read(s.path(token))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !s.now().Before(expiry) {
+		return nil, false, nil
+	}
+	return data, true, nil
+}
+
+// Commit saves the session data until expiry, replacing the previous one.
+func (s *FileStore) Commit(token string, data []byte, expiry time.Time) error {
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint64(header, uint64(expiry.UnixNano()))
+	_, err = f.Write(append(header, data...))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path(token))
+}
+
+// Delete removes the session, if it exists.
+func (s *FileStore) Delete(token string) error {
+	if err := os.Remove(s.path(token)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// DeleteExpired removes the files of the expired sessions.
+func (s *FileStore) DeleteExpired(ctx context.Context) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	now := s.now()
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return deleted, ctx.Err()
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileSuffix) {
+			continue
+		}
+
+		path := filepath.Join(s.dir, entry.Name())
+		expiry, err := s.readExpiry(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // deleted concurrently
+		}
+		if err != nil {
+			return deleted, err
+		}
+		if now.Before(expiry) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// path returns the file of the session.
+func (s *FileStore) path(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+fileSuffix)
+}
+
+// read returns the expiry and data of a session file.
+func (s *FileStore) read(path string) (time.Time, []byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if len(b) < headerSize {
+		return time.Time{}, nil, fmt.Errorf("corrupted session file %s", filepath.Base(path))
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b[:headerSize]))), b[headerSize:], nil
+}
+
+// readExpiry returns the expiry of a session file without reading its data.
+func (s *FileStore) readExpiry(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return time.Time{}, fmt.Errorf("corrupted session file %s: %w", filepath.Base(path), err)
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(header))), nil
+}
@@ -0,0 +1,77 @@
+package sessionstore
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FileStore(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	store, err := NewFileStore(t.TempDir())
+	required.NoError(err)
+	store.now = func() time.Time { return now }
+
+	required.NoError(store.Commit("token-1", []byte("first"), now.Add(time.Hour)))
+	required.NoError(store.Commit("token-1", []byte("second"), now.Add(time.Hour)))
+
+	data, found, err := store.Find("token-1")
+	required.NoError(err)
+	assert.True(found)
+	assert.Equal([]byte("second"), data)
+
+	_, found, err = store.Find("unknown")
+	required.NoError(err)
+	assert.False(found)
+
+	// tokens never end up in the file names
+	entries, err := os.ReadDir(store.dir)
+	required.NoError(err)
+	required.Len(entries, 1, "temporary files are removed")
+	assert.False(strings.Contains(entries[0].Name(), "token-1"))
+
+	required.NoError(store.Delete("token-1"))
+	required.NoError(store.Delete("token-1"), "deleting a missing session is not an error")
+	_, found, err = store.Find("token-1")
+	required.NoError(err)
+	assert.False(found)
+}
+
+func Test_FileStore_Expiry(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	store, err := NewFileStore(t.TempDir())
+	required.NoError(err)
+	store.now = func() time.Time { return now }
+
+	required.NoError(store.Commit("expired", []byte("old"), now.Add(-time.Second)))
+	required.NoError(store.Commit("valid", []byte("new"), now.Add(time.Hour)))
+
+	_, found, err := store.Find("expired")
+	required.NoError(err)
+	assert.False(found)
+
+	deleted, err := store.DeleteExpired(context.Background())
+	required.NoError(err)
+	assert.Equal(1, deleted)
+
+	_, found, err = store.Find("valid")
+	required.NoError(err)
+	assert.True(found)
+
+	// an hour later the remaining session expires too
+	now = now.Add(time.Hour)
+	deleted, err = store.DeleteExpired(context.Background())
+	required.NoError(err)
+	assert.Equal(1, deleted)
+}
@@ -0,0 +1,71 @@
+package sessionstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PostgresStore stores the sessions in the sessions table.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a PostgresStore using the given connection pool.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Find returns the data of an unexpired session.
+func (s *PostgresStore) Find(token string) ([]byte, bool, error) {
+	return s.FindCtx(context.Background(), token)
+}
+
+// FindCtx returns the data of an unexpired session.
+func (s *PostgresStore) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx,
+		`select data from sessions where token = $1 and current_timestamp < expiry`, token).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Commit saves the session data until expiry, replacing the previous one.
+func (s *PostgresStore) Commit(token string, data []byte, expiry time.Time) error {
+	return s.CommitCtx(context.Background(), token, data, expiry)
+}
+
+// CommitCtx saves the session data until expiry, replacing the previous one.
+func (s *PostgresStore) CommitCtx(ctx context.Context, token string, data []byte, expiry time.Time) error {
+	_, err := s.db.ExecContext(ctx, `insert into sessions (token, data, expiry) values ($1, $2, $3)
+		on conflict (token) do update set data = excluded.data, expiry = excluded.expiry`,
+		token, data, expiry.UTC())
+	return err
+}
+
+// Delete removes the session, if it exists.
+func (s *PostgresStore) Delete(token string) error {
+	return s.DeleteCtx(context.Background(), token)
+}
+
+// DeleteCtx removes the session, if it exists.
+func (s *PostgresStore) DeleteCtx(ctx context.Context, token string) error {
+	_, err := s.db.ExecContext(ctx, `delete from sessions where token = $1`, token)
+	return err
+}
+
+// DeleteExpired removes the expired sessions.
+func (s *PostgresStore) DeleteExpired(ctx context.Context) (int, error) {
+	result, err := s.db.ExecContext(ctx, `delete from sessions where expiry <= current_timestamp`)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
@@ -0,0 +1,57 @@
+package sessionstore
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/driver"
+)
+
+// Test_PostgresStore needs a database migrated with the migrations directory.
+func Test_PostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	assert := assert.New(t)
+	required := require.New(t)
+
+	db, err := driver.ConnectSQL(dsn)
+	required.NoError(err)
+	t.Cleanup(func() { _ = db.SQL.Close() })
+
+	token := "test-" + time.Now().Format(time.RFC3339Nano)
+	expired := token + "-expired"
+	t.Cleanup(func() {
+		_, _ = db.SQL.Exec(`delete from sessions where token in ($1, $2)`, token, expired)
+	})
+
+	store := NewPostgresStore(db.SQL)
+	required.NoError(store.Commit(token, []byte("first"), time.Now().Add(time.Hour)))
+	required.NoError(store.Commit(token, []byte("second"), time.Now().Add(time.Hour)))
+	required.NoError(store.Commit(expired, []byte("old"), time.Now().Add(-time.Minute)))
+
+	data, found, err := store.Find(token)
+	required.NoError(err)
+	assert.True(found)
+	assert.Equal([]byte("second"), data)
+
+	_, found, err = store.Find(expired)
+	required.NoError(err)
+	assert.False(found, "expired sessions are not found")
+
+	deleted, err := store.DeleteExpired(context.Background())
+	required.NoError(err)
+	assert.GreaterOrEqual(deleted, 1)
+
+	required.NoError(store.Delete(token))
+	_, found, err = store.Find(token)
+	required.NoError(err)
+	assert.False(found)
+}
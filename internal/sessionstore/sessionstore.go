@@ -0,0 +1,101 @@
+// Package sessionstore provides persistent stores for the scs session manager, so
+// sessions survive restarts and are shared between instances.
+package sessionstore
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+
+	"github.com/mayloo89/bamos/internal/driver"
+)
+
+const (
+	// DefaultDir is the directory of the file store when none is given.
+	DefaultDir = "data/sessions"
+	// DefaultCleanupInterval is how often the expired sessions are deleted.
+	DefaultCleanupInterval = 5 * time.Minute
+)
+
+// Cleaner is a store whose expired sessions have to be deleted explicitly.
+type Cleaner interface {
+	// DeleteExpired deletes the expired sessions, returning how many were deleted.
+	DeleteExpired(ctx context.Context) (int, error)
+}
+
+// Open opens the store of the given kind, "file" or "postgres", and deletes its expired
+// sessions every DefaultCleanupInterval until the returned function releases it. An
+// empty kind or "memory" returns a nil store, keeping the in-memory store of scs.
+func Open(kind, dir, databaseURL string, logger *slog.Logger) (scs.Store, func(), error) {
+	var store interface {
+		scs.Store
+		Cleaner
+	}
+	release := func() {}
+
+	switch kind {
+	case "", "memory":
+		return nil, func() {}, nil
+	case "file":
+		if dir == "" {
+			dir = DefaultDir
+		}
+		fs, err := NewFileStore(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		store = fs
+	case "postgres":
+		db, err := driver.ConnectSQL(databaseURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can not connect to the session database: %w", err)
+		}
+		store = NewPostgresStore(db.SQL)
+		release = func() { _ = db.SQL.Close() }
+	default:
+		return nil, nil, fmt.Errorf("invalid session store %q, must be memory, file or postgres", kind)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunCleanup(ctx, store, DefaultCleanupInterval, logger)
+	}()
+
+	return store, func() {
+		cancel()
+		<-done
+		release()
+	}, nil
+}
+
+// RunCleanup deletes the expired sessions of the store every interval until ctx is
+// done. Errors are reported to logger, or to the default logger when logger is nil.
+func RunCleanup(ctx context.Context, store Cleaner, interval time.Duration, logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := store.DeleteExpired(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.ErrorContext(ctx, "error deleting expired sessions", "error", err)
+		case deleted > 0:
+			logger.DebugContext(ctx, "expired sessions deleted", "sessions", deleted)
+		}
+	}
+}
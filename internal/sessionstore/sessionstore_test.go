@@ -0,0 +1,82 @@
+package sessionstore
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Open(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	store, release, err := Open("", "", "", nil)
+	required.NoError(err)
+	assert.Nil(store, "the in-memory store of scs is kept")
+	release()
+
+	store, release, err = Open("file", t.TempDir(), "", nil)
+	required.NoError(err)
+	assert.IsType(&FileStore{}, store)
+	release()
+
+	_, _, err = Open("redis", "", "", nil)
+	assert.ErrorContains(err, `invalid session store "redis"`)
+}
+
+func Test_Open_SessionManager(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	dir := t.TempDir()
+	store, release, err := Open("file", dir, "", nil)
+	required.NoError(err)
+	defer release()
+
+	session := scs.New()
+	session.Store = store
+	ctx, err := session.Load(context.Background(), "")
+	required.NoError(err)
+	session.Put(ctx, "flash", "Saved.")
+	token, _, err := session.Commit(ctx)
+	required.NoError(err)
+
+	// a new manager, as after a restart, finds the session
+	restarted := scs.New()
+	restarted.Store, err = NewFileStore(dir)
+	required.NoError(err)
+	ctx, err = restarted.Load(context.Background(), token)
+	required.NoError(err)
+	assert.Equal("Saved.", restarted.GetString(ctx, "flash"))
+}
+
+func Test_RunCleanup(t *testing.T) {
+	cleaner := &countingCleaner{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunCleanup(ctx, cleaner, time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}()
+
+	require.Eventually(t, func() bool { return cleaner.calls.Load() >= 2 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
+
+// countingCleaner counts the calls to DeleteExpired.
+type countingCleaner struct {
+	calls atomic.Int32
+}
+
+func (c *countingCleaner) DeleteExpired(ctx context.Context) (int, error) {
+	c.calls.Add(1)
+	return 1, nil
+}
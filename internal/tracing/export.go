@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+// Setup exports the spans of the named service to the OpenTelemetry collector
+// at endpoint, the base URL the /v1/traces path is appended to as with
+// OTEL_EXPORTER_OTLP_ENDPOINT, and makes the exporting provider and the W3C
+// propagator the global ones. Shutting the returned provider down exports the
+// last spans.
+func Setup(ctx context.Context, service, endpoint string, headers map[string]string, timeout time.Duration) (*sdktrace.TracerProvider, error) {
+	if service == "" {
+		service = DefaultServiceName
+	}
+
+	exporter, err := otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/")+"/v1/traces"),
+		otlptracehttp.WithHeaders(headers),
+		otlptracehttp.WithTimeout(timeout))
+	if err != nil {
+		return nil, fmt.Errorf("error creating the trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(service)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating the trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(DefaultFlushInterval)),
+		sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	return provider, nil
+}
+
+// ParseHeaders parses headers in the OTEL_EXPORTER_OTLP_HEADERS format,
+// comma separated key=value pairs.
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			// the value may be a secret, only the key is reported
+			return nil, fmt.Errorf("invalid header %q, must be key=value", key)
+		}
+		headers[key] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
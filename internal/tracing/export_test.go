@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_Setup(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	var body []byte
+	var header http.Header
+	var path string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, header = r.URL.Path, r.Header
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer collector.Close()
+
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	provider, err := Setup(context.Background(), "bamos-test", collector.URL+"/", map[string]string{"X-Api-Key": "key"}, time.Second)
+	required.NoError(err)
+
+	ctx, root := Start(context.Background(), "GET /")
+	_, child := Start(ctx, "render.Execute")
+	child.End()
+	root.End()
+
+	// the last spans are exported on shutdown
+	required.NoError(provider.Shutdown(context.Background()))
+
+	assert.Equal("/v1/traces", path)
+	assert.Equal("application/x-protobuf", header.Get("Content-Type"))
+	assert.Equal("key", header.Get("X-Api-Key"))
+
+	var request coltracepb.ExportTraceServiceRequest
+	required.NoError(proto.Unmarshal(body, &request))
+	required.Len(request.ResourceSpans, 1)
+	resource := request.ResourceSpans[0]
+	var service string
+	for _, attr := range resource.Resource.Attributes {
+		if attr.Key == "service.name" {
+			service = attr.Value.GetStringValue()
+		}
+	}
+	assert.Equal("bamos-test", service)
+
+	spans := resource.ScopeSpans[0].Spans
+	required.Len(spans, 2)
+	assert.Equal("render.Execute", spans[0].Name)
+	assert.Equal(spans[1].SpanId, spans[0].ParentSpanId)
+	assert.Equal(scopeName, resource.ScopeSpans[0].Scope.Name)
+}
+
+func Test_ParseHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	headers, err := ParseHeaders("api-key=secret, x-team = transit,")
+	assert.NoError(err)
+	assert.Equal(map[string]string{"api-key": "secret", "x-team": "transit"}, headers)
+
+	headers, err = ParseHeaders("")
+	assert.NoError(err)
+	assert.Empty(headers)
+
+	_, err = ParseHeaders("api-key")
+	assert.EqualError(err, `invalid header "api-key", must be key=value`)
+}
@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func Test_Middleware(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	recorder := recordSpans(t)
+
+	var handlerTrace string
+	mux := chi.NewRouter()
+	mux.Use(Middleware)
+	mux.Get("/analytics/lines/{route_id}", func(w http.ResponseWriter, r *http.Request) {
+		handlerTrace = TraceIDFromContext(r.Context())
+	})
+	mux.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	req := httptest.NewRequest("GET", "/analytics/lines/1426", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+
+	spans := recorder.Ended()
+	required.Len(spans, 2)
+
+	assert.Equal("GET /analytics/lines/{route_id}", spans[0].Name())
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String(), "the incoming trace is continued")
+	assert.Equal("00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.True(spans[0].Parent().IsRemote())
+	assert.Equal(spans[0].SpanContext().TraceID().String(), handlerTrace)
+	assert.Contains(spans[0].Attributes(), attribute.String("http.route", "/analytics/lines/{route_id}"))
+	assert.Contains(spans[0].Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+	assert.Equal(codes.Unset, spans[0].Status().Code)
+
+	assert.Equal("GET /fail", spans[1].Name())
+	assert.False(spans[1].Parent().IsValid())
+	assert.Equal(codes.Error, spans[1].Status().Code)
+}
@@ -0,0 +1,68 @@
+// Package tracing sets up OpenTelemetry to trace the request handling and the
+// upstream calls and export the spans to a collector with OTLP/HTTP. Until Setup
+// is called the global tracer provider is a no-op one and spans are not recorded.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultServiceName is the service name of the exported spans when none is configured.
+	DefaultServiceName = "bamos"
+	// DefaultFlushInterval is how often the ended spans are exported in batches.
+	DefaultFlushInterval = 5 * time.Second
+	// TraceparentHeader is the W3C Trace Context header linking spans across services.
+	TraceparentHeader = "traceparent"
+
+	// scopeName is the instrumentation scope of the spans of the application.
+	scopeName = "github.com/mayloo89/bamos"
+)
+
+// propagator carries the trace across services in the W3C Trace Context headers.
+var propagator = propagation.TraceContext{}
+
+// Tracer returns the tracer of the application from the global tracer provider.
+// It is looked up on every call so the provider set by Setup, or by a test, is
+// always the one used.
+func Tracer() trace.Tracer {
+	return otel.Tracer(scopeName)
+}
+
+// Start starts a span with the tracer of the application, child of the span of
+// ctx if any, and returns a context carrying it.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// Inject sets the traceparent header of the span of ctx, if any, so the
+// receiving service continues the trace.
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// TraceIDFromContext returns the ID of the trace of the span in ctx, empty if none.
+func TraceIDFromContext(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// RecordError records the error on the span and marks the span as failed,
+// ignoring a nil error.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
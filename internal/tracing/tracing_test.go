@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans makes a provider recording the ended spans the global one for the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func Test_Start(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	recorder := recordSpans(t)
+
+	ctx, root := Start(context.Background(), "GET /transit/allowed-parking", trace.WithSpanKind(trace.SpanKindServer))
+	childCtx, child := Start(ctx, "services.ParkingRules", trace.WithAttributes(attribute.String("line", "505")))
+	child.SetStatus(codes.Error, "upstream unavailable")
+	child.End()
+	root.End()
+
+	assert.Equal(TraceIDFromContext(ctx), TraceIDFromContext(childCtx))
+	assert.Len(TraceIDFromContext(ctx), 32)
+
+	spans := recorder.Ended()
+	required.Len(spans, 2)
+	assert.Equal("services.ParkingRules", spans[0].Name())
+	assert.Equal(spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(codes.Error, spans[0].Status().Code)
+	assert.Contains(spans[0].Attributes(), attribute.String("line", "505"))
+	assert.Equal(scopeName, spans[0].InstrumentationScope().Name)
+	assert.Equal(trace.SpanKindServer, spans[1].SpanKind())
+}
+
+func Test_Start_Disabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "noop")
+	defer span.End()
+
+	assert.False(t, span.IsRecording())
+	assert.Empty(t, TraceIDFromContext(ctx))
+}
+
+func Test_Inject(t *testing.T) {
+	assert := assert.New(t)
+	recordSpans(t)
+
+	ctx, span := Start(context.Background(), "client", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	header := http.Header{}
+	Inject(ctx, header)
+	assert.Regexp(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, header.Get(TraceparentHeader))
+	assert.Contains(header.Get(TraceparentHeader), TraceIDFromContext(ctx))
+
+	header = http.Header{}
+	Inject(context.Background(), header)
+	assert.Empty(header.Get(TraceparentHeader), "nothing to inject without a span")
+}
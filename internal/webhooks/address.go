@@ -0,0 +1,121 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Resolver looks up the addresses of a host, net.DefaultResolver in production.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+var (
+	// ErrInvalidURL is returned for subscription URLs that are not http or https URLs.
+	ErrInvalidURL = errors.New("the webhook URL must be an http or https URL")
+	// ErrPrivateAddress is returned for subscription URLs whose host is not, or does
+	// not resolve to, a public address, so webhooks can not reach the internal network.
+	ErrPrivateAddress = errors.New("the webhook URL must resolve to a public address")
+)
+
+// reservedPrefixes are the ranges that are neither loopback, private nor link-local
+// but must not be reached either: shared, benchmarking, documentation and
+// translation addresses, and the IPv6 ranges embedding IPv4 ones.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001::/32"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// publicAddr reports whether addr is a public unicast address: not loopback,
+// private, link-local, such as the cloud metadata service, multicast or reserved.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL checks the URL of a subscription is an http or https URL whose host
+// is, or resolves with resolver to, public addresses only.
+// Returns ErrInvalidURL or ErrPrivateAddress otherwise.
+func CheckURL(ctx context.Context, resolver Resolver, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	host := u.Hostname()
+	addrs := []netip.Addr{}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr)
+	} else {
+		addrs, err = resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return fmt.Errorf("%w: can not resolve %s: %v", ErrPrivateAddress, host, err)
+		}
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("%w: %s has no address", ErrPrivateAddress, host)
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateAddress, host, addr)
+		}
+	}
+	return nil
+}
+
+// dialPublic is the net.Dialer Control hook of the webhook requests. It checks the
+// address actually dialed, once resolved, so a host resolving to a private address
+// after its subscription was checked is refused as well.
+func dialPublic(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, address)
+	}
+	if !publicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// newClient returns the HTTP client of the webhook requests. It dials through
+// control, when not nil, never goes through a proxy, whose address would be the
+// one checked, and does not follow redirects, answered as failed deliveries.
+func newClient(timeout time.Duration, control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
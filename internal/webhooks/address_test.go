@@ -0,0 +1,73 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testResolver resolves the hosts of its map, failing for the others.
+type testResolver map[string][]netip.Addr
+
+func (res testResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, ok := res[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
+
+func Test_CheckURL(t *testing.T) {
+	resolver := testResolver{
+		"chat.example.com":     {netip.MustParseAddr("93.184.215.14")},
+		"internal.example.com": {netip.MustParseAddr("93.184.215.14"), netip.MustParseAddr("10.0.0.8")},
+		"empty.example.com":    {},
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want error
+	}{
+		{"public host", "https://chat.example.com/hooks/bamos", nil},
+		{"public address", "http://93.184.215.14:8080/hooks", nil},
+		{"public IPv6 address", "https://[2606:4700::1111]/hooks", nil},
+		{"ftp", "ftp://chat.example.com", ErrInvalidURL},
+		{"no host", "https:///hooks", ErrInvalidURL},
+		{"not a URL", "chat.example.com", ErrInvalidURL},
+		{"loopback", "http://127.0.0.1:8080/hooks", ErrPrivateAddress},
+		{"IPv6 loopback", "http://[::1]/hooks", ErrPrivateAddress},
+		{"private", "http://192.168.1.10/hooks", ErrPrivateAddress},
+		{"metadata service", "http://169.254.169.254/latest/meta-data", ErrPrivateAddress},
+		{"shared address", "http://100.64.0.1/hooks", ErrPrivateAddress},
+		{"unspecified", "http://0.0.0.0/hooks", ErrPrivateAddress},
+		{"IPv4 mapped", "http://[::ffff:10.0.0.1]/hooks", ErrPrivateAddress},
+		{"resolves to a private address", "https://internal.example.com/hooks", ErrPrivateAddress},
+		{"resolves to nothing", "https://empty.example.com/hooks", ErrPrivateAddress},
+		{"does not resolve", "https://unknown.example.com/hooks", ErrPrivateAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckURL(context.Background(), resolver, tt.url)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func Test_dialPublic(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(dialPublic("tcp4", "93.184.215.14:443", nil))
+	assert.NoError(dialPublic("tcp6", "[2606:4700::1111]:443", nil))
+	assert.ErrorIs(dialPublic("tcp4", "127.0.0.1:443", nil), ErrPrivateAddress)
+	assert.ErrorIs(dialPublic("tcp4", "169.254.169.254:80", nil), ErrPrivateAddress)
+	assert.ErrorIs(dialPublic("tcp6", "[fd00::1]:443", nil), ErrPrivateAddress)
+	assert.ErrorIs(dialPublic("tcp4", "localhost:443", nil), ErrPrivateAddress)
+}
@@ -0,0 +1,180 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+// Dispatcher detects the new service alerts and large delays of every realtime
+// snapshot and sends them to the matching subscriptions.
+type Dispatcher struct {
+	Store          Store
+	Sender         *Sender
+	Routes         func(line string) []string // IDs of the routes of a line
+	DelayThreshold time.Duration              // Smallest delay sent as an event
+	Logger         *slog.Logger
+
+	// IDs of the alerts and delayed trips of the previous snapshot, nil until the
+	// feed was seen once, so the ones active on startup are not sent again.
+	alerts map[string]bool
+	delays map[string]bool
+	now    func() time.Time
+}
+
+// DefaultDelayThreshold is the default smallest delay sent as an event.
+const DefaultDelayThreshold = 10 * time.Minute
+
+// NewDispatcher returns a Dispatcher sending the events of the subscriptions of
+// store with sender, resolving the lines of the subscriptions with routes.
+func NewDispatcher(store Store, sender *Sender, routes func(line string) []string, logger *slog.Logger) *Dispatcher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Dispatcher{
+		Store:          store,
+		Sender:         sender,
+		Routes:         routes,
+		DelayThreshold: DefaultDelayThreshold,
+		Logger:         logger,
+		now:            time.Now,
+	}
+}
+
+// Run dispatches the events of every new snapshot of the source until ctx is done
+// or the source stops.
+func (d *Dispatcher) Run(ctx context.Context, source realtime.Source) {
+	updates, cancel := source.Subscribe()
+	defer cancel()
+
+	for {
+		var snapshot *realtime.Snapshot
+		select {
+		case <-ctx.Done():
+			return
+		case s, ok := <-updates:
+			if !ok {
+				return
+			}
+			snapshot = s
+		}
+
+		events := d.Detect(snapshot)
+		if len(events) == 0 {
+			continue
+		}
+		sent, err := d.Dispatch(ctx, events)
+		if err != nil && ctx.Err() == nil {
+			d.Logger.ErrorContext(ctx, "error sending webhooks", "error", err)
+		}
+		if sent > 0 {
+			d.Logger.InfoContext(ctx, "webhooks sent", "events", len(events), "deliveries", sent)
+		}
+	}
+}
+
+// Detect returns the alerts and delays of the snapshot that were not in the
+// previous one. Feeds missing from the snapshot keep their previous state.
+func (d *Dispatcher) Detect(snapshot *realtime.Snapshot) []Event {
+	now := d.now()
+	var events []Event
+
+	if feed := snapshot.Feed(services.FeedServiceAlerts); feed != nil && feed.Message != nil {
+		current := map[string]bool{}
+		for _, alert := range snapshot.Alerts() {
+			current[alert.ID] = true
+			if d.alerts == nil || d.alerts[alert.ID] {
+				continue
+			}
+			events = append(events, Event{
+				ID:     "alert:" + alert.ID,
+				Type:   EventServiceAlert,
+				Time:   now,
+				Routes: alert.Routes,
+				Stops:  alert.Stops,
+				Alert:  &alert,
+			})
+		}
+		d.alerts = current
+	}
+
+	if feed := snapshot.Feed(services.FeedTripUpdates); feed != nil && feed.Message != nil {
+		current := map[string]bool{}
+		trips := map[string]*Event{}
+		var order []string
+		for _, arrival := range snapshot.Arrivals() {
+			if time.Duration(arrival.Delay)*time.Second < d.DelayThreshold {
+				continue
+			}
+			event, ok := trips[arrival.TripID]
+			if !ok {
+				event = &Event{
+					ID:      "delay:" + arrival.TripID,
+					Type:    EventDelay,
+					Time:    now,
+					Routes:  []string{arrival.RouteID},
+					Arrival: &arrival,
+				}
+				trips[arrival.TripID] = event
+				order = append(order, arrival.TripID)
+			}
+			if !slices.Contains(event.Stops, arrival.StopID) {
+				event.Stops = append(event.Stops, arrival.StopID)
+			}
+		}
+		for _, trip := range order {
+			current[trip] = true
+			if d.delays != nil && !d.delays[trip] {
+				events = append(events, *trips[trip])
+			}
+		}
+		d.delays = current
+	}
+
+	return events
+}
+
+// Dispatch sends the events to the matching subscriptions concurrently, returning
+// how many deliveries succeeded.
+func (d *Dispatcher) Dispatch(ctx context.Context, events []Event) (int, error) {
+	subs, err := d.Store.AllSubscriptions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		sent int
+		errs []error
+	)
+	for _, event := range events {
+		for _, sub := range subs {
+			if !sub.Matches(event, d.Routes) {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := d.Sender.Send(ctx, sub, event)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					errs = append(errs, err)
+					return
+				}
+				sent++
+			}()
+		}
+	}
+	wg.Wait()
+
+	return sent, errors.Join(errs...)
+}
@@ -0,0 +1,140 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+	"github.com/mayloo89/bamos/internal/services"
+)
+
+// testSource is a realtime.Source sending the snapshots written to updates.
+type testSource struct {
+	updates chan *realtime.Snapshot
+}
+
+func (s *testSource) Snapshot() *realtime.Snapshot {
+	return nil
+}
+
+func (s *testSource) Subscribe() (<-chan *realtime.Snapshot, func()) {
+	return s.updates, func() {}
+}
+
+// testSnapshot returns a snapshot with the given alerts and trip updates.
+func testSnapshot(alerts []*gtfs.FeedEntity, trips ...*gtfs.FeedEntity) *realtime.Snapshot {
+	return &realtime.Snapshot{Feeds: map[services.Feed]*realtime.FeedSnapshot{
+		services.FeedServiceAlerts: {Message: &gtfs.FeedMessage{Entity: alerts}},
+		services.FeedTripUpdates:   {Message: &gtfs.FeedMessage{Entity: trips}},
+	}}
+}
+
+// alert returns a feed entity for an alert of the given route.
+func alert(id, routeID string) *gtfs.FeedEntity {
+	return &gtfs.FeedEntity{
+		Id: proto.String(id),
+		Alert: &gtfs.Alert{
+			HeaderText:     &gtfs.TranslatedString{Translation: []*gtfs.TranslatedString_Translation{{Text: proto.String("Desvío")}}},
+			InformedEntity: []*gtfs.EntitySelector{{RouteId: proto.String(routeID)}},
+		},
+	}
+}
+
+// trip returns a feed entity for a trip of the given route delayed at two stops.
+func trip(id, routeID string, delay int32) *gtfs.FeedEntity {
+	return &gtfs.FeedEntity{
+		Id: proto.String(id),
+		TripUpdate: &gtfs.TripUpdate{
+			Trip: &gtfs.TripDescriptor{TripId: proto.String(id), RouteId: proto.String(routeID)},
+			StopTimeUpdate: []*gtfs.TripUpdate_StopTimeUpdate{
+				{StopId: proto.String("stop-1"), Arrival: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(1700000000), Delay: proto.Int32(delay)}},
+				{StopId: proto.String("stop-2"), Arrival: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(1700000300), Delay: proto.Int32(delay)}},
+			},
+		},
+	}
+}
+
+func Test_Dispatcher_Detect(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	d := NewDispatcher(NewMemoryStore(), nil, nil, nil)
+
+	// the alerts and delays active on startup are not sent
+	assert.Empty(d.Detect(testSnapshot([]*gtfs.FeedEntity{alert("a1", "1426")}, trip("t1", "1426", 900))))
+
+	events := d.Detect(testSnapshot(
+		[]*gtfs.FeedEntity{alert("a1", "1426"), alert("a2", "1427")},
+		trip("t1", "1426", 900), trip("t2", "1427", 660), trip("t3", "1427", 120),
+	))
+	required.Len(events, 2)
+	assert.Equal("alert:a2", events[0].ID)
+	assert.Equal(EventServiceAlert, events[0].Type)
+	assert.Equal([]string{"1427"}, events[0].Routes)
+	assert.Equal("Desvío", events[0].Alert.Header)
+	assert.Equal("delay:t2", events[1].ID)
+	assert.Equal(EventDelay, events[1].Type)
+	assert.Equal([]string{"stop-1", "stop-2"}, events[1].Stops)
+	assert.EqualValues(660, events[1].Arrival.Delay)
+
+	// missing feeds keep their state
+	assert.Empty(d.Detect(&realtime.Snapshot{}))
+
+	// delays that went away and came back are sent again
+	assert.Empty(d.Detect(testSnapshot(nil, trip("t1", "1426", 60))))
+	events = d.Detect(testSnapshot(nil, trip("t1", "1426", 700)))
+	required.Len(events, 1)
+	assert.Equal("delay:t1", events[0].ID)
+}
+
+func Test_Dispatcher_Run(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	receiver := &testReceiver{secret: "whsec_60"}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	store := NewMemoryStore()
+	required.NoError(store.CreateSubscription(ctx, &Subscription{UserID: 1, URL: server.URL, Secret: "whsec_60", Lines: []string{"60"}}))
+	required.NoError(store.CreateSubscription(ctx, &Subscription{UserID: 2, URL: server.URL, Secret: "other", Stops: []string{"stop-9"}}))
+
+	routes := func(line string) []string {
+		if line == "60" {
+			return []string{"1426"}
+		}
+		return nil
+	}
+	sender := NewSender(store, time.Second)
+	sender.Client = newClient(time.Second, nil)
+	d := NewDispatcher(store, sender, routes, nil)
+	source := &testSource{updates: make(chan *realtime.Snapshot)}
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx, source)
+		close(done)
+	}()
+
+	source.updates <- testSnapshot(nil)
+	source.updates <- testSnapshot([]*gtfs.FeedEntity{alert("a1", "1426"), alert("a2", "1427")})
+	close(source.updates)
+	<-done
+
+	required.Len(receiver.bodies, 1, "only the alert of line 60 is sent")
+	assert.True(receiver.verified[0])
+	var body Event
+	required.NoError(json.Unmarshal(receiver.bodies[0], &body))
+	assert.Equal("alert:a1", body.ID)
+
+	deliveries, err := store.Deliveries(ctx, 1, 0)
+	required.NoError(err)
+	assert.Len(deliveries, 1)
+}
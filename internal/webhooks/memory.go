@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the subscriptions and deliveries in memory, they are lost on
+// restart. The delivery log keeps the last MaxDeliveries attempts of each subscription.
+type MemoryStore struct {
+	mu            sync.Mutex
+	nextID        int64
+	subscriptions []Subscription
+	deliveries    map[int64][]Delivery
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{deliveries: map[int64][]Delivery{}}
+}
+
+// CreateSubscription saves a new subscription.
+func (s *MemoryStore) CreateSubscription(ctx context.Context, sub *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	sub.ID = s.nextID
+	sub.CreatedAt = time.Now()
+	s.subscriptions = append(s.subscriptions, *sub)
+	return nil
+}
+
+// Subscription returns a subscription of the user.
+func (s *MemoryStore) Subscription(ctx context.Context, userID, id int64) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sub := range s.subscriptions {
+		if sub.ID == id && sub.UserID == userID {
+			return &sub, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Subscriptions returns the subscriptions of the user.
+func (s *MemoryStore) Subscriptions(ctx context.Context, userID int64) ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subs []Subscription
+	for _, sub := range s.subscriptions {
+		if sub.UserID == userID {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+// AllSubscriptions returns the subscriptions of every user.
+func (s *MemoryStore) AllSubscriptions(ctx context.Context) ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.subscriptions), nil
+}
+
+// DeleteSubscription deletes a subscription of the user and its deliveries.
+func (s *MemoryStore) DeleteSubscription(ctx context.Context, userID, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.subscriptions, func(sub Subscription) bool { return sub.ID == id && sub.UserID == userID })
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.subscriptions = slices.Delete(s.subscriptions, i, i+1)
+	delete(s.deliveries, id)
+	return nil
+}
+
+// RecordDelivery adds an attempt to the delivery log.
+func (s *MemoryStore) RecordDelivery(ctx context.Context, delivery *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	delivery.ID = s.nextID
+	log := append(s.deliveries[delivery.SubscriptionID], *delivery)
+	if len(log) > MaxDeliveries {
+		log = log[len(log)-MaxDeliveries:]
+	}
+	s.deliveries[delivery.SubscriptionID] = log
+	return nil
+}
+
+// Deliveries returns the last attempts of the subscription.
+func (s *MemoryStore) Deliveries(ctx context.Context, subscriptionID int64, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log := slices.Clone(s.deliveries[subscriptionID])
+	slices.Reverse(log)
+	if limit > 0 && len(log) > limit {
+		log = log[:limit]
+	}
+	return log, nil
+}
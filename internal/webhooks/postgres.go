@@ -0,0 +1,166 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// listSeparator joins the lines and stops of a subscription in their columns.
+const listSeparator = ","
+
+// PostgresStore stores the subscriptions in the webhook_subscriptions table and
+// the delivery log in the webhook_deliveries table, which keeps the last
+// MaxDeliveries attempts of each subscription.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a PostgresStore using the given connection pool.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Ping checks the database is reachable.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// CreateSubscription saves a new subscription.
+func (s *PostgresStore) CreateSubscription(ctx context.Context, sub *Subscription) error {
+	return s.db.QueryRowContext(ctx, `insert into webhook_subscriptions
+		(user_id, url, secret, lines, stops, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, current_timestamp, current_timestamp) returning id, created_at`,
+		sub.UserID, sub.URL, sub.Secret, strings.Join(sub.Lines, listSeparator), strings.Join(sub.Stops, listSeparator),
+	).Scan(&sub.ID, &sub.CreatedAt)
+}
+
+// Subscription returns a subscription of the user.
+func (s *PostgresStore) Subscription(ctx context.Context, userID, id int64) (*Subscription, error) {
+	subs, err := s.subscriptions(ctx, `where id = $1 and user_id = $2`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(subs) == 0 {
+		return nil, ErrNotFound
+	}
+	return &subs[0], nil
+}
+
+// Subscriptions returns the subscriptions of the user.
+func (s *PostgresStore) Subscriptions(ctx context.Context, userID int64) ([]Subscription, error) {
+	return s.subscriptions(ctx, `where user_id = $1 order by id`, userID)
+}
+
+// AllSubscriptions returns the subscriptions of every user.
+func (s *PostgresStore) AllSubscriptions(ctx context.Context) ([]Subscription, error) {
+	return s.subscriptions(ctx, `order by id`)
+}
+
+// DeleteSubscription deletes a subscription of the user, its deliveries are
+// deleted in cascade.
+func (s *PostgresStore) DeleteSubscription(ctx context.Context, userID, id int64) error {
+	result, err := s.db.ExecContext(ctx, `delete from webhook_subscriptions where id = $1 and user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordDelivery adds an attempt to the delivery log, deleting the attempts of
+// the subscription older than the last MaxDeliveries.
+func (s *PostgresStore) RecordDelivery(ctx context.Context, d *Delivery) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx, `insert into webhook_deliveries
+		(subscription_id, delivery_id, event_id, event_type, attempt, status_code, error, duration_ms, delivered_at, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, current_timestamp, current_timestamp) returning id`,
+		d.SubscriptionID, d.DeliveryID, d.EventID, d.EventType, d.Attempt, d.StatusCode, d.Error,
+		d.Duration.Milliseconds(), d.DeliveredAt.UTC()).Scan(&d.ID)
+	if err != nil {
+		return err
+	}
+
+	// nothing is deleted while the subscription has fewer attempts, the subquery is null
+	_, err = tx.ExecContext(ctx, `delete from webhook_deliveries where subscription_id = $1 and id <
+		(select id from webhook_deliveries where subscription_id = $1 order by id desc offset $2 limit 1)`,
+		d.SubscriptionID, MaxDeliveries-1)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Deliveries returns the last attempts of the subscription.
+func (s *PostgresStore) Deliveries(ctx context.Context, subscriptionID int64, limit int) ([]Delivery, error) {
+	if limit <= 0 {
+		limit = MaxDeliveries
+	}
+	rows, err := s.db.QueryContext(ctx, `select id, subscription_id, delivery_id, event_id, event_type, attempt,
+		status_code, error, duration_ms, delivered_at
+		from webhook_deliveries where subscription_id = $1 order by id desc limit $2`, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var d Delivery
+		var ms int64
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.DeliveryID, &d.EventID, &d.EventType, &d.Attempt,
+			&d.StatusCode, &d.Error, &ms, &d.DeliveredAt); err != nil {
+			return nil, err
+		}
+		d.Duration = time.Duration(ms) * time.Millisecond
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (s *PostgresStore) subscriptions(ctx context.Context, where string, args ...any) ([]Subscription, error) {
+	rows, err := s.db.QueryContext(ctx, `select id, user_id, url, secret, lines, stops, created_at
+		from webhook_subscriptions `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var subs []Subscription
+	for rows.Next() {
+		var sub Subscription
+		var lines, stops string
+		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.URL, &sub.Secret, &lines, &stops, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		sub.Lines = split(lines)
+		sub.Stops = split(stops)
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+func split(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, listSeparator)
+}
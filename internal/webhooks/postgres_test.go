@@ -0,0 +1,82 @@
+package webhooks
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/accounts"
+	"github.com/mayloo89/bamos/internal/driver"
+)
+
+// Test_PostgresStore needs a database migrated with the migrations directory.
+func Test_PostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	db, err := driver.ConnectSQL(dsn)
+	required.NoError(err)
+	t.Cleanup(func() { _ = db.SQL.Close() })
+
+	user := &accounts.User{Email: "test-" + time.Now().Format("20060102150405.000000000") + "@example.com", PasswordHash: []byte("-")}
+	required.NoError(accounts.NewPostgresStore(db.SQL).CreateUser(ctx, user))
+	t.Cleanup(func() {
+		_, _ = db.SQL.Exec(`delete from users where id = $1`, user.ID)
+	})
+
+	store := NewPostgresStore(db.SQL)
+	required.NoError(store.Ping(ctx))
+	sub := &Subscription{UserID: user.ID, URL: "https://example.com/hook", Secret: NewSecret(), Lines: []string{"60", "152"}}
+	required.NoError(store.CreateSubscription(ctx, sub))
+
+	found, err := store.Subscription(ctx, user.ID, sub.ID)
+	required.NoError(err)
+	assert.Equal(sub.Secret, found.Secret)
+	assert.Equal([]string{"60", "152"}, found.Lines)
+	assert.Empty(found.Stops)
+	_, err = store.Subscription(ctx, user.ID+1, sub.ID)
+	assert.ErrorIs(err, ErrNotFound)
+
+	subs, err := store.Subscriptions(ctx, user.ID)
+	required.NoError(err)
+	assert.Len(subs, 1)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		required.NoError(store.RecordDelivery(ctx, &Delivery{
+			SubscriptionID: sub.ID, DeliveryID: "d1", EventID: "alert:1", EventType: EventServiceAlert,
+			Attempt: attempt, StatusCode: 500, Error: "webhook answered with status 500",
+			Duration: 150 * time.Millisecond, DeliveredAt: time.Now(),
+		}))
+	}
+	deliveries, err := store.Deliveries(ctx, sub.ID, 10)
+	required.NoError(err)
+	required.Len(deliveries, 2)
+	assert.Equal(2, deliveries[0].Attempt)
+	assert.Equal(150*time.Millisecond, deliveries[0].Duration)
+
+	// the log keeps the last attempts of the subscription
+	for attempt := 3; attempt <= MaxDeliveries+2; attempt++ {
+		required.NoError(store.RecordDelivery(ctx, &Delivery{
+			SubscriptionID: sub.ID, DeliveryID: "d1", EventID: "alert:1", EventType: EventServiceAlert,
+			Attempt: attempt, StatusCode: 500, DeliveredAt: time.Now(),
+		}))
+	}
+	deliveries, err = store.Deliveries(ctx, sub.ID, MaxDeliveries+10)
+	required.NoError(err)
+	required.Len(deliveries, MaxDeliveries)
+	assert.Equal(MaxDeliveries+2, deliveries[0].Attempt)
+	assert.Equal(3, deliveries[MaxDeliveries-1].Attempt)
+
+	required.NoError(store.DeleteSubscription(ctx, user.ID, sub.ID))
+	assert.ErrorIs(store.DeleteSubscription(ctx, user.ID, sub.ID), ErrNotFound)
+}
@@ -0,0 +1,157 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type (
+	// Sender posts signed events to the subscriptions, retrying failed attempts with
+	// an exponential backoff and recording every attempt in the delivery log.
+	Sender struct {
+		Client   *http.Client
+		Store    Store         // Delivery log
+		Attempts int           // Attempts of each delivery, at least one
+		Backoff  time.Duration // Wait before the first retry, doubled at every retry
+
+		now   func() time.Time
+		sleep func(ctx context.Context, d time.Duration) error
+	}
+
+	// payload is the JSON body of the webhook requests.
+	payload struct {
+		Event
+		SubscriptionID int64     `json:"subscription_id"`
+		SentAt         time.Time `json:"sent_at"`
+	}
+)
+
+const (
+	// DefaultAttempts is the default number of attempts of a delivery.
+	DefaultAttempts = 4
+	// DefaultBackoff is the default wait before the first retry.
+	DefaultBackoff = time.Second
+	// DefaultTimeout is the default timeout of the webhook requests.
+	DefaultTimeout = 10 * time.Second
+)
+
+// userAgent identifies bamos to the webhook receivers.
+const userAgent = "bamos-webhooks/1"
+
+// NewSender returns a Sender recording the deliveries in store, with the default
+// attempts and backoff and the given request timeout, DefaultTimeout when zero.
+// Its client only connects to public addresses and does not follow redirects.
+func NewSender(store Store, timeout time.Duration) *Sender {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Sender{
+		Client:   newClient(timeout, dialPublic),
+		Store:    store,
+		Attempts: DefaultAttempts,
+		Backoff:  DefaultBackoff,
+		now:      time.Now,
+		sleep:    sleep,
+	}
+}
+
+// Send posts the event to the subscription until it answers with a 2xx status or
+// the attempts run out. Client errors other than 408 and 429 are not retried.
+func (s *Sender) Send(ctx context.Context, sub Subscription, event Event) error {
+	body, err := json.Marshal(payload{Event: event, SubscriptionID: sub.ID, SentAt: s.now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	deliveryID := randomHex(16)
+	backoff := s.Backoff
+	var lastErr error
+	for attempt := 1; attempt <= max(s.Attempts, 1); attempt++ {
+		if attempt > 1 {
+			if err := s.sleep(ctx, backoff); err != nil {
+				return err
+			}
+			backoff *= 2
+		}
+
+		status, duration, err := s.post(ctx, sub, event.Type, deliveryID, body)
+		d := &Delivery{
+			SubscriptionID: sub.ID,
+			DeliveryID:     deliveryID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Attempt:        attempt,
+			StatusCode:     status,
+			Duration:       duration,
+			DeliveredAt:    s.now(),
+		}
+		if err != nil {
+			d.Error = err.Error()
+		}
+		if recordErr := s.Store.RecordDelivery(ctx, d); recordErr != nil {
+			return fmt.Errorf("can not record the delivery of %s to subscription %d: %w", event.ID, sub.ID, recordErr)
+		}
+
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retryable(status) {
+			break
+		}
+	}
+	return fmt.Errorf("can not deliver %s to subscription %d: %w", event.ID, sub.ID, lastErr)
+}
+
+// post makes a single attempt, returning the response status, zero when there is none.
+func (s *Sender) post(ctx context.Context, sub Subscription, eventType, deliveryID string, body []byte) (int, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, time.Since(start), err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	duration := time.Since(start)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, duration, fmt.Errorf("webhook answered with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, duration, nil
+}
+
+// retryable reports whether an attempt answered with status may succeed later.
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,161 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReceiver is a webhook receiver answering with the given statuses in order,
+// then 204, and verifying the signatures.
+type testReceiver struct {
+	mu       sync.Mutex
+	secret   string
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	verified []bool
+}
+
+func (rec *testReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.requests = append(rec.requests, r)
+	rec.bodies = append(rec.bodies, body)
+	rec.verified = append(rec.verified, Verify(rec.secret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)))
+
+	status := http.StatusNoContent
+	if len(rec.statuses) > 0 {
+		status, rec.statuses = rec.statuses[0], rec.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+// testSender returns a Sender that records its waits instead of sleeping. Its
+// client dials any address, the test receivers listen on the loopback one.
+func testSender(store Store, waits *[]time.Duration) *Sender {
+	sender := NewSender(store, time.Second)
+	sender.Client = newClient(time.Second, nil)
+	sender.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return nil
+	}
+	return sender
+}
+
+func Test_Sender_Send(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	receiver := &testReceiver{secret: "whsec_test", statuses: []int{http.StatusInternalServerError, http.StatusTooManyRequests}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	store := NewMemoryStore()
+	var waits []time.Duration
+	sender := testSender(store, &waits)
+	sub := Subscription{ID: 7, URL: server.URL, Secret: "whsec_test"}
+	event := Event{ID: "alert:1", Type: EventServiceAlert, Routes: []string{"1426"}}
+
+	required.NoError(sender.Send(ctx, sub, event))
+
+	required.Len(receiver.requests, 3)
+	assert.Equal([]bool{true, true, true}, receiver.verified)
+	assert.Equal([]time.Duration{time.Second, 2 * time.Second}, waits)
+	first := receiver.requests[0].Header
+	assert.Equal(EventServiceAlert, first.Get(HeaderEvent))
+	assert.Equal("application/json", first.Get("Content-Type"))
+	assert.NotEmpty(first.Get(HeaderDelivery))
+	assert.Equal(first.Get(HeaderDelivery), receiver.requests[2].Header.Get(HeaderDelivery), "retries keep the delivery ID")
+
+	var body map[string]any
+	required.NoError(json.Unmarshal(receiver.bodies[0], &body))
+	assert.Equal("alert:1", body["id"])
+	assert.Equal("service_alert", body["type"])
+	assert.EqualValues(7, body["subscription_id"])
+
+	deliveries, err := store.Deliveries(ctx, sub.ID, 0)
+	required.NoError(err)
+	required.Len(deliveries, 3)
+	assert.Equal(3, deliveries[0].Attempt)
+	assert.Equal(http.StatusNoContent, deliveries[0].StatusCode)
+	assert.Empty(deliveries[0].Error)
+	assert.Equal(http.StatusInternalServerError, deliveries[2].StatusCode)
+	assert.Contains(deliveries[2].Error, "status 500")
+}
+
+func Test_Sender_Send_Fails(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	receiver := &testReceiver{secret: "s", statuses: []int{http.StatusGone}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	store := NewMemoryStore()
+	var waits []time.Duration
+	sender := testSender(store, &waits)
+
+	err := sender.Send(ctx, Subscription{ID: 1, URL: server.URL, Secret: "s"}, Event{ID: "delay:trip-1", Type: EventDelay})
+	assert.ErrorContains(err, "status 410")
+	assert.Len(receiver.requests, 1, "client errors are not retried")
+
+	// unreachable receivers are retried until the attempts run out
+	server.Close()
+	err = sender.Send(ctx, Subscription{ID: 2, URL: server.URL, Secret: "s"}, Event{ID: "delay:trip-1", Type: EventDelay})
+	assert.Error(err)
+	deliveries, err := store.Deliveries(ctx, 2, 0)
+	required.NoError(err)
+	assert.Len(deliveries, DefaultAttempts)
+	assert.Zero(deliveries[0].StatusCode)
+	assert.NotEmpty(deliveries[0].Error)
+}
+
+func Test_Sender_Send_PrivateAddress(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+
+	receiver := &testReceiver{secret: "s"}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	store := NewMemoryStore()
+	sender := NewSender(store, time.Second)
+	sender.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	err := sender.Send(ctx, Subscription{ID: 1, URL: server.URL, Secret: "s"}, Event{ID: "alert:1", Type: EventServiceAlert})
+	required.Error(err)
+	assert.ErrorIs(err, ErrPrivateAddress)
+	assert.Empty(receiver.requests, "loopback receivers are never dialed")
+}
+
+func Test_Sender_Send_Redirect(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	receiver := &testReceiver{secret: "s"}
+	target := httptest.NewServer(receiver)
+	defer target.Close()
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer server.Close()
+
+	var waits []time.Duration
+	sender := testSender(NewMemoryStore(), &waits)
+
+	err := sender.Send(ctx, Subscription{ID: 1, URL: server.URL, Secret: "s"}, Event{ID: "alert:1", Type: EventServiceAlert})
+	assert.ErrorContains(err, "status 302")
+	assert.Empty(receiver.requests, "redirects are not followed")
+}
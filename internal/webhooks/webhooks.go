@@ -0,0 +1,164 @@
+// Package webhooks posts the service alerts and large delays of the realtime feeds
+// to the URLs users subscribed for their lines and stops. Payloads are JSON, signed
+// with HMAC-SHA256, retried on failure and every attempt is kept in a delivery log.
+// The payloads and the signature are documented in docs/webhooks.md.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mayloo89/bamos/internal/realtime"
+)
+
+type (
+	// Subscription is a URL receiving the events of some lines and stops.
+	Subscription struct {
+		ID        int64
+		UserID    int64
+		URL       string
+		Secret    string // Key of the payload signatures
+		Lines     []string
+		Stops     []string
+		CreatedAt time.Time
+	}
+
+	// Event is a service alert or large delay detected in the realtime feeds.
+	Event struct {
+		ID      string            `json:"id"`
+		Type    string            `json:"type"`
+		Time    time.Time         `json:"time"`
+		Routes  []string          `json:"routes,omitempty"`
+		Stops   []string          `json:"stops,omitempty"`
+		Alert   *realtime.Alert   `json:"alert,omitempty"`
+		Arrival *realtime.Arrival `json:"arrival,omitempty"` // First delayed arrival of the trip
+	}
+
+	// Delivery is an attempt to post an event to a subscription.
+	Delivery struct {
+		ID             int64
+		SubscriptionID int64
+		DeliveryID     string // Same for every attempt of an event, sent in the X-Bamos-Delivery header
+		EventID        string
+		EventType      string
+		Attempt        int
+		StatusCode     int    // Zero when no response was received
+		Error          string // Empty when the delivery succeeded
+		Duration       time.Duration
+		DeliveredAt    time.Time
+	}
+
+	// Store keeps the subscriptions and their delivery log.
+	Store interface {
+		// CreateSubscription saves a new subscription, setting its ID and creation time.
+		CreateSubscription(ctx context.Context, sub *Subscription) error
+		// Subscription returns a subscription of the user, or ErrNotFound.
+		Subscription(ctx context.Context, userID, id int64) (*Subscription, error)
+		// Subscriptions returns the subscriptions of the user, oldest first.
+		Subscriptions(ctx context.Context, userID int64) ([]Subscription, error)
+		// AllSubscriptions returns the subscriptions of every user.
+		AllSubscriptions(ctx context.Context) ([]Subscription, error)
+		// DeleteSubscription deletes a subscription of the user, or returns ErrNotFound.
+		DeleteSubscription(ctx context.Context, userID, id int64) error
+		// RecordDelivery adds an attempt to the delivery log, setting its ID, and
+		// keeps the last MaxDeliveries attempts of the subscription.
+		RecordDelivery(ctx context.Context, delivery *Delivery) error
+		// Deliveries returns the last attempts of the subscription, newest first.
+		Deliveries(ctx context.Context, subscriptionID int64, limit int) ([]Delivery, error)
+	}
+)
+
+// Event types.
+const (
+	EventServiceAlert = "service_alert"
+	EventDelay        = "delay"
+)
+
+// Headers of the webhook requests.
+const (
+	HeaderEvent     = "X-Bamos-Event"
+	HeaderDelivery  = "X-Bamos-Delivery"
+	HeaderTimestamp = "X-Bamos-Timestamp"
+	HeaderSignature = "X-Bamos-Signature"
+)
+
+// MaxDeliveries is the number of attempts kept by subscription in the delivery log
+// of the stores.
+const MaxDeliveries = 100
+
+// ErrNotFound is returned when a subscription does not exist.
+var ErrNotFound = errors.New("subscription not found")
+
+// Sign returns the signature of a payload sent at the given Unix timestamp, the
+// hex encoded HMAC-SHA256 of "<timestamp>.<body>" prefixed with "sha256=".
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of the payload, in constant time.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// NewSecret returns a random signing key.
+func NewSecret() string {
+	return "whsec_" + randomHex(24)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Matches reports whether the event concerns a line or stop of the subscription,
+// routeIDs returning the IDs of the routes of a line.
+func (s Subscription) Matches(event Event, routeIDs func(line string) []string) bool {
+	for _, stop := range s.Stops {
+		if slices.Contains(event.Stops, stop) {
+			return true
+		}
+	}
+	for _, line := range s.Lines {
+		for _, id := range routeIDs(line) {
+			if slices.Contains(event.Routes, id) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseList splits a comma or space separated list of lines or stops.
+func ParseList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t' })
+}
+
+// Open opens the store of the given kind, "memory" or "postgres" on db, the
+// accounts database whose pool the caller releases.
+func Open(kind string, db *sql.DB) (Store, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "postgres":
+		if db == nil {
+			return nil, errors.New("the postgres webhooks store requires a database")
+		}
+		return NewPostgresStore(db), nil
+	default:
+		return nil, fmt.Errorf("invalid webhooks store %q, must be memory or postgres", kind)
+	}
+}
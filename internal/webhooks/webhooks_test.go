@@ -0,0 +1,106 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SignVerify(t *testing.T) {
+	assert := assert.New(t)
+	body := []byte(`{"id":"alert:1"}`)
+
+	// echo -n '1700000000.{"id":"alert:1"}' | openssl dgst -sha256 -hmac secret
+	signature := Sign("secret", "1700000000", body)
+	assert.Equal("sha256=a78bc91b5bf6d8eccdbaf01a9a19f3eec1df9dfdcb3318be23818848973720f3", signature)
+	assert.True(Verify("secret", "1700000000", body, signature))
+	assert.False(Verify("other", "1700000000", body, signature))
+	assert.False(Verify("secret", "1700000001", body, signature))
+	assert.False(Verify("secret", "1700000000", []byte(`{"id":"alert:2"}`), signature))
+	assert.NotEqual(NewSecret(), NewSecret())
+}
+
+func Test_Subscription_Matches(t *testing.T) {
+	assert := assert.New(t)
+	routes := func(line string) []string {
+		if line == "60" {
+			return []string{"1426", "1427"}
+		}
+		return nil
+	}
+
+	byLine := Subscription{Lines: []string{"152", "60"}}
+	byStop := Subscription{Stops: []string{"stop-1"}}
+	event := Event{Routes: []string{"1427"}, Stops: []string{"stop-2"}}
+
+	assert.True(byLine.Matches(event, routes))
+	assert.False(byStop.Matches(event, routes))
+	assert.True(byStop.Matches(Event{Stops: []string{"stop-1"}}, routes))
+	assert.False(byLine.Matches(Event{Routes: []string{"999"}}, routes))
+}
+
+func Test_ParseList(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"60", "152", "29"}, ParseList(" 60, 152\n29,"))
+	assert.Empty(ParseList(" , "))
+}
+
+func Test_MemoryStore(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	sub := &Subscription{UserID: 1, URL: "https://example.com/hook", Secret: "s", Lines: []string{"60"}}
+	required.NoError(store.CreateSubscription(ctx, sub))
+	required.NoError(store.CreateSubscription(ctx, &Subscription{UserID: 2, URL: "https://example.com/other"}))
+	assert.NotZero(sub.ID)
+
+	got, err := store.Subscription(ctx, 1, sub.ID)
+	required.NoError(err)
+	assert.Equal([]string{"60"}, got.Lines)
+	_, err = store.Subscription(ctx, 2, sub.ID)
+	assert.ErrorIs(err, ErrNotFound)
+
+	subs, err := store.Subscriptions(ctx, 1)
+	required.NoError(err)
+	assert.Len(subs, 1)
+	all, err := store.AllSubscriptions(ctx)
+	required.NoError(err)
+	assert.Len(all, 2)
+
+	for attempt := 1; attempt <= MaxDeliveries+5; attempt++ {
+		required.NoError(store.RecordDelivery(ctx, &Delivery{SubscriptionID: sub.ID, Attempt: attempt}))
+	}
+	deliveries, err := store.Deliveries(ctx, sub.ID, 2)
+	required.NoError(err)
+	required.Len(deliveries, 2)
+	assert.Equal(MaxDeliveries+5, deliveries[0].Attempt, "newest first")
+	deliveries, err = store.Deliveries(ctx, sub.ID, 0)
+	required.NoError(err)
+	assert.Len(deliveries, MaxDeliveries)
+
+	assert.ErrorIs(store.DeleteSubscription(ctx, 2, sub.ID), ErrNotFound)
+	required.NoError(store.DeleteSubscription(ctx, 1, sub.ID))
+	deliveries, err = store.Deliveries(ctx, sub.ID, 0)
+	required.NoError(err)
+	assert.Empty(deliveries)
+}
+
+func Test_Open(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	store, err := Open("memory", nil)
+	required.NoError(err)
+	assert.IsType(&MemoryStore{}, store)
+
+	_, err = Open("postgres", nil)
+	assert.ErrorContains(err, "the postgres webhooks store requires a database")
+
+	_, err = Open("redis", nil)
+	assert.ErrorContains(err, `invalid webhooks store "redis"`)
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mayloo89/bamos/internal/geo"
+)
+
+// Stop represents a single stop entry from the stops GTFS file.
+type Stop struct {
+	ID   string  `csv:"stop_id"`   // Unique stop identifier
+	Code string  `csv:"stop_code"` // Code shown to the riders, optional
+	Name string  `csv:"stop_name"` // Name of the stop
+	Lat  float64 `csv:"stop_lat"`  // Latitude of the stop
+	Lon  float64 `csv:"stop_lon"`  // Longitude of the stop
+}
+
+// Feed is the static GTFS data drawn on the maps: the stops and the paths the
+// trips of every route follow.
+type Feed struct {
+	Stops       []Stop
+	Shapes      map[string][]geo.Point // Paths by shape ID
+	RouteShapes map[string][]string    // Shape IDs of the trips of each route ID
+}
+
+// LoadFeed loads the stops.txt, shapes.txt and trips.txt files of the GTFS
+// directory at dir, leaving the data of the missing ones empty.
+// Returns an error if a file is malformed.
+func LoadFeed(dir string) (*Feed, error) {
+	feed := &Feed{Shapes: map[string][]geo.Point{}, RouteShapes: map[string][]string{}}
+
+	err := readGTFSFile(filepath.Join(dir, "stops.txt"), []string{"stop_id", "stop_name", "stop_lat", "stop_lon"}, func(record map[string]string) error {
+		lat, latErr := strconv.ParseFloat(record["stop_lat"], 64)
+		lon, lonErr := strconv.ParseFloat(record["stop_lon"], 64)
+		if latErr != nil || lonErr != nil {
+			return fmt.Errorf("invalid location %q, %q of stop %s", record["stop_lat"], record["stop_lon"], record["stop_id"])
+		}
+		feed.Stops = append(feed.Stops, Stop{
+			ID:   record["stop_id"],
+			Code: record["stop_code"],
+			Name: record["stop_name"],
+			Lat:  lat,
+			Lon:  lon,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type shapePoint struct {
+		geo.Point
+		sequence int
+	}
+	points := map[string][]shapePoint{}
+	err = readGTFSFile(filepath.Join(dir, "shapes.txt"), []string{"shape_id", "shape_pt_lat", "shape_pt_lon", "shape_pt_sequence"}, func(record map[string]string) error {
+		lat, latErr := strconv.ParseFloat(record["shape_pt_lat"], 64)
+		lon, lonErr := strconv.ParseFloat(record["shape_pt_lon"], 64)
+		sequence, seqErr := strconv.Atoi(record["shape_pt_sequence"])
+		if latErr != nil || lonErr != nil || seqErr != nil {
+			return fmt.Errorf("invalid point %q of shape %s", record["shape_pt_sequence"], record["shape_id"])
+		}
+		id := record["shape_id"]
+		points[id] = append(points[id], shapePoint{geo.Point{Lat: lat, Lon: lon}, sequence})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for id, shape := range points {
+		slices.SortStableFunc(shape, func(a, b shapePoint) int { return cmp.Compare(a.sequence, b.sequence) })
+		path := make([]geo.Point, len(shape))
+		for i, p := range shape {
+			path[i] = p.Point
+		}
+		feed.Shapes[id] = path
+	}
+
+	// many trips of a route follow the same shapes
+	err = readGTFSFile(filepath.Join(dir, "trips.txt"), []string{"route_id"}, func(record map[string]string) error {
+		route, shape := record["route_id"], record["shape_id"]
+		if shape != "" && !slices.Contains(feed.RouteShapes[route], shape) {
+			feed.RouteShapes[route] = append(feed.RouteShapes[route], shape)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// readGTFSFile calls fn with every record of the GTFS file at path by column
+// name, nothing when the file does not exist.
+// Returns an error if a required column is missing or fn fails.
+func readGTFSFile(path string, required []string, fn func(record map[string]string) error) error {
+	csvFile, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not open GTFS file at %s: %w", path, err)
+	}
+	defer func() {
+		if cerr := csvFile.Close(); cerr != nil {
+			slog.Warn("error closing csv file", "path", path, "error", cerr)
+		}
+	}()
+
+	reader := csv.NewReader(csvFile)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading GTFS file at %s: %w", path, err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+	for _, column := range required {
+		if !slices.Contains(header, column) {
+			return fmt.Errorf("malformed GTFS file at %s: missing the %s column", path, column)
+		}
+	}
+
+	record := make(map[string]string, len(header))
+	for line := 2; ; line++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading GTFS file at %s: %w", path, err)
+		}
+		clear(record)
+		for i, column := range header {
+			if i < len(fields) {
+				record[column] = strings.TrimSpace(fields[i])
+			}
+		}
+		if err := fn(record); err != nil {
+			return fmt.Errorf("malformed GTFS file at %s: line %d: %w", path, line, err)
+		}
+	}
+}
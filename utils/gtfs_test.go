@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mayloo89/bamos/internal/geo"
+)
+
+func writeGTFSFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	return dir
+}
+
+func Test_LoadFeed(t *testing.T) {
+	assert := assert.New(t)
+	required := require.New(t)
+
+	dir := writeGTFSFiles(t, map[string]string{
+		"stops.txt": "\ufeffstop_id,stop_code,stop_name,stop_lat,stop_lon\n" +
+			"201,,AV. CORRIENTES 1000,-34.6035,-58.3830\n" +
+			"202,1234,AV. CORRIENTES 1100,-34.6036,-58.3850\n",
+		"shapes.txt": "shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\n" +
+			"s1,-34.6036,-58.3850,2\n" +
+			"s1,-34.6035,-58.3830,1\n" +
+			"s2,-34.6020,-58.3840,1\n",
+		"trips.txt": "route_id,service_id,trip_id,shape_id\n" +
+			"1426,weekday,t1,s1\n" +
+			"1426,weekday,t2,s1\n" +
+			"1427,weekday,t3,s2\n" +
+			"1428,weekday,t4,\n",
+	})
+
+	feed, err := LoadFeed(dir)
+	required.NoError(err)
+	assert.Equal([]Stop{
+		{ID: "201", Name: "AV. CORRIENTES 1000", Lat: -34.6035, Lon: -58.3830},
+		{ID: "202", Code: "1234", Name: "AV. CORRIENTES 1100", Lat: -34.6036, Lon: -58.3850},
+	}, feed.Stops)
+	// the points are in sequence order
+	assert.Equal([]geo.Point{{Lat: -34.6035, Lon: -58.3830}, {Lat: -34.6036, Lon: -58.3850}}, feed.Shapes["s1"])
+	assert.Equal(map[string][]string{"1426": {"s1"}, "1427": {"s2"}}, feed.RouteShapes)
+
+	// the missing files leave their data empty
+	feed, err = LoadFeed(t.TempDir())
+	required.NoError(err)
+	assert.Empty(feed.Stops)
+	assert.Empty(feed.Shapes)
+
+	dir = writeGTFSFiles(t, map[string]string{"stops.txt": "stop_id,stop_name\n201,A\n"})
+	_, err = LoadFeed(dir)
+	assert.ErrorContains(err, "missing the stop_lat column")
+
+	dir = writeGTFSFiles(t, map[string]string{"shapes.txt": "shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\ns1,-34.6,-58.3,first\n"})
+	_, err = LoadFeed(dir)
+	assert.ErrorContains(err, `line 2: invalid point "first" of shape s1`)
+}
@@ -4,10 +4,11 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -19,29 +20,38 @@ type Route struct {
 	LongName  string `csv:"route_long_name"`  // Long name of the route
 	Desc      string `csv:"route_desc"`       // Description of the route
 	Type      string `csv:"route_type"`       // Type of the route
+	Color     string `csv:"route_color"`      // Background color as hex RGB without "#", optional
+	TextColor string `csv:"route_text_color"` // Text color over Color as hex RGB without "#", optional
 }
 
 // GetRoutes loads routes from the CSV file specified by the ROUTES_FILE environment variable.
 // If the variable is not set, it defaults to static/routesinfo/routes.txt.
 // Returns a slice of Route and an error if the file is missing or malformed.
 func GetRoutes() ([]Route, error) {
-	var routes []Route
 	path := os.Getenv("ROUTES_FILE")
 	if path == "" {
 		path = filepath.Join("static", "routesinfo", "routes.txt")
 	}
+	return LoadRoutes(path)
+}
+
+// LoadRoutes loads routes from the CSV file at path.
+// Returns a slice of Route and an error if the file is missing or malformed.
+func LoadRoutes(path string) ([]Route, error) {
+	var routes []Route
 	csvFile, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("could not open routes file at %s: %w", path, err)
 	}
 	defer func() {
 		if cerr := csvFile.Close(); cerr != nil {
-			log.Println("error closing csv file:", cerr)
+			slog.Warn("error closing csv file", "path", path, "error", cerr)
 		}
 	}()
 
 	reaader := csv.NewReader(csvFile)
-	for {
+	color, textColor := -1, -1
+	for first := true; ; first = false {
 		line, err := reaader.Read()
 		if err != nil {
 			if err == io.EOF {
@@ -49,17 +59,29 @@ func GetRoutes() ([]Route, error) {
 			}
 			return nil, fmt.Errorf("error reading routes file at %s: %w", path, err)
 		}
+		// a GTFS header gives the columns of the optional colors
+		if first && strings.TrimPrefix(line[0], "\ufeff") == "route_id" {
+			color, textColor = slices.Index(line, "route_color"), slices.Index(line, "route_text_color")
+			continue
+		}
 		if len(line) < 6 {
 			return nil, fmt.Errorf("malformed routes file at %s: expected at least 6 columns, got %d", path, len(line))
 		}
-		routes = append(routes, Route{
+		route := Route{
 			ID:        line[0],
 			AgencyID:  line[1],
 			ShortName: line[2],
 			LongName:  line[3],
 			Desc:      line[4],
 			Type:      line[5],
-		})
+		}
+		if color >= 0 && color < len(line) {
+			route.Color = line[color]
+		}
+		if textColor >= 0 && textColor < len(line) {
+			route.TextColor = line[textColor]
+		}
+		routes = append(routes, route)
 	}
 
 	return routes, nil
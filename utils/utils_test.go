@@ -78,6 +78,20 @@ func TestGetRoutes(t *testing.T) {
 		}
 	})
 
+	t.Run("gtfs header with colors", func(t *testing.T) {
+		file := createTempFileWithContent(t, "route_id,agency_id,route_short_name,route_long_name,route_desc,route_type,route_color,route_text_color\n"+
+			"1426,110,60A,Linea 60,Ramal A,3,FFCC00,000000\n")
+		err := os.Setenv("ROUTES_FILE", file)
+		require.Nil(t, err)
+
+		routes, err := GetRoutes()
+		require.NoError(t, err)
+		require.Len(t, routes, 1, "the header is not a route")
+		if routes[0].Color != "FFCC00" || routes[0].TextColor != "000000" {
+			t.Errorf("expected colors FFCC00 and 000000, got %q and %q", routes[0].Color, routes[0].TextColor)
+		}
+	})
+
 	t.Run("empty file", func(t *testing.T) {
 		file := createTempFileWithContent(t, "")
 		err := os.Setenv("ROUTES_FILE", file)